@@ -2,15 +2,29 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/eso"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/logging"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/notify"
 	"github.com/jackweinbender/k8s-secret-sync/pkg/sync"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -22,6 +36,66 @@ func main() {
 	klog.InitFlags(nil)
 	defer klog.Flush()
 
+	// Run a subcommand instead of the operator if one is given, e.g. "report" or
+	// "history <namespace>/<name>"
+	var command string
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+	reportFormat := flag.String("format", "json", "output format of the report command (json, csv or backstage)")
+	componentList := flag.String("components", "controller,metrics", "comma-separated components to run: controller, metrics")
+	observeOnly := flag.Bool("observe-only", false, "report the secrets that would be managed without resolving or writing anything")
+	configMapName := flag.String("configmap", "", "name of a ConfigMap the configgen command emits instead of env lines")
+	importProvider := flag.String("provider", "op", "provider name set on secrets by the import and scaffold commands, pushed to by migrate-sealed, and backing the -secret-store of export")
+	refPrefix := flag.String("ref-prefix", "", "prefix added to remote keys by the import, scaffold, and migrate-sealed commands and removed by export, e.g. op://vault/")
+	dryRun := flag.Bool("dry-run", false, "report what the migrate-sealed command would do without pushing or writing anything")
+	secretStore := flag.String("secret-store", "", "SecretStore the ExternalSecrets from the export command fetch from")
+	secretStoreKind := flag.String("secret-store-kind", "SecretStore", "kind of the -secret-store: SecretStore or ClusterSecretStore")
+	auditPrev := flag.String("audit-prev", notify.AuditGenesis, "signature the first entry checked by the audit-verify command follows: the last of the log it was rotated from, or genesis for a new log")
+	auditHead := flag.String("audit-head", "", "file recording the latest audit entry (KSS_AUDIT_HEAD_PATH) the log checked by the audit-verify command must end at")
+	if command != "" && !slices.Contains([]string{"report", "history", "configgen", "import", "export", "audit-verify", "migrate-sealed", "scaffold"}, command) {
+		klog.ErrorS(nil, "Unknown command", "command", command)
+		os.Exit(2)
+	}
+
+	// Converting values and manifests needs no cluster
+	if command == "configgen" {
+		flag.Parse()
+		if err := configgen(flag.Arg(0), *configMapName); err != nil {
+			klog.ErrorS(err, "Failed to generate configuration")
+			os.Exit(1)
+		}
+		return
+	}
+	if command == "import" {
+		flag.Parse()
+		opts := eso.ImportOptions{Provider: *importProvider, RefPrefix: *refPrefix}
+		if err := importExternalSecrets(flag.Arg(0), opts); err != nil {
+			klog.ErrorS(err, "Failed to import ExternalSecrets")
+			os.Exit(1)
+		}
+		return
+	}
+	if command == "scaffold" {
+		flag.Parse()
+		defaults := sync.ScaffoldSpec{Provider: *importProvider, RefPrefix: *refPrefix}
+		if err := scaffold(flag.Arg(0), defaults); err != nil {
+			klog.ErrorS(err, "Failed to scaffold secrets")
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "audit-verify" {
+		flag.Parse()
+		if err := auditVerify(flag.Arg(0), *auditPrev, *auditHead); err != nil {
+			klog.ErrorS(err, "Audit log verification failed")
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Giddy up!
 	klog.InfoS("Starting k8s-secret-sync operator...")
 
@@ -40,11 +114,78 @@ func main() {
 	// Load configuration from environment variables and initialize Kubernetes client
 	klog.InfoS("Loading configuration...")
 	cfg := config.New(clientset)
+	if err := cfg.Err(); err != nil {
+		klog.ErrorS(err, "Invalid configuration")
+		os.Exit(2)
+	}
+	if *observeOnly {
+		cfg.ObserveOnly = true
+	}
+	if err := logging.SetLevels(cfg.LogLevels); err != nil {
+		klog.ErrorS(err, "Invalid log levels")
+		os.Exit(2)
+	}
+
+	if command == "report" {
+		if err := report(ctx, cfg, *reportFormat); err != nil {
+			klog.ErrorS(err, "Failed to produce report")
+			os.Exit(1)
+		}
+		return
+	}
+	if command == "history" {
+		if err := history(ctx, cfg, flag.Arg(0)); err != nil {
+			klog.ErrorS(err, "Failed to get sync history")
+			os.Exit(1)
+		}
+		return
+	}
+	if command == "export" {
+		opts := eso.ExportOptions{
+			Provider:        *importProvider,
+			SecretStore:     *secretStore,
+			SecretStoreKind: *secretStoreKind,
+			RefPrefix:       *refPrefix,
+			RefreshInterval: (time.Duration(cfg.PollInterval) * time.Second).String(),
+			DefaultKey:      cfg.DefaultSecretDataKey,
+		}
+		if err := exportExternalSecrets(ctx, cfg, opts); err != nil {
+			klog.ErrorS(err, "Failed to export ExternalSecrets")
+			os.Exit(1)
+		}
+		return
+	}
+	if command == "migrate-sealed" {
+		opts := sync.MigrateOptions{Namespaces: flag.Args(), Provider: *importProvider, RefPrefix: *refPrefix, DryRun: *dryRun}
+		if err := migrateSealedSecrets(ctx, cfg, opts); err != nil {
+			klog.ErrorS(err, "Failed to migrate sealed secrets")
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Run only the requested components, so each can be deployed and scaled separately
+	components, err := parseComponents(*componentList)
+	if err != nil {
+		klog.ErrorS(err, "Invalid components")
+		os.Exit(2)
+	}
+
+	// Serve Prometheus metrics
+	if components["metrics"] && cfg.MetricsAddr != "" {
+		go func() {
+			if err := metrics.Serve(ctx, cfg.MetricsAddr); err != nil {
+				klog.ErrorS(err, "Metrics server exited with error")
+			}
+		}()
+	}
 
 	// Start the sync process
-	klog.InfoS("Starting sync process...")
-	if err := sync.Run(ctx, cfg); err != nil {
-		klog.ErrorS(err, "Sync exited with error")
+	if components["controller"] {
+		klog.InfoS("Starting sync process...")
+		if err := sync.Run(ctx, cfg); err != nil {
+			klog.ErrorS(err, "Sync exited with error")
+		}
 	}
 
 	// Wait for shutdown signal
@@ -52,6 +193,194 @@ func main() {
 	klog.InfoS("Shutting down")
 }
 
+// report writes a summary of the annotated secrets in the cluster to stdout.
+func report(ctx context.Context, cfg *config.Sync, format string) error {
+	r, err := sync.BuildReport(ctx, cfg, time.Now())
+	if err != nil {
+		return err
+	}
+	return r.Write(os.Stdout, format)
+}
+
+// history writes the sync history of the secret named "<namespace>/<name>" to stdout as JSON.
+func history(ctx context.Context, cfg *config.Sync, secret string) error {
+	namespace, name, ok := strings.Cut(secret, "/")
+	if !ok || namespace == "" || name == "" {
+		return fmt.Errorf("expected a secret as <namespace>/<name>, got %q", secret)
+	}
+	entries, err := sync.SecretHistory(ctx, cfg, namespace, name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// importExternalSecrets converts the ExternalSecret manifests in the file at path
+// ("-" or empty for stdin) into annotated Secret manifests written to stdout.
+func importExternalSecrets(path string, opts eso.ImportOptions) error {
+	manifests, err := readInput(path)
+	if err != nil {
+		return err
+	}
+	out, warnings, err := eso.Import(manifests, config.New(nil).Annotations, opts)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		klog.InfoS("Import warning", "warning", warning)
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// scaffold converts the scaffold spec at path ("-" or empty for stdin) into
+// placeholder Secret manifests written to stdout.
+func scaffold(path string, defaults sync.ScaffoldSpec) error {
+	spec, err := readInput(path)
+	if err != nil {
+		return err
+	}
+	out, err := sync.Scaffold(spec, config.New(nil).Annotations, defaults)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// exportExternalSecrets writes ExternalSecret manifests equivalent to the annotated
+// secrets in the cluster to stdout.
+func exportExternalSecrets(ctx context.Context, cfg *config.Sync, opts eso.ExportOptions) error {
+	if opts.SecretStore == "" {
+		return errors.New("-secret-store is required")
+	}
+	secrets, err := cfg.Clientset.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing secrets: %w", err)
+	}
+	out, warnings, err := eso.Export(secrets.Items, cfg.Annotations, opts)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		klog.InfoS("Export warning", "warning", warning)
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// migrateSealedSecrets moves the values of Sealed Secrets in the given namespaces to
+// a provider and writes the outcome for each secret to stdout as JSON. Once migrated,
+// the SealedSecrets can be deleted.
+func migrateSealedSecrets(ctx context.Context, cfg *config.Sync, opts sync.MigrateOptions) error {
+	if len(opts.Namespaces) == 0 {
+		return errors.New("expected the namespaces to migrate as arguments")
+	}
+	migrations, err := sync.MigrateSealedSecrets(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(migrations); err != nil {
+		return err
+	}
+	failed := 0
+	for _, m := range migrations {
+		if m.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d secrets were not migrated", failed, len(migrations))
+	}
+	return nil
+}
+
+// readInput reads the file at path, or stdin if path is "-" or empty.
+func readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// auditVerify checks the signatures of the audit log at path ("-" or empty for
+// stdin) against KSS_AUDIT_KEY, starting from prev and, if headPath is set, ending at
+// the head recorded there.
+func auditVerify(path, prev, headPath string) error {
+	cfg := config.New(nil)
+	if cfg.AuditKey == "" {
+		return errors.New("KSS_AUDIT_KEY is not set")
+	}
+	key, err := base64.StdEncoding.DecodeString(cfg.AuditKey)
+	if err != nil {
+		return fmt.Errorf("decoding KSS_AUDIT_KEY: %w", err)
+	}
+	log, err := readInput(path)
+	if err != nil {
+		return err
+	}
+	n, head, err := notify.VerifyAudit(bytes.NewReader(log), key, prev)
+	if err != nil {
+		return err
+	}
+	if headPath != "" {
+		recorded, err := notify.ReadAuditHead(headPath)
+		if err != nil {
+			return err
+		}
+		if recorded != head {
+			return fmt.Errorf("audit log ends at %s, not the head recorded in %s: entries may have been removed", head, headPath)
+		}
+	}
+	fmt.Printf("%d audit entries verified, ending at %s\n", n, head)
+	return nil
+}
+
+// configgen converts the values-style YAML file at path ("-" or empty for stdin)
+// into the operator's environment variables, written to stdout as NAME=value lines
+// or as a ConfigMap named configMap.
+func configgen(path, configMap string) error {
+	values, err := readInput(path)
+	if err != nil {
+		return err
+	}
+	env, err := config.EnvFromValues(values)
+	if err != nil {
+		return err
+	}
+	out := config.RenderEnv(env)
+	if configMap != "" {
+		if out, err = config.RenderConfigMap(env, configMap); err != nil {
+			return err
+		}
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// parseComponents parses the -components flag into the set of components to run.
+func parseComponents(value string) (map[string]bool, error) {
+	components := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+		case "controller", "metrics":
+			components[name] = true
+		default:
+			return nil, fmt.Errorf("unknown component %q, expected controller or metrics", name)
+		}
+	}
+	if len(components) == 0 {
+		return nil, errors.New("no components to run")
+	}
+	return components, nil
+}
+
 // initClientSet initializes and returns a Kubernetes clientset for cluster interaction.
 // It attempts to create a connection using in-cluster configuration first. If that fails,
 // it falls back to using the local kubeconfig file, typically found in ~/.kube/config.
@@ -0,0 +1,128 @@
+// Package agevalue implements a secret provider whose refs are the values themselves,
+// encrypted with age, so small teams can keep encrypted values in their manifests
+// without running a secret store.
+package agevalue
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func init() {
+	provider.Register(provider.Info{
+		Name:            "age",
+		RequiredConfig:  []string{"KSS_AGE_IDENTITY_FILE"},
+		Capabilities:    provider.Capabilities{Binary: true},
+		NamespaceScoped: true,
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			return SecretProvider{IdentityFile: cfg.AgeIdentityFile}, nil
+		},
+	})
+}
+
+// ScopeHeader starts the first line of every plaintext, naming the namespace the
+// value may be synced into, or "*" for any, e.g.
+//
+//	k8s-secret-sync.weinbender.io/namespace: team-a
+//
+// The value follows the line. The header is encrypted with the value, so copying a
+// ciphertext into another namespace does not get it decrypted there.
+const ScopeHeader = "k8s-secret-sync.weinbender.io/namespace: "
+
+// SecretProvider resolves refs that are age-encrypted values, either ASCII armored
+// ("-----BEGIN AGE ENCRYPTED FILE-----") or base64 encoded, by decrypting them with
+// the identities in a mounted file. The file is read on each request, so a rotated
+// key takes effect without a restart. Plaintexts must start with a ScopeHeader line
+// naming the namespace of the secret they are for.
+type SecretProvider struct {
+	IdentityFile string // path of an age identity file, e.g. from age-keygen
+}
+
+// Resolve decrypts the ref. Values encrypted to other keys, or scoped to another
+// namespace, are reported as unauthorized.
+func (p SecretProvider) Resolve(_ context.Context, req provider.Request) (provider.Response, error) {
+	identities, err := p.identities()
+	if err != nil {
+		return provider.Response{}, err
+	}
+
+	ciphertext, err := decode(req.Ref)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	r, err := age.Decrypt(ciphertext, identities...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return provider.Response{}, fmt.Errorf("%w: value is not encrypted to the operator's age identity", provider.ErrUnauthorized)
+		}
+		return provider.Response{}, fmt.Errorf("decrypting age value: %w", err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return provider.Response{}, fmt.Errorf("decrypting age value: %w", err)
+	}
+	value, err := unscope(plaintext, req.Namespace)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	return provider.Response{Value: value}, nil
+}
+
+// unscope checks that a plaintext is scoped to namespace and returns the value
+// following its ScopeHeader line.
+func unscope(plaintext []byte, namespace string) ([]byte, error) {
+	header, value, ok := bytes.Cut(plaintext, []byte("\n"))
+	scope, scoped := strings.CutPrefix(string(header), ScopeHeader)
+	if !ok || !scoped {
+		return nil, fmt.Errorf("%w: age value is not scoped to a namespace; encrypt it with a %q line first", provider.ErrUnauthorized, ScopeHeader+namespace)
+	}
+	if scope = strings.TrimSpace(scope); scope != "*" && scope != namespace {
+		return nil, fmt.Errorf("%w: age value is scoped to namespace %q, not %s", provider.ErrUnauthorized, scope, namespace)
+	}
+	return value, nil
+}
+
+// HealthCheck checks that the identity file can be read and parsed.
+func (p SecretProvider) HealthCheck(context.Context) error {
+	_, err := p.identities()
+	return err
+}
+
+// identities reads the identity file.
+func (p SecretProvider) identities() ([]age.Identity, error) {
+	f, err := os.Open(p.IdentityFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading age identity file: %w", err)
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing age identity file %s: %w", p.IdentityFile, err)
+	}
+	return identities, nil
+}
+
+// decode returns a reader of the binary age file in ref.
+func decode(ref string) (io.Reader, error) {
+	ref = strings.TrimSpace(ref)
+	if strings.HasPrefix(ref, armor.Header) {
+		return armor.NewReader(strings.NewReader(ref + "\n")), nil
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(ref)
+	if err != nil {
+		return nil, fmt.Errorf("ref is neither an armored nor a base64 age value: %w", err)
+	}
+	return bytes.NewReader(ciphertext), nil
+}
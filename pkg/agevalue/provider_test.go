@@ -0,0 +1,91 @@
+package agevalue
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func encryptTo(t *testing.T, recipient age.Recipient, value string, armored bool) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var dst io.Writer = &buf
+	var a io.WriteCloser
+	if armored {
+		a = armor.NewWriter(&buf)
+		dst = a
+	}
+	w, err := age.Encrypt(dst, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, value)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if a != nil {
+		a.Close()
+		return buf.String()
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestResolve(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(path, []byte(identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := SecretProvider{IdentityFile: path}
+	if err := p.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("HealthCheck: %v", err)
+	}
+
+	for _, armored := range []bool{true, false} {
+		ref := encryptTo(t, identity.Recipient(), ScopeHeader+"team-a\ns3cr3t\n", armored)
+		resp, err := p.Resolve(context.Background(), provider.Request{Ref: ref, Namespace: "team-a"})
+		if err != nil || string(resp.Value) != "s3cr3t\n" {
+			t.Errorf("Resolve(armored=%v) = %q, %v; want s3cr3t", armored, resp.Value, err)
+		}
+	}
+
+	// Values are only decrypted for the namespace they are scoped to
+	for plaintext, namespace := range map[string]string{
+		ScopeHeader + "team-a\ns3cr3t": "team-b",
+		"s3cr3t":                       "team-a",
+		"team-a\ns3cr3t":               "team-a",
+	} {
+		ref := encryptTo(t, identity.Recipient(), plaintext, true)
+		if _, err := p.Resolve(context.Background(), provider.Request{Ref: ref, Namespace: namespace}); !errors.Is(err, provider.ErrUnauthorized) {
+			t.Errorf("Resolve(%q) in %s = %v, want ErrUnauthorized", plaintext, namespace, err)
+		}
+	}
+	ref := encryptTo(t, identity.Recipient(), ScopeHeader+"*\ns3cr3t", true)
+	if resp, err := p.Resolve(context.Background(), provider.Request{Ref: ref, Namespace: "team-b"}); err != nil || string(resp.Value) != "s3cr3t" {
+		t.Errorf("Resolve of a value for any namespace = %q, %v; want s3cr3t", resp.Value, err)
+	}
+
+	other, _ := age.GenerateX25519Identity()
+	ref = encryptTo(t, other.Recipient(), ScopeHeader+"*\ns3cr3t", true)
+	if _, err := p.Resolve(context.Background(), provider.Request{Ref: ref}); !errors.Is(err, provider.ErrUnauthorized) {
+		t.Errorf("Resolve of a value for another key = %v, want ErrUnauthorized", err)
+	}
+	if _, err := p.Resolve(context.Background(), provider.Request{Ref: "not age"}); err == nil {
+		t.Errorf("expected error for a ref that is not an age value")
+	}
+	if err := (SecretProvider{IdentityFile: filepath.Join(t.TempDir(), "missing")}).HealthCheck(context.Background()); err == nil {
+		t.Errorf("expected HealthCheck to fail without an identity file")
+	}
+}
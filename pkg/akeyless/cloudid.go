@@ -0,0 +1,100 @@
+package akeyless
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// cloudIDs returns the cloud ID proving the operator's identity for each cloud
+// identity access type.
+var cloudIDs = map[string]func(ctx context.Context) (string, error){
+	"aws_iam":  awsCloudID,
+	"gcp":      gcpCloudID,
+	"azure_ad": azureCloudID,
+}
+
+// stsBody is the signed STS request Akeyless replays to learn the operator's IAM
+// identity.
+const stsBody = "Action=GetCallerIdentity&Version=2011-06-15"
+
+// awsCloudID signs a GetCallerIdentity request with the standard AWS credential
+// chain, without sending it.
+func awsCloudID(ctx context.Context) (string, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("retrieving AWS credentials: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://sts.amazonaws.com/", strings.NewReader(stsBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	hash := sha256.Sum256([]byte(stsBody))
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "sts", "us-east-1", time.Now()); err != nil {
+		return "", fmt.Errorf("signing STS request: %w", err)
+	}
+	headers, err := json.Marshal(req.Header)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(map[string]string{
+		"sts_request_method":  req.Method,
+		"sts_request_url":     base64.StdEncoding.EncodeToString([]byte(req.URL.String())),
+		"sts_request_body":    base64.StdEncoding.EncodeToString([]byte(stsBody)),
+		"sts_request_headers": base64.StdEncoding.EncodeToString(headers),
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// gcpCloudID gets an identity token for Akeyless from the GCE metadata server, for
+// workloads running as a GCP service account.
+func gcpCloudID(ctx context.Context) (string, error) {
+	token, err := metadata.GetWithContext(ctx, "instance/service-accounts/default/identity?audience=akeyless.io&format=full")
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(token)), nil
+}
+
+// azureCloudID gets a managed identity access token from the Azure instance metadata
+// service.
+func azureCloudID(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01&resource=https%3A%2F%2Fmanagement.azure.com%2F", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata", "true")
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("azure instance metadata service returned %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(token.AccessToken)), nil
+}
@@ -0,0 +1,211 @@
+// Package akeyless implements a secret provider that reads static secrets from
+// Akeyless, authenticating with an access key or the operator's cloud identity.
+package akeyless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+// tokenLifetime is how long an access token is reused. Akeyless tokens last as long
+// as their auth method allows, an hour by default, so they are replaced well before.
+const tokenLifetime = 10 * time.Minute
+
+func init() {
+	provider.Register(provider.Info{
+		Name:           "akeyless",
+		RequiredConfig: []string{"KSS_AKEYLESS_ACCESS_ID"},
+		Capabilities:   provider.Capabilities{Versioning: true},
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			p := SecretProvider{
+				URL:        cfg.AkeylessURL,
+				AccessID:   cfg.AkeylessAccessID,
+				AccessType: cfg.AkeylessAccessType,
+				AccessKey:  cfg.AkeylessAccessKey,
+				HTTP:       &http.Client{Timeout: 30 * time.Second},
+				tokens:     shared,
+			}
+			switch p.AccessType {
+			case "access_key":
+				if p.AccessKey == "" {
+					return nil, errors.New("KSS_AKEYLESS_ACCESS_KEY must be set for access_key authentication")
+				}
+			case "aws_iam", "gcp", "azure_ad":
+				p.CloudID = cloudIDs[p.AccessType]
+			default:
+				return nil, fmt.Errorf("unknown KSS_AKEYLESS_ACCESS_TYPE %q, expected access_key, aws_iam, gcp, or azure_ad", p.AccessType)
+			}
+			return p, nil
+		},
+	})
+}
+
+// shared caches access tokens between the providers created for each request.
+var shared = &tokenCache{}
+
+// SecretProvider resolves refs that are paths of Akeyless static secrets, e.g.
+// "/prod/db/password", to the secret's value. A pinned version reads that version of
+// the secret.
+//
+// It authenticates as an access ID, either with its access key or, for the "aws_iam",
+// "gcp", and "azure_ad" access types, with a cloud ID proving the operator's own
+// cloud identity.
+type SecretProvider struct {
+	URL        string // URL of the Akeyless API, or the v2 API of an Akeyless gateway
+	AccessID   string // access ID of the auth method, e.g. "p-abc123"
+	AccessType string // "access_key", or a cloud identity type
+	AccessKey  string // access key, for the access_key type
+	HTTP       *http.Client
+
+	// CloudID returns the cloud ID sent for cloud identity types.
+	CloudID func(ctx context.Context) (string, error)
+
+	tokens *tokenCache
+}
+
+// tokenCache holds the last access token obtained, with the identity it is for.
+type tokenCache struct {
+	mu       gosync.Mutex
+	identity string
+	token    string
+	expires  time.Time
+}
+
+func (p SecretProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	name := "/" + strings.TrimPrefix(req.Ref, "/")
+	if name == "/" {
+		return provider.Response{}, errors.New("invalid akeyless ref, expected a secret path")
+	}
+	body := map[string]any{"names": []string{name}}
+	if req.Version != "" {
+		version, err := strconv.Atoi(req.Version)
+		if err != nil {
+			return provider.Response{}, fmt.Errorf("invalid akeyless version %q, expected a version number", req.Version)
+		}
+		body["version"] = version
+	}
+
+	var values map[string]string
+	err := p.call(ctx, "get-secret-value", body, &values)
+	if errors.Is(err, provider.ErrUnauthorized) {
+		// The token may have been revoked or expired early; retry once with a new one
+		p.tokens.clear()
+		err = p.call(ctx, "get-secret-value", body, &values)
+	}
+	if err != nil {
+		return provider.Response{}, err
+	}
+	value, ok := values[name]
+	if !ok {
+		return provider.Response{}, fmt.Errorf("%w: %s", provider.ErrNotFound, name)
+	}
+	return provider.Response{Value: []byte(value), Version: req.Version}, nil
+}
+
+// HealthCheck authenticates, which fails if Akeyless is unreachable or the
+// credentials are no longer valid.
+func (p SecretProvider) HealthCheck(ctx context.Context) error {
+	_, err := p.authenticate(ctx)
+	return err
+}
+
+// call posts body to an API command with an access token, decoding the response
+// into out.
+func (p SecretProvider) call(ctx context.Context, command string, body map[string]any, out any) error {
+	token, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+	body["token"] = token
+	return p.post(ctx, command, body, out)
+}
+
+// token returns a cached access token, authenticating for a new one if it is missing
+// or due for replacement.
+func (p SecretProvider) token(ctx context.Context) (string, error) {
+	identity := p.URL + "\x00" + p.AccessID + "\x00" + p.AccessType
+	p.tokens.mu.Lock()
+	defer p.tokens.mu.Unlock()
+	if p.tokens.identity == identity && time.Now().Before(p.tokens.expires) {
+		return p.tokens.token, nil
+	}
+	token, err := p.authenticate(ctx)
+	if err != nil {
+		return "", err
+	}
+	p.tokens.identity, p.tokens.token, p.tokens.expires = identity, token, time.Now().Add(tokenLifetime)
+	return token, nil
+}
+
+// authenticate exchanges the access key or cloud ID for an access token.
+func (p SecretProvider) authenticate(ctx context.Context) (string, error) {
+	body := map[string]any{"access-id": p.AccessID, "access-type": p.AccessType}
+	if p.AccessType == "access_key" {
+		body["access-key"] = p.AccessKey
+	} else {
+		cloudID, err := p.CloudID(ctx)
+		if err != nil {
+			return "", fmt.Errorf("getting %s cloud ID: %w", p.AccessType, err)
+		}
+		body["cloud-id"] = cloudID
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := p.post(ctx, "auth", body, &resp); err != nil {
+		return "", fmt.Errorf("authenticating to akeyless as %s: %w", p.AccessID, err)
+	}
+	if resp.Token == "" {
+		return "", fmt.Errorf("authenticating to akeyless as %s returned no token", p.AccessID)
+	}
+	return resp.Token, nil
+}
+
+// post sends body as JSON to an API command and decodes the response into out,
+// mapping failures to the shared provider errors.
+func (p SecretProvider) post(ctx context.Context, command string, body map[string]any, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.URL, "/")+"/"+command, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", provider.ErrTransient, err)
+	}
+	defer resp.Body.Close()
+
+	if err := provider.CheckResponse(resp); err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", provider.ErrNotFound, command)
+	case resp.StatusCode >= http.StatusBadRequest:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: unexpected status %s: %s", command, resp.Status, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *tokenCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.expires = "", time.Time{}
+}
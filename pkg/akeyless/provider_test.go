@@ -0,0 +1,109 @@
+package akeyless
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func TestResolve(t *testing.T) {
+	var authentications int
+	revoked := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/auth":
+			if body["access-id"] != "p-abc123" || body["access-type"] != "access_key" || body["access-key"] != "key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			authentications++
+			revoked = false
+			w.Write([]byte(`{"token":"t-1"}`))
+		case "/get-secret-value":
+			if body["token"] != "t-1" || revoked {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch names := body["names"].([]any); {
+			case names[0] != "/prod/db/password":
+				w.WriteHeader(http.StatusNotFound)
+			case body["version"] == 1.0:
+				w.Write([]byte(`{"/prod/db/password":"hunter1"}`))
+			default:
+				w.Write([]byte(`{"/prod/db/password":"hunter2"}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	p := SecretProvider{URL: server.URL + "/", AccessID: "p-abc123", AccessType: "access_key", AccessKey: "key", HTTP: server.Client(), tokens: &tokenCache{}}
+	ctx := context.Background()
+
+	resp, err := p.Resolve(ctx, provider.Request{Ref: "prod/db/password"})
+	if err != nil || string(resp.Value) != "hunter2" {
+		t.Fatalf("Resolve = %q, %v", resp.Value, err)
+	}
+	resp, err = p.Resolve(ctx, provider.Request{Ref: "/prod/db/password", Version: "1"})
+	if err != nil || string(resp.Value) != "hunter1" || resp.Version != "1" {
+		t.Errorf("Resolve(version 1) = %q at %q, %v", resp.Value, resp.Version, err)
+	}
+	if authentications != 1 {
+		t.Errorf("authenticated %d times, want the token reused", authentications)
+	}
+
+	// A revoked token is replaced
+	revoked = true
+	if _, err := p.Resolve(ctx, provider.Request{Ref: "/prod/db/password"}); err != nil || authentications != 2 {
+		t.Errorf("Resolve after revocation = %v with %d authentications", err, authentications)
+	}
+
+	if _, err := p.Resolve(ctx, provider.Request{Ref: "/prod/db/missing"}); !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("Resolve(missing) = %v, want ErrNotFound", err)
+	}
+	if _, err := p.Resolve(ctx, provider.Request{Ref: "/prod/db/password", Version: "latest"}); err == nil {
+		t.Errorf("Resolve with a non-numeric version succeeded, want an error")
+	}
+
+	if err := p.HealthCheck(ctx); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+	p.AccessKey = "wrong"
+	if err := p.HealthCheck(ctx); !errors.Is(err, provider.ErrUnauthorized) {
+		t.Errorf("HealthCheck with a wrong key = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCloudID(t *testing.T) {
+	var cloudID any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path == "/auth" {
+			cloudID = body["cloud-id"]
+			w.Write([]byte(`{"token":"t-1"}`))
+		}
+	}))
+	defer server.Close()
+	p := SecretProvider{
+		URL:        server.URL,
+		AccessID:   "p-abc123",
+		AccessType: "gcp",
+		CloudID:    func(context.Context) (string, error) { return "c2lnbmVk", nil },
+		HTTP:       server.Client(),
+		tokens:     &tokenCache{},
+	}
+	if err := p.HealthCheck(context.Background()); err != nil || cloudID != "c2lnbmVk" {
+		t.Errorf("HealthCheck sent cloud ID %v, %v", cloudID, err)
+	}
+}
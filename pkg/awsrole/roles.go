@@ -0,0 +1,151 @@
+// Package awsrole assumes the IAM roles the AWS providers read with on behalf of
+// secrets, so a single operator identity can reach several AWS accounts.
+package awsrole
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	gosync "sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+// MetadataKey is the provider metadata naming the role a secret is read with.
+const MetadataKey = "roleArn"
+
+// shared caches the loaded Roles and assumed role credentials between the providers
+// created for each request, so the AWS configuration is only loaded once and roles
+// are only assumed again as their credentials expire.
+var shared = &cache{}
+
+// cache holds the credentials of each role assumed, by role ARN, and the Roles
+// loaded for each configuration.
+type cache struct {
+	mu     gosync.Mutex
+	creds  map[string]*aws.CredentialsCache
+	loaded map[string]*Roles
+}
+
+// namespaceRole is a role secrets in namespaces matching a glob may be read with.
+type namespaceRole struct {
+	pattern string
+	roleARN string
+}
+
+// Roles selects the role a request is made with: the role given by the secret's
+// "roleArn" metadata, or else the first role mapped to its namespace. A secret may
+// only select a role mapped to its own namespace, so annotating a secret never
+// reaches an account that was not granted to the namespace, including roles mapped to
+// other namespaces.
+type Roles struct {
+	STS         stscreds.AssumeRoleAPIClient
+	SessionName string // role session name recorded in CloudTrail
+
+	namespaces []namespaceRole
+	cache      *cache
+}
+
+// New returns Roles assuming roles with client. namespaceRoles maps namespaces to
+// roles as "pattern=arn" pairs, comma separated, where patterns are globs such as
+// "team-*". A namespace may use every role mapped to it; the first is its default.
+func New(client stscreds.AssumeRoleAPIClient, namespaceRoles, sessionName string) (*Roles, error) {
+	r := &Roles{STS: client, SessionName: sessionName, cache: &cache{}}
+	for _, pair := range strings.Split(namespaceRoles, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		pattern, roleARN, ok := strings.Cut(pair, "=")
+		pattern, roleARN = strings.TrimSpace(pattern), strings.TrimSpace(roleARN)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid namespace role %q, expected namespace=arn", pair)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+		}
+		if !arn.IsARN(roleARN) {
+			return nil, fmt.Errorf("invalid role ARN %q for namespace %s", roleARN, pattern)
+		}
+		r.namespaces = append(r.namespaces, namespaceRole{pattern: pattern, roleARN: roleARN})
+	}
+	return r, nil
+}
+
+// Load returns Roles assuming roles with the standard AWS credential chain, as
+// configured by KSS_AWS_NAMESPACE_ROLES, sharing assumed credentials with the
+// providers created for other requests.
+func Load(ctx context.Context, cfg *config.Sync) (*Roles, error) {
+	key := strings.Join([]string{cfg.AWSRegion, cfg.AWSNamespaceRoles, cfg.AWSSTSSessionName}, "\x00")
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	if r, ok := shared.loaded[key]; ok {
+		return r, nil
+	}
+
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.AWSRegion != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.AWSRegion))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	r, err := New(sts.NewFromConfig(awsCfg), cfg.AWSNamespaceRoles, cfg.AWSSTSSessionName)
+	if err != nil {
+		return nil, fmt.Errorf("KSS_AWS_NAMESPACE_ROLES: %w", err)
+	}
+	r.cache = shared
+	if shared.loaded == nil {
+		shared.loaded = make(map[string]*Roles)
+	}
+	shared.loaded[key] = r
+	return r, nil
+}
+
+// Role returns the ARN of the role to make a request with, or "" to use the
+// operator's own identity.
+func (r *Roles) Role(req provider.Request) (string, error) {
+	var mapped []string
+	for _, ns := range r.namespaces {
+		if matched, _ := path.Match(ns.pattern, req.Namespace); matched {
+			mapped = append(mapped, ns.roleARN)
+		}
+	}
+	requested, ok := req.Metadata[MetadataKey]
+	switch {
+	case !ok && len(mapped) > 0:
+		return mapped[0], nil
+	case !ok:
+		return "", nil
+	case !slices.Contains(mapped, requested):
+		return "", fmt.Errorf("%w: role %q is not mapped to namespace %s in KSS_AWS_NAMESPACE_ROLES", provider.ErrUnauthorized, requested, req.Namespace)
+	}
+	return requested, nil
+}
+
+// Credentials returns the credentials of a role, assuming it when they are first
+// needed and again before they expire.
+func (r *Roles) Credentials(roleARN string) aws.CredentialsProvider {
+	r.cache.mu.Lock()
+	defer r.cache.mu.Unlock()
+	if creds, ok := r.cache.creds[roleARN]; ok {
+		return creds
+	}
+	if r.cache.creds == nil {
+		r.cache.creds = make(map[string]*aws.CredentialsCache)
+	}
+	creds := aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(r.STS, roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = r.SessionName
+	}))
+	r.cache.creds[roleARN] = creds
+	return creds
+}
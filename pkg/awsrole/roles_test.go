@@ -0,0 +1,107 @@
+package awsrole
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+type fakeSTS struct {
+	assumed []string
+}
+
+func (c *fakeSTS) AssumeRole(_ context.Context, params *sts.AssumeRoleInput, _ ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	c.assumed = append(c.assumed, aws.ToString(params.RoleArn)+" as "+aws.ToString(params.RoleSessionName))
+	return &sts.AssumeRoleOutput{Credentials: &types.Credentials{
+		AccessKeyId:     aws.String("ASIAEXAMPLE"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("token"),
+		Expiration:      aws.Time(time.Now().Add(time.Hour)),
+	}}, nil
+}
+
+const (
+	teamA = "arn:aws:iam::111111111111:role/team-a"
+	other = "arn:aws:iam::222222222222:role/reader"
+)
+
+func TestRole(t *testing.T) {
+	r, err := New(&fakeSTS{}, "team-a="+teamA+", team-a-*="+other, "kss")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, tt := range []struct {
+		namespace, requested string
+		want                 string
+		wantErr              bool
+	}{
+		{namespace: "default", want: ""},
+		{namespace: "default", requested: other, wantErr: true},
+		{namespace: "default", requested: teamA, wantErr: true},
+		{namespace: "team-a", want: teamA},
+		{namespace: "team-a", requested: teamA, want: teamA},
+		{namespace: "team-a", requested: other, wantErr: true},
+		{namespace: "team-a-dev", want: other},
+		{namespace: "team-a-dev", requested: teamA, wantErr: true},
+		{namespace: "default", requested: "reader", wantErr: true},
+	} {
+		req := provider.Request{Ref: "prod/db", Namespace: tt.namespace, Metadata: map[string]string{}}
+		if tt.requested != "" {
+			req.Metadata[MetadataKey] = tt.requested
+		}
+		got, err := r.Role(req)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Role(%s, %q) = %q, %v; want %q", tt.namespace, tt.requested, got, err, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"team-a", "=" + teamA, "team-a=reader", "[=" + teamA} {
+		if _, err := New(&fakeSTS{}, spec, "kss"); err == nil {
+			t.Errorf("New(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	client := &fakeSTS{}
+	r, err := New(client, "", "kss")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	for range 2 {
+		creds, err := r.Credentials(teamA).Retrieve(ctx)
+		if err != nil || creds.AccessKeyID != "ASIAEXAMPLE" {
+			t.Fatalf("Retrieve = %+v, %v", creds, err)
+		}
+	}
+	if _, err := r.Credentials(other).Retrieve(ctx); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	// Credentials are cached until they expire
+	want := []string{teamA + " as kss", other + " as kss"}
+	if len(client.assumed) != len(want) || client.assumed[0] != want[0] || client.assumed[1] != want[1] {
+		t.Errorf("assumed %v, want %v", client.assumed, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cfg := &config.Sync{AWSRegion: "us-east-1", AWSNamespaceRoles: "team-a=" + teamA, AWSSTSSessionName: "k8s-secret-sync"}
+	first, err := Load(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if second, _ := Load(context.Background(), cfg); second != first {
+		t.Errorf("expected Roles to be reused between requests")
+	}
+	if _, err := Load(context.Background(), &config.Sync{AWSNamespaceRoles: "team-a"}); err == nil {
+		t.Errorf("expected invalid namespace roles to fail")
+	}
+}
@@ -0,0 +1,255 @@
+// Package awssts implements a secret provider that assumes IAM roles and returns
+// temporary AWS credentials, for legacy workloads that cannot use IRSA directly.
+package awssts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/awsrole"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+// unauthorizedCodes are the STS error codes returned when the operator's identity
+// may not assume a role (including roles that do not exist) or its own credentials
+// are invalid.
+var unauthorizedCodes = map[string]bool{
+	"AccessDenied":                true,
+	"ExpiredToken":                true,
+	"InvalidClientTokenId":        true,
+	"SignatureDoesNotMatch":       true,
+	"RegionDisabledException":     true,
+	"UnrecognizedClientException": true,
+}
+
+func init() {
+	provider.Register(provider.Info{
+		Name:            "aws-sts",
+		Capabilities:    provider.Capabilities{Expiry: true},
+		NamespaceScoped: true,
+		New: func(ctx context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			client, err := shared.client(ctx)
+			if err != nil {
+				return nil, err
+			}
+			roles, err := awsrole.Load(ctx, cfg)
+			if err != nil {
+				return nil, err
+			}
+			targets, err := parseTargetRoles(cfg.AWSSTSRoles)
+			if err != nil {
+				return nil, fmt.Errorf("KSS_AWS_STS_ROLES: %w", err)
+			}
+			return SecretProvider{
+				Client:      client,
+				Roles:       roles,
+				Duration:    time.Duration(cfg.AWSSTSDuration) * time.Second,
+				SessionName: cfg.AWSSTSSessionName,
+				targets:     targets,
+			}, nil
+		},
+	})
+}
+
+// shared caches the STS client between the providers created for each request, so
+// the AWS configuration is only loaded once.
+var shared = &clientCache{}
+
+// clientCache holds the STS client once it has been created.
+type clientCache struct {
+	mu  gosync.Mutex
+	sts *sts.Client
+}
+
+// client returns the cached client, creating it on first use.
+func (c *clientCache) client(ctx context.Context) (*sts.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sts == nil {
+		client, err := NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.sts = client
+	}
+	return c.sts, nil
+}
+
+// Client is the part of the STS API used by the provider.
+type Client interface {
+	AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error)
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// Credentials is the JSON value written for a ref, which the key-mapping annotation
+// can split into e.g. AWS_ACCESS_KEY_ID=.AccessKeyId,AWS_SECRET_ACCESS_KEY=.SecretAccessKey.
+type Credentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// SecretProvider resolves refs that are IAM role ARNs to freshly minted temporary
+// credentials for the role. Requests may set "externalId" and "sessionName" metadata
+// for roles whose trust policy requires them. Credentials are only minted for roles
+// mapped to the secret's namespace, since any secret could otherwise name a role the
+// operator may assume.
+//
+// Roles are assumed from a role mapped to the secret's namespace, if any, chosen by
+// the "roleArn" metadata, chaining through it into its account. AWS limits
+// credentials from chained roles to one hour.
+type SecretProvider struct {
+	Client      Client
+	Roles       *awsrole.Roles // roles chained through for secrets or namespaces; nil assumes roles as the operator
+	Duration    time.Duration  // requested lifetime of the credentials
+	SessionName string         // role session name recorded in CloudTrail
+
+	targets []targetRole
+}
+
+// targetRole is a role credentials may be minted for on behalf of secrets in
+// namespaces matching a glob.
+type targetRole struct {
+	pattern string
+	roleARN string // glob matching role ARNs, such as "arn:aws:iam::123456789012:role/team-a-*"
+}
+
+// Resolve assumes the role and returns its credentials as JSON, along with when
+// they expire so the controller can renew them in time.
+func (p SecretProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	roleARN := req.Ref
+	if !p.allowed(roleARN, req.Namespace) {
+		return provider.Response{}, fmt.Errorf("%w: role %q is not mapped to namespace %s in KSS_AWS_STS_ROLES", provider.ErrUnauthorized, roleARN, req.Namespace)
+	}
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(roleARN),
+		RoleSessionName: aws.String(p.SessionName),
+		DurationSeconds: aws.Int32(int32(p.Duration.Seconds())),
+	}
+	if externalID := req.Metadata["externalId"]; externalID != "" {
+		input.ExternalId = aws.String(externalID)
+	}
+	if sessionName := req.Metadata["sessionName"]; sessionName != "" {
+		input.RoleSessionName = aws.String(sessionName)
+	}
+	var optFns []func(*sts.Options)
+	if p.Roles != nil {
+		sourceARN, err := p.Roles.Role(req)
+		if err != nil {
+			return provider.Response{}, err
+		}
+		if sourceARN != "" {
+			creds := p.Roles.Credentials(sourceARN)
+			optFns = append(optFns, func(o *sts.Options) { o.Credentials = creds })
+		}
+	}
+	out, err := p.Client.AssumeRole(ctx, input, optFns...)
+	if err != nil {
+		return provider.Response{}, mapError(err)
+	}
+	if out.Credentials == nil {
+		return provider.Response{}, fmt.Errorf("assuming role %q returned no credentials", roleARN)
+	}
+
+	creds := Credentials{
+		AccessKeyId:     aws.ToString(out.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(out.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(out.Credentials.SessionToken),
+		Expiration:      aws.ToTime(out.Credentials.Expiration).UTC(),
+	}
+	value, err := json.Marshal(creds)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	return provider.Response{Value: value, Expiry: creds.Expiration}, nil
+}
+
+// allowed reports whether credentials may be minted for the role on behalf of a secret
+// in namespace.
+func (p SecretProvider) allowed(roleARN, namespace string) bool {
+	for _, target := range p.targets {
+		nsMatched, _ := path.Match(target.pattern, namespace)
+		roleMatched, _ := path.Match(target.roleARN, roleARN)
+		if nsMatched && roleMatched {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTargetRoles parses the roles credentials may be minted for on behalf of
+// namespaces as "pattern=arn" pairs, comma separated, where both are globs such as
+// "team-*" and "arn:aws:iam::123456789012:role/team-*".
+func parseTargetRoles(spec string) ([]targetRole, error) {
+	var targets []targetRole
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		pattern, roleARN, ok := strings.Cut(pair, "=")
+		pattern, roleARN = strings.TrimSpace(pattern), strings.TrimSpace(roleARN)
+		if !ok || pattern == "" || !strings.HasPrefix(roleARN, "arn:") {
+			return nil, fmt.Errorf("invalid namespace role %q, expected namespace=arn", pair)
+		}
+		for _, glob := range []string{pattern, roleARN} {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", glob, err)
+			}
+		}
+		targets = append(targets, targetRole{pattern: pattern, roleARN: roleARN})
+	}
+	return targets, nil
+}
+
+// HealthCheck checks the operator's own AWS identity, which fails if STS is
+// unreachable or the operator's credentials are invalid.
+func (p SecretProvider) HealthCheck(ctx context.Context) error {
+	if _, err := p.Client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+// mapError wraps SDK errors in the shared provider errors where they can be identified.
+func mapError(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.ErrorCode() == "Throttling":
+			return &provider.RateLimitedError{Err: err}
+		case unauthorizedCodes[apiErr.ErrorCode()]:
+			return fmt.Errorf("%w: %v", provider.ErrUnauthorized, err)
+		case apiErr.ErrorFault() == smithy.FaultServer:
+			return fmt.Errorf("%w: %v", provider.ErrTransient, err)
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", provider.ErrTransient, err)
+	}
+	return err
+}
+
+// NewClient returns an STS client using the standard AWS credential chain
+// (environment, shared config, web identity, or instance metadata).
+func NewClient(ctx context.Context) (*sts.Client, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	return sts.NewFromConfig(cfg), nil
+}
@@ -0,0 +1,144 @@
+package awssts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+type fakeClient struct {
+	input *sts.AssumeRoleInput
+	err   error
+}
+
+func (c *fakeClient) AssumeRole(_ context.Context, params *sts.AssumeRoleInput, _ ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	c.input = params
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &sts.AssumeRoleOutput{Credentials: &types.Credentials{
+		AccessKeyId:     aws.String("ASIAEXAMPLE"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("token"),
+		Expiration:      aws.Time(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)),
+	}}, nil
+}
+
+func (c *fakeClient) GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{}, c.err
+}
+
+func TestResolve(t *testing.T) {
+	client := &fakeClient{}
+	targets, err := parseTargetRoles("team-a=arn:aws:iam::123456789012:role/app")
+	if err != nil {
+		t.Fatalf("parseTargetRoles: %v", err)
+	}
+	p := SecretProvider{Client: client, Duration: 15 * time.Minute, SessionName: "kss", targets: targets}
+
+	req := provider.Request{Ref: "arn:aws:iam::123456789012:role/app", Namespace: "team-a", Metadata: map[string]string{"externalId": "ext-1"}}
+	resp, err := p.Resolve(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got := aws.ToString(client.input.RoleArn); got != "arn:aws:iam::123456789012:role/app" {
+		t.Errorf("RoleArn = %q", got)
+	}
+	if got := aws.ToInt32(client.input.DurationSeconds); got != 900 {
+		t.Errorf("DurationSeconds = %d, want 900", got)
+	}
+	if got := aws.ToString(client.input.ExternalId); got != "ext-1" {
+		t.Errorf("ExternalId = %q, want ext-1", got)
+	}
+	if got := aws.ToString(client.input.RoleSessionName); got != "kss" {
+		t.Errorf("RoleSessionName = %q, want kss", got)
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(resp.Value, &creds); err != nil {
+		t.Fatalf("decoding credentials: %v", err)
+	}
+	if creds.AccessKeyId != "ASIAEXAMPLE" || creds.SecretAccessKey != "secret" || creds.SessionToken != "token" {
+		t.Errorf("credentials = %+v", creds)
+	}
+	if want := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC); !resp.Expiry.Equal(want) || !creds.Expiration.Equal(want) {
+		t.Errorf("expiry = %v, want %v", resp.Expiry, want)
+	}
+}
+
+func TestResolveUnmappedRole(t *testing.T) {
+	targets, err := parseTargetRoles("team-a=arn:aws:iam::123456789012:role/team-a-*,team-b=arn:aws:iam::123456789012:role/team-b")
+	if err != nil {
+		t.Fatalf("parseTargetRoles: %v", err)
+	}
+	client := &fakeClient{}
+	p := SecretProvider{Client: client, targets: targets}
+
+	tests := []struct {
+		namespace, roleARN string
+		allowed            bool
+	}{
+		{"team-a", "arn:aws:iam::123456789012:role/team-a-app", true},
+		{"team-a", "arn:aws:iam::123456789012:role/team-b", false},
+		{"team-b", "arn:aws:iam::123456789012:role/team-a-app", false},
+		{"default", "arn:aws:iam::123456789012:role/team-b", false},
+	}
+	for _, tt := range tests {
+		client.input = nil
+		_, err := p.Resolve(context.Background(), provider.Request{Ref: tt.roleARN, Namespace: tt.namespace})
+		if tt.allowed && err != nil {
+			t.Errorf("Resolve %s in %s = %v, want success", tt.roleARN, tt.namespace, err)
+		}
+		if !tt.allowed && (!errors.Is(err, provider.ErrUnauthorized) || client.input != nil) {
+			t.Errorf("Resolve %s in %s = %v, want ErrUnauthorized without assuming the role", tt.roleARN, tt.namespace, err)
+		}
+	}
+
+	for _, spec := range []string{"team-a", "team-a=app", "=arn:aws:iam::123456789012:role/app", "team-a=arn:[", "[=arn:aws:iam::123456789012:role/app"} {
+		if _, err := parseTargetRoles(spec); err == nil {
+			t.Errorf("parseTargetRoles(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{&smithy.GenericAPIError{Code: "AccessDenied"}, provider.ErrUnauthorized},
+		{&smithy.GenericAPIError{Code: "InternalFailure", Fault: smithy.FaultServer}, provider.ErrTransient},
+		{context.DeadlineExceeded, provider.ErrTransient},
+	}
+	for _, tt := range tests {
+		p := SecretProvider{Client: &fakeClient{err: tt.err}, targets: []targetRole{{pattern: "*", roleARN: "*"}}}
+		if _, err := p.Resolve(context.Background(), provider.Request{Ref: "arn"}); !errors.Is(err, tt.want) {
+			t.Errorf("Resolve with %v = %v, want %v", tt.err, err, tt.want)
+		}
+	}
+
+	p := SecretProvider{Client: &fakeClient{err: &smithy.GenericAPIError{Code: "Throttling"}}}
+	var rateLimited *provider.RateLimitedError
+	if err := p.HealthCheck(context.Background()); !errors.As(err, &rateLimited) {
+		t.Errorf("HealthCheck while throttled = %v, want RateLimitedError", err)
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	cache := &clientCache{}
+	first, err := cache.client(context.Background())
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	if second, _ := cache.client(context.Background()); second != first {
+		t.Errorf("expected the client to be reused between requests")
+	}
+}
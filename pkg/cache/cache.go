@@ -0,0 +1,162 @@
+// Package cache provides caches for values resolved from secret providers: an
+// in-memory Cache, and Redis for sharing values between replicas.
+//
+// Cached values are never held in plaintext. Each Cache encrypts entries with an
+// ephemeral AES-256-GCM key generated at construction time, which exists only in
+// process memory and is lost on restart. Sealed buffers are zeroed when entries are
+// evicted, limiting what can be recovered from core dumps or /proc memory reads.
+// Redis entries are encrypted with a key shared by the replicas, so values are never
+// readable from Redis itself.
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// Store is a TTL store of provider values. Failing backends behave as if empty, so
+// a cache outage costs provider requests rather than failing syncs.
+type Store interface {
+	Get(key string) (string, bool)
+	Set(key, value string)
+	Delete(key string)
+	// Prune evicts expired entries, for backends that do not expire them on their own.
+	Prune()
+}
+
+var (
+	_ Store = (*Cache)(nil)
+	_ Store = (*Redis)(nil)
+)
+
+// Cache is a TTL cache of provider values, encrypted at rest in memory.
+type Cache struct {
+	mu      sync.Mutex
+	aead    cipher.AEAD
+	ttl     time.Duration
+	entries map[string]*entry
+	now     func() time.Time
+}
+
+type entry struct {
+	nonce   []byte
+	sealed  []byte
+	expires time.Time
+}
+
+// New returns a Cache whose entries expire after ttl.
+func New(ttl time.Duration) (*Cache, error) {
+	key := make([]byte, 32)
+	defer clear(key)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		aead:    aead,
+		ttl:     ttl,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}, nil
+}
+
+// Get returns the cached value for key, if present and not expired.
+func (c *Cache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !c.now().Before(e.expires) {
+		c.evict(key, e)
+		return "", false
+	}
+
+	// The cache key is bound to the ciphertext as additional data, so a sealed
+	// value can never be returned for a different key.
+	plaintext, err := c.aead.Open(nil, e.nonce, e.sealed, []byte(key))
+	if err != nil {
+		c.evict(key, e)
+		return "", false
+	}
+	defer clear(plaintext)
+
+	return string(plaintext), true
+}
+
+// Set stores value under key, replacing (and zeroing) any existing entry.
+func (c *Cache) Set(key, value string) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// Without a fresh nonce the value cannot be sealed safely; skip caching it.
+		return
+	}
+
+	plaintext := []byte(value)
+	defer clear(plaintext)
+	sealed := c.aead.Seal(nil, nonce, plaintext, []byte(key))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.evict(key, e)
+	}
+	c.entries[key] = &entry{
+		nonce:   nonce,
+		sealed:  sealed,
+		expires: c.now().Add(c.ttl),
+	}
+}
+
+// Delete removes key from the cache, zeroing its sealed buffer.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.evict(key, e)
+	}
+}
+
+// Prune evicts all expired entries.
+func (c *Cache) Prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for key, e := range c.entries {
+		if !now.Before(e.expires) {
+			c.evict(key, e)
+		}
+	}
+}
+
+// Len returns the number of entries currently held, including expired entries
+// that have not been pruned yet.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// evict zeroes an entry's buffers and removes it. Callers must hold c.mu.
+func (c *Cache) evict(key string, e *entry) {
+	clear(e.sealed)
+	clear(e.nonce)
+	delete(c.entries, key)
+}
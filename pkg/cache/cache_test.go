@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCacheRoundTrip(t *testing.T) {
+	c, err := New(time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	c.Set("op\x00op://vault/item/field", "s3cr3t")
+	got, ok := c.Get("op\x00op://vault/item/field")
+	if !ok || got != "s3cr3t" {
+		t.Fatalf("Get = %q, %v; want s3cr3t, true", got, ok)
+	}
+
+	if _, ok := c.Get("missing"); ok {
+		t.Errorf("expected miss for unknown key")
+	}
+}
+
+func TestCacheDoesNotHoldPlaintext(t *testing.T) {
+	c, err := New(time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	c.Set("k", "plaintext-value")
+	e := c.entries["k"]
+	if bytes.Contains(e.sealed, []byte("plaintext-value")) {
+		t.Fatalf("sealed buffer contains plaintext")
+	}
+}
+
+func TestCacheExpiryZeroesBuffers(t *testing.T) {
+	c, err := New(time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Set("k", "value")
+	e := c.entries["k"]
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("expected expired entry to miss")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len = %d, want 0 after expiry", c.Len())
+	}
+	if !bytes.Equal(e.sealed, make([]byte, len(e.sealed))) {
+		t.Errorf("sealed buffer was not zeroed on eviction")
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	c, err := New(time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Set("old", "a")
+	now = now.Add(30 * time.Second)
+	c.Set("new", "b")
+	now = now.Add(45 * time.Second)
+
+	c.Prune()
+	if _, ok := c.entries["old"]; ok {
+		t.Errorf("expected expired entry to be pruned")
+	}
+	if got, ok := c.Get("new"); !ok || got != "b" {
+		t.Errorf("Get(new) = %q, %v; want b, true", got, ok)
+	}
+}
+
+func TestCacheKeysAreBoundToCiphertext(t *testing.T) {
+	c, err := New(time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	c.Set("a", "value-a")
+	c.Set("b", "value-b")
+
+	// Swapping sealed entries must not decrypt under the wrong key.
+	c.entries["a"], c.entries["b"] = c.entries["b"], c.entries["a"]
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected swapped entry to fail authentication")
+	}
+}
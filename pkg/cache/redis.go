@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// redisTimeout bounds each Redis round trip, so a hung server delays a sync by at
+// most this long before it falls back to the provider.
+const redisTimeout = 2 * time.Second
+
+// redisMaxIdle is how many idle connections are kept open for reuse. Callers never
+// wait for a connection; more are opened while all idle ones are in use.
+const redisMaxIdle = 8
+
+// Redis is a Store backed by a Redis server, shared by every replica using the same
+// address, key, and prefix. Entries expire in Redis after ttl.
+type Redis struct {
+	Addr     string
+	Password string      // sent with AUTH when set
+	Prefix   string      // prepended to keys, to keep stores sharing a server apart
+	TLS      *tls.Config // connections use TLS when set
+
+	aead    cipher.AEAD
+	nameKey []byte // keys the HMAC key names are stored under
+	ttl     time.Duration
+	idle    chan *redisConn
+}
+
+// redisConn is a connection to the server and a reader of its replies.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// NewRedis returns a Store in the Redis server at addr, connecting with TLS if
+// tlsConfig is not nil. key is the 32-byte AES-256 key entries are encrypted with,
+// and must be the same for all replicas.
+func NewRedis(addr, password, prefix string, tlsConfig *tls.Config, key []byte, ttl time.Duration) (*Redis, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("redis cache key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Key names are keyed with a separate key derived from the encryption key, so
+	// they don't reveal the providers and refs cached
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("k8s-secret-sync redis key names"))
+
+	return &Redis{
+		Addr:     addr,
+		Password: password,
+		Prefix:   prefix,
+		TLS:      tlsConfig,
+		aead:     aead,
+		nameKey:  mac.Sum(nil),
+		ttl:      ttl,
+		idle:     make(chan *redisConn, redisMaxIdle),
+	}, nil
+}
+
+// name returns the Redis key an entry is stored under: the prefix and an HMAC of key.
+func (c *Redis) name(key string) string {
+	mac := hmac.New(sha256.New, c.nameKey)
+	mac.Write([]byte(key))
+	return c.Prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Get returns the value stored under key, if present and not expired.
+func (c *Redis) Get(key string) (string, bool) {
+	reply, err := c.do("GET", c.name(key))
+	if err != nil {
+		klog.ErrorS(err, "Failed to read from Redis cache", "addr", c.Addr)
+		return "", false
+	}
+	sealed, ok := reply.([]byte)
+	if !ok || len(sealed) < c.aead.NonceSize() {
+		return "", false
+	}
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, []byte(key))
+	if err != nil {
+		return "", false
+	}
+	defer clear(plaintext)
+	return string(plaintext), true
+}
+
+// Set stores value under key, replacing any existing entry.
+func (c *Redis) Set(key, value string) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return
+	}
+	plaintext := []byte(value)
+	defer clear(plaintext)
+	sealed := c.aead.Seal(nonce, nonce, plaintext, []byte(key))
+
+	ttl := strconv.FormatInt(c.ttl.Milliseconds(), 10)
+	if _, err := c.do("SET", c.name(key), string(sealed), "PX", ttl); err != nil {
+		klog.ErrorS(err, "Failed to write to Redis cache", "addr", c.Addr)
+	}
+}
+
+// Delete removes key from the store.
+func (c *Redis) Delete(key string) {
+	if _, err := c.do("DEL", c.name(key)); err != nil {
+		klog.ErrorS(err, "Failed to delete from Redis cache", "addr", c.Addr)
+	}
+}
+
+// Prune does nothing; Redis expires entries itself.
+func (c *Redis) Prune() {}
+
+// do sends a command and returns its reply: a []byte for bulk strings (nil if
+// missing), a string for status replies, or an int64. It uses an idle connection if
+// there is one, and keeps the connection for reuse unless it failed.
+func (c *Redis) do(args ...string) (any, error) {
+	var conn *redisConn
+	select {
+	case conn = <-c.idle:
+	default:
+		var err error
+		if conn, err = c.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := conn.roundTrip(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case c.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+func (c *Redis) connect() (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if c.TLS != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.Addr, c.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", c.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &redisConn{Conn: conn, r: bufio.NewReader(conn)}
+	if c.Password != "" {
+		if _, err := rc.roundTrip("AUTH", c.Password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("authenticating to redis: %w", err)
+		}
+	}
+	return rc, nil
+}
+
+func (c *redisConn) roundTrip(args ...string) (any, error) {
+	if err := c.SetDeadline(time.Now().Add(redisTimeout)); err != nil {
+		return nil, err
+	}
+	buf := fmt.Appendf(nil, "*%d\r\n", len(args))
+	for _, arg := range args {
+		buf = fmt.Appendf(buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// redisError is an error reply from the server, after which the connection is
+// still usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// readReply reads a single RESP reply. Array replies are not used by the commands
+// the store sends and are rejected.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed redis reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed redis bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	default:
+		return nil, fmt.Errorf("unsupported redis reply type %q", kind)
+	}
+}
@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a minimal RESP server supporting AUTH, GET, SET, and DEL.
+type fakeRedis struct {
+	mu       sync.Mutex
+	data     map[string]string
+	ttls     map[string]string
+	password string
+	conns    int
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return serveFakeRedis(t, ln, password), ln.Addr().String()
+}
+
+func serveFakeRedis(t *testing.T, ln net.Listener, password string) *fakeRedis {
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeRedis{data: map[string]string{}, ttls: map[string]string{}, password: password}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil || len(args) == 0 {
+			return
+		}
+		s.mu.Lock()
+		var reply string
+		switch {
+		case strings.EqualFold(args[0], "AUTH"):
+			if args[1] == s.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "GET":
+			if value, ok := s.data[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case args[0] == "SET":
+			s.data[args[1]] = args[2]
+			s.ttls[args[1]] = args[4]
+			reply = "+OK\r\n"
+		case args[0] == "DEL":
+			delete(s.data, args[1])
+			reply = ":1\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func TestRedisRoundTrip(t *testing.T) {
+	server, addr := startFakeRedis(t, "hunter2")
+	c, err := NewRedis(addr, "hunter2", "kss:value:", nil, bytes.Repeat([]byte{1}, 32), time.Minute)
+	if err != nil {
+		t.Fatalf("NewRedis: %v", err)
+	}
+
+	c.Set("op\x00op://vault/item/field", "s3cr3t")
+	got, ok := c.Get("op\x00op://vault/item/field")
+	if !ok || got != "s3cr3t" {
+		t.Fatalf("Get = %q, %v; want s3cr3t, true", got, ok)
+	}
+
+	server.mu.Lock()
+	var name, stored, ttl string
+	for name, stored = range server.data {
+		ttl = server.ttls[name]
+	}
+	conns := server.conns
+	server.mu.Unlock()
+	if !strings.HasPrefix(name, "kss:value:") || strings.Contains(name, "vault") {
+		t.Errorf("stored key = %q, want the prefix and an HMAC of the key", name)
+	}
+	if stored == "" || strings.Contains(stored, "s3cr3t") {
+		t.Errorf("stored value = %q, want it present and encrypted", stored)
+	}
+	if ttl != "60000" {
+		t.Errorf("TTL = %s ms, want 60000", ttl)
+	}
+	if conns != 1 {
+		t.Errorf("opened %d connections, want 1 reused", conns)
+	}
+
+	c.Delete("op\x00op://vault/item/field")
+	if _, ok := c.Get("op\x00op://vault/item/field"); ok {
+		t.Errorf("expected miss after Delete")
+	}
+}
+
+func TestRedisSharedBetweenReplicas(t *testing.T) {
+	_, addr := startFakeRedis(t, "")
+	key := bytes.Repeat([]byte{1}, 32)
+	first, _ := NewRedis(addr, "", "", nil, key, time.Minute)
+	second, _ := NewRedis(addr, "", "", nil, key, time.Minute)
+	other, _ := NewRedis(addr, "", "", nil, bytes.Repeat([]byte{2}, 32), time.Minute)
+
+	first.Set("k", "value")
+	if got, ok := second.Get("k"); !ok || got != "value" {
+		t.Errorf("second replica Get = %q, %v; want value, true", got, ok)
+	}
+	if _, ok := other.Get("k"); ok {
+		t.Errorf("expected miss with a different key")
+	}
+}
+
+func TestRedisUnavailableMisses(t *testing.T) {
+	c, err := NewRedis("127.0.0.1:1", "", "", nil, bytes.Repeat([]byte{1}, 32), time.Minute)
+	if err != nil {
+		t.Fatalf("NewRedis: %v", err)
+	}
+	c.Set("k", "value")
+	if _, ok := c.Get("k"); ok {
+		t.Errorf("expected miss when Redis is unreachable")
+	}
+}
+
+func TestRedisTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(nil)
+	ts.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", ts.TLS)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	serveFakeRedis(t, ln, "hunter2")
+
+	roots := ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	c, err := NewRedis(ln.Addr().String(), "hunter2", "", &tls.Config{RootCAs: roots}, bytes.Repeat([]byte{1}, 32), time.Minute)
+	if err != nil {
+		t.Fatalf("NewRedis: %v", err)
+	}
+	c.Set("k", "value")
+	if got, ok := c.Get("k"); !ok || got != "value" {
+		t.Errorf("Get = %q, %v; want value, true", got, ok)
+	}
+
+	// The server's certificate is checked
+	untrusted, _ := NewRedis(ln.Addr().String(), "hunter2", "", &tls.Config{}, bytes.Repeat([]byte{1}, 32), time.Minute)
+	if _, ok := untrusted.Get("k"); ok {
+		t.Errorf("expected miss when the server's certificate is not trusted")
+	}
+}
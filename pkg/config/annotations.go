@@ -11,10 +11,189 @@ type Annotations struct {
 	ProviderName string // default: "k8s-secret-sync.weinbender.io/provider-name"
 
 	// Key for the annotation that specifies the secret reference for the provider.
-	// Used to specify the identifier or path of the secret for a given provider.
+	// Used to specify the identifier or path of the secret for a given provider. It may instead
+	// reference sync configuration in a ConfigMap key ("configmap:name#key"): a YAML map of
+	// the annotations naming refs and data formats (e.g. provider-ref, template) to values,
+	// for configuration too large or complex for annotations. Refs may use {{ .Namespace }},
+	// {{ .Name }}, and {{ .ClusterName }} (KSS_CLUSTER_NAME), e.g.
+	// "op://{{ .Namespace }}/db/password", as may refs resolved by templates, additional
+	// refs, and bundle files.
 	ProviderRef string // default: "k8s-secret-sync.weinbender.io/provider-ref"
 
 	// Key for the annotation that specifies where to store the fetched data.
 	// Used to specify which key in the Kubernetes Secret to update with the fetched secret value.
 	SecretKey string // default: "k8s-secret-sync.weinbender.io/secret-key"
+
+	// Key for the annotation that specifies what to do when the secret does not exist in the provider.
+	// One of "fail" (default, leave the secret untouched), "empty" (write an empty value), or "delete"
+	// (remove the managed key). The outcome is recorded in the status annotations.
+	OnNotFound string // default: "k8s-secret-sync.weinbender.io/on-not-found"
+
+	// Key for the annotation that specifies what to do when both the provider value and the
+	// managed data in the cluster changed since the last sync. One of "provider-wins" (default,
+	// overwrite the cluster edit), "cluster-wins" (keep the cluster edit), or "fail-and-alert"
+	// (leave the secret untouched, mark it Failed, and raise a Warning event).
+	OnConflict string // default: "k8s-secret-sync.weinbender.io/on-conflict"
+
+	// Key for the annotation that maps fields of a JSON provider value to data keys.
+	// Formatted as "username=.user,password=.pass"; when set, it replaces the single secret key.
+	KeyMapping string // default: "k8s-secret-sync.weinbender.io/key-mapping"
+
+	// Key for the annotation that specifies a transformation applied to the provider value.
+	// For example, "dotenv" expands KEY=value lines into one data key per variable, and
+	// "pem-bundle" splits a combined PEM bundle into tls.crt, tls.key, and ca.crt. "pkcs12"
+	// and "jks" assemble the same bundle into keystore.p12 or keystore.jks for JVM apps.
+	// "known-hosts" and "authorized-keys" compose those files from lines of SSH keys, and
+	// "ssh-auth" writes a private key to ssh-privatekey (with any host keys in known_hosts)
+	// for kubernetes.io/ssh-auth secrets. "basic-auth" writes username and password for
+	// kubernetes.io/basic-auth secrets, from a JSON item or a username and a password ref;
+	// "htpasswd" writes the same credentials as a bcrypt entry to auth.
+	// A secret's type cannot be changed once created, so typed secrets must be created with
+	// their type; the keys it requires are checked.
+	Transform string // default: "k8s-secret-sync.weinbender.io/transform"
+
+	// Key for the annotation listing further refs, comma separated, whose values are appended
+	// to the provider value one per line before a transformation, e.g. to compose known_hosts
+	// from several refs or a PEM bundle from separate certificate and key refs. They are
+	// resolved from the same provider.
+	AdditionalRefs string // default: "k8s-secret-sync.weinbender.io/additional-refs"
+
+	// Key for the annotation giving the provider ref of the password that protects keystores
+	// built by the "pkcs12" and "jks" transformations. It is resolved from the same provider.
+	KeystorePassword string // default: "k8s-secret-sync.weinbender.io/keystore-password-ref"
+
+	// Key for the annotation packing several refs into the single data key of the secret key
+	// annotation, for apps that mount one file holding several artifacts: "tar", "tar.gz" and
+	// "zip" archive the files, and "concat" joins them one after another.
+	Bundle string // default: "k8s-secret-sync.weinbender.io/bundle"
+
+	// Key for the annotation listing the files of a bundle, comma separated, as "path=ref",
+	// or just "path" for the provider value, e.g.
+	// "client.properties,truststore.jks=op://vault/kafka/truststore". Refs are resolved from
+	// the same provider.
+	BundleFiles string // default: "k8s-secret-sync.weinbender.io/bundle-files"
+
+	// Key for the annotation that restricts when refreshed values may be written, overriding
+	// the global maintenance windows. Formatted as "<cron> <duration>", e.g. "0 2 * * 6 4h";
+	// multiple windows are separated by ";". New secrets are always synced immediately.
+	MaintenanceWindow string // default: "k8s-secret-sync.weinbender.io/maintenance-window"
+
+	// Key for the annotation that marks a secret as a canary for its provider ref ("true").
+	// When a refreshed value changes, canaries are updated first; other secrets sharing the
+	// ref wait until every canary has held the new value for the canary soak period.
+	Canary string // default: "k8s-secret-sync.weinbender.io/canary"
+
+	// Key for the annotation listing workloads in a canary's namespace that must be healthy
+	// before the rollout continues, e.g. "deployment/api,statefulset/worker".
+	CanaryWorkloads string // default: "k8s-secret-sync.weinbender.io/canary-workloads"
+
+	// Key for the annotation listing secrets that must sync successfully before this one,
+	// e.g. "ca" or "other-namespace/ca". Dependency cycles are reported as errors.
+	DependsOn string // default: "k8s-secret-sync.weinbender.io/depends-on"
+
+	// Key for the annotation holding an integer priority. When the operator starts,
+	// secrets are first synced in order of priority, highest first; unset is 0.
+	Priority string // default: "k8s-secret-sync.weinbender.io/priority"
+
+	// Key for the annotation holding a Go text/template rendered into the secret key, for
+	// composed values such as whole config files (e.g. .npmrc) with embedded credentials.
+	// The template can use {{ .Value }}, {{ .Fields.<name> }} when the provider value is a
+	// JSON object, and {{ ref "<ref>" }} to resolve further refs from the same provider.
+	Template string // default: "k8s-secret-sync.weinbender.io/template"
+
+	// Key for the annotation referencing a template stored in a ConfigMap in the secret's
+	// namespace instead, formatted as "configmap-name#key".
+	TemplateConfigMap string // default: "k8s-secret-sync.weinbender.io/template-configmap"
+
+	// Key for the annotation that specifies how template output is written. One of "bytes"
+	// (default, written as-is), "string" (must be valid UTF-8, like stringData), or "base64"
+	// (decoded into binary data).
+	TemplateEncoding string // default: "k8s-secret-sync.weinbender.io/template-encoding"
+
+	// Key for the annotation that requires approval before a refreshed value overwrites the
+	// existing one ("true"). Changes are held with status Pending until approved.
+	RequireApproval string // default: "k8s-secret-sync.weinbender.io/require-approval"
+
+	// Key for the annotation a human or pipeline sets to approve a pending value change. Its
+	// value must be the data hash of the pending change, as shown in the status message. A
+	// single approval applies the change; anyone who may update the Secret can give it, so
+	// restrict who may with RBAC or an admission policy.
+	Approve string // default: "k8s-secret-sync.weinbender.io/approve"
+
+	// Key for the annotation that delays writing a newly detected upstream value, e.g. "1h",
+	// giving dependent systems still validating the old credential time to converge.
+	ApplyAfter string // default: "k8s-secret-sync.weinbender.io/apply-after"
+
+	// Key for the annotation that keeps the outgoing value when a refresh changes it ("true").
+	// Each managed key's previous value is written to a sibling "<key>_previous" key, so
+	// apps can accept both credentials while rolling over.
+	KeepPrevious string // default: "k8s-secret-sync.weinbender.io/keep-previous"
+
+	// Key for the annotation listing age recipients ("age1...", comma separated) that values
+	// are encrypted to before being written, for clusters where etcd encryption at rest is
+	// not trusted. Apps decrypt the values themselves with the matching identity. Hashes of
+	// the plaintext recorded on the Secret are keyed with the operator's hash key.
+	Encrypt string // default: "k8s-secret-sync.weinbender.io/encrypt"
+
+	// Key for the annotation on a Deployment or StatefulSet listing values to inject as
+	// environment variables, formatted as "name=DB_PASSWORD,ref=op://vault/db/password";
+	// multiple values are separated by ";" and "provider=" overrides the default "op".
+	// Requires KSS_WORKLOAD_INJECTION. A Secret owned by the workload is created for each
+	// value, and an env entry referencing it is added to every container.
+	Inject string // default: "k8s-secret-sync.weinbender.io/inject"
+
+	// Key for the annotation on a Namespace listing Secrets to create in it if they are
+	// missing, as a YAML list of objects with "name", "type", "labels", and "annotations"
+	// (the sync annotations for the Secret). Requires KSS_SECRET_BOOTSTRAP. The Secrets are
+	// owned by the Namespace, and their labels and annotations are kept up to date.
+	Secrets string // default: "k8s-secret-sync.weinbender.io/secrets"
+
+	// Key for the annotation on a kubernetes.io/dockerconfigjson secret naming the
+	// ServiceAccounts in its namespace it is added to as an image pull secret once synced,
+	// either comma separated (e.g. "default,builder") or "selector:<label selector>".
+	PullSecretFor string // default: "k8s-secret-sync.weinbender.io/pull-secret-for"
+
+	// Key for the annotation that overrides how synced values are written, for admission
+	// setups that treat patch types differently. One of "strategic-merge", "merge" (JSON
+	// merge patch), "json-patch" (fails on concurrent changes), or "apply" (server-side
+	// apply, fails if another field manager owns a managed field).
+	PatchStrategy string // default: "k8s-secret-sync.weinbender.io/patch-strategy"
+
+	// Key for the annotation that selects how values are rotated. "immutable" also writes
+	// each new value to a new immutable Secret named "<name>-<hash>", owned by this one,
+	// and records its name in the current-secret annotation; the previous copy is kept
+	// for workloads still mounting it and older copies are deleted.
+	Rotation string // default: "k8s-secret-sync.weinbender.io/rotation"
+
+	// Key for the annotation holding provider-specific request parameters, formatted as
+	// "key=value" pairs separated by commas, e.g. "externalId=abc123" for aws-sts or
+	// "namespace=team-a" for vault.
+	// Providers ignore parameters they do not support.
+	ProviderMetadata string // default: "k8s-secret-sync.weinbender.io/provider-metadata"
+
+	// Key for the annotation pinning the upstream version of the value to sync. Only
+	// providers that keep versions support it; with others the secret fails to sync.
+	ProviderVersion string // default: "k8s-secret-sync.weinbender.io/provider-version"
+
+	// Key for the annotation declaring that the value is binary ("true"), so a provider
+	// that only returns text fails the sync instead of producing a mangled value.
+	Binary string // default: "k8s-secret-sync.weinbender.io/binary"
+
+	// Key for the annotation marking a secret as a break-glass credential ("true"). It is only
+	// synced while a reason is given, and its managed keys are removed once the checkout TTL
+	// passes. Checking it out again needs a new reason.
+	BreakGlass string // default: "k8s-secret-sync.weinbender.io/break-glass"
+
+	// Key for the annotation giving the reason a break-glass credential is checked out, which
+	// is recorded in the operator log, an Event, and the audit log.
+	BreakGlassReason string // default: "k8s-secret-sync.weinbender.io/break-glass-reason"
+
+	// Key for the annotation shortening how long a break-glass checkout lasts, e.g. "15m". It
+	// cannot extend the checkout beyond KSS_BREAK_GLASS_TTL.
+	BreakGlassTTL string // default: "k8s-secret-sync.weinbender.io/break-glass-ttl"
+
+	// Key for the annotation on a Secret listing the other namespaces (comma separated globs,
+	// e.g. "team-*") whose secrets may read it with the kubernetes provider. Secrets can always
+	// be read from their own namespace.
+	ShareWith string // default: "k8s-secret-sync.weinbender.io/share-with"
 }
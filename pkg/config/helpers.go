@@ -1,8 +1,17 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"sync"
+)
+
+// defaults records the environment variables read by env and their defaults, so
+// tooling can enumerate the configuration.
+var (
+	defaultsMu sync.Mutex
+	defaults   = make(map[string]string)
 )
 
 // envVar is a type constraint that matches string, int, and bool types.
@@ -14,6 +23,10 @@ type envVar interface {
 // or defaultValue if the environment variable is not present or cannot be parsed.
 // The type of the return value matches the type of defaultValue.
 func env[T envVar](envVar string, defaultValue T) T {
+	defaultsMu.Lock()
+	defaults[envVar] = fmt.Sprint(defaultValue)
+	defaultsMu.Unlock()
+
 	if value := os.Getenv(envVar); value != "" {
 		switch any(defaultValue).(type) {
 		case string:
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// providerPolicyPrefix starts the environment variables setting provider policies.
+const providerPolicyPrefix = "KSS_PROVIDER_"
+
+// defaultProviderPolicy is the policy of providers with none configured.
+var defaultProviderPolicy = ProviderPolicy{Timeout: 30}
+
+// ProviderPolicy controls how requests to a single provider are timed out and retried,
+// since providers differ widely in latency and error characteristics.
+type ProviderPolicy struct {
+	Timeout     int // Seconds to wait for the provider to resolve a value (0 disables)
+	MaxRetries  int // Failed syncs retried with backoff before waiting for the next refresh (0 is unlimited)
+	BackoffMax  int // Cap in seconds on the retry backoff (0 uses the controller default)
+	MaxInFlight int // Requests to the provider resolving at once, across all workers (0 is unlimited)
+}
+
+// ProviderPolicy returns the policy for the named provider, read when the
+// configuration was loaded from environment variables prefixed with
+// KSS_PROVIDER_<NAME>_, e.g. KSS_PROVIDER_OP_TIMEOUT.
+func (s *Sync) ProviderPolicy(name string) ProviderPolicy {
+	if policy, ok := s.providerPolicies[policyName(name)]; ok {
+		return policy
+	}
+	return defaultProviderPolicy
+}
+
+// policyName returns the name a provider's policy variables are set under.
+func policyName(provider string) string {
+	return strings.ToUpper(strings.ReplaceAll(provider, "-", "_"))
+}
+
+// parseProviderPolicies parses the provider policies set in environ, a list of
+// "NAME=value" environment variables, by policy name. Settings that don't exist and
+// values that are not non-negative integers are errors.
+func parseProviderPolicies(environ []string) (map[string]ProviderPolicy, error) {
+	policies := make(map[string]ProviderPolicy)
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		rest, ok := strings.CutPrefix(name, providerPolicyPrefix)
+		if !ok || value == "" {
+			continue
+		}
+
+		var provider string
+		var field func(*ProviderPolicy) *int
+		for suffix, f := range map[string]func(*ProviderPolicy) *int{
+			"_TIMEOUT":       func(p *ProviderPolicy) *int { return &p.Timeout },
+			"_MAX_RETRIES":   func(p *ProviderPolicy) *int { return &p.MaxRetries },
+			"_BACKOFF_MAX":   func(p *ProviderPolicy) *int { return &p.BackoffMax },
+			"_MAX_IN_FLIGHT": func(p *ProviderPolicy) *int { return &p.MaxInFlight },
+		} {
+			if p, ok := strings.CutSuffix(rest, suffix); ok && p != "" {
+				provider, field = p, f
+			}
+		}
+		if field == nil {
+			return nil, fmt.Errorf("unknown provider policy setting %s", name)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s %q, expected a non-negative integer", name, value)
+		}
+
+		policy, ok := policies[provider]
+		if !ok {
+			policy = defaultProviderPolicy
+		}
+		*field(&policy) = n
+		policies[provider] = policy
+	}
+	return policies, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestProviderPolicy(t *testing.T) {
+	cfg := New(&kubernetes.Clientset{})
+	if got, want := cfg.ProviderPolicy("op"), (ProviderPolicy{Timeout: 30}); got != want {
+		t.Errorf("default policy = %+v, want %+v", got, want)
+	}
+
+	t.Setenv("KSS_PROVIDER_HTTP_JSON_TIMEOUT", "5")
+	t.Setenv("KSS_PROVIDER_HTTP_JSON_MAX_RETRIES", "3")
+	t.Setenv("KSS_PROVIDER_HTTP_JSON_BACKOFF_MAX", "60")
+	t.Setenv("KSS_PROVIDER_HTTP_JSON_MAX_IN_FLIGHT", "2")
+	t.Setenv("KSS_PROVIDER_OP_MAX_RETRIES", "4")
+	cfg = New(&kubernetes.Clientset{})
+	if err := cfg.Err(); err != nil {
+		t.Fatalf("Err = %v", err)
+	}
+	if got, want := cfg.ProviderPolicy("http-json"), (ProviderPolicy{Timeout: 5, MaxRetries: 3, BackoffMax: 60, MaxInFlight: 2}); got != want {
+		t.Errorf("overridden policy = %+v, want %+v", got, want)
+	}
+	if got, want := cfg.ProviderPolicy("op"), (ProviderPolicy{Timeout: 30, MaxRetries: 4}); got != want {
+		t.Errorf("partly overridden policy = %+v, want %+v", got, want)
+	}
+}
+
+func TestProviderPolicyInvalid(t *testing.T) {
+	for _, variable := range []string{
+		"KSS_PROVIDER_OP_TIMEOUT=soon",
+		"KSS_PROVIDER_OP_MAX_RETRIES=-1",
+		"KSS_PROVIDER_OP_RETRIES=3",
+		"KSS_PROVIDER_TIMEOUT=3",
+	} {
+		if _, err := parseProviderPolicies([]string{variable}); err == nil {
+			t.Errorf("parseProviderPolicies(%q) succeeded, want error", variable)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 )
@@ -9,7 +11,103 @@ type Sync struct {
 	Clientset            kubernetes.Interface
 	Annotations          Annotations
 	DefaultSecretDataKey string // Default key in the secret data to store fetched calues if annotation is not set
-	PollInterval         int    // Sync interval in seconds
+	PollInterval         int    // Sync interval in seconds; synced secrets are refreshed this often if Refresh is set
+	Refresh              bool   // Whether synced secrets are re-resolved every poll interval, rather than synced once
+	CacheTTL             int    // Lifetime of cached provider values in seconds (0 disables caching)
+	VerifyInterval       int    // Interval in seconds between integrity checks of managed data (0 disables)
+	MetricsAddr          string // Address to serve Prometheus metrics on (empty disables)
+	Workers              int    // Number of secrets synced concurrently
+	ChecksumAnnotation   string // Annotation set to a checksum of the managed data whenever it is written (empty disables)
+	MaintenanceWindows   string // Windows ("<cron> <duration>", separated by ";") outside which refreshed values are deferred (empty allows any time)
+	CanarySoak           int    // Seconds canary secrets must hold a new value before it is rolled out to the rest
+	CanaryMaxHold        int    // Seconds a value change may be held for canaries before the held secret is marked Failed (0 holds indefinitely)
+	MaxSecretKeys        int    // Maximum number of data keys a synced secret may have (0 disables)
+	SizeWarningPercent   int    // Percentage of the 1MiB Secret size limit at which a warning is raised (0 disables)
+	ReloaderAnnotations  string // Annotations ("key=value", comma separated) set on synced secrets for Stakater Reloader (empty disables)
+	SecretLabels         string // Labels ("key=value", comma separated) set on synced secrets besides app.kubernetes.io/managed-by
+	SummaryConfigMap     string // ConfigMap ("namespace/name") maintained with per-namespace sync status counts (empty disables)
+	SummaryInterval      int    // Interval in seconds between updates of the summary ConfigMap
+	HealthCheckInterval  int    // Interval in seconds between provider health checks (0 disables)
+	StorePath            string // Path of an on-disk database to cache watched secrets in, for very large clusters (empty caches in memory)
+	NamespaceSelector    string // Label selector for namespaces to watch, e.g. "secret-sync=enabled" (empty watches all; not combined with StorePath)
+	Notifiers            string // Notifiers told about sync outcomes, comma separated: "log", "events", "webhook", "audit" or any registered by name
+	NotifyWebhookURL     string // URL the webhook notifier posts JSON to
+	AuditLogPath         string // File the audit notifier appends JSON lines to (empty writes to stdout)
+	AuditKey             string // Base64 HMAC key audit entries are signed and chained with (empty leaves them unsigned)
+	AuditHeadPath        string // File the signature of the latest audit entry is kept in, apart from the log, to detect truncation (empty disables)
+	HashKey              string // Base64 HMAC key the hashes of synced data recorded in annotations are keyed with; must match across replicas (empty uses HashKeySecret)
+	HashKeySecret        string // Secret ("namespace/name", or a name in the operator's namespace) holding a hash key generated on first start, used when HashKey is empty
+	EventBurst           int    // Events that may be published about a secret in a burst before being rate limited
+	EventRefillInterval  int    // Seconds for each additional event allowed about a secret once its burst is spent
+	HistoryLimit         int    // Number of sync outcomes recorded in each secret's history annotation; 0 disables history
+	MaxRefreshSlowdown   int    // Maximum factor refreshes are slowed by while the API server is throttling the operator (1 disables)
+	CheckpointConfigMap  string // ConfigMap ("namespace/name") refresh progress is saved to on shutdown and resumed from (empty disables)
+	SidecarPath          string // Directory values are written to as files instead of into Secrets, running as a sidecar (empty runs as an operator)
+	Namespace            string // Namespace watched in sidecar mode (empty uses the pod's own namespace)
+	SidecarReloadCommand string // Shell command run in sidecar mode when files change, e.g. "curl -X POST localhost:8080/reload" (empty disables)
+	SidecarReloadProcess string // Name of a process signalled in sidecar mode when files change; requires shareProcessNamespace (empty disables)
+	SidecarReloadSignal  string // Signal sent to the reload process, e.g. "SIGHUP" or "SIGUSR1"
+	ProtectedNamespaces  string // Namespaces (comma separated globs) whose secrets are never written, e.g. "kube-*" (empty protects none)
+	AllowedNamespaces    string // Protected namespaces (comma separated globs) whose secrets may be written anyway
+	Providers            string // Providers secrets may use, comma separated: "op", "op-connect", "aws-sts", "aws-sm", "gcp-sa", "gcp-sm", "vault", "conjur", "akeyless", "age", "keepass", "git", "kubernetes", "cert-manager"
+	AWSSTSDuration       int    // Lifetime in seconds of credentials minted by the aws-sts provider
+	AWSSTSSessionName    string // Role session name used by the aws-sts provider, recorded in CloudTrail
+	AWSSTSRoles          string // IAM roles the aws-sts provider mints credentials for on behalf of secrets in matching namespaces ("namespace=arn", comma separated globs; empty allows none)
+	AWSRegion            string // Region the aws-sm provider reads secrets from by name (empty uses AWS_REGION or the shared config)
+	AWSNamespaceRoles    string // IAM roles the AWS providers assume for secrets in matching namespaces ("namespace=arn", comma separated globs; empty uses the operator's identity)
+	GCPKeyRotation       int    // Seconds between service account keys minted by the gcp-sa provider
+	GCPKeyGracePeriod    int    // Seconds a superseded gcp-sa key remains valid before it is deleted
+	GCPKeyAccounts       string // Service accounts the gcp-sa provider mints keys for secrets in matching namespaces ("namespace=email", comma separated globs; empty allows none)
+	GCPKeyRecordSecret   string // Secret ("namespace/name", or a name in the operator's namespace) recording the keys the gcp-sa provider minted, the only keys it deletes
+	GCPNamespaceAccounts string // Service accounts the gcp-sm provider impersonates for secrets in matching namespaces ("namespace=email", comma separated globs; empty uses the operator's identity)
+	WorkloadInjection    bool   // Whether inject annotations on Deployments and StatefulSets are acted on
+	SecretBootstrap      bool   // Whether Secrets listed in the secrets annotation on Namespaces are created if missing
+	PatchStrategy        string // How synced values are written: "strategic-merge", "merge", "json-patch", or "apply"
+	DedupeWindow         int    // Seconds a resolved value is shared with other secrets with the same ref (0 disables)
+	CacheBackend         string // Where cached and shared values are kept: "memory", or "redis" to share them between replicas
+	CacheRedisAddr       string // Address ("host:port") of the Redis server for the redis cache backend
+	CacheRedisPassword   string // Password for the Redis server (empty disables AUTH)
+	CacheRedisTLS        bool   // Whether connections to the Redis server use TLS
+	CacheRedisCACertFile string // CA certificates the Redis server's TLS certificate is checked against (empty uses the system roots)
+	CacheKey             string // Base64 32-byte key values are encrypted with in Redis; must match across replicas
+	MetricsSecretLabels  string // Labels identifying secrets on per-secret metrics: "secret", "namespace", or "none"
+	LogLevels            string // Log verbosity per subsystem ("subsystem=level", comma separated): sync, providers, providers/<name>, webhook, cache
+	CleanUpUnmanaged     bool   // Remove the operator's annotations and managed-by label from managed secrets whose provider or ref annotation is removed
+	SkipLogging          string // How skipped secrets are logged: "sampled" (once per secret and reason per SkipLogInterval), "debug" (only at sync verbosity 4), or "all"
+	SkipLogInterval      int    // Seconds between logs of the same secret being skipped for the same reason when sampled
+	ObserveOnly          bool   // Report the secrets that would be managed without resolving or writing anything
+	SyncDeadline         int    // Seconds a secret may go without a successful sync before it is flagged as degraded (0 disables)
+	ClusterName          string // Name of the cluster, for ref templates ({{ .ClusterName }}), provenance annotations, and notifications
+	AgeIdentityFile      string // Path of the age identity file the age provider decrypts refs with
+	KeePassFile          string // Path of the KDBX database the keepass provider reads
+	KeePassKeyFile       string // Path of the key file the KDBX database is unlocked with (empty if none)
+	KeePassPassword      string // Master password the KDBX database is unlocked with (empty if none)
+	GitRepoURL           string // URL of the git repository the git provider reads, e.g. "git@github.com:org/secrets.git"
+	GitBranch            string // Branch of the git repository to read
+	GitSSHKeyFile        string // Deploy key the git repository is cloned with over SSH (empty uses the default SSH configuration)
+	GitKnownHosts        string // known_hosts file the git server is checked against (required for SSH repository URLs)
+	GitPullInterval      int    // Interval in seconds between pulls of the git repository
+	GitCheckoutDir       string // Directory the git repository is cloned into
+	OPConnectHost        string // URL of the 1Password Connect server the op-connect provider reads, e.g. "http://onepassword-connect:8080"
+	OPConnectToken       string // Access token the op-connect provider authenticates to 1Password Connect with
+	OPAllowedVaults      string // 1Password vaults (titles or IDs, comma separated) the op and op-connect providers may read or write (empty allows all); changing it starts a fresh Redis cache
+	VaultAddr            string // Address of the Vault server the vault provider reads, e.g. "https://vault.example.com:8200"
+	VaultToken           string // Token the vault provider authenticates to Vault with
+	VaultNamespace       string // Vault Enterprise namespace read when a secret's namespace is mapped to none (empty for the root namespace)
+	VaultNamespaces      string // Vault namespaces secrets in matching namespaces read from ("namespace=vault-namespace", comma separated globs; empty maps none)
+	ConjurURL            string // URL of the Conjur server the conjur provider reads, e.g. "https://conjur.example.com"
+	ConjurAccount        string // Conjur organization account
+	ConjurAuthnLogin     string // Conjur host identity the conjur provider authenticates as, e.g. "host/k8s-secret-sync"
+	ConjurAPIKey         string // API key of the Conjur host identity
+	ConjurCACertFile     string // CA certificates the Conjur server is verified with (empty uses the system roots)
+	AkeylessURL          string // URL of the Akeyless API the akeyless provider reads, or a gateway's, e.g. "https://gateway.example.com:8000/api/v2"
+	AkeylessAccessID     string // Access ID of the Akeyless auth method the akeyless provider authenticates with
+	AkeylessAccessType   string // How the akeyless provider authenticates: "access_key", or the operator's cloud identity with "aws_iam", "gcp", or "azure_ad"
+	AkeylessAccessKey    string // Access key for access_key authentication
+	BreakGlassTTL        int    // Maximum seconds a break-glass secret stays checked out before its managed keys are removed
+
+	providerPolicies map[string]ProviderPolicy // policies set for providers, by policy name
+	err              error                     // first invalid value found loading the configuration
 }
 
 func New(cs kubernetes.Interface) *Sync {
@@ -17,14 +115,148 @@ func New(cs kubernetes.Interface) *Sync {
 
 	// Read in configuration from environment variables with defaults
 	klog.InfoS("Loading configuration from environment variables...")
-	return &Sync{
+	s := &Sync{
 		Clientset: cs,
 		Annotations: Annotations{
-			ProviderName: env("KSS_SECRET_ANNOTATION_KEY_PROVIDER_NAME", "k8s-secret-sync.weinbender.io/provider-name"),
-			ProviderRef:  env("KSS_SECRET_ANNOTATION_KEY_PROVIDER_REF", "k8s-secret-sync.weinbender.io/provider-ref"),
-			SecretKey:    env("KSS_SECRET_ANNOTATION_KEY_SECRET_KEY", "k8s-secret-sync.weinbender.io/secret-key"),
+			ProviderName:      env("KSS_SECRET_ANNOTATION_KEY_PROVIDER_NAME", "k8s-secret-sync.weinbender.io/provider-name"),
+			ProviderRef:       env("KSS_SECRET_ANNOTATION_KEY_PROVIDER_REF", "k8s-secret-sync.weinbender.io/provider-ref"),
+			SecretKey:         env("KSS_SECRET_ANNOTATION_KEY_SECRET_KEY", "k8s-secret-sync.weinbender.io/secret-key"),
+			OnNotFound:        env("KSS_SECRET_ANNOTATION_KEY_ON_NOT_FOUND", "k8s-secret-sync.weinbender.io/on-not-found"),
+			OnConflict:        env("KSS_SECRET_ANNOTATION_KEY_ON_CONFLICT", "k8s-secret-sync.weinbender.io/on-conflict"),
+			KeyMapping:        env("KSS_SECRET_ANNOTATION_KEY_KEY_MAPPING", "k8s-secret-sync.weinbender.io/key-mapping"),
+			Transform:         env("KSS_SECRET_ANNOTATION_KEY_TRANSFORM", "k8s-secret-sync.weinbender.io/transform"),
+			AdditionalRefs:    env("KSS_SECRET_ANNOTATION_KEY_ADDITIONAL_REFS", "k8s-secret-sync.weinbender.io/additional-refs"),
+			KeystorePassword:  env("KSS_SECRET_ANNOTATION_KEY_KEYSTORE_PASSWORD_REF", "k8s-secret-sync.weinbender.io/keystore-password-ref"),
+			Bundle:            env("KSS_SECRET_ANNOTATION_KEY_BUNDLE", "k8s-secret-sync.weinbender.io/bundle"),
+			BundleFiles:       env("KSS_SECRET_ANNOTATION_KEY_BUNDLE_FILES", "k8s-secret-sync.weinbender.io/bundle-files"),
+			MaintenanceWindow: env("KSS_SECRET_ANNOTATION_KEY_MAINTENANCE_WINDOW", "k8s-secret-sync.weinbender.io/maintenance-window"),
+			Canary:            env("KSS_SECRET_ANNOTATION_KEY_CANARY", "k8s-secret-sync.weinbender.io/canary"),
+			CanaryWorkloads:   env("KSS_SECRET_ANNOTATION_KEY_CANARY_WORKLOADS", "k8s-secret-sync.weinbender.io/canary-workloads"),
+			DependsOn:         env("KSS_SECRET_ANNOTATION_KEY_DEPENDS_ON", "k8s-secret-sync.weinbender.io/depends-on"),
+			Priority:          env("KSS_SECRET_ANNOTATION_KEY_PRIORITY", "k8s-secret-sync.weinbender.io/priority"),
+			Template:          env("KSS_SECRET_ANNOTATION_KEY_TEMPLATE", "k8s-secret-sync.weinbender.io/template"),
+			TemplateConfigMap: env("KSS_SECRET_ANNOTATION_KEY_TEMPLATE_CONFIGMAP", "k8s-secret-sync.weinbender.io/template-configmap"),
+			TemplateEncoding:  env("KSS_SECRET_ANNOTATION_KEY_TEMPLATE_ENCODING", "k8s-secret-sync.weinbender.io/template-encoding"),
+			RequireApproval:   env("KSS_SECRET_ANNOTATION_KEY_REQUIRE_APPROVAL", "k8s-secret-sync.weinbender.io/require-approval"),
+			Approve:           env("KSS_SECRET_ANNOTATION_KEY_APPROVE", "k8s-secret-sync.weinbender.io/approve"),
+			ApplyAfter:        env("KSS_SECRET_ANNOTATION_KEY_APPLY_AFTER", "k8s-secret-sync.weinbender.io/apply-after"),
+			KeepPrevious:      env("KSS_SECRET_ANNOTATION_KEY_KEEP_PREVIOUS", "k8s-secret-sync.weinbender.io/keep-previous"),
+			Encrypt:           env("KSS_SECRET_ANNOTATION_KEY_ENCRYPT", "k8s-secret-sync.weinbender.io/encrypt"),
+			Inject:            env("KSS_SECRET_ANNOTATION_KEY_INJECT", "k8s-secret-sync.weinbender.io/inject"),
+			Secrets:           env("KSS_SECRET_ANNOTATION_KEY_SECRETS", "k8s-secret-sync.weinbender.io/secrets"),
+			PullSecretFor:     env("KSS_SECRET_ANNOTATION_KEY_PULL_SECRET_FOR", "k8s-secret-sync.weinbender.io/pull-secret-for"),
+			PatchStrategy:     env("KSS_SECRET_ANNOTATION_KEY_PATCH_STRATEGY", "k8s-secret-sync.weinbender.io/patch-strategy"),
+			Rotation:          env("KSS_SECRET_ANNOTATION_KEY_ROTATION", "k8s-secret-sync.weinbender.io/rotation"),
+			ProviderMetadata:  env("KSS_SECRET_ANNOTATION_KEY_PROVIDER_METADATA", "k8s-secret-sync.weinbender.io/provider-metadata"),
+			ProviderVersion:   env("KSS_SECRET_ANNOTATION_KEY_PROVIDER_VERSION", "k8s-secret-sync.weinbender.io/provider-version"),
+			Binary:            env("KSS_SECRET_ANNOTATION_KEY_BINARY", "k8s-secret-sync.weinbender.io/binary"),
+			BreakGlass:        env("KSS_SECRET_ANNOTATION_KEY_BREAK_GLASS", "k8s-secret-sync.weinbender.io/break-glass"),
+			BreakGlassReason:  env("KSS_SECRET_ANNOTATION_KEY_BREAK_GLASS_REASON", "k8s-secret-sync.weinbender.io/break-glass-reason"),
+			BreakGlassTTL:     env("KSS_SECRET_ANNOTATION_KEY_BREAK_GLASS_TTL", "k8s-secret-sync.weinbender.io/break-glass-ttl"),
+			ShareWith:         env("KSS_SECRET_ANNOTATION_KEY_SHARE_WITH", "k8s-secret-sync.weinbender.io/share-with"),
 		},
 		DefaultSecretDataKey: env("KSS_DEFAULT_SECRET_DATA_KEY", "value"),
 		PollInterval:         env("KSS_POLL_INTERVAL", 300),
+		Refresh:              env("KSS_REFRESH", false),
+		CacheTTL:             env("KSS_CACHE_TTL", 0),
+		VerifyInterval:       env("KSS_VERIFY_INTERVAL", 300),
+		MetricsAddr:          env("KSS_METRICS_ADDR", ":8080"),
+		Workers:              env("KSS_WORKERS", 2),
+		ChecksumAnnotation:   env("KSS_CHECKSUM_ANNOTATION", ""),
+		MaintenanceWindows:   env("KSS_MAINTENANCE_WINDOWS", ""),
+		CanarySoak:           env("KSS_CANARY_SOAK", 600),
+		CanaryMaxHold:        env("KSS_CANARY_MAX_HOLD", 86400),
+		MaxSecretKeys:        env("KSS_MAX_SECRET_KEYS", 0),
+		SizeWarningPercent:   env("KSS_SIZE_WARNING_PERCENT", 90),
+		ReloaderAnnotations:  env("KSS_RELOADER_ANNOTATIONS", "reloader.stakater.com/match=true"),
+		SecretLabels:         env("KSS_SECRET_LABELS", ""),
+		SummaryConfigMap:     env("KSS_SUMMARY_CONFIGMAP", ""),
+		SummaryInterval:      env("KSS_SUMMARY_INTERVAL", 60),
+		HealthCheckInterval:  env("KSS_HEALTH_CHECK_INTERVAL", 30),
+		StorePath:            env("KSS_STORE_PATH", ""),
+		NamespaceSelector:    env("KSS_NAMESPACE_SELECTOR", ""),
+		Notifiers:            env("KSS_NOTIFIERS", "events"),
+		NotifyWebhookURL:     env("KSS_NOTIFY_WEBHOOK_URL", ""),
+		AuditLogPath:         env("KSS_AUDIT_LOG_PATH", ""),
+		AuditKey:             env("KSS_AUDIT_KEY", ""),
+		AuditHeadPath:        env("KSS_AUDIT_HEAD_PATH", ""),
+		HashKey:              env("KSS_HASH_KEY", ""),
+		HashKeySecret:        env("KSS_HASH_KEY_SECRET", "k8s-secret-sync-hash-key"),
+		EventBurst:           env("KSS_EVENT_BURST", 10),
+		EventRefillInterval:  env("KSS_EVENT_REFILL_INTERVAL", 300),
+		HistoryLimit:         env("KSS_HISTORY_LIMIT", 10),
+		MaxRefreshSlowdown:   env("KSS_MAX_REFRESH_SLOWDOWN", 8),
+		CheckpointConfigMap:  env("KSS_CHECKPOINT_CONFIGMAP", ""),
+		SidecarPath:          env("KSS_SIDECAR_PATH", ""),
+		Namespace:            env("KSS_NAMESPACE", ""),
+		SidecarReloadCommand: env("KSS_SIDECAR_RELOAD_COMMAND", ""),
+		SidecarReloadProcess: env("KSS_SIDECAR_RELOAD_PROCESS", ""),
+		SidecarReloadSignal:  env("KSS_SIDECAR_RELOAD_SIGNAL", "SIGHUP"),
+		ProtectedNamespaces:  env("KSS_PROTECTED_NAMESPACES", "kube-*"),
+		AllowedNamespaces:    env("KSS_ALLOWED_NAMESPACES", ""),
+		Providers:            env("KSS_PROVIDERS", "op"),
+		AWSSTSDuration:       env("KSS_AWS_STS_DURATION", 3600),
+		AWSSTSSessionName:    env("KSS_AWS_STS_SESSION_NAME", "k8s-secret-sync"),
+		AWSSTSRoles:          env("KSS_AWS_STS_ROLES", ""),
+		AWSRegion:            env("KSS_AWS_REGION", ""),
+		AWSNamespaceRoles:    env("KSS_AWS_NAMESPACE_ROLES", ""),
+		GCPKeyRotation:       env("KSS_GCP_KEY_ROTATION", 86400),
+		GCPKeyGracePeriod:    env("KSS_GCP_KEY_GRACE_PERIOD", 3600),
+		GCPKeyAccounts:       env("KSS_GCP_KEY_SERVICE_ACCOUNTS", ""),
+		GCPKeyRecordSecret:   env("KSS_GCP_KEY_RECORD_SECRET", "k8s-secret-sync-gcp-keys"),
+		GCPNamespaceAccounts: env("KSS_GCP_NAMESPACE_SERVICE_ACCOUNTS", ""),
+		WorkloadInjection:    env("KSS_WORKLOAD_INJECTION", false),
+		SecretBootstrap:      env("KSS_SECRET_BOOTSTRAP", false),
+		PatchStrategy:        env("KSS_PATCH_STRATEGY", "strategic-merge"),
+		DedupeWindow:         env("KSS_DEDUPE_WINDOW", 0),
+		CacheBackend:         env("KSS_CACHE_BACKEND", "memory"),
+		CacheRedisAddr:       env("KSS_CACHE_REDIS_ADDR", "localhost:6379"),
+		CacheRedisPassword:   env("KSS_CACHE_REDIS_PASSWORD", ""),
+		CacheRedisTLS:        env("KSS_CACHE_REDIS_TLS", true),
+		CacheRedisCACertFile: env("KSS_CACHE_REDIS_CA_CERT_FILE", ""),
+		CacheKey:             env("KSS_CACHE_KEY", ""),
+		MetricsSecretLabels:  env("KSS_METRICS_SECRET_LABELS", "secret"),
+		LogLevels:            env("KSS_LOG_LEVELS", ""),
+		CleanUpUnmanaged:     env("KSS_CLEAN_UP_UNMANAGED", false),
+		SkipLogging:          env("KSS_SKIP_LOGGING", "sampled"),
+		SkipLogInterval:      env("KSS_SKIP_LOG_INTERVAL", 3600),
+		ObserveOnly:          env("KSS_OBSERVE_ONLY", false),
+		SyncDeadline:         env("KSS_SYNC_DEADLINE", 900),
+		ClusterName:          env("KSS_CLUSTER_NAME", ""),
+		AgeIdentityFile:      env("KSS_AGE_IDENTITY_FILE", ""),
+		KeePassFile:          env("KSS_KEEPASS_FILE", ""),
+		KeePassKeyFile:       env("KSS_KEEPASS_KEY_FILE", ""),
+		KeePassPassword:      env("KSS_KEEPASS_PASSWORD", ""),
+		GitRepoURL:           env("KSS_GIT_REPO_URL", ""),
+		GitBranch:            env("KSS_GIT_BRANCH", "main"),
+		GitSSHKeyFile:        env("KSS_GIT_SSH_KEY_FILE", ""),
+		GitKnownHosts:        env("KSS_GIT_KNOWN_HOSTS", ""),
+		GitPullInterval:      env("KSS_GIT_PULL_INTERVAL", 60),
+		GitCheckoutDir:       env("KSS_GIT_CHECKOUT_DIR", "/tmp/k8s-secret-sync/git"),
+		OPConnectHost:        env("KSS_OP_CONNECT_HOST", ""),
+		OPConnectToken:       env("KSS_OP_CONNECT_TOKEN", ""),
+		OPAllowedVaults:      env("KSS_OP_ALLOWED_VAULTS", ""),
+		VaultAddr:            env("KSS_VAULT_ADDR", ""),
+		VaultToken:           env("KSS_VAULT_TOKEN", ""),
+		VaultNamespace:       env("KSS_VAULT_NAMESPACE", ""),
+		VaultNamespaces:      env("KSS_VAULT_NAMESPACES", ""),
+		ConjurURL:            env("KSS_CONJUR_URL", ""),
+		ConjurAccount:        env("KSS_CONJUR_ACCOUNT", ""),
+		ConjurAuthnLogin:     env("KSS_CONJUR_AUTHN_LOGIN", ""),
+		ConjurAPIKey:         env("KSS_CONJUR_API_KEY", ""),
+		ConjurCACertFile:     env("KSS_CONJUR_CA_CERT_FILE", ""),
+		AkeylessURL:          env("KSS_AKEYLESS_URL", "https://api.akeyless.io"),
+		AkeylessAccessID:     env("KSS_AKEYLESS_ACCESS_ID", ""),
+		AkeylessAccessType:   env("KSS_AKEYLESS_ACCESS_TYPE", "access_key"),
+		AkeylessAccessKey:    env("KSS_AKEYLESS_ACCESS_KEY", ""),
+		BreakGlassTTL:        env("KSS_BREAK_GLASS_TTL", 3600),
 	}
+	s.providerPolicies, s.err = parseProviderPolicies(os.Environ())
+	return s
+}
+
+// Err returns the first invalid value found while loading the configuration, which
+// should stop the operator from starting.
+func (s *Sync) Err() error {
+	return s.err
 }
@@ -20,7 +20,46 @@ func TestNewDefaults(t *testing.T) {
 		{"ProviderName", cfg.Annotations.ProviderName, "k8s-secret-sync.weinbender.io/provider-name"},
 		{"ProviderRef", cfg.Annotations.ProviderRef, "k8s-secret-sync.weinbender.io/provider-ref"},
 		{"SecretKey", cfg.Annotations.SecretKey, "k8s-secret-sync.weinbender.io/secret-key"},
+		{"OnNotFound", cfg.Annotations.OnNotFound, "k8s-secret-sync.weinbender.io/on-not-found"},
+		{"OnConflict", cfg.Annotations.OnConflict, "k8s-secret-sync.weinbender.io/on-conflict"},
+		{"KeyMapping", cfg.Annotations.KeyMapping, "k8s-secret-sync.weinbender.io/key-mapping"},
+		{"Transform", cfg.Annotations.Transform, "k8s-secret-sync.weinbender.io/transform"},
+		{"AdditionalRefs", cfg.Annotations.AdditionalRefs, "k8s-secret-sync.weinbender.io/additional-refs"},
+		{"KeystorePassword", cfg.Annotations.KeystorePassword, "k8s-secret-sync.weinbender.io/keystore-password-ref"},
+		{"Bundle", cfg.Annotations.Bundle, "k8s-secret-sync.weinbender.io/bundle"},
+		{"BundleFiles", cfg.Annotations.BundleFiles, "k8s-secret-sync.weinbender.io/bundle-files"},
+		{"MaintenanceWindow", cfg.Annotations.MaintenanceWindow, "k8s-secret-sync.weinbender.io/maintenance-window"},
+		{"Canary", cfg.Annotations.Canary, "k8s-secret-sync.weinbender.io/canary"},
+		{"CanaryWorkloads", cfg.Annotations.CanaryWorkloads, "k8s-secret-sync.weinbender.io/canary-workloads"},
+		{"DependsOn", cfg.Annotations.DependsOn, "k8s-secret-sync.weinbender.io/depends-on"},
+		{"Priority", cfg.Annotations.Priority, "k8s-secret-sync.weinbender.io/priority"},
+		{"Template", cfg.Annotations.Template, "k8s-secret-sync.weinbender.io/template"},
+		{"TemplateConfigMap", cfg.Annotations.TemplateConfigMap, "k8s-secret-sync.weinbender.io/template-configmap"},
+		{"TemplateEncoding", cfg.Annotations.TemplateEncoding, "k8s-secret-sync.weinbender.io/template-encoding"},
+		{"RequireApproval", cfg.Annotations.RequireApproval, "k8s-secret-sync.weinbender.io/require-approval"},
+		{"Approve", cfg.Annotations.Approve, "k8s-secret-sync.weinbender.io/approve"},
+		{"ApplyAfter", cfg.Annotations.ApplyAfter, "k8s-secret-sync.weinbender.io/apply-after"},
+		{"KeepPrevious", cfg.Annotations.KeepPrevious, "k8s-secret-sync.weinbender.io/keep-previous"},
+		{"Encrypt", cfg.Annotations.Encrypt, "k8s-secret-sync.weinbender.io/encrypt"},
+		{"Inject", cfg.Annotations.Inject, "k8s-secret-sync.weinbender.io/inject"},
+		{"Secrets", cfg.Annotations.Secrets, "k8s-secret-sync.weinbender.io/secrets"},
+		{"PullSecretFor", cfg.Annotations.PullSecretFor, "k8s-secret-sync.weinbender.io/pull-secret-for"},
+		{"PatchStrategy", cfg.Annotations.PatchStrategy, "k8s-secret-sync.weinbender.io/patch-strategy"},
+		{"Rotation", cfg.Annotations.Rotation, "k8s-secret-sync.weinbender.io/rotation"},
+		{"ProviderMetadata", cfg.Annotations.ProviderMetadata, "k8s-secret-sync.weinbender.io/provider-metadata"},
+		{"ProviderVersion", cfg.Annotations.ProviderVersion, "k8s-secret-sync.weinbender.io/provider-version"},
+		{"Binary", cfg.Annotations.Binary, "k8s-secret-sync.weinbender.io/binary"},
+		{"BreakGlass", cfg.Annotations.BreakGlass, "k8s-secret-sync.weinbender.io/break-glass"},
+		{"BreakGlassReason", cfg.Annotations.BreakGlassReason, "k8s-secret-sync.weinbender.io/break-glass-reason"},
+		{"BreakGlassTTL", cfg.Annotations.BreakGlassTTL, "k8s-secret-sync.weinbender.io/break-glass-ttl"},
+		{"ShareWith", cfg.Annotations.ShareWith, "k8s-secret-sync.weinbender.io/share-with"},
 		{"DefaultSecretDataKey", cfg.DefaultSecretDataKey, "value"},
+		{"MetricsAddr", cfg.MetricsAddr, ":8080"},
+		{"ReloaderAnnotations", cfg.ReloaderAnnotations, "reloader.stakater.com/match=true"},
+		{"Notifiers", cfg.Notifiers, "events"},
+		{"CacheBackend", cfg.CacheBackend, "memory"},
+		{"CacheRedisAddr", cfg.CacheRedisAddr, "localhost:6379"},
+		{"MetricsSecretLabels", cfg.MetricsSecretLabels, "secret"},
 	}
 	for _, c := range cases {
 		if c.got != c.want {
@@ -30,6 +69,87 @@ func TestNewDefaults(t *testing.T) {
 	if cfg.PollInterval != 300 {
 		t.Errorf("PollInterval = %d, want 300", cfg.PollInterval)
 	}
+	if cfg.CacheTTL != 0 {
+		t.Errorf("CacheTTL = %d, want 0", cfg.CacheTTL)
+	}
+	if cfg.VerifyInterval != 300 {
+		t.Errorf("VerifyInterval = %d, want 300", cfg.VerifyInterval)
+	}
+	if cfg.Workers != 2 {
+		t.Errorf("Workers = %d, want 2", cfg.Workers)
+	}
+	if cfg.MaxSecretKeys != 0 {
+		t.Errorf("MaxSecretKeys = %d, want 0", cfg.MaxSecretKeys)
+	}
+	if cfg.SizeWarningPercent != 90 {
+		t.Errorf("SizeWarningPercent = %d, want 90", cfg.SizeWarningPercent)
+	}
+	if cfg.SummaryInterval != 60 {
+		t.Errorf("SummaryInterval = %d, want 60", cfg.SummaryInterval)
+	}
+	if cfg.HealthCheckInterval != 30 {
+		t.Errorf("HealthCheckInterval = %d, want 30", cfg.HealthCheckInterval)
+	}
+	if cfg.EventBurst != 10 || cfg.EventRefillInterval != 300 {
+		t.Errorf("EventBurst, EventRefillInterval = %d, %d; want 10, 300", cfg.EventBurst, cfg.EventRefillInterval)
+	}
+	if cfg.HistoryLimit != 10 {
+		t.Errorf("HistoryLimit = %d, want 10", cfg.HistoryLimit)
+	}
+	if cfg.MaxRefreshSlowdown != 8 {
+		t.Errorf("MaxRefreshSlowdown = %d, want 8", cfg.MaxRefreshSlowdown)
+	}
+	if !cfg.CacheRedisTLS {
+		t.Errorf("CacheRedisTLS = false, want true")
+	}
+	if cfg.CheckpointConfigMap != "" {
+		t.Errorf("CheckpointConfigMap = %q, want empty", cfg.CheckpointConfigMap)
+	}
+	if cfg.SidecarPath != "" || cfg.Namespace != "" {
+		t.Errorf("SidecarPath, Namespace = %q, %q; want empty", cfg.SidecarPath, cfg.Namespace)
+	}
+	if cfg.SidecarReloadSignal != "SIGHUP" {
+		t.Errorf("SidecarReloadSignal = %q, want SIGHUP", cfg.SidecarReloadSignal)
+	}
+	if cfg.Providers != "op" {
+		t.Errorf("Providers = %q, want op", cfg.Providers)
+	}
+	if cfg.AWSSTSDuration != 3600 || cfg.AWSSTSSessionName != "k8s-secret-sync" {
+		t.Errorf("AWSSTSDuration, AWSSTSSessionName = %d, %q; want 3600, k8s-secret-sync", cfg.AWSSTSDuration, cfg.AWSSTSSessionName)
+	}
+	if cfg.GCPKeyRotation != 86400 || cfg.GCPKeyGracePeriod != 3600 {
+		t.Errorf("GCPKeyRotation, GCPKeyGracePeriod = %d, %d; want 86400, 3600", cfg.GCPKeyRotation, cfg.GCPKeyGracePeriod)
+	}
+	if cfg.CanaryMaxHold != 86400 {
+		t.Errorf("CanaryMaxHold = %d, want 86400", cfg.CanaryMaxHold)
+	}
+	if cfg.GCPKeyRecordSecret != "k8s-secret-sync-gcp-keys" {
+		t.Errorf("GCPKeyRecordSecret = %q, want k8s-secret-sync-gcp-keys", cfg.GCPKeyRecordSecret)
+	}
+	if cfg.Refresh {
+		t.Errorf("Refresh = true, want false")
+	}
+	if cfg.WorkloadInjection {
+		t.Errorf("WorkloadInjection = true, want false")
+	}
+	if cfg.SecretBootstrap {
+		t.Errorf("SecretBootstrap = true, want false")
+	}
+	if cfg.ObserveOnly {
+		t.Errorf("ObserveOnly = true, want false")
+	}
+	if cfg.SyncDeadline != 900 {
+		t.Errorf("SyncDeadline = %d, want 900", cfg.SyncDeadline)
+	}
+	if cfg.PatchStrategy != "strategic-merge" {
+		t.Errorf("PatchStrategy = %q, want strategic-merge", cfg.PatchStrategy)
+	}
+	if cfg.DedupeWindow != 0 {
+		t.Errorf("DedupeWindow = %d, want 0", cfg.DedupeWindow)
+	}
+	if cfg.ProtectedNamespaces != "kube-*" || cfg.AllowedNamespaces != "" {
+		t.Errorf("ProtectedNamespaces, AllowedNamespaces = %q, %q; want kube-*, empty", cfg.ProtectedNamespaces, cfg.AllowedNamespaces)
+	}
 }
 
 func TestNewOverrides(t *testing.T) {
@@ -39,6 +159,7 @@ func TestNewOverrides(t *testing.T) {
 	t.Setenv("KSS_SECRET_ANNOTATION_KEY_SECRET_KEY", "custom/key")
 	t.Setenv("KSS_DEFAULT_SECRET_DATA_KEY", "customval")
 	t.Setenv("KSS_POLL_INTERVAL", "123")
+	t.Setenv("KSS_CACHE_TTL", "60")
 
 	cfg := New(&kubernetes.Clientset{})
 	if cfg.Annotations.ProviderName != "custom/provider" {
@@ -56,6 +177,9 @@ func TestNewOverrides(t *testing.T) {
 	if cfg.PollInterval != 123 {
 		t.Errorf("PollInterval = %d", cfg.PollInterval)
 	}
+	if cfg.CacheTTL != 60 {
+		t.Errorf("CacheTTL = %d", cfg.CacheTTL)
+	}
 }
 
 func TestNewInvalidPollInterval(t *testing.T) {
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Variables returns the environment variables the operator is configured with,
+// mapped to their defaults.
+func Variables() map[string]string {
+	New(nil)
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+	return maps.Clone(defaults)
+}
+
+// envVarName returns the environment variable for a camelCase values key, e.g.
+// KSS_POLL_INTERVAL for "pollInterval" and KSS_CACHE_TTL for "cacheTTL".
+func envVarName(key string) string {
+	runes := []rune(key)
+	var name strings.Builder
+	name.WriteString("KSS_")
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				name.WriteByte('_')
+			}
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
+
+// EnvFromValues converts a values-style YAML mapping of camelCase keys, e.g.
+// "pollInterval: 60", into the environment variables configuring the operator. It
+// fails on keys that do not correspond to a setting, so chart values cannot drift
+// from the settings the binary reads.
+func EnvFromValues(values []byte) (map[string]string, error) {
+	var parsed map[string]any
+	if err := yaml.Unmarshal(values, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing values: %w", err)
+	}
+	known := Variables()
+	env := make(map[string]string, len(parsed))
+	var unknown []string
+	for key, value := range parsed {
+		name := envVarName(key)
+		if _, ok := known[name]; !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+		switch value.(type) {
+		case map[string]any, []any:
+			return nil, fmt.Errorf("value of %s must be a scalar", key)
+		case nil:
+			env[name] = ""
+		case float64:
+			env[name] = strconv.FormatFloat(value.(float64), 'f', -1, 64)
+		default:
+			env[name] = fmt.Sprint(value)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return nil, fmt.Errorf("unknown settings: %s", strings.Join(unknown, ", "))
+	}
+	return env, nil
+}
+
+// RenderEnv writes env as sorted NAME=value lines.
+func RenderEnv(env map[string]string) []byte {
+	var out strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		fmt.Fprintf(&out, "%s=%s\n", name, env[name])
+	}
+	return []byte(out.String())
+}
+
+// RenderConfigMap returns env as a ConfigMap manifest, for use with envFrom.
+func RenderConfigMap(env map[string]string, name string) ([]byte, error) {
+	return yaml.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": name},
+		"data":       env,
+	})
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvVarName(t *testing.T) {
+	cases := map[string]string{
+		"pollInterval":                    "KSS_POLL_INTERVAL",
+		"cacheTTL":                        "KSS_CACHE_TTL",
+		"awsStsDuration":                  "KSS_AWS_STS_DURATION",
+		"secretAnnotationKeyProviderName": "KSS_SECRET_ANNOTATION_KEY_PROVIDER_NAME",
+	}
+	for key, want := range cases {
+		if got := envVarName(key); got != want {
+			t.Errorf("envVarName(%q) = %s, want %s", key, got, want)
+		}
+	}
+}
+
+func TestEnvFromValues(t *testing.T) {
+	env, err := EnvFromValues([]byte("pollInterval: 60\nproviders: op,aws-sts\nworkloadInjection: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "KSS_POLL_INTERVAL=60\nKSS_PROVIDERS=op,aws-sts\nKSS_WORKLOAD_INJECTION=true\n"
+	if got := string(RenderEnv(env)); got != want {
+		t.Errorf("RenderEnv = %q, want %q", got, want)
+	}
+
+	configMap, err := RenderConfigMap(env, "k8s-secret-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(configMap), "kind: ConfigMap") || !strings.Contains(string(configMap), `KSS_POLL_INTERVAL: "60"`) {
+		t.Errorf("RenderConfigMap = %s", configMap)
+	}
+}
+
+func TestEnvFromValuesRejectsUnknownSettings(t *testing.T) {
+	_, err := EnvFromValues([]byte("pollInterval: 60\npollIntervall: 30\n"))
+	if err == nil || !strings.Contains(err.Error(), "pollIntervall") {
+		t.Errorf("EnvFromValues = %v, want error naming the unknown setting", err)
+	}
+}
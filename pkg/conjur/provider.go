@@ -0,0 +1,197 @@
+// Package conjur implements a secret provider that reads variables from CyberArk
+// Conjur, authenticating as a Conjur host identity with its API key.
+package conjur
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+// tokenLifetime is how long an access token is reused. Conjur tokens expire after
+// eight minutes, so they are replaced well before then.
+const tokenLifetime = 5 * time.Minute
+
+func init() {
+	provider.Register(provider.Info{
+		Name:           "conjur",
+		RequiredConfig: []string{"KSS_CONJUR_URL", "KSS_CONJUR_ACCOUNT", "KSS_CONJUR_AUTHN_LOGIN", "KSS_CONJUR_API_KEY"},
+		Capabilities:   provider.Capabilities{Binary: true, Versioning: true},
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			client, err := httpClient(cfg.ConjurCACertFile)
+			if err != nil {
+				return nil, err
+			}
+			return SecretProvider{
+				URL:     cfg.ConjurURL,
+				Account: cfg.ConjurAccount,
+				Login:   cfg.ConjurAuthnLogin,
+				APIKey:  cfg.ConjurAPIKey,
+				HTTP:    client,
+				tokens:  shared,
+			}, nil
+		},
+	})
+}
+
+// shared caches access tokens between the providers created for each request.
+var shared = &tokenCache{}
+
+// SecretProvider resolves refs that are Conjur variable IDs, e.g. "prod/db/password",
+// to the variable's value. A pinned version reads that version of the variable.
+type SecretProvider struct {
+	URL     string // URL of the Conjur server, e.g. "https://conjur.example.com"
+	Account string // Conjur organization account
+	Login   string // host identity to authenticate as, e.g. "host/k8s-secret-sync"
+	APIKey  string // API key of the host identity
+	HTTP    *http.Client
+
+	tokens *tokenCache
+}
+
+// tokenCache holds the last access token obtained, with the identity it is for.
+type tokenCache struct {
+	mu       gosync.Mutex
+	identity string
+	token    string
+	expires  time.Time
+}
+
+func (p SecretProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	if req.Ref == "" {
+		return provider.Response{}, errors.New("invalid conjur ref, expected a variable ID")
+	}
+	endpoint := fmt.Sprintf("%s/secrets/%s/variable/%s", strings.TrimSuffix(p.URL, "/"), url.PathEscape(p.Account), url.PathEscape(req.Ref))
+	if req.Version != "" {
+		if _, err := strconv.Atoi(req.Version); err != nil {
+			return provider.Response{}, fmt.Errorf("invalid conjur version %q, expected a version number", req.Version)
+		}
+		endpoint += "?version=" + req.Version
+	}
+
+	value, err := p.get(ctx, endpoint)
+	if errors.Is(err, provider.ErrUnauthorized) {
+		// The token may have been revoked or expired early; retry once with a new one
+		p.tokens.clear()
+		value, err = p.get(ctx, endpoint)
+	}
+	if err != nil {
+		return provider.Response{}, err
+	}
+	return provider.Response{Value: value, Version: req.Version}, nil
+}
+
+// HealthCheck authenticates, which fails if Conjur is unreachable or the API key is
+// no longer valid.
+func (p SecretProvider) HealthCheck(ctx context.Context) error {
+	_, err := p.authenticate(ctx)
+	return err
+}
+
+// get fetches endpoint with an access token, returning the response body.
+func (p SecretProvider) get(ctx context.Context, endpoint string) ([]byte, error) {
+	token, err := p.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", `Token token="`+token+`"`)
+	return p.do(req)
+}
+
+// token returns a cached access token, authenticating for a new one if it is missing
+// or due for replacement.
+func (p SecretProvider) token(ctx context.Context) (string, error) {
+	identity := p.URL + "\x00" + p.Account + "\x00" + p.Login
+	p.tokens.mu.Lock()
+	defer p.tokens.mu.Unlock()
+	if p.tokens.identity == identity && time.Now().Before(p.tokens.expires) {
+		return p.tokens.token, nil
+	}
+	token, err := p.authenticate(ctx)
+	if err != nil {
+		return "", err
+	}
+	p.tokens.identity, p.tokens.token, p.tokens.expires = identity, token, time.Now().Add(tokenLifetime)
+	return token, nil
+}
+
+// authenticate exchanges the API key for an access token, encoded for the
+// Authorization header.
+func (p SecretProvider) authenticate(ctx context.Context) (string, error) {
+	endpoint := fmt.Sprintf("%s/authn/%s/%s/authenticate", strings.TrimSuffix(p.URL, "/"), url.PathEscape(p.Account), url.PathEscape(p.Login))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(p.APIKey))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	token, err := p.do(req)
+	if err != nil {
+		return "", fmt.Errorf("authenticating to conjur as %s: %w", p.Login, err)
+	}
+	return base64.StdEncoding.EncodeToString(bytes.TrimSpace(token)), nil
+}
+
+// do sends a request and returns the response body, mapping failures to the shared
+// provider errors.
+func (p SecretProvider) do(req *http.Request) ([]byte, error) {
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", provider.ErrTransient, err)
+	}
+	defer resp.Body.Close()
+
+	if err := provider.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", provider.ErrNotFound, req.URL.Path)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return nil, fmt.Errorf("%s %s: unexpected status %s", req.Method, req.URL.Path, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (c *tokenCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.expires = "", time.Time{}
+}
+
+// httpClient returns a client trusting the CA certificates in caFile, for Conjur
+// servers with a private CA, or the system roots if it is empty.
+func httpClient(caFile string) (*http.Client, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if caFile == "" {
+		return client, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading conjur CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	return client, nil
+}
@@ -0,0 +1,77 @@
+package conjur
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func TestResolve(t *testing.T) {
+	token := `{"protected":"p","payload":"1","signature":"s"}`
+	authorization := `Token token="` + base64.StdEncoding.EncodeToString([]byte(token)) + `"`
+	var authentications int
+	revoked := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/authn/acme/host%2Fk8s-secret-sync/authenticate":
+			if body, _ := io.ReadAll(r.Body); string(body) != "api-key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			authentications++
+			revoked = false
+			w.Write([]byte(token))
+			return
+		}
+		if r.Header.Get("Authorization") != authorization || revoked {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.RequestURI() {
+		case "/secrets/acme/variable/prod%2Fdb%2Fpassword":
+			w.Write([]byte("hunter2"))
+		case "/secrets/acme/variable/prod%2Fdb%2Fpassword?version=1":
+			w.Write([]byte("hunter1"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	p := SecretProvider{URL: server.URL, Account: "acme", Login: "host/k8s-secret-sync", APIKey: "api-key", HTTP: server.Client(), tokens: &tokenCache{}}
+	ctx := context.Background()
+
+	resp, err := p.Resolve(ctx, provider.Request{Ref: "prod/db/password"})
+	if err != nil || string(resp.Value) != "hunter2" {
+		t.Fatalf("Resolve = %q, %v", resp.Value, err)
+	}
+	resp, err = p.Resolve(ctx, provider.Request{Ref: "prod/db/password", Version: "1"})
+	if err != nil || string(resp.Value) != "hunter1" || resp.Version != "1" {
+		t.Errorf("Resolve(version 1) = %q at %q, %v", resp.Value, resp.Version, err)
+	}
+	if authentications != 1 {
+		t.Errorf("authenticated %d times, want the token reused", authentications)
+	}
+
+	// A rejected token is replaced once
+	revoked = true
+	if _, err := p.Resolve(ctx, provider.Request{Ref: "prod/db/password"}); err != nil || authentications != 2 {
+		t.Errorf("Resolve with revoked token = %v after %d authentications", err, authentications)
+	}
+
+	if _, err := p.Resolve(ctx, provider.Request{Ref: "prod/db/missing"}); !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("Resolve(missing) = %v, want ErrNotFound", err)
+	}
+	if err := p.HealthCheck(ctx); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+	p.APIKey = "wrong"
+	if err := p.HealthCheck(ctx); !errors.Is(err, provider.ErrUnauthorized) {
+		t.Errorf("HealthCheck with wrong API key = %v, want ErrUnauthorized", err)
+	}
+}
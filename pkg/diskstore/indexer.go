@@ -0,0 +1,422 @@
+// Package diskstore implements a client-go cache.Indexer backed by a bbolt database,
+// so informers in very large clusters can keep watched objects on disk rather than
+// in memory.
+package diskstore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	gosync "sync"
+
+	bolt "go.etcd.io/bbolt"
+	"k8s.io/apimachinery/pkg/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// objectsBucket holds encoded objects by key. Each index has its own bucket of
+// "<indexed value>\x00<object key>" entries, so lookups are prefix scans.
+var objectsBucket = []byte("objects")
+
+// indexBucket returns the name of the bucket holding the named index.
+func indexBucket(name string) []byte {
+	return []byte("index:" + name)
+}
+
+// Indexer is a cache.Indexer that stores objects as encrypted JSON in a bbolt
+// database. Objects are decoded on every read, trading CPU for memory.
+type Indexer struct {
+	db        *bolt.DB
+	keyFunc   toolscache.KeyFunc
+	newObject func() runtime.Object
+
+	// aead encrypts stored objects under a key that only exists in memory, so Secret
+	// data written to disk is unreadable once the process exits.
+	aead cipher.AEAD
+
+	mu       gosync.RWMutex
+	indexers toolscache.Indexers
+}
+
+var _ toolscache.Indexer = (*Indexer)(nil)
+
+// Open creates an Indexer backed by the database at path, discarding anything left
+// from a previous run since informers relist on start. newObject returns an empty
+// object of the type being stored, to decode into.
+func Open(path string, keyFunc toolscache.KeyFunc, indexers toolscache.Indexers, newObject func() runtime.Object) (*Indexer, error) {
+	aead, err := newAEAD()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening store %q: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			return tx.DeleteBucket(name)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("resetting store %q: %w", path, err)
+	}
+
+	i := &Indexer{db: db, keyFunc: keyFunc, newObject: newObject, aead: aead, indexers: toolscache.Indexers{}}
+	if err := i.AddIndexers(indexers); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return i, nil
+}
+
+// Close closes the underlying database.
+func (i *Indexer) Close() error {
+	return i.db.Close()
+}
+
+// newAEAD returns AES-256-GCM under a freshly generated key.
+func newAEAD() (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generating store key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encode returns obj as JSON sealed with a random nonce, which is prepended.
+func (i *Indexer) encode(obj any) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, i.aead.NonceSize(), i.aead.NonceSize()+len(data)+i.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return i.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (i *Indexer) decode(data []byte) (any, error) {
+	if len(data) < i.aead.NonceSize() {
+		return nil, errors.New("stored object is truncated")
+	}
+	nonce, sealed := data[:i.aead.NonceSize()], data[i.aead.NonceSize():]
+	data, err := i.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting stored object: %w", err)
+	}
+
+	obj := i.newObject()
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// indexEntry returns the key of an index entry for an object.
+func indexEntry(value, key string) []byte {
+	return []byte(value + "\x00" + key)
+}
+
+// putIndexes adds (add true) or removes the index entries of obj under key.
+func (i *Indexer) putIndexes(tx *bolt.Tx, indexers toolscache.Indexers, key string, obj any, add bool) error {
+	for name, indexFunc := range indexers {
+		values, err := indexFunc(obj)
+		if err != nil {
+			return fmt.Errorf("indexing %q with %q: %w", key, name, err)
+		}
+		bucket := tx.Bucket(indexBucket(name))
+		for _, value := range values {
+			if add {
+				err = bucket.Put(indexEntry(value, key), nil)
+			} else {
+				err = bucket.Delete(indexEntry(value, key))
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// put stores obj, replacing any previous version and its index entries.
+func (i *Indexer) put(tx *bolt.Tx, obj any) error {
+	key, err := i.keyFunc(obj)
+	if err != nil {
+		return toolscache.KeyError{Obj: obj, Err: err}
+	}
+	if err := i.remove(tx, key); err != nil {
+		return err
+	}
+
+	data, err := i.encode(obj)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(objectsBucket).Put([]byte(key), data); err != nil {
+		return err
+	}
+	return i.putIndexes(tx, i.indexers, key, obj, true)
+}
+
+// remove deletes the object stored under key, if any, and its index entries.
+func (i *Indexer) remove(tx *bolt.Tx, key string) error {
+	objects := tx.Bucket(objectsBucket)
+	data := objects.Get([]byte(key))
+	if data == nil {
+		return nil
+	}
+	old, err := i.decode(data)
+	if err != nil {
+		return err
+	}
+	if err := i.putIndexes(tx, i.indexers, key, old, false); err != nil {
+		return err
+	}
+	return objects.Delete([]byte(key))
+}
+
+func (i *Indexer) Add(obj any) error {
+	return i.Update(obj)
+}
+
+func (i *Indexer) Update(obj any) error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.db.Update(func(tx *bolt.Tx) error {
+		return i.put(tx, obj)
+	})
+}
+
+func (i *Indexer) Delete(obj any) error {
+	key, err := i.keyFunc(obj)
+	if err != nil {
+		return toolscache.KeyError{Obj: obj, Err: err}
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.db.Update(func(tx *bolt.Tx) error {
+		return i.remove(tx, key)
+	})
+}
+
+func (i *Indexer) List() []any {
+	var list []any
+	_ = i.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(objectsBucket).ForEach(func(_, data []byte) error {
+			if obj, err := i.decode(data); err == nil {
+				list = append(list, obj)
+			}
+			return nil
+		})
+	})
+	return list
+}
+
+func (i *Indexer) ListKeys() []string {
+	var keys []string
+	_ = i.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(objectsBucket).ForEach(func(key, _ []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+	})
+	return keys
+}
+
+func (i *Indexer) Get(obj any) (any, bool, error) {
+	key, err := i.keyFunc(obj)
+	if err != nil {
+		return nil, false, toolscache.KeyError{Obj: obj, Err: err}
+	}
+	return i.GetByKey(key)
+}
+
+func (i *Indexer) GetByKey(key string) (item any, exists bool, err error) {
+	err = i.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(objectsBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		item, err = i.decode(data)
+		exists = err == nil
+		return err
+	})
+	return item, exists, err
+}
+
+func (i *Indexer) Replace(list []any, _ string) error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.db.Update(func(tx *bolt.Tx) error {
+		if err := i.resetBuckets(tx); err != nil {
+			return err
+		}
+		for _, obj := range list {
+			if err := i.put(tx, obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// resetBuckets empties the objects bucket and every index bucket.
+func (i *Indexer) resetBuckets(tx *bolt.Tx) error {
+	names := [][]byte{objectsBucket}
+	for name := range i.indexers {
+		names = append(names, indexBucket(name))
+	}
+	for _, name := range names {
+		if err := tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resync is a no-op; there is nothing to resync for a local store.
+func (i *Indexer) Resync() error {
+	return nil
+}
+
+func (i *Indexer) Index(indexName string, obj any) ([]any, error) {
+	i.mu.RLock()
+	indexFunc, ok := i.indexers[indexName]
+	i.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("index with name %s does not exist", indexName)
+	}
+	values, err := indexFunc(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var items []any
+	for _, value := range values {
+		keys, err := i.IndexKeys(indexName, value)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if item, exists, err := i.GetByKey(key); err == nil && exists {
+				items = append(items, item)
+			}
+		}
+	}
+	return items, nil
+}
+
+func (i *Indexer) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	var keys []string
+	err := i.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(indexBucket(indexName))
+		if bucket == nil {
+			return fmt.Errorf("index with name %s does not exist", indexName)
+		}
+		prefix := []byte(indexedValue + "\x00")
+		c := bucket.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, string(k[len(prefix):]))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (i *Indexer) ListIndexFuncValues(indexName string) []string {
+	var values []string
+	_ = i.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(indexBucket(indexName))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, _ []byte) error {
+			value, _, _ := bytes.Cut(k, []byte{0})
+			if len(values) == 0 || values[len(values)-1] != string(value) {
+				values = append(values, string(value))
+			}
+			return nil
+		})
+	})
+	return values
+}
+
+func (i *Indexer) ByIndex(indexName, indexedValue string) ([]any, error) {
+	keys, err := i.IndexKeys(indexName, indexedValue)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]any, 0, len(keys))
+	for _, key := range keys {
+		item, exists, err := i.GetByKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (i *Indexer) GetIndexers() toolscache.Indexers {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return maps.Clone(i.indexers)
+}
+
+// AddIndexers adds new indexes, indexing any objects already stored.
+func (i *Indexer) AddIndexers(newIndexers toolscache.Indexers) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for name := range newIndexers {
+		if _, exists := i.indexers[name]; exists {
+			return fmt.Errorf("indexer conflict: %s", name)
+		}
+	}
+
+	err := i.db.Update(func(tx *bolt.Tx) error {
+		objects, err := tx.CreateBucketIfNotExists(objectsBucket)
+		if err != nil {
+			return err
+		}
+		for name := range newIndexers {
+			if _, err := tx.CreateBucketIfNotExists(indexBucket(name)); err != nil {
+				return err
+			}
+		}
+		return objects.ForEach(func(key, data []byte) error {
+			obj, err := i.decode(data)
+			if err != nil {
+				return err
+			}
+			return i.putIndexes(tx, newIndexers, string(key), obj, true)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	maps.Copy(i.indexers, newIndexers)
+	return nil
+}
@@ -0,0 +1,145 @@
+package diskstore
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func secret(namespace, name, team string) *v1.Secret {
+	return &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace: namespace,
+		Name:      name,
+		Labels:    map[string]string{"team": team},
+	}}
+}
+
+func byTeam(obj any) ([]string, error) {
+	return []string{obj.(*v1.Secret).Labels["team"]}, nil
+}
+
+func openIndexer(t *testing.T) *Indexer {
+	t.Helper()
+	i, err := Open(filepath.Join(t.TempDir(), "store.db"), toolscache.MetaNamespaceKeyFunc,
+		toolscache.Indexers{"team": byTeam}, func() runtime.Object { return &v1.Secret{} })
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { i.Close() })
+	return i
+}
+
+func names(items []any) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.(*v1.Secret).Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestIndexerStoreAndIndex(t *testing.T) {
+	i := openIndexer(t)
+	for _, s := range []*v1.Secret{secret("a", "one", "payments"), secret("a", "two", "payments"), secret("b", "three", "search")} {
+		if err := i.Add(s); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	item, exists, err := i.GetByKey("a/one")
+	if err != nil || !exists || item.(*v1.Secret).Labels["team"] != "payments" {
+		t.Fatalf("GetByKey = %v, %v, %v", item, exists, err)
+	}
+	items, err := i.ByIndex("team", "payments")
+	if err != nil {
+		t.Fatalf("ByIndex: %v", err)
+	}
+	if got, want := names(items), []string{"one", "two"}; !slices.Equal(got, want) {
+		t.Errorf("ByIndex = %v, want %v", got, want)
+	}
+
+	// Updates move objects between index values
+	if err := i.Update(secret("a", "two", "search")); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	keys, _ := i.IndexKeys("team", "search")
+	if got, want := keys, []string{"a/two", "b/three"}; !slices.Equal(got, want) {
+		t.Errorf("IndexKeys after update = %v, want %v", got, want)
+	}
+	if got, want := i.ListIndexFuncValues("team"), []string{"payments", "search"}; !slices.Equal(got, want) {
+		t.Errorf("ListIndexFuncValues = %v, want %v", got, want)
+	}
+
+	if err := i.Delete(secret("a", "one", "payments")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, exists, _ := i.GetByKey("a/one"); exists {
+		t.Errorf("expected deleted object to be gone")
+	}
+	if keys, _ := i.IndexKeys("team", "payments"); len(keys) != 0 {
+		t.Errorf("expected deleted object to be unindexed, got %v", keys)
+	}
+	if got, want := names(i.List()), []string{"three", "two"}; !slices.Equal(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+}
+
+func TestIndexerReplaceAndAddIndexers(t *testing.T) {
+	i := openIndexer(t)
+	if err := i.Add(secret("a", "stale", "payments")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := i.Replace([]any{secret("a", "fresh", "search")}, "1"); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if got, want := i.ListKeys(), []string{"a/fresh"}; !slices.Equal(got, want) {
+		t.Errorf("ListKeys = %v, want %v", got, want)
+	}
+
+	// Indexes added later cover objects already stored
+	err := i.AddIndexers(toolscache.Indexers{"namespace": toolscache.MetaNamespaceIndexFunc})
+	if err != nil {
+		t.Fatalf("AddIndexers: %v", err)
+	}
+	items, err := i.ByIndex("namespace", "a")
+	if err != nil {
+		t.Fatalf("ByIndex: %v", err)
+	}
+	if got, want := names(items), []string{"fresh"}; !slices.Equal(got, want) {
+		t.Errorf("ByIndex = %v, want %v", got, want)
+	}
+	if err := i.AddIndexers(toolscache.Indexers{"team": byTeam}); err == nil {
+		t.Errorf("expected conflict adding an existing index")
+	}
+}
+
+func TestIndexerEncryptsStoredObjects(t *testing.T) {
+	i := openIndexer(t)
+	s := secret("a", "one", "payments")
+	s.Data = map[string][]byte{"password": []byte("hunter2")}
+	if err := i.Add(s); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	err := i.db.View(func(tx *bolt.Tx) error {
+		if stored := tx.Bucket(objectsBucket).Get([]byte("a/one")); bytes.Contains(stored, []byte("aHVudGVyMg")) || bytes.Contains(stored, []byte("payments")) {
+			t.Errorf("expected the stored object to be encrypted, got %q", stored)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading store: %v", err)
+	}
+
+	item, exists, err := i.GetByKey("a/one")
+	if err != nil || !exists || string(item.(*v1.Secret).Data["password"]) != "hunter2" {
+		t.Errorf("GetByKey = %v, %v, %v", item, exists, err)
+	}
+}
@@ -0,0 +1,89 @@
+// Package eso converts between External Secrets Operator ExternalSecret resources
+// and secrets annotated for k8s-secret-sync, for migrating between the two and
+// evaluating them side by side.
+package eso
+
+import (
+	"bytes"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+// APIVersion is the ExternalSecret API version read and written.
+const APIVersion = "external-secrets.io/v1beta1"
+
+// ExternalSecret is the subset of an External Secrets Operator ExternalSecret that
+// maps onto k8s-secret-sync annotations.
+type ExternalSecret struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Metadata   Metadata `json:"metadata"`
+	Spec       Spec     `json:"spec"`
+}
+
+// Metadata is the object metadata carried over between resources.
+type Metadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Spec is the desired state of an ExternalSecret.
+type Spec struct {
+	RefreshInterval string         `json:"refreshInterval,omitempty"`
+	SecretStoreRef  SecretStoreRef `json:"secretStoreRef"`
+	Target          Target         `json:"target,omitempty"`
+	Data            []Data         `json:"data,omitempty"`
+	DataFrom        []any          `json:"dataFrom,omitempty"`
+}
+
+// SecretStoreRef names the SecretStore values are fetched from.
+type SecretStoreRef struct {
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"`
+}
+
+// Target describes the Secret an ExternalSecret writes.
+type Target struct {
+	Name string `json:"name,omitempty"`
+}
+
+// Data maps one remote value to a key of the target Secret.
+type Data struct {
+	SecretKey string    `json:"secretKey"`
+	RemoteRef RemoteRef `json:"remoteRef"`
+}
+
+// RemoteRef identifies a value in the secret store, optionally a property of it.
+type RemoteRef struct {
+	Key      string `json:"key"`
+	Property string `json:"property,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// splitDocuments splits a multi-document YAML stream.
+func splitDocuments(data []byte) [][]byte {
+	var docs [][]byte
+	for _, doc := range bytes.Split(append([]byte("\n"), data...), []byte("\n---")) {
+		if len(bytes.TrimSpace(doc)) > 0 {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// joinDocuments marshals objects into a multi-document YAML stream.
+func joinDocuments[T any](objects []T) ([]byte, error) {
+	var out bytes.Buffer
+	for i, obj := range objects {
+		doc, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling document %d: %w", i, err)
+		}
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(doc)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,105 @@
+package eso
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/transform"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ExportOptions controls the SecretStore and remote keys of exported ExternalSecrets.
+type ExportOptions struct {
+	Provider        string // provider backing the SecretStore; secrets from other providers are skipped
+	SecretStore     string // name of the SecretStore the ExternalSecrets fetch from
+	SecretStoreKind string // "SecretStore" or "ClusterSecretStore"
+	RefPrefix       string // removed from the start of each ref, e.g. "op://vault/"
+	RefreshInterval string // e.g. "5m"; empty leaves the ESO default
+	DefaultKey      string // data key of secrets without a secret-key annotation
+}
+
+// Export converts the secrets annotated for sync from opts.Provider into
+// ExternalSecret manifests fetching from opts.SecretStore. Secrets synced from other
+// providers, or using features ExternalSecrets cannot express with plain data entries
+// (transforms, templates, or ConfigMap refs), are skipped and reported in warnings;
+// secrets not annotated for sync are ignored.
+func Export(secrets []v1.Secret, annotations config.Annotations, opts ExportOptions) (out []byte, warnings []string, err error) {
+	var externalSecrets []ExternalSecret
+	for _, secret := range secrets {
+		providerName := secret.Annotations[annotations.ProviderName]
+		if providerName == "" || secret.Annotations[annotations.ProviderRef] == "" {
+			continue
+		}
+		if canonicalName(providerName) != canonicalName(opts.Provider) {
+			warnings = append(warnings, fmt.Sprintf("skipped secret %s/%s: synced from provider %q, not %q", secret.Namespace, secret.Name, providerName, opts.Provider))
+			continue
+		}
+		es, err := exportSecret(secret, annotations, opts)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("skipped secret %s/%s: %v", secret.Namespace, secret.Name, err))
+			continue
+		}
+		externalSecrets = append(externalSecrets, es)
+	}
+	out, err = joinDocuments(externalSecrets)
+	return out, warnings, err
+}
+
+// canonicalName returns the registered name of the provider called name, which may be
+// an alias, or name itself if no such provider is registered.
+func canonicalName(name string) string {
+	if info, ok := provider.Lookup(name); ok {
+		return info.Name
+	}
+	return name
+}
+
+// exportSecret returns the ExternalSecret equivalent to an annotated secret.
+func exportSecret(secret v1.Secret, annotations config.Annotations, opts ExportOptions) (ExternalSecret, error) {
+	for _, unsupported := range []string{annotations.Transform, annotations.Template, annotations.TemplateConfigMap} {
+		if secret.Annotations[unsupported] != "" {
+			return ExternalSecret{}, fmt.Errorf("%s has no ExternalSecret equivalent", unsupported)
+		}
+	}
+	ref := secret.Annotations[annotations.ProviderRef]
+	if strings.HasPrefix(ref, "configmap:") {
+		return ExternalSecret{}, fmt.Errorf("refs to ConfigMap sync configuration are not supported")
+	}
+	remote := RemoteRef{
+		Key:     strings.TrimPrefix(ref, opts.RefPrefix),
+		Version: secret.Annotations[annotations.ProviderVersion],
+	}
+
+	var data []Data
+	if spec := secret.Annotations[annotations.KeyMapping]; spec != "" {
+		keyMap, err := transform.ParseKeyMap(spec)
+		if err != nil {
+			return ExternalSecret{}, err
+		}
+		for _, entry := range keyMap {
+			property := remote
+			property.Property = strings.TrimPrefix(entry.Path, ".")
+			data = append(data, Data{SecretKey: entry.Key, RemoteRef: property})
+		}
+	} else {
+		key := secret.Annotations[annotations.SecretKey]
+		if key == "" {
+			key = opts.DefaultKey
+		}
+		data = append(data, Data{SecretKey: key, RemoteRef: remote})
+	}
+
+	return ExternalSecret{
+		APIVersion: APIVersion,
+		Kind:       "ExternalSecret",
+		Metadata:   Metadata{Name: secret.Name, Namespace: secret.Namespace},
+		Spec: Spec{
+			RefreshInterval: opts.RefreshInterval,
+			SecretStoreRef:  SecretStoreRef{Name: opts.SecretStore, Kind: opts.SecretStoreKind},
+			Target:          Target{Name: secret.Name},
+			Data:            data,
+		},
+	}, nil
+}
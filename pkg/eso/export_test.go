@@ -0,0 +1,76 @@
+package eso
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestExport(t *testing.T) {
+	annotations := config.New(nil).Annotations
+	secret := func(name string, extra map[string]string) v1.Secret {
+		a := map[string]string{
+			annotations.ProviderName: "op",
+			annotations.ProviderRef:  "op://vault/" + name,
+		}
+		for k, v := range extra {
+			a[k] = v
+		}
+		return v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name, Annotations: a}}
+	}
+	secrets := []v1.Secret{
+		secret("db", map[string]string{annotations.KeyMapping: "username=.user,password=.pass"}),
+		secret("api-key", nil),
+		secret("env", map[string]string{annotations.Transform: "dotenv"}),
+		secret("cloud", map[string]string{annotations.ProviderName: "awssm"}),
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "unmanaged"}},
+	}
+
+	out, warnings, err := Export(secrets, annotations, ExportOptions{
+		Provider: "op", SecretStore: "onepassword", SecretStoreKind: "ClusterSecretStore", RefPrefix: "op://vault/", DefaultKey: "value",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "team-a/env") || !strings.Contains(warnings[1], "team-a/cloud") {
+		t.Errorf("warnings = %q, want the transformed and other provider's secrets skipped", warnings)
+	}
+
+	docs := splitDocuments(out)
+	if len(docs) != 2 {
+		t.Fatalf("got %d documents, want 2:\n%s", len(docs), out)
+	}
+	var db, api ExternalSecret
+	if err := yaml.Unmarshal(docs[0], &db); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(docs[1], &api); err != nil {
+		t.Fatal(err)
+	}
+	if db.Spec.SecretStoreRef != (SecretStoreRef{Name: "onepassword", Kind: "ClusterSecretStore"}) {
+		t.Errorf("store = %+v", db.Spec.SecretStoreRef)
+	}
+	want := []Data{
+		{SecretKey: "username", RemoteRef: RemoteRef{Key: "db", Property: "user"}},
+		{SecretKey: "password", RemoteRef: RemoteRef{Key: "db", Property: "pass"}},
+	}
+	if len(db.Spec.Data) != 2 || db.Spec.Data[0] != want[0] || db.Spec.Data[1] != want[1] {
+		t.Errorf("db data = %+v, want %+v", db.Spec.Data, want)
+	}
+	if len(api.Spec.Data) != 1 || api.Spec.Data[0] != (Data{SecretKey: "value", RemoteRef: RemoteRef{Key: "api-key"}}) {
+		t.Errorf("api data = %+v", api.Spec.Data)
+	}
+
+	// Importing the export gives back the original annotations
+	back, _, err := Import(out, annotations, ImportOptions{Provider: "op", RefPrefix: "op://vault/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(back), "username=.user,password=.pass") {
+		t.Errorf("round trip lost the key mapping:\n%s", back)
+	}
+}
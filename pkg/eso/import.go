@@ -0,0 +1,91 @@
+package eso
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"sigs.k8s.io/yaml"
+)
+
+// ImportOptions controls how ExternalSecret remote refs become provider refs.
+type ImportOptions struct {
+	Provider  string // provider name for the imported secrets, e.g. "op"
+	RefPrefix string // prepended to each remote key, e.g. "op://vault/"
+}
+
+// Import converts the ExternalSecret resources in a multi-document YAML stream
+// into Secret manifests annotated for sync. ExternalSecrets that cannot be
+// expressed as annotations (dataFrom, or data from more than one remote key) are
+// skipped and reported in warnings; other documents are ignored.
+func Import(manifests []byte, annotations config.Annotations, opts ImportOptions) (out []byte, warnings []string, err error) {
+	var secrets []map[string]any
+	for i, doc := range splitDocuments(manifests) {
+		var es ExternalSecret
+		if err := yaml.Unmarshal(doc, &es); err != nil {
+			return nil, nil, fmt.Errorf("parsing document %d: %w", i, err)
+		}
+		if es.Kind != "ExternalSecret" {
+			continue
+		}
+		secret, err := importExternalSecret(es, annotations, opts)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("skipped ExternalSecret %s/%s: %v", es.Metadata.Namespace, es.Metadata.Name, err))
+			continue
+		}
+		secrets = append(secrets, secret)
+	}
+	out, err = joinDocuments(secrets)
+	return out, warnings, err
+}
+
+// importExternalSecret returns the annotated Secret equivalent to es.
+func importExternalSecret(es ExternalSecret, annotations config.Annotations, opts ImportOptions) (map[string]any, error) {
+	if len(es.Spec.DataFrom) > 0 {
+		return nil, fmt.Errorf("dataFrom is not supported")
+	}
+	if len(es.Spec.Data) == 0 {
+		return nil, fmt.Errorf("no data")
+	}
+
+	remote := es.Spec.Data[0].RemoteRef
+	for _, data := range es.Spec.Data[1:] {
+		if data.RemoteRef.Key != remote.Key || data.RemoteRef.Version != remote.Version {
+			return nil, fmt.Errorf("data comes from more than one remote key")
+		}
+	}
+
+	secretAnnotations := map[string]string{
+		annotations.ProviderName: opts.Provider,
+		annotations.ProviderRef:  opts.RefPrefix + remote.Key,
+	}
+	if remote.Version != "" {
+		secretAnnotations[annotations.ProviderVersion] = remote.Version
+	}
+	if len(es.Spec.Data) == 1 && remote.Property == "" {
+		secretAnnotations[annotations.SecretKey] = es.Spec.Data[0].SecretKey
+	} else {
+		var mapping []string
+		for _, data := range es.Spec.Data {
+			if data.RemoteRef.Property == "" {
+				return nil, fmt.Errorf("data key %s needs a property to be combined with other keys", data.SecretKey)
+			}
+			mapping = append(mapping, data.SecretKey+"=."+data.RemoteRef.Property)
+		}
+		secretAnnotations[annotations.KeyMapping] = strings.Join(mapping, ",")
+	}
+
+	name := es.Spec.Target.Name
+	if name == "" {
+		name = es.Metadata.Name
+	}
+	metadata := map[string]any{"name": name, "annotations": secretAnnotations}
+	if es.Metadata.Namespace != "" {
+		metadata["namespace"] = es.Metadata.Namespace
+	}
+	return map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   metadata,
+	}, nil
+}
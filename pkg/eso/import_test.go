@@ -0,0 +1,105 @@
+package eso
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"sigs.k8s.io/yaml"
+)
+
+const externalSecrets = `apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: db
+  namespace: team-a
+spec:
+  secretStoreRef:
+    name: onepassword
+  target:
+    name: db-credentials
+  data:
+  - secretKey: username
+    remoteRef:
+      key: db
+      property: user
+  - secretKey: password
+    remoteRef:
+      key: db
+      property: pass
+---
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: api-key
+  namespace: team-a
+spec:
+  secretStoreRef:
+    name: onepassword
+  data:
+  - secretKey: token
+    remoteRef:
+      key: api/token
+      version: "3"
+---
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: everything
+  namespace: team-a
+spec:
+  dataFrom:
+  - extract:
+      key: everything
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: unrelated
+`
+
+func TestImport(t *testing.T) {
+	annotations := config.New(nil).Annotations
+	out, warnings, err := Import([]byte(externalSecrets), annotations, ImportOptions{Provider: "op", RefPrefix: "op://vault/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "team-a/everything") {
+		t.Errorf("warnings = %q, want the dataFrom ExternalSecret skipped", warnings)
+	}
+
+	docs := splitDocuments(out)
+	if len(docs) != 2 {
+		t.Fatalf("got %d documents, want 2:\n%s", len(docs), out)
+	}
+	var db, api struct {
+		Metadata struct {
+			Name        string            `json:"name"`
+			Namespace   string            `json:"namespace"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := yaml.Unmarshal(docs[0], &db); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(docs[1], &api); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.Metadata.Name != "db-credentials" || db.Metadata.Namespace != "team-a" {
+		t.Errorf("db secret = %s/%s, want team-a/db-credentials", db.Metadata.Namespace, db.Metadata.Name)
+	}
+	if got := db.Metadata.Annotations[annotations.ProviderRef]; got != "op://vault/db" {
+		t.Errorf("db ref = %q", got)
+	}
+	if got := db.Metadata.Annotations[annotations.KeyMapping]; got != "username=.user,password=.pass" {
+		t.Errorf("db key mapping = %q", got)
+	}
+
+	if got := api.Metadata.Annotations[annotations.SecretKey]; got != "token" {
+		t.Errorf("api secret key = %q, want token", got)
+	}
+	if got := api.Metadata.Annotations[annotations.ProviderVersion]; got != "3" {
+		t.Errorf("api version = %q, want 3", got)
+	}
+}
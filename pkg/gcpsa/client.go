@@ -0,0 +1,135 @@
+package gcpsa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	gosync "sync"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	defaultEndpoint = "https://iam.googleapis.com/v1/"
+	cloudPlatform   = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// clients caches the operator's client between the providers created for each
+// request, so credentials are only loaded once and access tokens are reused until
+// they expire.
+var clients = &clientCache{}
+
+// clientCache holds the operator's client.
+type clientCache struct {
+	mu       gosync.Mutex
+	operator *HTTPClient
+}
+
+// operatorClient returns the client using Application Default Credentials, creating
+// it on first use. It outlives the request creating it, so its tokens are fetched
+// with a background context.
+func (c *clientCache) operatorClient() (*HTTPClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.operator != nil {
+		return c.operator, nil
+	}
+	client, err := NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	c.operator = client
+	return client, nil
+}
+
+// HTTPClient calls the IAM REST API.
+type HTTPClient struct {
+	Endpoint string
+	HTTP     *http.Client
+	Tokens   oauth2.TokenSource
+}
+
+// NewClient returns an IAM client using Application Default Credentials.
+func NewClient(ctx context.Context) (*HTTPClient, error) {
+	tokens, err := google.DefaultTokenSource(ctx, cloudPlatform)
+	if err != nil {
+		return nil, fmt.Errorf("loading GCP credentials: %w", err)
+	}
+	return &HTTPClient{
+		Endpoint: defaultEndpoint,
+		HTTP:     oauth2.NewClient(ctx, tokens),
+		Tokens:   tokens,
+	}, nil
+}
+
+func (c *HTTPClient) CreateKey(ctx context.Context, serviceAccount string) (*Key, error) {
+	var key Key
+	body := strings.NewReader(`{"privateKeyType":"TYPE_GOOGLE_CREDENTIALS_FILE"}`)
+	if err := c.do(ctx, http.MethodPost, keysPath(serviceAccount), body, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (c *HTTPClient) ListKeys(ctx context.Context, serviceAccount string) ([]Key, error) {
+	var list struct {
+		Keys []Key `json:"keys"`
+	}
+	if err := c.do(ctx, http.MethodGet, keysPath(serviceAccount)+"?keyTypes=USER_MANAGED", nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Keys, nil
+}
+
+func (c *HTTPClient) DeleteKey(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, name, nil, nil)
+}
+
+// HealthCheck fetches an access token, which fails if the operator's credentials
+// are missing or invalid.
+func (c *HTTPClient) HealthCheck(context.Context) error {
+	if _, err := c.Tokens.Token(); err != nil {
+		return fmt.Errorf("%w: %v", provider.ErrUnauthorized, err)
+	}
+	return nil
+}
+
+func keysPath(serviceAccount string) string {
+	return "projects/-/serviceAccounts/" + url.PathEscape(serviceAccount) + "/keys"
+}
+
+// do sends a request to the API and decodes the JSON response into out, if not nil.
+func (c *HTTPClient) do(ctx context.Context, method, path string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.Endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", provider.ErrTransient, err)
+	}
+	defer resp.Body.Close()
+
+	if err := provider.CheckResponse(resp); err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s %s", provider.ErrNotFound, method, path)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("%s %s: unexpected status %s", method, path, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
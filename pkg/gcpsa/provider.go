@@ -0,0 +1,264 @@
+// Package gcpsa implements a secret provider that brokers GCP service account keys,
+// minting a new key on each rotation and deleting the superseded keys it minted after
+// a grace period, for workloads that cannot use workload identity.
+package gcpsa
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	provider.Register(provider.Info{
+		Name:            "gcp-sa",
+		Capabilities:    provider.Capabilities{Expiry: true},
+		NamespaceScoped: true,
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			client, err := clients.operatorClient()
+			if err != nil {
+				return nil, err
+			}
+			namespaces, err := parseNamespaceAccounts(cfg.GCPKeyAccounts)
+			if err != nil {
+				return nil, fmt.Errorf("KSS_GCP_KEY_SERVICE_ACCOUNTS: %w", err)
+			}
+			record, err := newKeyRecord(cfg.Clientset, cfg.GCPKeyRecordSecret)
+			if err != nil {
+				return nil, fmt.Errorf("KSS_GCP_KEY_RECORD_SECRET: %w", err)
+			}
+			return SecretProvider{
+				Client:      client,
+				Rotation:    time.Duration(cfg.GCPKeyRotation) * time.Second,
+				GracePeriod: time.Duration(cfg.GCPKeyGracePeriod) * time.Second,
+				keys:        shared,
+				record:      record,
+				namespaces:  namespaces,
+			}, nil
+		},
+	})
+}
+
+// Key is a service account key as returned by the IAM API. PrivateKeyData is only
+// set on newly created keys.
+type Key struct {
+	Name           string `json:"name"`
+	PrivateKeyData string `json:"privateKeyData,omitempty"`
+	ValidAfterTime string `json:"validAfterTime,omitempty"`
+}
+
+// Client is the part of the IAM API used by the provider.
+type Client interface {
+	CreateKey(ctx context.Context, serviceAccount string) (*Key, error)
+	ListKeys(ctx context.Context, serviceAccount string) ([]Key, error)
+	DeleteKey(ctx context.Context, name string) error
+	HealthCheck(ctx context.Context) error
+}
+
+// SecretProvider resolves refs that are service account emails to a JSON key for the
+// account. A key is minted once per rotation and returned again until it is due, so
+// retries and values held back by the controller do not mint more. Keys are only
+// minted for service accounts mapped to the secret's namespace.
+//
+// Every key minted is recorded in a Secret owned by the operator, and only recorded
+// keys are deleted, once older than Rotation plus GracePeriod; other user-managed keys
+// of the account are left alone.
+type SecretProvider struct {
+	Client      Client
+	Rotation    time.Duration // how long each key is used before a new one is minted
+	GracePeriod time.Duration // how long a superseded key stays valid for running workloads
+	now         func() time.Time
+	keys        *keyCache
+	record      *keyRecord
+	namespaces  []namespaceAccount
+}
+
+// namespaceAccount is a service account keys may be minted for on behalf of secrets in
+// namespaces matching a glob.
+type namespaceAccount struct {
+	pattern        string
+	serviceAccount string
+}
+
+// shared holds the keys minted for each service account between the providers
+// created for each request.
+var shared = &keyCache{}
+
+// keyCache holds the key last minted for each service account.
+type keyCache struct {
+	mu   gosync.Mutex
+	keys map[string]mintedKey
+}
+
+// mintedKey is a key minted for a service account, with its JSON credentials file.
+type mintedKey struct {
+	name   string
+	value  []byte
+	minted time.Time
+}
+
+// Resolve returns a key for the service account and its JSON credentials file, with
+// the time the next key is due as its expiry, minting one if the last is due. Recorded
+// keys superseded more than a grace period ago are deleted; failing to delete them is
+// logged but does not fail the sync.
+func (p SecretProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	serviceAccount := req.Ref
+	if !p.allowed(serviceAccount, req.Namespace) {
+		return provider.Response{}, fmt.Errorf("%w: service account %q is not mapped to namespace %s in KSS_GCP_KEY_SERVICE_ACCOUNTS", provider.ErrUnauthorized, serviceAccount, req.Namespace)
+	}
+	now := time.Now()
+	if p.now != nil {
+		now = p.now()
+	}
+
+	cache := p.keys
+	if cache == nil {
+		cache = &keyCache{}
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	key, err := p.key(ctx, cache, serviceAccount, now)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	if err := p.deleteExpired(ctx, serviceAccount, key.name, now); err != nil {
+		klog.ErrorS(err, "Failed to delete expired GCP service account keys", "serviceAccount", serviceAccount)
+	}
+	return provider.Response{Value: key.value, Version: key.name, Expiry: key.minted.Add(p.Rotation)}, nil
+}
+
+// allowed reports whether keys may be minted for the service account on behalf of a
+// secret in namespace.
+func (p SecretProvider) allowed(serviceAccount, namespace string) bool {
+	for _, ns := range p.namespaces {
+		if matched, _ := path.Match(ns.pattern, namespace); matched && ns.serviceAccount == serviceAccount {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the key last minted for the service account, or mints one if there is
+// none or four fifths of its rotation have passed, when the controller renews it. A
+// new key is recorded before it is used, and deleted again if it can't be recorded,
+// so no key the provider minted is left behind. The caller holds cache.mu.
+func (p SecretProvider) key(ctx context.Context, cache *keyCache, serviceAccount string, now time.Time) (mintedKey, error) {
+	if key, ok := cache.keys[serviceAccount]; ok && now.Before(key.minted.Add(p.Rotation*4/5)) {
+		return key, nil
+	}
+	if p.record == nil {
+		return mintedKey{}, errors.New("gcp-sa has no record to keep minted keys in")
+	}
+
+	created, err := p.Client.CreateKey(ctx, serviceAccount)
+	if err != nil {
+		return mintedKey{}, err
+	}
+	err = p.record.update(ctx, serviceAccount, func(keys []string) []string {
+		return append(keys, created.Name)
+	})
+	if err != nil {
+		if err := p.Client.DeleteKey(ctx, created.Name); err != nil {
+			klog.ErrorS(err, "Failed to delete unrecorded GCP service account key", "serviceAccount", serviceAccount, "key", created.Name)
+		}
+		return mintedKey{}, err
+	}
+	value, err := base64.StdEncoding.DecodeString(created.PrivateKeyData)
+	if err != nil {
+		return mintedKey{}, fmt.Errorf("decoding key %s: %w", created.Name, err)
+	}
+	klog.InfoS("Minted GCP service account key", "serviceAccount", serviceAccount, "key", created.Name)
+	key := mintedKey{name: created.Name, value: value, minted: now}
+	if cache.keys == nil {
+		cache.keys = make(map[string]mintedKey)
+	}
+	cache.keys[serviceAccount] = key
+	return key, nil
+}
+
+// deleteExpired deletes the keys recorded for the service account, other than current,
+// that were created longer ago than a rotation plus the grace period, and removes them
+// from the record along with recorded keys that no longer exist.
+func (p SecretProvider) deleteExpired(ctx context.Context, serviceAccount, current string, now time.Time) error {
+	if p.record == nil {
+		return nil
+	}
+	recorded, err := p.record.keys(ctx, serviceAccount)
+	if err != nil || len(recorded) == 0 {
+		return err
+	}
+	keys, err := p.Client.ListKeys(ctx, serviceAccount)
+	if err != nil {
+		return err
+	}
+
+	// Recorded keys missing from the list were deleted by someone else
+	gone := slices.DeleteFunc(slices.Clone(recorded), func(name string) bool {
+		return slices.ContainsFunc(keys, func(key Key) bool { return key.Name == name })
+	})
+	var deleteErr error
+	cutoff := now.Add(-(p.Rotation + p.GracePeriod))
+	for _, key := range keys {
+		if key.Name == current || !slices.Contains(recorded, key.Name) {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, key.ValidAfterTime)
+		if err != nil || !created.Before(cutoff) {
+			continue
+		}
+		if err := p.Client.DeleteKey(ctx, key.Name); err != nil && !errors.Is(err, provider.ErrNotFound) {
+			deleteErr = fmt.Errorf("deleting key %s: %w", key.Name, err)
+			break
+		}
+		klog.InfoS("Deleted expired GCP service account key", "serviceAccount", serviceAccount, "key", key.Name)
+		gone = append(gone, key.Name)
+	}
+
+	// Deleted keys are dropped from the record even if a later deletion failed
+	if len(gone) > 0 {
+		if err := p.record.update(ctx, serviceAccount, func(keys []string) []string {
+			return slices.DeleteFunc(keys, func(name string) bool { return slices.Contains(gone, name) })
+		}); err != nil {
+			return err
+		}
+	}
+	return deleteErr
+}
+
+// HealthCheck checks that the operator can obtain credentials for the IAM API.
+func (p SecretProvider) HealthCheck(ctx context.Context) error {
+	return p.Client.HealthCheck(ctx)
+}
+
+// parseNamespaceAccounts parses the service accounts keys may be minted for on behalf
+// of namespaces as "pattern=email" pairs, comma separated, where patterns are globs
+// such as "team-*".
+func parseNamespaceAccounts(spec string) ([]namespaceAccount, error) {
+	var namespaces []namespaceAccount
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		pattern, serviceAccount, ok := strings.Cut(pair, "=")
+		pattern, serviceAccount = strings.TrimSpace(pattern), strings.TrimSpace(serviceAccount)
+		if !ok || pattern == "" || !strings.Contains(serviceAccount, "@") {
+			return nil, fmt.Errorf("invalid namespace service account %q, expected namespace=email", pair)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+		}
+		namespaces = append(namespaces, namespaceAccount{pattern: pattern, serviceAccount: serviceAccount})
+	}
+	return namespaces, nil
+}
@@ -0,0 +1,199 @@
+package gcpsa
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type fakeClient struct {
+	keys    []Key
+	created int
+	deleted []string
+}
+
+func (c *fakeClient) CreateKey(_ context.Context, serviceAccount string) (*Key, error) {
+	c.created++
+	key := Key{
+		Name:           fmt.Sprintf("projects/p/serviceAccounts/%s/keys/k%d", serviceAccount, c.created),
+		PrivateKeyData: base64.StdEncoding.EncodeToString([]byte(`{"type":"service_account"}`)),
+	}
+	c.keys = append(c.keys, Key{Name: key.Name, ValidAfterTime: "2030-01-02T00:00:00Z"})
+	return &key, nil
+}
+
+func (c *fakeClient) ListKeys(context.Context, string) ([]Key, error) {
+	return c.keys, nil
+}
+
+func (c *fakeClient) DeleteKey(_ context.Context, name string) error {
+	c.deleted = append(c.deleted, name)
+	return nil
+}
+
+func (c *fakeClient) HealthCheck(context.Context) error {
+	return nil
+}
+
+// newTestRecord returns a record in a fake clientset holding recorded keys for
+// app@p.iam.gserviceaccount.com.
+func newTestRecord(t *testing.T, recorded ...string) (*keyRecord, *fake.Clientset) {
+	t.Helper()
+	cs := fake.NewSimpleClientset()
+	record, err := newKeyRecord(cs, "k8s-secret-sync/gcp-keys")
+	if err != nil {
+		t.Fatalf("newKeyRecord: %v", err)
+	}
+	if len(recorded) > 0 {
+		if err := record.update(context.Background(), "app@p.iam.gserviceaccount.com", func([]string) []string { return recorded }); err != nil {
+			t.Fatalf("recording keys: %v", err)
+		}
+	}
+	return record, cs
+}
+
+func TestResolve(t *testing.T) {
+	now := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	client := &fakeClient{keys: []Key{
+		{Name: "expired", ValidAfterTime: now.Add(-26 * time.Hour).Format(time.RFC3339)},
+		{Name: "in-grace", ValidAfterTime: now.Add(-24 * time.Hour).Format(time.RFC3339)},
+		{Name: "not-minted", ValidAfterTime: now.Add(-48 * time.Hour).Format(time.RFC3339)},
+	}}
+	record, _ := newTestRecord(t, "expired", "in-grace", "gone")
+	namespaces, err := parseNamespaceAccounts("team-*=app@p.iam.gserviceaccount.com")
+	if err != nil {
+		t.Fatalf("parseNamespaceAccounts: %v", err)
+	}
+	p := SecretProvider{Client: client, Rotation: 24 * time.Hour, GracePeriod: time.Hour, now: func() time.Time { return now }, keys: &keyCache{}, record: record, namespaces: namespaces}
+	req := provider.Request{Ref: "app@p.iam.gserviceaccount.com", Namespace: "team-a"}
+
+	resp, err := p.Resolve(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if string(resp.Value) != `{"type":"service_account"}` {
+		t.Errorf("value = %q", resp.Value)
+	}
+	if want := now.Add(24 * time.Hour); !resp.Expiry.Equal(want) {
+		t.Errorf("expiry = %v, want %v", resp.Expiry, want)
+	}
+	first := "projects/p/serviceAccounts/app@p.iam.gserviceaccount.com/keys/k1"
+	if resp.Version != first {
+		t.Errorf("version = %q, want the new key's name", resp.Version)
+	}
+	// Keys missing from the record are never deleted
+	if len(client.deleted) != 1 || client.deleted[0] != "expired" {
+		t.Errorf("deleted = %v, want [expired]", client.deleted)
+	}
+	recorded, err := record.keys(context.Background(), req.Ref)
+	if want := []string{"in-grace", first}; err != nil || !slices.Equal(recorded, want) {
+		t.Errorf("recorded = %v, %v; want %v", recorded, err, want)
+	}
+
+	// The key is returned again until it is due for renewal
+	now = now.Add(19 * time.Hour)
+	if resp, err := p.Resolve(context.Background(), req); err != nil || resp.Version != first || client.created != 1 {
+		t.Errorf("Resolve before renewal = %q, %v; minted %d keys, want the first key again", resp.Version, err, client.created)
+	}
+	now = now.Add(time.Hour)
+	if resp, err := p.Resolve(context.Background(), req); err != nil || resp.Version == first || client.created != 2 {
+		t.Errorf("Resolve at renewal = %q, %v; minted %d keys, want a new key", resp.Version, err, client.created)
+	}
+}
+
+func TestResolveUnmappedNamespace(t *testing.T) {
+	client := &fakeClient{}
+	record, _ := newTestRecord(t)
+	namespaces, err := parseNamespaceAccounts("team-a=app@p.iam.gserviceaccount.com,team-b=other@p.iam.gserviceaccount.com")
+	if err != nil {
+		t.Fatalf("parseNamespaceAccounts: %v", err)
+	}
+	p := SecretProvider{Client: client, Rotation: time.Hour, keys: &keyCache{}, record: record, namespaces: namespaces}
+
+	for _, namespace := range []string{"team-b", "default"} {
+		_, err := p.Resolve(context.Background(), provider.Request{Ref: "app@p.iam.gserviceaccount.com", Namespace: namespace})
+		if !errors.Is(err, provider.ErrUnauthorized) {
+			t.Errorf("Resolve in %s = %v, want ErrUnauthorized", namespace, err)
+		}
+	}
+	if client.created != 0 {
+		t.Errorf("minted %d keys for unmapped namespaces", client.created)
+	}
+}
+
+func TestResolveDeletesUnrecordedKey(t *testing.T) {
+	client := &fakeClient{}
+	record, cs := newTestRecord(t)
+	cs.PrependReactor("create", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+	namespaces, _ := parseNamespaceAccounts("*=app@p.iam.gserviceaccount.com")
+	p := SecretProvider{Client: client, Rotation: time.Hour, keys: &keyCache{}, record: record, namespaces: namespaces}
+
+	if _, err := p.Resolve(context.Background(), provider.Request{Ref: "app@p.iam.gserviceaccount.com", Namespace: "default"}); err == nil {
+		t.Fatal("Resolve succeeded without recording the key")
+	}
+	if want := []string{"projects/p/serviceAccounts/app@p.iam.gserviceaccount.com/keys/k1"}; !slices.Equal(client.deleted, want) {
+		t.Errorf("deleted = %v, want the key that could not be recorded", client.deleted)
+	}
+}
+
+func TestParseNamespaceAccounts(t *testing.T) {
+	for _, spec := range []string{"team-a", "team-a=not-an-email", "=app@p.iam.gserviceaccount.com", "[=app@p.iam.gserviceaccount.com"} {
+		if _, err := parseNamespaceAccounts(spec); err == nil {
+			t.Errorf("parseNamespaceAccounts(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.RequestURI())
+		switch r.Method {
+		case http.MethodPost:
+			w.Write([]byte(`{"name":"projects/p/serviceAccounts/app@p.iam.gserviceaccount.com/keys/k1","privateKeyData":"e30="}`))
+		case http.MethodGet:
+			w.Write([]byte(`{"keys":[{"name":"k1","validAfterTime":"2030-01-01T00:00:00Z"}]}`))
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+	c := &HTTPClient{Endpoint: server.URL + "/v1/", HTTP: server.Client()}
+
+	key, err := c.CreateKey(context.Background(), "app@p.iam.gserviceaccount.com")
+	if err != nil || key.PrivateKeyData != "e30=" {
+		t.Fatalf("CreateKey = %+v, %v", key, err)
+	}
+	keys, err := c.ListKeys(context.Background(), "app@p.iam.gserviceaccount.com")
+	if err != nil || len(keys) != 1 || keys[0].Name != "k1" {
+		t.Fatalf("ListKeys = %+v, %v", keys, err)
+	}
+	if err := c.DeleteKey(context.Background(), key.Name); !errors.Is(err, provider.ErrUnauthorized) {
+		t.Errorf("DeleteKey = %v, want ErrUnauthorized", err)
+	}
+
+	want := []string{
+		"POST /v1/projects/-/serviceAccounts/app@p.iam.gserviceaccount.com/keys",
+		"GET /v1/projects/-/serviceAccounts/app@p.iam.gserviceaccount.com/keys?keyTypes=USER_MANAGED",
+		"DELETE /v1/projects/p/serviceAccounts/app@p.iam.gserviceaccount.com/keys/k1",
+	}
+	for i := range want {
+		if i >= len(paths) || paths[i] != want[i] {
+			t.Errorf("requests = %v, want %v", paths, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gcpsa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// recordDataKey is the data key of the record Secret holding the keys minted for each
+// service account, as a JSON object of key names by service account.
+const recordDataKey = "keys"
+
+// serviceAccountNamespaceFile holds the namespace of the operator's pod.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// keyRecord records the keys the provider minted in a Secret owned by the operator,
+// so the keys it deletes never come from anything the owners of synced secrets can
+// edit.
+type keyRecord struct {
+	clientset kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// newKeyRecord returns the record kept in the Secret spec names, as "namespace/name"
+// or a name in the operator's namespace.
+func newKeyRecord(clientset kubernetes.Interface, spec string) (*keyRecord, error) {
+	namespace, name, ok := strings.Cut(spec, "/")
+	if !ok {
+		data, err := os.ReadFile(serviceAccountNamespaceFile)
+		if err != nil {
+			return nil, fmt.Errorf("determining pod namespace (use namespace/name outside a pod): %w", err)
+		}
+		namespace, name = strings.TrimSpace(string(data)), spec
+	}
+	if namespace == "" || name == "" {
+		return nil, fmt.Errorf("invalid Secret %q, expected namespace/name or a name", spec)
+	}
+	return &keyRecord{clientset: clientset, namespace: namespace, name: name}, nil
+}
+
+// keys returns the keys recorded as minted for a service account.
+func (r *keyRecord) keys(ctx context.Context, serviceAccount string) ([]string, error) {
+	recorded, _, err := r.load(ctx)
+	return recorded[serviceAccount], err
+}
+
+// update replaces the keys recorded for a service account with those f returns given
+// the keys recorded now, creating the Secret if there is none. Updates made by another
+// replica in between fail with a conflict rather than being lost.
+func (r *keyRecord) update(ctx context.Context, serviceAccount string, f func([]string) []string) error {
+	recorded, secret, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+	if recorded == nil {
+		recorded = make(map[string][]string)
+	}
+	if keys := f(recorded[serviceAccount]); len(keys) > 0 {
+		recorded[serviceAccount] = keys
+	} else {
+		delete(recorded, serviceAccount)
+	}
+	data, err := json.Marshal(recorded)
+	if err != nil {
+		return err
+	}
+
+	secrets := r.clientset.CoreV1().Secrets(r.namespace)
+	if secret == nil {
+		_, err = secrets.Create(ctx, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: r.namespace, Name: r.name},
+			Data:       map[string][]byte{recordDataKey: data},
+		}, metav1.CreateOptions{})
+	} else {
+		secret = secret.DeepCopy()
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		secret.Data[recordDataKey] = data
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("updating minted key record %s/%s: %w", r.namespace, r.name, err)
+	}
+	return nil
+}
+
+// load returns the keys recorded for each service account and the Secret holding
+// them, which is nil if it does not exist yet.
+func (r *keyRecord) load(ctx context.Context) (map[string][]string, *v1.Secret, error) {
+	secret, err := r.clientset.CoreV1().Secrets(r.namespace).Get(ctx, r.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading minted key record %s/%s: %w", r.namespace, r.name, err)
+	}
+	var recorded map[string][]string
+	if data := secret.Data[recordDataKey]; len(data) > 0 {
+		if err := json.Unmarshal(data, &recorded); err != nil {
+			return nil, nil, fmt.Errorf("decoding minted key record %s/%s: %w", r.namespace, r.name, err)
+		}
+	}
+	return recorded, secret, nil
+}
@@ -0,0 +1,194 @@
+package gcpsm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	defaultEndpoint        = "https://secretmanager.googleapis.com/v1/"
+	iamCredentialsEndpoint = "https://iamcredentials.googleapis.com/v1/"
+	cloudPlatform          = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// shared caches the operator's client and the clients impersonating service accounts
+// between the providers created for each request, so credentials are only loaded once
+// and access tokens are reused until they expire.
+var shared = &clientCache{}
+
+// clientCache holds the operator's client and a client for each service account
+// impersonated.
+type clientCache struct {
+	mu       gosync.Mutex
+	operator *HTTPClient
+	clients  map[string]*HTTPClient
+}
+
+// operatorClient returns the client using Application Default Credentials, creating
+// it on first use. It outlives the request creating it, so its tokens are fetched
+// with a background context.
+func (c *clientCache) operatorClient() (*HTTPClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.operator != nil {
+		return c.operator, nil
+	}
+	client, err := NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	c.operator = client
+	return client, nil
+}
+
+// HTTPClient calls the Secret Manager REST API.
+type HTTPClient struct {
+	Endpoint string
+	HTTP     *http.Client
+	Tokens   oauth2.TokenSource
+
+	// IAMEndpoint is the IAM Credentials API access tokens of impersonated service
+	// accounts are generated with.
+	IAMEndpoint string
+
+	impersonated *clientCache
+}
+
+// NewClient returns a Secret Manager client using Application Default Credentials.
+func NewClient(ctx context.Context) (*HTTPClient, error) {
+	tokens, err := google.DefaultTokenSource(ctx, cloudPlatform)
+	if err != nil {
+		return nil, fmt.Errorf("loading GCP credentials: %w", err)
+	}
+	return &HTTPClient{
+		Endpoint:     defaultEndpoint,
+		HTTP:         oauth2.NewClient(ctx, tokens),
+		Tokens:       tokens,
+		IAMEndpoint:  iamCredentialsEndpoint,
+		impersonated: shared,
+	}, nil
+}
+
+// Impersonate returns a client authenticating as serviceAccount, with access tokens
+// generated for it with the operator's credentials. The operator needs the Service
+// Account Token Creator role on the service account.
+func (c *HTTPClient) Impersonate(serviceAccount string) Client {
+	if c.impersonated != nil {
+		c.impersonated.mu.Lock()
+		defer c.impersonated.mu.Unlock()
+		if client, ok := c.impersonated.clients[serviceAccount]; ok {
+			return client
+		}
+	}
+	tokens := oauth2.ReuseTokenSource(nil, impersonatedTokens{endpoint: c.IAMEndpoint, http: c.HTTP, serviceAccount: serviceAccount})
+	client := &HTTPClient{
+		Endpoint:    c.Endpoint,
+		HTTP:        oauth2.NewClient(context.Background(), tokens),
+		Tokens:      tokens,
+		IAMEndpoint: c.IAMEndpoint,
+	}
+	if c.impersonated != nil {
+		if c.impersonated.clients == nil {
+			c.impersonated.clients = make(map[string]*HTTPClient)
+		}
+		c.impersonated.clients[serviceAccount] = client
+	}
+	return client
+}
+
+// impersonatedTokens generates access tokens for a service account.
+type impersonatedTokens struct {
+	endpoint       string
+	http           *http.Client // authenticated as the operator
+	serviceAccount string
+}
+
+func (s impersonatedTokens) Token() (*oauth2.Token, error) {
+	body, err := json.Marshal(map[string]any{"scope": []string{cloudPlatform}})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	endpoint := s.endpoint + "projects/-/serviceAccounts/" + url.PathEscape(s.serviceAccount) + ":generateAccessToken"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", provider.ErrTransient, err)
+	}
+	defer resp.Body.Close()
+
+	if err := provider.CheckResponse(resp); err != nil {
+		return nil, fmt.Errorf("impersonating %s: %w", s.serviceAccount, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: impersonating %s: service account not found", provider.ErrUnauthorized, s.serviceAccount)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return nil, fmt.Errorf("impersonating %s: unexpected status %s", s.serviceAccount, resp.Status)
+	}
+	var token struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("decoding access token for %s: %w", s.serviceAccount, err)
+	}
+	return &oauth2.Token{AccessToken: token.AccessToken, TokenType: "Bearer", Expiry: token.ExpireTime}, nil
+}
+
+// GetSecretValue accesses the secret version with the given resource name.
+func (c *HTTPClient) GetSecretValue(ctx context.Context, name string) (*SecretVersion, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Endpoint+name+":access", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		// Failures to obtain an access token are returned as they are
+		if errors.Is(err, provider.ErrUnauthorized) || errors.Is(err, provider.ErrTransient) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", provider.ErrTransient, err)
+	}
+	defer resp.Body.Close()
+
+	if err := provider.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: secret version %s", provider.ErrNotFound, name)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return nil, fmt.Errorf("accessing %s: unexpected status %s", name, resp.Status)
+	}
+	var version SecretVersion
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return nil, fmt.Errorf("decoding secret version %s: %w", name, err)
+	}
+	return &version, nil
+}
+
+// HealthCheck fetches an access token, which fails if the operator's credentials
+// are missing or invalid.
+func (c *HTTPClient) HealthCheck(context.Context) error {
+	if _, err := c.Tokens.Token(); err != nil {
+		return fmt.Errorf("%w: %v", provider.ErrUnauthorized, err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+// Package gcpsm implements a secret provider that reads secret versions from Google
+// Cloud Secret Manager.
+package gcpsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func init() {
+	provider.Register(provider.Info{
+		Name:            "gcp-sm",
+		Aliases:         []string{"gcp-secret-manager"},
+		Capabilities:    provider.Capabilities{Binary: true, Versioning: true},
+		NamespaceScoped: true,
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			client, err := shared.operatorClient()
+			if err != nil {
+				return nil, err
+			}
+			namespaces, err := parseNamespaceAccounts(cfg.GCPNamespaceAccounts)
+			if err != nil {
+				return nil, fmt.Errorf("KSS_GCP_NAMESPACE_SERVICE_ACCOUNTS: %w", err)
+			}
+			return SecretProvider{Client: client, Impersonate: client.Impersonate, namespaces: namespaces}, nil
+		},
+	})
+}
+
+// SecretVersion is an accessed secret version as returned by the Secret Manager API.
+type SecretVersion struct {
+	Name    string `json:"name"`
+	Payload struct {
+		Data       []byte `json:"data"`
+		DataCRC32C string `json:"dataCrc32c,omitempty"`
+	} `json:"payload"`
+}
+
+// Client is the part of the Secret Manager API used by the provider.
+type Client interface {
+	GetSecretValue(ctx context.Context, name string) (*SecretVersion, error)
+	HealthCheck(ctx context.Context) error
+}
+
+// serviceAccountKey is the provider metadata naming the service account a secret is
+// read as.
+const serviceAccountKey = "serviceAccount"
+
+// namespaceAccount is a service account secrets in namespaces matching a glob may be
+// read as.
+type namespaceAccount struct {
+	pattern        string
+	serviceAccount string
+}
+
+// SecretProvider resolves refs of the form "projects/p/secrets/name/versions/v" to the
+// payload of a secret version, where v is a version number or "latest". The version may
+// be left out to read the pinned version, or the latest one if none is pinned; a pinned
+// version replaces the one in the ref. The response version is the version number read.
+//
+// Secrets are read by impersonating the service account given by the "serviceAccount"
+// metadata or else the first mapped to the secret's namespace, if any, so each can be
+// granted access to only its own projects' secrets. A secret may only select a service
+// account mapped to its own namespace, including none mapped to other namespaces.
+type SecretProvider struct {
+	Client Client
+
+	// Impersonate returns a client authenticating as a service account; nil disallows
+	// impersonation.
+	Impersonate func(serviceAccount string) Client
+
+	namespaces []namespaceAccount
+}
+
+func (p SecretProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	name, err := versionName(req.Ref, req.Version)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	client, err := p.client(req)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	version, err := client.GetSecretValue(ctx, name)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	if version.Payload.DataCRC32C != "" {
+		want, err := strconv.ParseUint(version.Payload.DataCRC32C, 10, 32)
+		if err != nil || crc32.Checksum(version.Payload.Data, crc32.MakeTable(crc32.Castagnoli)) != uint32(want) {
+			return provider.Response{}, fmt.Errorf("%w: checksum mismatch reading %s", provider.ErrTransient, name)
+		}
+	}
+	return provider.Response{Value: version.Payload.Data, Version: path.Base(version.Name)}, nil
+}
+
+// HealthCheck checks that the operator can obtain credentials for the Secret Manager API.
+func (p SecretProvider) HealthCheck(ctx context.Context) error {
+	return p.Client.HealthCheck(ctx)
+}
+
+// client returns the client to read a request's secret with, impersonating the
+// service account it selects or its namespace is mapped to.
+func (p SecretProvider) client(req provider.Request) (Client, error) {
+	var mapped []string
+	for _, ns := range p.namespaces {
+		if matched, _ := path.Match(ns.pattern, req.Namespace); matched {
+			mapped = append(mapped, ns.serviceAccount)
+		}
+	}
+	serviceAccount, ok := req.Metadata[serviceAccountKey]
+	switch {
+	case !ok && len(mapped) > 0:
+		serviceAccount = mapped[0]
+	case ok && !slices.Contains(mapped, serviceAccount):
+		return nil, fmt.Errorf("%w: service account %q is not mapped to namespace %s in KSS_GCP_NAMESPACE_SERVICE_ACCOUNTS", provider.ErrUnauthorized, serviceAccount, req.Namespace)
+	}
+	if serviceAccount == "" {
+		return p.Client, nil
+	}
+	if p.Impersonate == nil {
+		return nil, errors.New("gcp-sm cannot impersonate service accounts")
+	}
+	return p.Impersonate(serviceAccount), nil
+}
+
+// parseNamespaceAccounts parses service accounts mapped to namespaces as
+// "pattern=email" pairs, comma separated, where patterns are globs such as "team-*".
+// A namespace may use every service account mapped to it; the first is its default.
+func parseNamespaceAccounts(spec string) ([]namespaceAccount, error) {
+	var namespaces []namespaceAccount
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		pattern, serviceAccount, ok := strings.Cut(pair, "=")
+		pattern, serviceAccount = strings.TrimSpace(pattern), strings.TrimSpace(serviceAccount)
+		if !ok || pattern == "" || !strings.Contains(serviceAccount, "@") {
+			return nil, fmt.Errorf("invalid namespace service account %q, expected namespace=email", pair)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+		}
+		namespaces = append(namespaces, namespaceAccount{pattern: pattern, serviceAccount: serviceAccount})
+	}
+	return namespaces, nil
+}
+
+// versionName returns the resource name of the secret version a ref and pinned version
+// refer to.
+func versionName(ref, pinned string) (string, error) {
+	segments := strings.Split(strings.Trim(ref, "/"), "/")
+	valid := (len(segments) == 4 || len(segments) == 6) && segments[0] == "projects" && segments[2] == "secrets"
+	if valid && len(segments) == 6 {
+		valid = segments[4] == "versions"
+	}
+	for _, segment := range segments {
+		valid = valid && segment != ""
+	}
+	if !valid {
+		return "", fmt.Errorf("invalid gcp-sm ref %q, expected projects/<project>/secrets/<name>/versions/<version>", ref)
+	}
+
+	version := "latest"
+	if len(segments) == 6 {
+		version = segments[5]
+	}
+	if pinned != "" {
+		version = pinned
+	}
+	return strings.Join(append(segments[:4], "versions", version), "/"), nil
+}
@@ -0,0 +1,155 @@
+package gcpsm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func TestVersionName(t *testing.T) {
+	tests := []struct {
+		ref, pinned, want string
+	}{
+		{ref: "projects/p/secrets/db/versions/3", want: "projects/p/secrets/db/versions/3"},
+		{ref: "projects/p/secrets/db/versions/latest", pinned: "2", want: "projects/p/secrets/db/versions/2"},
+		{ref: "projects/p/secrets/db", want: "projects/p/secrets/db/versions/latest"},
+		{ref: "projects/p/secrets/db", pinned: "7", want: "projects/p/secrets/db/versions/7"},
+		{ref: "projects/p/secrets/db/aliases/x"},
+		{ref: "projects//secrets/db"},
+		{ref: "db"},
+	}
+	for _, tt := range tests {
+		got, err := versionName(tt.ref, tt.pinned)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("versionName(%q) = %q, want error", tt.ref, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("versionName(%q, %q) = %q, %v, want %q", tt.ref, tt.pinned, got, err, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/v1/projects/p/secrets/db/versions/latest:access":
+			// "hunter2", with its CRC32C checksum
+			w.Write([]byte(`{"name":"projects/123/secrets/db/versions/4","payload":{"data":"aHVudGVyMg==","dataCrc32c":"1736498283"}}`))
+		case "/v1/projects/p/secrets/corrupt/versions/latest:access":
+			w.Write([]byte(`{"name":"projects/123/secrets/corrupt/versions/1","payload":{"data":"aHVudGVyMg==","dataCrc32c":"1"}}`))
+		case "/v1/projects/p/secrets/denied/versions/latest:access":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	p := SecretProvider{Client: &HTTPClient{Endpoint: server.URL + "/v1/", HTTP: server.Client()}}
+
+	resp, err := p.Resolve(context.Background(), provider.Request{Ref: "projects/p/secrets/db/versions/latest"})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if string(resp.Value) != "hunter2" || resp.Version != "4" {
+		t.Errorf("Resolve = %q at version %q, want hunter2 at version 4", resp.Value, resp.Version)
+	}
+
+	for ref, want := range map[string]error{
+		"projects/p/secrets/corrupt": provider.ErrTransient,
+		"projects/p/secrets/denied":  provider.ErrUnauthorized,
+		"projects/p/secrets/missing": provider.ErrNotFound,
+	} {
+		if _, err := p.Resolve(context.Background(), provider.Request{Ref: ref}); !errors.Is(err, want) {
+			t.Errorf("Resolve(%q) = %v, want %v", ref, err, want)
+		}
+	}
+}
+
+func TestImpersonation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/iam/projects/-/serviceAccounts/team-a@p.iam.gserviceaccount.com:generateAccessToken":
+			w.Write([]byte(`{"accessToken":"team-a-token","expireTime":"2030-01-01T00:00:00Z"}`))
+		case "/iam/projects/-/serviceAccounts/other@p.iam.gserviceaccount.com:generateAccessToken":
+			w.WriteHeader(http.StatusForbidden)
+		case "/v1/projects/p/secrets/db/versions/latest:access":
+			if r.Header.Get("Authorization") != "Bearer team-a-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"name":"projects/123/secrets/db/versions/4","payload":{"data":"aHVudGVyMg=="}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	operator := &HTTPClient{Endpoint: server.URL + "/v1/", IAMEndpoint: server.URL + "/iam/", HTTP: server.Client()}
+	namespaces, err := parseNamespaceAccounts("team-a=team-a@p.iam.gserviceaccount.com,team-b=other@p.iam.gserviceaccount.com")
+	if err != nil {
+		t.Fatalf("parseNamespaceAccounts: %v", err)
+	}
+	p := SecretProvider{Client: operator, Impersonate: operator.Impersonate, namespaces: namespaces}
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		namespace, serviceAccount string
+		want                      error
+	}{
+		{namespace: "team-a"},
+		{namespace: "default", serviceAccount: "team-a@p.iam.gserviceaccount.com", want: provider.ErrUnauthorized},
+		{namespace: "team-b", want: provider.ErrUnauthorized},
+		{namespace: "team-b", serviceAccount: "team-a@p.iam.gserviceaccount.com", want: provider.ErrUnauthorized},
+		{namespace: "default", want: provider.ErrUnauthorized},
+		{namespace: "default", serviceAccount: "other@p.iam.gserviceaccount.com", want: provider.ErrUnauthorized},
+	} {
+		req := provider.Request{Ref: "projects/p/secrets/db", Namespace: tt.namespace, Metadata: map[string]string{}}
+		if tt.serviceAccount != "" {
+			req.Metadata["serviceAccount"] = tt.serviceAccount
+		}
+		resp, err := p.Resolve(ctx, req)
+		if tt.want == nil && (err != nil || string(resp.Value) != "hunter2") {
+			t.Errorf("Resolve(%s, %q) = %q, %v", tt.namespace, tt.serviceAccount, resp.Value, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("Resolve(%s, %q) = %v, want %v", tt.namespace, tt.serviceAccount, err, tt.want)
+		}
+	}
+
+	req := provider.Request{Ref: "projects/p/secrets/db", Namespace: "team-a", Metadata: map[string]string{"serviceAccount": "other@p.iam.gserviceaccount.com"}}
+	if _, err := p.Resolve(ctx, req); err == nil {
+		t.Errorf("Resolve in team-a with another service account succeeded, want an error")
+	}
+	for _, spec := range []string{"team-a", "team-a=team-a", "[=team-a@p.iam.gserviceaccount.com"} {
+		if _, err := parseNamespaceAccounts(spec); err == nil {
+			t.Errorf("parseNamespaceAccounts(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestOperatorClientReused(t *testing.T) {
+	credentials := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(credentials, []byte(`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"token"}`), 0o600); err != nil {
+		t.Fatalf("writing credentials: %v", err)
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", credentials)
+
+	cache := &clientCache{}
+	first, err := cache.operatorClient()
+	if err != nil {
+		t.Fatalf("operatorClient: %v", err)
+	}
+	if second, _ := cache.operatorClient(); second != first {
+		t.Errorf("expected the operator's client to be reused between requests")
+	}
+}
@@ -0,0 +1,149 @@
+// Package gitrepo implements a secret provider that resolves refs to files in a git
+// repository, optionally encrypted with SOPS, as a GitOps-native secret backend. The
+// commit each value was read at is recorded in the secret's provenance annotations.
+package gitrepo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/transform"
+	"sigs.k8s.io/yaml"
+)
+
+func init() {
+	provider.Register(provider.Info{
+		Name:           "git",
+		RequiredConfig: []string{"KSS_GIT_REPO_URL"},
+		Capabilities:   provider.Capabilities{Binary: true},
+		New: func(ctx context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			repo, err := clone(ctx, cfg)
+			if err != nil {
+				return nil, err
+			}
+			return SecretProvider{Repo: repo, AgeIdentityFile: cfg.AgeIdentityFile}, nil
+		},
+	})
+}
+
+var (
+	mu    gosync.Mutex
+	repos = map[string]*Repo{}
+)
+
+// clone returns the configured repository, cloning it and starting to pull it in the
+// background the first time it is used.
+func clone(ctx context.Context, cfg *config.Sync) (*Repo, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	key := cfg.GitRepoURL + "#" + cfg.GitBranch
+	if repo, ok := repos[key]; ok {
+		return repo, nil
+	}
+	repo := &Repo{
+		URL:        cfg.GitRepoURL,
+		Branch:     cfg.GitBranch,
+		Dir:        cfg.GitCheckoutDir,
+		SSHKeyFile: cfg.GitSSHKeyFile,
+		KnownHosts: cfg.GitKnownHosts,
+	}
+	if err := repo.Pull(ctx); err != nil {
+		return nil, err
+	}
+	go repo.Watch(ctx, time.Duration(cfg.GitPullInterval)*time.Second)
+	repos[key] = repo
+	return repo, nil
+}
+
+// sopsMetadata matches the metadata SOPS adds to the files it encrypts, in YAML, JSON,
+// and dotenv form.
+var sopsMetadata = regexp.MustCompile(`(?m)^(sops:|\s*"sops":|sops_mac=)`)
+
+// SecretProvider resolves refs of the form "path/to/file" to the contents of a file in
+// the repository, or "path/to/file#field.path" to a field of a YAML or JSON file. Files
+// encrypted with SOPS are decrypted with the sops command, using the age identity in
+// AgeIdentityFile. The response version is the commit the value was read at.
+type SecretProvider struct {
+	Repo            *Repo
+	AgeIdentityFile string // age identity SOPS files are decrypted with
+	SOPSCommand     string // path of the sops command (empty uses "sops")
+}
+
+func (p SecretProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	path, field, _ := strings.Cut(req.Ref, "#")
+	var resp provider.Response
+	err := p.Repo.Read(strings.TrimPrefix(path, "/"), func(file, commit string) error {
+		content, err := os.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: no file %s at commit %s", provider.ErrNotFound, path, commit)
+		}
+		if err != nil {
+			return err
+		}
+
+		if sopsMetadata.Match(content) {
+			args := []string{"--decrypt", file}
+			if field != "" {
+				args = []string{"--decrypt", "--output-type", "json", file}
+			}
+			if content, err = p.sops(ctx, args...); err != nil {
+				return err
+			}
+		}
+		if field != "" {
+			if content, err = lookup(content, field); err != nil {
+				return fmt.Errorf("%w: %s#%s: %v", provider.ErrNotFound, path, field, err)
+			}
+		}
+		resp = provider.Response{Value: content, Version: commit}
+		return nil
+	})
+	return resp, err
+}
+
+// HealthCheck checks that the repository can still be fetched.
+func (p SecretProvider) HealthCheck(ctx context.Context) error {
+	_, err := p.Repo.git(ctx, p.Repo.Dir, "ls-remote", "--exit-code", "origin", p.Repo.Branch)
+	return err
+}
+
+// sops runs the sops command with args, returning its output.
+func (p SecretProvider) sops(ctx context.Context, args ...string) ([]byte, error) {
+	command := p.SOPSCommand
+	if command == "" {
+		command = "sops"
+	}
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Env = append(os.Environ(), "SOPS_AGE_KEY_FILE="+p.AgeIdentityFile)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("decrypting with sops: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// lookup returns the field at a dot-separated path in a YAML or JSON document.
+func lookup(content []byte, field string) ([]byte, error) {
+	doc, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("file is not YAML or JSON: %w", err)
+	}
+	data, err := transform.KeyMap{{Key: "value", Path: "." + field}}.Apply(string(doc))
+	if err != nil {
+		return nil, err
+	}
+	return data["value"], nil
+}
@@ -0,0 +1,119 @@
+package gitrepo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+// commitFiles writes files to the repository at dir and commits them.
+func commitFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"add", "-A"}, {"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "update"}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	upstream := t.TempDir()
+	if out, err := exec.Command("git", "init", "--quiet", "--initial-branch", "main", upstream).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	commitFiles(t, upstream, map[string]string{
+		"db/password":     "hunter2",
+		"app/config.yaml": "db:\n  user: admin\n",
+		"app/secret.yaml": "db:\n  password: ENC[AES256_GCM,data:abc]\nsops:\n  age: []\n",
+	})
+
+	// Symlinks are followed within the repository, but not out of it
+	outside := filepath.Join(t.TempDir(), "outside")
+	if err := os.WriteFile(outside, []byte("host secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("db/password", filepath.Join(upstream, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(upstream, "leak")); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, upstream, nil)
+
+	// A fake sops stands in for decrypting the SOPS file
+	sops := filepath.Join(t.TempDir(), "sops")
+	if err := os.WriteFile(sops, []byte("#!/bin/sh\necho '{\"db\":{\"password\":\"decrypted\"}}'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &Repo{URL: upstream, Branch: "main", Dir: filepath.Join(t.TempDir(), "clone")}
+	if err := repo.Pull(context.Background()); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	p := SecretProvider{Repo: repo, SOPSCommand: sops}
+
+	for ref, want := range map[string]string{"db/password": "hunter2", "alias": "hunter2", "app/config.yaml#db.user": "admin", "app/secret.yaml#db.password": "decrypted"} {
+		resp, err := p.Resolve(context.Background(), provider.Request{Ref: ref})
+		if err != nil || string(resp.Value) != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", ref, resp.Value, err, want)
+		}
+		if len(resp.Version) != 40 {
+			t.Errorf("Resolve(%q) version = %q, want a commit hash", ref, resp.Version)
+		}
+	}
+	for _, ref := range []string{"db/missing", "app/config.yaml#db.password"} {
+		if _, err := p.Resolve(context.Background(), provider.Request{Ref: ref}); !errors.Is(err, provider.ErrNotFound) {
+			t.Errorf("Resolve(%q) = %v, want ErrNotFound", ref, err)
+		}
+	}
+	for _, ref := range []string{"../etc/passwd", "leak"} {
+		if resp, err := p.Resolve(context.Background(), provider.Request{Ref: ref}); err == nil {
+			t.Errorf("Resolve(%q) = %q, want error for a path outside the repository", ref, resp.Value)
+		}
+	}
+
+	// New commits are picked up by the next pull
+	first, _ := p.Resolve(context.Background(), provider.Request{Ref: "db/password"})
+	commitFiles(t, upstream, map[string]string{"db/password": "rotated"})
+	if err := repo.Pull(context.Background()); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	resp, err := p.Resolve(context.Background(), provider.Request{Ref: "db/password"})
+	if err != nil || string(resp.Value) != "rotated" || resp.Version == first.Version {
+		t.Errorf("Resolve after pull = %q at %s, %v; want rotated at a new commit", resp.Value, resp.Version, err)
+	}
+	if err := p.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+}
+
+func TestPullRequiresKnownHostsForSSH(t *testing.T) {
+	for _, url := range []string{"git@github.com:example/secrets.git", "ssh://git@github.com/example/secrets.git"} {
+		repo := &Repo{URL: url, Branch: "main", Dir: filepath.Join(t.TempDir(), "clone")}
+		if err := repo.Pull(context.Background()); err == nil || !strings.Contains(err.Error(), "known_hosts") {
+			t.Errorf("Pull(%q) = %v, want known_hosts error", url, err)
+		}
+	}
+	for url, want := range map[string]bool{"https://github.com/example/secrets.git": false, "/srv/git/secrets": false, "./a:b": false, "git@host:repo": true, "git+ssh://host/repo": true} {
+		if got := sshURL(url); got != want {
+			t.Errorf("sshURL(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package gitrepo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Repo is a local clone of a branch of a git repository, kept up to date by pulling.
+// It uses the git command, authenticating over SSH with a deploy key if one is set.
+type Repo struct {
+	URL        string
+	Branch     string
+	Dir        string // directory the repository is cloned into
+	SSHKeyFile string // deploy key used for SSH URLs (empty uses the default SSH configuration)
+	KnownHosts string // known_hosts file the server is checked against (required for SSH URLs)
+
+	mu     gosync.RWMutex
+	commit string
+}
+
+// Pull clones the repository if it has not been cloned yet, and otherwise fetches the
+// branch and resets the clone to it.
+func (r *Repo) Pull(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.KnownHosts == "" && sshURL(r.URL) {
+		return fmt.Errorf("a known_hosts file is required to check the server of SSH repository %s", r.URL)
+	}
+	if _, err := os.Stat(filepath.Join(r.Dir, ".git")); err != nil {
+		if err := os.MkdirAll(filepath.Dir(r.Dir), 0o700); err != nil {
+			return err
+		}
+		if _, err := r.git(ctx, "", "clone", "--quiet", "--single-branch", "--branch", r.Branch, r.URL, r.Dir); err != nil {
+			return err
+		}
+	} else {
+		if _, err := r.git(ctx, r.Dir, "fetch", "--quiet", "origin", r.Branch); err != nil {
+			return err
+		}
+		if _, err := r.git(ctx, r.Dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return err
+		}
+	}
+	commit, err := r.git(ctx, r.Dir, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	if commit != r.commit {
+		klog.InfoS("Pulled git repository", "url", r.URL, "branch", r.Branch, "commit", commit)
+	}
+	r.commit = commit
+	return nil
+}
+
+// Watch pulls the repository every interval until ctx is done. Failures are logged and
+// the last pulled commit keeps being served.
+func (r *Repo) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Pull(ctx); err != nil {
+				klog.ErrorS(err, "Failed to pull git repository", "url", r.URL, "branch", r.Branch)
+			}
+		}
+	}
+}
+
+// Read calls read with the path of the file at path within the clone and the commit
+// it is at, holding off pulls until it returns. Symlinks are followed, but only to
+// files within the clone.
+func (r *Repo) Read(path string, read func(file, commit string) error) error {
+	if !filepath.IsLocal(path) {
+		return fmt.Errorf("path %q is outside the repository", path)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	file, err := filepath.EvalSymlinks(filepath.Join(r.Dir, path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return read(filepath.Join(r.Dir, path), r.commit)
+	}
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(r.Dir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(root, file); err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("path %q links outside the repository", path)
+	}
+	return read(file, r.commit)
+}
+
+// git runs a git command in dir, returning its trimmed output.
+func (r *Repo) git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if r.SSHKeyFile != "" || r.KnownHosts != "" {
+		ssh := []string{"ssh", "-o", "BatchMode=yes"}
+		if r.SSHKeyFile != "" {
+			ssh = append(ssh, "-i", shellQuote(r.SSHKeyFile), "-o", "IdentitiesOnly=yes")
+		}
+		if r.KnownHosts != "" {
+			ssh = append(ssh, "-o", "UserKnownHostsFile="+shellQuote(r.KnownHosts))
+		}
+		ssh = append(ssh, "-o", "StrictHostKeyChecking=yes")
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+strings.Join(ssh, " "))
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// sshURL reports whether git reaches url over SSH: an ssh:// URL, or the scp-like
+// "[user@]host:path" form, which has a colon before any slash.
+func sshURL(url string) bool {
+	if scheme, _, ok := strings.Cut(url, "://"); ok {
+		return scheme == "ssh" || scheme == "git+ssh" || scheme == "ssh+git"
+	}
+	colon := strings.Index(url, ":")
+	return colon > 0 && !strings.Contains(url[:colon], "/")
+}
+
+// shellQuote quotes s for GIT_SSH_COMMAND, which git runs with the shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
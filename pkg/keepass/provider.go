@@ -0,0 +1,174 @@
+// Package keepass implements a secret provider that reads entries from a mounted
+// KeePass (KDBX) database, for air-gapped and homelab clusters without a secret store.
+package keepass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"github.com/tobischo/gokeepasslib/v3"
+)
+
+func init() {
+	provider.Register(provider.Info{
+		Name:           "keepass",
+		Aliases:        []string{"kdbx"},
+		RequiredConfig: []string{"KSS_KEEPASS_FILE"},
+		Capabilities:   provider.Capabilities{Binary: true},
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			return SecretProvider{Path: cfg.KeePassFile, KeyFile: cfg.KeePassKeyFile, Password: cfg.KeePassPassword, cache: shared}, nil
+		},
+	})
+}
+
+// shared caches the unlocked database between the providers created for each request,
+// as unlocking runs a deliberately slow key derivation.
+var shared = &cache{}
+
+// SecretProvider resolves refs of the form "group/.../entry/field" to a field of an
+// entry in a KDBX database, unlocked with a password, a key file, or both. Groups are
+// named from below the root group, and entries by title. The field is a standard field
+// ("Password", "UserName", "URL", "Notes"), a custom string field, or an attachment. The
+// database is re-read when the file changes, so updates to the mount are picked up.
+type SecretProvider struct {
+	Path     string // path of the KDBX database
+	KeyFile  string // path of a key file, if one is needed to unlock it
+	Password string // master password, if one is needed to unlock it
+
+	cache *cache
+}
+
+// cache holds the last database read, with the file state it was read at.
+type cache struct {
+	mu      gosync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	db      *gokeepasslib.Database
+}
+
+func (p SecretProvider) Resolve(_ context.Context, req provider.Request) (provider.Response, error) {
+	segments := strings.Split(strings.Trim(req.Ref, "/"), "/")
+	if len(segments) < 2 || slices.Contains(segments, "") {
+		return provider.Response{}, fmt.Errorf("invalid keepass ref %q, expected group/.../entry/field", req.Ref)
+	}
+	groups, title, field := segments[:len(segments)-2], segments[len(segments)-2], segments[len(segments)-1]
+
+	db, err := p.database()
+	if err != nil {
+		return provider.Response{}, err
+	}
+	if len(db.Content.Root.Groups) == 0 {
+		return provider.Response{}, fmt.Errorf("%w: database has no root group", provider.ErrNotFound)
+	}
+	group := &db.Content.Root.Groups[0]
+	for _, name := range groups {
+		next := findGroup(group, name)
+		if next == nil {
+			return provider.Response{}, fmt.Errorf("%w: no group %q in %q", provider.ErrNotFound, name, req.Ref)
+		}
+		group = next
+	}
+
+	for i := range group.Entries {
+		entry := &group.Entries[i]
+		if entry.GetTitle() != title {
+			continue
+		}
+		if value := entry.Get(field); value != nil {
+			return provider.Response{Value: []byte(value.Value.Content)}, nil
+		}
+		for _, ref := range entry.Binaries {
+			if ref.Name != field {
+				continue
+			}
+			binary := ref.Find(db)
+			if binary == nil {
+				return provider.Response{}, fmt.Errorf("attachment %q of %q is missing from the database", field, req.Ref)
+			}
+			content, err := binary.GetContentBytes()
+			if err != nil {
+				return provider.Response{}, fmt.Errorf("reading attachment %q: %w", field, err)
+			}
+			return provider.Response{Value: content}, nil
+		}
+		return provider.Response{}, fmt.Errorf("%w: entry has no field %q in %q", provider.ErrNotFound, field, req.Ref)
+	}
+	return provider.Response{}, fmt.Errorf("%w: no entry %q in %q", provider.ErrNotFound, title, req.Ref)
+}
+
+// HealthCheck checks that the database can be read and unlocked.
+func (p SecretProvider) HealthCheck(context.Context) error {
+	_, err := p.database()
+	return err
+}
+
+// database returns the unlocked database, reading it again if the file has changed
+// since it was last read.
+func (p SecretProvider) database() (*gokeepasslib.Database, error) {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading keepass database: %w", err)
+	}
+
+	p.cache.mu.Lock()
+	defer p.cache.mu.Unlock()
+	c := p.cache
+	if c.db != nil && c.path == p.Path && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.db, nil
+	}
+
+	credentials, err := p.credentials()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading keepass database: %w", err)
+	}
+	defer f.Close()
+	db := gokeepasslib.NewDatabase()
+	db.Credentials = credentials
+	if err := gokeepasslib.NewDecoder(f).Decode(db); err != nil {
+		// A wrong password or key file fails the header or content checks
+		return nil, fmt.Errorf("%w: unlocking keepass database: %v", provider.ErrUnauthorized, err)
+	}
+	if err := db.UnlockProtectedEntries(); err != nil {
+		return nil, fmt.Errorf("unlocking keepass entries: %w", err)
+	}
+
+	c.path, c.modTime, c.size, c.db = p.Path, info.ModTime(), info.Size(), db
+	return db, nil
+}
+
+// credentials returns the credentials the database is unlocked with.
+func (p SecretProvider) credentials() (*gokeepasslib.DBCredentials, error) {
+	switch {
+	case p.KeyFile != "" && p.Password != "":
+		return gokeepasslib.NewPasswordAndKeyCredentials(p.Password, p.KeyFile)
+	case p.KeyFile != "":
+		return gokeepasslib.NewKeyCredentials(p.KeyFile)
+	case p.Password != "":
+		return gokeepasslib.NewPasswordCredentials(p.Password), nil
+	default:
+		return nil, errors.New("keepass provider needs KSS_KEEPASS_PASSWORD or KSS_KEEPASS_KEY_FILE")
+	}
+}
+
+// findGroup returns the subgroup of group with the given name.
+func findGroup(group *gokeepasslib.Group, name string) *gokeepasslib.Group {
+	for i := range group.Groups {
+		if group.Groups[i].Name == name {
+			return &group.Groups[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package keepass
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"github.com/tobischo/gokeepasslib/v3"
+	w "github.com/tobischo/gokeepasslib/v3/wrappers"
+)
+
+// writeDatabase writes a database with a "Servers/db01" entry to path.
+func writeDatabase(t *testing.T, path, password string) {
+	t.Helper()
+	entry := gokeepasslib.NewEntry()
+	entry.Values = append(entry.Values,
+		gokeepasslib.ValueData{Key: "Title", Value: gokeepasslib.V{Content: "db01"}},
+		gokeepasslib.ValueData{Key: "UserName", Value: gokeepasslib.V{Content: "admin"}},
+		gokeepasslib.ValueData{Key: "Password", Value: gokeepasslib.V{Content: "hunter2", Protected: w.NewBoolWrapper(true)}},
+	)
+	servers := gokeepasslib.NewGroup()
+	servers.Name = "Servers"
+	servers.Entries = append(servers.Entries, entry)
+	root := gokeepasslib.NewGroup()
+	root.Name = "Root"
+	root.Groups = append(root.Groups, servers)
+
+	db := gokeepasslib.NewDatabase(gokeepasslib.WithDatabaseKDBXVersion3())
+	db.Credentials = gokeepasslib.NewPasswordCredentials(password)
+	db.Content.Root = &gokeepasslib.RootData{Groups: []gokeepasslib.Group{root}}
+	if err := db.LockProtectedEntries(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := gokeepasslib.NewEncoder(f).Encode(db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.kdbx")
+	writeDatabase(t, path, "master")
+	p := SecretProvider{Path: path, Password: "master", cache: &cache{}}
+
+	for ref, want := range map[string]string{"Servers/db01/Password": "hunter2", "Servers/db01/UserName": "admin"} {
+		resp, err := p.Resolve(context.Background(), provider.Request{Ref: ref})
+		if err != nil || string(resp.Value) != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", ref, resp.Value, err, want)
+		}
+	}
+	for _, ref := range []string{"Servers/db02/Password", "Other/db01/Password", "Servers/db01/Missing"} {
+		if _, err := p.Resolve(context.Background(), provider.Request{Ref: ref}); !errors.Is(err, provider.ErrNotFound) {
+			t.Errorf("Resolve(%q) = %v, want ErrNotFound", ref, err)
+		}
+	}
+	if _, err := p.Resolve(context.Background(), provider.Request{Ref: "Password"}); err == nil {
+		t.Errorf("expected error for a ref without an entry")
+	}
+
+	wrong := SecretProvider{Path: path, Password: "wrong", cache: &cache{}}
+	if err := wrong.HealthCheck(context.Background()); !errors.Is(err, provider.ErrUnauthorized) {
+		t.Errorf("HealthCheck with the wrong password = %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,82 @@
+package kubesecret
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/transform"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	provider.Register(provider.Info{
+		Name:            "cert-manager",
+		NamespaceScoped: true,
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			return CertificateProvider{SecretProvider{Clientset: cfg.Clientset, ShareWith: cfg.Annotations.ShareWith}}, nil
+		},
+	})
+}
+
+// CertManagerCertificateAnnotation is set by cert-manager on the Secrets it issues
+// certificates into.
+const CertManagerCertificateAnnotation = "cert-manager.io/certificate-name"
+
+// CertManagerManaged reports whether cert-manager writes to secret, either because it
+// issued a certificate into it or because a Certificate owns it.
+func CertManagerManaged(secret *v1.Secret) bool {
+	if secret.Annotations[CertManagerCertificateAnnotation] != "" {
+		return true
+	}
+	return slices.ContainsFunc(secret.OwnerReferences, func(ref metav1.OwnerReference) bool {
+		return ref.Kind == "Certificate" && strings.HasPrefix(ref.APIVersion, "cert-manager.io/")
+	})
+}
+
+// CertificateProvider resolves refs of the form "namespace/name" to the certificate
+// cert-manager issued into a Secret, as one PEM bundle of its private key, certificate
+// chain, and CA. This lets a separate Secret reshape the certificate, with the
+// pem-bundle transformation or a keystore, instead of writing to the Secret
+// cert-manager owns. The namespace and sharing rules are those of the kubernetes
+// provider.
+type CertificateProvider struct {
+	SecretProvider
+}
+
+func (p CertificateProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	namespace, name, ok := strings.Cut(req.Ref, "/")
+	if !ok {
+		namespace, name = req.Namespace, namespace
+	}
+	if namespace == "" || name == "" || strings.Contains(name, "#") {
+		return provider.Response{}, fmt.Errorf("invalid cert-manager ref %q, expected namespace/name", req.Ref)
+	}
+	secret, err := p.get(ctx, req, namespace, name)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	if !CertManagerManaged(secret) {
+		return provider.Response{}, fmt.Errorf("%w: secret %s/%s was not issued by cert-manager", provider.ErrNotFound, namespace, name)
+	}
+	if len(secret.Data[transform.TLSCertKey]) == 0 || len(secret.Data[transform.TLSKeyKey]) == 0 {
+		return provider.Response{}, fmt.Errorf("%w: secret %s/%s has no certificate issued yet", provider.ErrNotFound, namespace, name)
+	}
+
+	var bundle bytes.Buffer
+	for _, key := range []string{transform.TLSKeyKey, transform.TLSCertKey, transform.CACertKey} {
+		part := bytes.TrimSpace(secret.Data[key])
+		// Issuers may repeat the CA at the end of the chain
+		if len(part) == 0 || bytes.Contains(bundle.Bytes(), part) {
+			continue
+		}
+		bundle.Write(part)
+		bundle.WriteByte('\n')
+	}
+	return provider.Response{Value: bundle.Bytes(), Version: secret.ResourceVersion}, nil
+}
@@ -0,0 +1,112 @@
+// Package kubesecret implements a secret provider that reads other Secrets in the
+// cluster, so values can be fanned out or reshaped with key mappings and templates
+// without an external secret store.
+package kubesecret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func init() {
+	provider.Register(provider.Info{
+		Name:            "kubernetes",
+		Aliases:         []string{"k8s"},
+		Capabilities:    provider.Capabilities{Binary: true},
+		NamespaceScoped: true,
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			return SecretProvider{Clientset: cfg.Clientset, ShareWith: cfg.Annotations.ShareWith}, nil
+		},
+	})
+}
+
+// SecretProvider resolves refs of the form "namespace/name#key" to a key of another
+// Secret, or "namespace/name" to all of its keys as a JSON object of strings for key
+// mappings and templates; the namespace may be left out to read from the requesting
+// secret's own namespace. Secrets in other namespaces can only be read if they list
+// the requesting namespace in their share-with annotation. The response version is
+// the source Secret's resource version.
+type SecretProvider struct {
+	Clientset kubernetes.Interface
+	ShareWith string // annotation listing the namespaces a Secret is shared with
+}
+
+func (p SecretProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	name, key, hasKey := strings.Cut(req.Ref, "#")
+	namespace, name, ok := strings.Cut(name, "/")
+	if !ok {
+		namespace, name = req.Namespace, namespace
+	}
+	if namespace == "" || name == "" || (hasKey && key == "") {
+		return provider.Response{}, fmt.Errorf("invalid kubernetes ref %q, expected namespace/name#key", req.Ref)
+	}
+
+	secret, err := p.get(ctx, req, namespace, name)
+	if err != nil {
+		return provider.Response{}, err
+	}
+
+	if hasKey {
+		value, ok := secret.Data[key]
+		if !ok {
+			return provider.Response{}, fmt.Errorf("%w: secret %s/%s has no key %q", provider.ErrNotFound, namespace, name, key)
+		}
+		return provider.Response{Value: value, Version: secret.ResourceVersion}, nil
+	}
+	fields := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		fields[k] = string(v)
+	}
+	value, err := json.Marshal(fields)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	return provider.Response{Value: value, Version: secret.ResourceVersion}, nil
+}
+
+// HealthCheck checks that the operator can still reach the API server.
+func (p SecretProvider) HealthCheck(ctx context.Context) error {
+	_, err := p.Clientset.Discovery().ServerVersion()
+	return err
+}
+
+// get returns the Secret namespace/name, if the requesting namespace may read it.
+func (p SecretProvider) get(ctx context.Context, req provider.Request, namespace, name string) (*v1.Secret, error) {
+	secret, err := p.Clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil, fmt.Errorf("%w: secret %s/%s", provider.ErrNotFound, namespace, name)
+	case apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err):
+		return nil, fmt.Errorf("%w: %v", provider.ErrUnauthorized, err)
+	case err != nil:
+		return nil, err
+	}
+	if namespace != req.Namespace && !sharedWith(secret.Annotations[p.ShareWith], req.Namespace) {
+		return nil, fmt.Errorf("%w: secret %s/%s is not shared with namespace %q", provider.ErrUnauthorized, namespace, name, req.Namespace)
+	}
+	return secret, nil
+}
+
+// sharedWith reports whether namespace matches one of the comma separated glob
+// patterns in shareWith.
+func sharedWith(shareWith, namespace string) bool {
+	if namespace == "" {
+		return false
+	}
+	for _, pattern := range strings.Split(shareWith, ",") {
+		if matched, err := path.Match(strings.TrimSpace(pattern), namespace); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
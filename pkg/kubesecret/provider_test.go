@@ -0,0 +1,95 @@
+package kubesecret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolve(t *testing.T) {
+	p := SecretProvider{
+		ShareWith: "share-with",
+		Clientset: fake.NewSimpleClientset(
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: "db", Annotations: map[string]string{"share-with": "team-*"}},
+				Data:       map[string][]byte{"username": []byte("admin"), "password": []byte("hunter2")},
+			},
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: "private"},
+				Data:       map[string][]byte{"token": []byte("t0k3n")},
+			},
+		),
+	}
+	resolve := func(namespace, ref string) (string, error) {
+		resp, err := p.Resolve(context.Background(), provider.Request{Ref: ref, Namespace: namespace})
+		return string(resp.Value), err
+	}
+
+	if got, err := resolve("team-a", "platform/db#password"); err != nil || got != "hunter2" {
+		t.Errorf("shared key = %q, %v; want hunter2", got, err)
+	}
+	if got, err := resolve("platform", "private#token"); err != nil || got != "t0k3n" {
+		t.Errorf("same namespace key = %q, %v; want t0k3n", got, err)
+	}
+	got, err := resolve("team-a", "platform/db")
+	var fields map[string]string
+	if err != nil || json.Unmarshal([]byte(got), &fields) != nil || fields["username"] != "admin" {
+		t.Errorf("whole secret = %q, %v; want JSON of its keys", got, err)
+	}
+
+	if _, err := resolve("team-a", "platform/private#token"); !errors.Is(err, provider.ErrUnauthorized) {
+		t.Errorf("unshared secret = %v, want ErrUnauthorized", err)
+	}
+	if _, err := resolve("other", "platform/db#password"); !errors.Is(err, provider.ErrUnauthorized) {
+		t.Errorf("secret shared with other namespaces = %v, want ErrUnauthorized", err)
+	}
+	for _, ref := range []string{"platform/missing#key", "platform/db#missing"} {
+		if _, err := resolve("platform", ref); !errors.Is(err, provider.ErrNotFound) {
+			t.Errorf("Resolve(%q) = %v, want ErrNotFound", ref, err)
+		}
+	}
+	if _, err := resolve("", "db#password"); err == nil {
+		t.Errorf("expected error for a ref without a namespace")
+	}
+}
+
+func TestResolveCertificate(t *testing.T) {
+	issued := map[string]string{CertManagerCertificateAnnotation: "api", "share-with": "team-a"}
+	p := CertificateProvider{SecretProvider{
+		ShareWith: "share-with",
+		Clientset: fake.NewSimpleClientset(
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: "api-tls", Annotations: issued},
+				Data: map[string][]byte{
+					"tls.key": []byte("KEY\n"),
+					"tls.crt": []byte("LEAF\nROOT\n"),
+					"ca.crt":  []byte("ROOT\n"),
+				},
+			},
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: "manual-tls", Annotations: map[string]string{"share-with": "team-a"}},
+				Data:       map[string][]byte{"tls.key": []byte("KEY"), "tls.crt": []byte("LEAF")},
+			},
+		),
+	}}
+	resolve := func(namespace, ref string) (string, error) {
+		resp, err := p.Resolve(context.Background(), provider.Request{Ref: ref, Namespace: namespace})
+		return string(resp.Value), err
+	}
+
+	if got, err := resolve("team-a", "platform/api-tls"); err != nil || got != "KEY\nLEAF\nROOT\n" {
+		t.Errorf("certificate bundle = %q, %v; want key and chain without the repeated CA", got, err)
+	}
+	if _, err := resolve("team-a", "platform/manual-tls"); !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("secret not issued by cert-manager = %v, want ErrNotFound", err)
+	}
+	if _, err := resolve("other", "platform/api-tls"); !errors.Is(err, provider.ErrUnauthorized) {
+		t.Errorf("unshared certificate = %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,70 @@
+// Package logging sets log verbosity per subsystem, so one subsystem or provider can
+// be debugged without raising klog's -v for the whole operator.
+package logging
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"k8s.io/klog/v2"
+)
+
+// Subsystems whose verbosity can be set. A single provider can be set as
+// "providers/<name>", falling back to the level for all providers.
+const (
+	Sync      = "sync"
+	Providers = "providers"
+	Webhook   = "webhook"
+	Cache     = "cache"
+)
+
+var levels atomic.Pointer[map[string]klog.Level]
+
+// Provider returns the subsystem of the named provider.
+func Provider(name string) string {
+	return Providers + "/" + name
+}
+
+// SetLevels sets the verbosity of subsystems from a comma separated list of
+// "subsystem=level", e.g. "providers/op=4,cache=2". Subsystems not listed log at
+// klog's -v.
+func SetLevels(spec string) error {
+	parsed := map[string]klog.Level{}
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		subsystem, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		level, err := strconv.ParseUint(value, 10, 31)
+		if !ok || err != nil {
+			return fmt.Errorf("invalid log level %q, expected subsystem=level", entry)
+		}
+		base, name, hasName := strings.Cut(subsystem, "/")
+		switch {
+		case hasName && (base != Providers || name == ""):
+			return fmt.Errorf("invalid log subsystem %q, only providers can be set individually", subsystem)
+		case base != Sync && base != Providers && base != Webhook && base != Cache:
+			return fmt.Errorf("unknown log subsystem %q, expected sync, providers, webhook or cache", subsystem)
+		}
+		parsed[subsystem] = klog.Level(level)
+	}
+	levels.Store(&parsed)
+	return nil
+}
+
+// V returns a klog.Verbose that is enabled if level is within the verbosity set for
+// the subsystem, or within klog's -v.
+func V(subsystem string, level klog.Level) klog.Verbose {
+	if current := levels.Load(); current != nil {
+		set, ok := (*current)[subsystem]
+		if base, _, hasName := strings.Cut(subsystem, "/"); !ok && hasName {
+			set, ok = (*current)[base]
+		}
+		if ok && level <= set {
+			return klog.V(0)
+		}
+	}
+	return klog.V(level)
+}
@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"testing"
+
+	"k8s.io/klog/v2"
+)
+
+func TestSetLevels(t *testing.T) {
+	for _, spec := range []string{"sync", "sync=-1", "informer=2", "cache/redis=2", "providers/=4"} {
+		if err := SetLevels(spec); err == nil {
+			t.Errorf("SetLevels(%q) succeeded, want error", spec)
+		}
+	}
+
+	if err := SetLevels("providers=2, providers/op=4,cache=1"); err != nil {
+		t.Fatalf("SetLevels: %v", err)
+	}
+	defer SetLevels("")
+	tests := []struct {
+		subsystem string
+		level     klog.Level
+		want      bool
+	}{
+		{Provider("op"), 4, true},
+		{Provider("op"), 5, false},
+		{Provider("aws-sm"), 2, true},
+		{Provider("aws-sm"), 3, false},
+		{Cache, 1, true},
+		{Sync, 1, false},
+	}
+	for _, tt := range tests {
+		if got := V(tt.subsystem, tt.level).Enabled(); got != tt.want {
+			t.Errorf("V(%q, %d) enabled = %v, want %v", tt.subsystem, tt.level, got, tt.want)
+		}
+	}
+}
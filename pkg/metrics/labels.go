@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Granularities of the labels identifying secrets on per-secret metrics. Coarser
+// granularities aggregate the secrets' series, for clusters with too many secrets
+// to keep a series for each.
+const (
+	// SecretLabelsSecret labels series with the secret's namespace and name (the default).
+	SecretLabelsSecret = "secret"
+	// SecretLabelsNamespace labels series with the namespace only, leaving name empty.
+	SecretLabelsNamespace = "namespace"
+	// SecretLabelsNone leaves both labels empty, aggregating all secrets into one series.
+	SecretLabelsNone = "none"
+)
+
+var (
+	labelsMu     sync.Mutex
+	secretLabels = SecretLabelsSecret
+	flagged      = make(map[*prometheus.GaugeVec]map[[2]string]bool) // secrets each per-secret gauge is set for
+)
+
+// SetSecretLabels sets the granularity of the labels identifying secrets.
+func SetSecretLabels(granularity string) error {
+	switch granularity {
+	case "":
+		granularity = SecretLabelsSecret
+	case SecretLabelsSecret, SecretLabelsNamespace, SecretLabelsNone:
+	default:
+		return fmt.Errorf("unknown secret label granularity %q, expected secret, namespace, or none", granularity)
+	}
+	labelsMu.Lock()
+	defer labelsMu.Unlock()
+	secretLabels = granularity
+	return nil
+}
+
+// secretLabelValues returns the namespace and name label values for a secret at
+// the configured granularity. Callers must hold labelsMu.
+func secretLabelValues(namespace, name string) (string, string) {
+	switch secretLabels {
+	case SecretLabelsNamespace:
+		return namespace, ""
+	case SecretLabelsNone:
+		return "", ""
+	default:
+		return namespace, name
+	}
+}
+
+// RecordTamper counts managed data of a secret found modified out-of-band.
+func RecordTamper(namespace, name string) {
+	labelsMu.Lock()
+	defer labelsMu.Unlock()
+	TamperDetected.WithLabelValues(secretLabelValues(namespace, name)).Inc()
+}
+
+// SetPendingApproval records whether a secret has a value change awaiting approval.
+func SetPendingApproval(namespace, name string, pending bool) {
+	setSecretGauge(PendingApproval, namespace, name, pending)
+}
+
+// SetDegraded records whether a secret has gone without a successful sync for longer
+// than the sync deadline.
+func SetDegraded(namespace, name string, degraded bool) {
+	setSecretGauge(SecretDegraded, namespace, name, degraded)
+}
+
+// setSecretGauge sets a per-secret gauge to whether a condition holds for a secret.
+// At coarser granularities the gauge counts the secrets in each series it holds for.
+func setSecretGauge(gauge *prometheus.GaugeVec, namespace, name string, on bool) {
+	labelsMu.Lock()
+	defer labelsMu.Unlock()
+	secrets := flagged[gauge]
+	if secrets == nil {
+		secrets = make(map[[2]string]bool)
+		flagged[gauge] = secrets
+	}
+	if on {
+		secrets[[2]string{namespace, name}] = true
+	} else {
+		delete(secrets, [2]string{namespace, name})
+	}
+
+	ns, n := secretLabelValues(namespace, name)
+	gauge.WithLabelValues(ns, n).Set(float64(countSeries(secrets, ns, n)))
+}
+
+// ForgetSecret removes a deleted secret from the per-secret metrics, deleting the
+// series it was the only secret in.
+func ForgetSecret(namespace, name string) {
+	labelsMu.Lock()
+	defer labelsMu.Unlock()
+	ns, n := secretLabelValues(namespace, name)
+	for _, gauge := range []*prometheus.GaugeVec{PendingApproval, SecretDegraded} {
+		secrets := flagged[gauge]
+		delete(secrets, [2]string{namespace, name})
+		if count := countSeries(secrets, ns, n); count > 0 {
+			gauge.WithLabelValues(ns, n).Set(float64(count))
+		} else {
+			gauge.DeleteLabelValues(ns, n)
+		}
+	}
+	if secretLabels == SecretLabelsSecret {
+		TamperDetected.DeleteLabelValues(namespace, name)
+	}
+}
+
+// countSeries returns how many of secrets fall in the series labeled ns and n.
+// Callers must hold labelsMu.
+func countSeries(secrets map[[2]string]bool, ns, n string) int {
+	count := 0
+	for secret := range secrets {
+		if sns, sn := secretLabelValues(secret[0], secret[1]); sns == ns && sn == n {
+			count++
+		}
+	}
+	return count
+}
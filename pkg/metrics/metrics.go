@@ -0,0 +1,186 @@
+// Package metrics defines the Prometheus metrics exported by the operator and
+// serves them over HTTP.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog/v2"
+)
+
+// Registry holds all operator metrics, along with the standard Go and process
+// collectors, which include the goroutine count (go_goroutines).
+var Registry = prometheus.NewRegistry()
+
+var (
+	// TamperDetected counts managed data keys found modified out-of-band. Record with
+	// RecordTamper, which applies the configured secret label granularity.
+	TamperDetected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kss",
+		Name:      "tamper_detected_total",
+		Help:      "Number of times managed secret data was found modified outside of the operator.",
+	}, []string{"namespace", "name"})
+
+	// ProviderHealthy is 1 for providers whose last health check passed, 0 otherwise.
+	ProviderHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kss",
+		Name:      "provider_healthy",
+		Help:      "Whether the last health check of a secret provider succeeded.",
+	}, []string{"provider"})
+
+	// ProviderUnauthorized counts provider requests rejected for invalid credentials or
+	// missing access.
+	ProviderUnauthorized = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kss",
+		Name:      "provider_unauthorized_total",
+		Help:      "Number of provider requests rejected as unauthorized.",
+	}, []string{"provider"})
+
+	// PendingApproval is 1 for secrets holding a value change until it is approved. Set
+	// with SetPendingApproval, which applies the configured secret label granularity.
+	PendingApproval = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kss",
+		Name:      "pending_approval",
+		Help:      "Whether a secret has a refreshed value change awaiting approval.",
+	}, []string{"namespace", "name"})
+
+	// SecretDegraded is 1 for secrets that have gone without a successful sync for
+	// longer than the sync deadline. Set with SetDegraded, which applies the configured
+	// secret label granularity.
+	SecretDegraded = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kss",
+		Name:      "secret_degraded",
+		Help:      "Whether a secret has gone without a successful sync for longer than the sync deadline.",
+	}, []string{"namespace", "name"})
+
+	// ProviderRequestsDeduplicated counts provider requests saved by sharing a value
+	// resolved for one secret with the other secrets referencing the same ref.
+	ProviderRequestsDeduplicated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kss",
+		Name:      "provider_requests_deduplicated_total",
+		Help:      "Number of provider requests avoided by sharing values between secrets with the same ref.",
+	}, []string{"provider"})
+
+	// SecretsSkipped counts the secrets the controller saw and did not sync, by reason,
+	// in place of logging each one.
+	SecretsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kss",
+		Name:      "secrets_skipped_total",
+		Help:      "Number of times a secret was skipped rather than synced, by reason.",
+	}, []string{"reason"})
+
+	// ObservedSecrets counts the secrets an operator in observe-only mode would manage,
+	// by provider and what syncing them would do.
+	ObservedSecrets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kss",
+		Name:      "observed_secrets",
+		Help:      "Number of annotated secrets seen in observe-only mode, by provider and outcome.",
+	}, []string{"provider", "outcome"})
+
+	// RefreshSlowdown is the factor refresh intervals are multiplied by while the
+	// Kubernetes API server is throttling the operator.
+	RefreshSlowdown = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "kss",
+		Name:      "refresh_slowdown",
+		Help:      "Factor by which secret refreshes are slowed due to API server throttling.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		TamperDetected,
+		PendingApproval,
+		SecretDegraded,
+		ProviderHealthy,
+		ProviderUnauthorized,
+		ProviderRequestsDeduplicated,
+		SecretsSkipped,
+		ObservedSecrets,
+		RefreshSlowdown,
+	)
+}
+
+// readyzChecks are the named checks reported by /readyz.
+var (
+	readyzMu     sync.Mutex
+	readyzChecks = make(map[string]func() error)
+)
+
+// AddReadyzCheck registers a named check reported by /readyz. The endpoint fails
+// while any check returns an error.
+func AddReadyzCheck(name string, check func() error) {
+	readyzMu.Lock()
+	defer readyzMu.Unlock()
+	readyzChecks[name] = check
+}
+
+// readyz reports each readiness check in the style of the Kubernetes API server,
+// e.g. "[+]provider-op ok", returning 503 if any of them fail.
+func readyz(w http.ResponseWriter, _ *http.Request) {
+	readyzMu.Lock()
+	names := slices.Sorted(maps.Keys(readyzChecks))
+	checks := maps.Clone(readyzChecks)
+	readyzMu.Unlock()
+
+	var body strings.Builder
+	status := http.StatusOK
+	for _, name := range names {
+		if err := checks[name](); err != nil {
+			status = http.StatusServiceUnavailable
+			fmt.Fprintf(&body, "[-]%s failed: %v\n", name, err)
+		} else {
+			fmt.Fprintf(&body, "[+]%s ok\n", name)
+		}
+	}
+	if status == http.StatusOK {
+		body.WriteString("readyz check passed\n")
+	} else {
+		body.WriteString("readyz check failed\n")
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, body.String())
+}
+
+// Serve exposes the registry at /metrics, and readiness at /readyz, on addr until
+// ctx is cancelled.
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/readyz", readyz)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.ErrorS(err, "Failed to shut down metrics server")
+		}
+	}()
+
+	klog.InfoS("Serving metrics", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestReadyz(t *testing.T) {
+	var healthErr error
+	AddReadyzCheck("provider-test", func() error { return healthErr })
+	t.Cleanup(func() {
+		readyzMu.Lock()
+		delete(readyzChecks, "provider-test")
+		readyzMu.Unlock()
+	})
+
+	rec := httptest.NewRecorder()
+	readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "[+]provider-test ok") {
+		t.Errorf("body = %q, want passing check listed", rec.Body.String())
+	}
+
+	healthErr = errors.New("connection refused")
+	rec = httptest.NewRecorder()
+	readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "[-]provider-test failed: connection refused") {
+		t.Errorf("body = %q, want failing check listed", rec.Body.String())
+	}
+}
+
+func TestSecretLabelGranularity(t *testing.T) {
+	t.Cleanup(func() {
+		_ = SetSecretLabels(SecretLabelsSecret)
+		labelsMu.Lock()
+		clear(flagged)
+		labelsMu.Unlock()
+		PendingApproval.Reset()
+	})
+	if err := SetSecretLabels("pod"); err == nil {
+		t.Errorf("expected error for unknown granularity")
+	}
+
+	if err := SetSecretLabels(SecretLabelsNamespace); err != nil {
+		t.Fatal(err)
+	}
+	SetPendingApproval("team-a", "db", true)
+	SetPendingApproval("team-a", "api", true)
+	SetPendingApproval("team-b", "db", true)
+	SetPendingApproval("team-a", "db", false)
+
+	if got := testutil.ToFloat64(PendingApproval.WithLabelValues("team-a", "")); got != 1 {
+		t.Errorf("team-a pending = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(PendingApproval.WithLabelValues("team-b", "")); got != 1 {
+		t.Errorf("team-b pending = %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(PendingApproval); n != 2 {
+		t.Errorf("series = %d, want 2 (one per namespace)", n)
+	}
+
+	// Deleted secrets leave their series, which is removed once no secret is in it
+	ForgetSecret("team-a", "api")
+	ForgetSecret("team-b", "other")
+	if got := testutil.ToFloat64(PendingApproval.WithLabelValues("team-b", "")); got != 1 {
+		t.Errorf("team-b pending = %v, want 1 after another secret is forgotten", got)
+	}
+	if n := testutil.CollectAndCount(PendingApproval); n != 1 {
+		t.Errorf("series = %d, want 1 after team-a's last secret is forgotten", n)
+	}
+}
+
+func TestWorkqueueMetrics(t *testing.T) {
+	queue := workqueue.NewTypedWithConfig(workqueue.TypedQueueConfig[string]{Name: "test-queue"})
+	defer queue.ShutDown()
+	queue.Add("a")
+	queue.Add("b")
+
+	if got := testutil.ToFloat64(workqueueDepth.WithLabelValues("test-queue")); got != 2 {
+		t.Errorf("depth = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(workqueueAdds.WithLabelValues("test-queue")); got != 2 {
+		t.Errorf("adds = %v, want 2", got)
+	}
+}
+
+func TestRegisterInformerObjects(t *testing.T) {
+	RegisterInformerObjects("test-informer", func() int { return 3 })
+	RegisterInformerObjects("test-informer", func() int { return 3 })
+
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "kss_informer_objects" {
+			if got := family.GetMetric()[0].GetGauge().GetValue(); got != 3 {
+				t.Errorf("informer objects = %v, want 3", got)
+			}
+			return
+		}
+	}
+	t.Errorf("kss_informer_objects not exported")
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Work queue metrics, labelled by queue name, so a backlog building up can be seen
+// before it turns into stale secrets or memory pressure.
+var (
+	workqueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kss",
+		Subsystem: "workqueue",
+		Name:      "depth",
+		Help:      "Number of items waiting in a work queue.",
+	}, []string{"name"})
+
+	workqueueAdds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kss",
+		Subsystem: "workqueue",
+		Name:      "adds_total",
+		Help:      "Number of items added to a work queue.",
+	}, []string{"name"})
+
+	workqueueLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "kss",
+		Subsystem: "workqueue",
+		Name:      "queue_duration_seconds",
+		Help:      "Seconds an item waits in a work queue before it is processed.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"name"})
+
+	workqueueWorkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "kss",
+		Subsystem: "workqueue",
+		Name:      "work_duration_seconds",
+		Help:      "Seconds taken to process an item from a work queue.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"name"})
+
+	workqueueUnfinished = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kss",
+		Subsystem: "workqueue",
+		Name:      "unfinished_work_seconds",
+		Help:      "Seconds of work in progress that has not yet been observed by work_duration_seconds.",
+	}, []string{"name"})
+
+	workqueueLongestRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kss",
+		Subsystem: "workqueue",
+		Name:      "longest_running_processor_seconds",
+		Help:      "Seconds the longest running item of a work queue has been processing.",
+	}, []string{"name"})
+
+	workqueueRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kss",
+		Subsystem: "workqueue",
+		Name:      "retries_total",
+		Help:      "Number of items re-added to a work queue for a retry.",
+	}, []string{"name"})
+)
+
+func init() {
+	Registry.MustRegister(
+		workqueueDepth,
+		workqueueAdds,
+		workqueueLatency,
+		workqueueWorkDuration,
+		workqueueUnfinished,
+		workqueueLongestRunning,
+		workqueueRetries,
+	)
+	workqueue.SetProvider(workqueueMetrics{})
+}
+
+// workqueueMetrics exports the metrics of client-go work queues created with a name.
+type workqueueMetrics struct{}
+
+func (workqueueMetrics) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return workqueueLatency.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return workqueueWorkDuration.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueUnfinished.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueLongestRunning.WithLabelValues(name)
+}
+
+func (workqueueMetrics) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.WithLabelValues(name)
+}
+
+// RegisterInformerObjects exports the number of objects held in an informer's
+// cache, counted by count when the metrics are scraped. Registering a name again
+// is a no-op.
+func RegisterInformerObjects(name string, count func() int) {
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   "kss",
+		Name:        "informer_objects",
+		Help:        "Number of objects held in an informer's cache.",
+		ConstLabels: prometheus.Labels{"informer": name},
+	}, func() float64 { return float64(count()) })
+	if err := Registry.Register(gauge); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if !errors.As(err, &already) {
+			panic(err)
+		}
+	}
+}
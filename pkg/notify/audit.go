@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// AuditGenesis is the Prev of the first entry of a new audit log, so a log cannot be
+// passed off as complete with its leading entries removed.
+const AuditGenesis = "genesis"
+
+// AuditEntry is one line of the audit log. When the log is signed, Signature is an
+// HMAC-SHA256 of the entry with Signature empty, and Prev is the signature of the
+// entry before it, or AuditGenesis for the first, so that edited, reordered or
+// removed entries can be detected.
+type AuditEntry struct {
+	Time      string `json:"time"`
+	Event     string `json:"event"` // "sync_success", "sync_failure" or "credential_error"
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Provider  string `json:"provider,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Reason    string `json:"reason,omitempty"` // reason a break-glass secret was checked out
+	Prev      string `json:"prev,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Audit appends sync outcomes to a log as JSON lines, signing them if it has a key.
+type Audit struct {
+	Writer      io.Writer
+	Key         []byte // HMAC key entries are signed with; nil leaves them unsigned
+	Cluster     string // name of the cluster included in each entry, if set
+	ProviderKey string // annotation naming a secret's provider
+	ReasonKey   string // annotation giving the reason a break-glass secret is checked out
+	Now         func() time.Time
+
+	// HeadPath is a file the signature of the latest entry is written to, kept apart
+	// from the log so removing entries from its end can be detected; empty disables.
+	HeadPath string
+
+	mu   gosync.Mutex
+	prev string
+}
+
+func newAuditFromConfig(cfg *config.Sync, _ record.EventRecorder) (Notifier, error) {
+	key, err := base64.StdEncoding.DecodeString(cfg.AuditKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding KSS_AUDIT_KEY: %w", err)
+	}
+	a := &Audit{Writer: os.Stdout, Key: key, Cluster: cfg.ClusterName, ProviderKey: cfg.Annotations.ProviderName, ReasonKey: cfg.Annotations.BreakGlassReason, Now: time.Now, HeadPath: cfg.AuditHeadPath}
+	if cfg.AuditLogPath == "" {
+		return a, nil
+	}
+
+	// Continue the chain from the last entry already in the log, which must be the
+	// recorded head once one has been recorded
+	existing, err := os.ReadFile(cfg.AuditLogPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if lines := bytes.Split(bytes.TrimSpace(existing), []byte("\n")); len(lines[len(lines)-1]) > 0 {
+		var last AuditEntry
+		if err := json.Unmarshal(lines[len(lines)-1], &last); err != nil {
+			return nil, fmt.Errorf("reading last audit entry: %w", err)
+		}
+		a.prev = last.Signature
+	}
+	if a.HeadPath != "" {
+		head, err := ReadAuditHead(a.HeadPath)
+		if err != nil {
+			return nil, err
+		}
+		if head != "" && head != a.prev {
+			return nil, fmt.Errorf("audit log %s does not end at the head recorded in %s", cfg.AuditLogPath, a.HeadPath)
+		}
+	}
+	f, err := os.OpenFile(cfg.AuditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	a.Writer = f
+	return a, nil
+}
+
+func (a *Audit) OnSyncSuccess(_ context.Context, secret *v1.Secret) {
+	a.write(AuditEntry{Event: "sync_success", Namespace: secret.Namespace, Name: secret.Name, Provider: secret.Annotations[a.ProviderKey], Reason: secret.Annotations[a.ReasonKey]})
+}
+
+func (a *Audit) OnSyncFailure(_ context.Context, secret *v1.Secret, err error) {
+	a.write(AuditEntry{Event: "sync_failure", Namespace: secret.Namespace, Name: secret.Name, Provider: secret.Annotations[a.ProviderKey], Error: err.Error()})
+}
+
+func (a *Audit) OnCredentialError(_ context.Context, secret *v1.Secret, providerName string, err error) {
+	a.write(AuditEntry{Event: "credential_error", Namespace: secret.Namespace, Name: secret.Name, Provider: providerName, Error: err.Error()})
+}
+
+func (a *Audit) write(entry AuditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry.Time = a.Now().UTC().Format(time.RFC3339Nano)
+	entry.Cluster = a.Cluster
+	if len(a.Key) > 0 {
+		entry.Prev = a.prev
+		if entry.Prev == "" {
+			entry.Prev = AuditGenesis
+		}
+		entry.Signature = signAudit(a.Key, entry)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		klog.ErrorS(err, "Failed to encode audit entry", "namespace", entry.Namespace, "name", entry.Name)
+		return
+	}
+	if _, err := a.Writer.Write(append(line, '\n')); err != nil {
+		klog.ErrorS(err, "Failed to write audit entry", "namespace", entry.Namespace, "name", entry.Name)
+		return
+	}
+	a.prev = entry.Signature
+	if a.HeadPath != "" && entry.Signature != "" {
+		if err := writeAuditHead(a.HeadPath, entry.Signature); err != nil {
+			klog.ErrorS(err, "Failed to record audit log head", "path", a.HeadPath)
+		}
+	}
+}
+
+// ReadAuditHead returns the signature of the latest audit entry recorded at path,
+// or "" if none has been recorded yet.
+func ReadAuditHead(path string) (string, error) {
+	head, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return string(bytes.TrimSpace(head)), err
+}
+
+// writeAuditHead records head at path, replacing the file so it is never partial.
+func writeAuditHead(path, head string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(head+"\n"), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// signAudit returns the hex HMAC-SHA256 of entry with its signature cleared.
+func signAudit(key []byte, entry AuditEntry) string {
+	entry.Signature = ""
+	body, _ := json.Marshal(entry) // a struct of strings always encodes
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyAudit checks that every entry of the audit log read from r is signed with
+// key and chained to the entry before it, the first following prev: AuditGenesis for
+// a new log, or the last signature of the log it was rotated from. It returns the
+// number of entries checked and the signature of the last, which should be compared
+// with the head recorded outside the log to detect entries removed from its end.
+func VerifyAudit(r io.Reader, key []byte, prev string) (n int, head string, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return n, prev, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !hmac.Equal([]byte(entry.Signature), []byte(signAudit(key, entry))) {
+			return n, prev, fmt.Errorf("line %d: invalid signature", line)
+		}
+		if entry.Prev != prev {
+			return n, prev, fmt.Errorf("line %d: does not follow the previous entry", line)
+		}
+		prev = entry.Signature
+		n++
+	}
+	return n, prev, scanner.Err()
+}
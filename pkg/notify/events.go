@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Events publishes sync outcomes as Kubernetes Events on the secret. Credential
+// errors are left out, as the controller records them whichever notifiers are
+// configured.
+type Events struct {
+	Recorder record.EventRecorder
+}
+
+func (e Events) OnSyncSuccess(_ context.Context, secret *v1.Secret) {
+	e.Recorder.Event(secret, v1.EventTypeNormal, "Synced", "Secret synced from provider")
+}
+
+func (e Events) OnSyncFailure(_ context.Context, secret *v1.Secret, err error) {
+	e.Recorder.Eventf(secret, v1.EventTypeWarning, "SyncFailed", "Failed to sync secret: %v", err)
+}
+
+func (e Events) OnCredentialError(context.Context, *v1.Secret, string, error) {}
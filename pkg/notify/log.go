@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Log writes sync outcomes to the operator log.
+type Log struct {
+	Cluster string // name of the cluster included in each entry, if set
+}
+
+func (l Log) OnSyncSuccess(_ context.Context, secret *v1.Secret) {
+	klog.InfoS("Secret synced", "cluster", l.Cluster, "namespace", secret.Namespace, "name", secret.Name)
+}
+
+func (l Log) OnSyncFailure(_ context.Context, secret *v1.Secret, err error) {
+	klog.ErrorS(err, "Secret sync failed", "cluster", l.Cluster, "namespace", secret.Namespace, "name", secret.Name)
+}
+
+func (l Log) OnCredentialError(_ context.Context, secret *v1.Secret, providerName string, err error) {
+	klog.ErrorS(err, "Provider rejected credentials", "cluster", l.Cluster, "namespace", secret.Namespace, "name", secret.Name, "provider", providerName)
+}
@@ -0,0 +1,108 @@
+// Package notify reports sync outcomes to pluggable notifiers. Built-in notifiers
+// log, call a webhook, publish Kubernetes Events, or append to a signed audit log; others can be compiled in by
+// registering them from an init function.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	gosync "sync"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Notifier is told about the outcome of syncing a secret. Implementations should
+// not block for long, as they are called from the sync workers.
+type Notifier interface {
+	// OnSyncSuccess is called after a secret is written with a new value.
+	OnSyncSuccess(ctx context.Context, secret *v1.Secret)
+
+	// OnSyncFailure is called when syncing a secret fails and will be retried.
+	OnSyncFailure(ctx context.Context, secret *v1.Secret, err error)
+
+	// OnCredentialError is called when a provider rejects the operator's credentials,
+	// which needs someone to intervene.
+	OnCredentialError(ctx context.Context, secret *v1.Secret, providerName string, err error)
+}
+
+// Factory creates a notifier from the operator configuration.
+type Factory func(cfg *config.Sync, recorder record.EventRecorder) (Notifier, error)
+
+var (
+	mu        gosync.Mutex
+	factories = map[string]Factory{
+		"log": func(cfg *config.Sync, _ record.EventRecorder) (Notifier, error) {
+			return Log{Cluster: cfg.ClusterName}, nil
+		},
+		"events": func(_ *config.Sync, recorder record.EventRecorder) (Notifier, error) {
+			return Events{Recorder: recorder}, nil
+		},
+		"webhook": newWebhookFromConfig,
+		"audit":   newAuditFromConfig,
+	}
+)
+
+// Register makes a notifier available by name for KSS_NOTIFIERS. It panics if the
+// name is already registered.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := factories[name]; exists {
+		panic(fmt.Sprintf("notifier %q already registered", name))
+	}
+	factories[name] = factory
+}
+
+// New creates the notifiers named in names (comma separated), combined into one.
+func New(names string, cfg *config.Sync, recorder record.EventRecorder) (Notifier, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var notifiers Multi
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		factory, ok := factories[name]
+		if !ok {
+			known := make([]string, 0, len(factories))
+			for name := range factories {
+				known = append(known, name)
+			}
+			slices.Sort(known)
+			return nil, fmt.Errorf("unknown notifier %q, expected one of %s", name, strings.Join(known, ", "))
+		}
+		notifier, err := factory(cfg, recorder)
+		if err != nil {
+			return nil, fmt.Errorf("creating notifier %q: %w", name, err)
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	return notifiers, nil
+}
+
+// Multi notifies each of its notifiers in turn.
+type Multi []Notifier
+
+func (m Multi) OnSyncSuccess(ctx context.Context, secret *v1.Secret) {
+	for _, n := range m {
+		n.OnSyncSuccess(ctx, secret)
+	}
+}
+
+func (m Multi) OnSyncFailure(ctx context.Context, secret *v1.Secret, err error) {
+	for _, n := range m {
+		n.OnSyncFailure(ctx, secret, err)
+	}
+}
+
+func (m Multi) OnCredentialError(ctx context.Context, secret *v1.Secret, providerName string, err error) {
+	for _, n := range m {
+		n.OnCredentialError(ctx, secret, providerName, err)
+	}
+}
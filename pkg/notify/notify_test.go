@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+// counter counts notifications, for testing registration.
+type counter struct{ successes, failures, credentialErrors int }
+
+func (c *counter) OnSyncSuccess(context.Context, *v1.Secret)                    { c.successes++ }
+func (c *counter) OnSyncFailure(context.Context, *v1.Secret, error)             { c.failures++ }
+func (c *counter) OnCredentialError(context.Context, *v1.Secret, string, error) { c.credentialErrors++ }
+
+func TestNewAndRegister(t *testing.T) {
+	cfg := config.New(fake.NewSimpleClientset())
+	c := &counter{}
+	Register("test-counter", func(*config.Sync, record.EventRecorder) (Notifier, error) { return c, nil })
+
+	recorder := record.NewFakeRecorder(10)
+	n, err := New("events, test-counter", cfg, recorder)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"}}
+	n.OnSyncSuccess(context.Background(), secret)
+	n.OnCredentialError(context.Background(), secret, "op", errors.New("denied"))
+
+	if c.successes != 1 || c.credentialErrors != 1 {
+		t.Errorf("counter = %+v, want one success and one credential error", c)
+	}
+	// Credential errors are recorded as events by the controller, not the notifier
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected one event, got %d", len(recorder.Events))
+	}
+
+	if _, err := New("carrier-pigeon", cfg, recorder); err == nil {
+		t.Errorf("expected error for unknown notifier")
+	}
+	if _, err := New("webhook", cfg, recorder); err == nil {
+		t.Errorf("expected error for webhook without a URL")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	received := make(chan WebhookEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event WebhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decoding webhook body: %v", err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	w := &Webhook{URL: server.URL, Client: server.Client(), Cluster: "prod-eu"}
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"}}
+	w.OnSyncFailure(context.Background(), secret, errors.New("boom"))
+
+	want := WebhookEvent{Event: "sync_failure", Cluster: "prod-eu", Namespace: "default", Name: "example", Error: "boom"}
+	if got := <-received; got != want {
+		t.Errorf("webhook event = %+v, want %+v", got, want)
+	}
+}
+
+func TestWebhookDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-release }))
+	defer server.Close()
+	defer close(release)
+
+	// A stalled webhook fills the queue, after which notifications are dropped
+	w := &Webhook{URL: server.URL, Client: server.Client()}
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"}}
+	done := make(chan struct{})
+	go func() {
+		for range webhookQueueSize + 10 {
+			w.OnSyncSuccess(context.Background(), secret)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("notifications blocked on a stalled webhook")
+	}
+}
+
+func TestAuditSignsAndChains(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	key := []byte("audit-key")
+	a := &Audit{Writer: &buf, Key: key, Cluster: "prod-eu", ProviderKey: "provider", ReasonKey: "reason", Now: func() time.Time { return now }}
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", Annotations: map[string]string{"provider": "op", "reason": "INC-123"}}}
+	a.OnSyncSuccess(context.Background(), secret)
+	a.OnSyncFailure(context.Background(), secret, errors.New("boom"))
+	a.OnSyncSuccess(context.Background(), secret)
+
+	n, head, err := VerifyAudit(bytes.NewReader(buf.Bytes()), key, AuditGenesis)
+	if n != 3 || err != nil {
+		t.Fatalf("VerifyAudit = %d, %v; want 3 entries verified", n, err)
+	}
+	if _, _, err := VerifyAudit(bytes.NewReader(buf.Bytes()), []byte("other-key"), AuditGenesis); err == nil {
+		t.Errorf("expected verification with the wrong key to fail")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var first AuditEntry
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil || first.Provider != "op" || first.Reason != "INC-123" {
+		t.Errorf("first entry = %+v, %v; want provider and reason recorded", first, err)
+	}
+	tampered := strings.Replace(buf.String(), `"error":"boom"`, `"error":"fine"`, 1)
+	if _, _, err := VerifyAudit(strings.NewReader(tampered), key, AuditGenesis); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("VerifyAudit of an edited entry = %v, want invalid signature on line 2", err)
+	}
+	removed := lines[0] + "\n" + lines[2] + "\n"
+	if _, _, err := VerifyAudit(strings.NewReader(removed), key, AuditGenesis); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("VerifyAudit with an entry removed = %v, want chain error on line 2", err)
+	}
+
+	// Removing leading entries breaks the chain from the genesis, and removing
+	// trailing entries changes the head
+	if _, _, err := VerifyAudit(strings.NewReader(lines[1]+"\n"+lines[2]+"\n"), key, AuditGenesis); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("VerifyAudit with the first entry removed = %v, want chain error on line 1", err)
+	}
+	if _, truncated, err := VerifyAudit(strings.NewReader(lines[0]+"\n"+lines[1]+"\n"), key, AuditGenesis); err != nil || truncated == head {
+		t.Errorf("VerifyAudit with the last entry removed = %s, %v; want a different head than %s", truncated, err, head)
+	}
+}
+
+func TestAuditContinuesChainInFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cfg := config.New(fake.NewSimpleClientset())
+	cfg.AuditLogPath = path
+	cfg.AuditKey = base64.StdEncoding.EncodeToString([]byte("audit-key"))
+	cfg.AuditHeadPath = filepath.Join(t.TempDir(), "audit.head")
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"}}
+
+	// Each notifier stands in for an operator restart appending to the same log
+	for range 2 {
+		n, err := New("audit", cfg, nil)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		n.OnSyncSuccess(context.Background(), secret)
+	}
+
+	log, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, head, err := VerifyAudit(bytes.NewReader(log), []byte("audit-key"), AuditGenesis)
+	if n != 2 || err != nil {
+		t.Errorf("VerifyAudit = %d, %v; want 2 entries verified", n, err)
+	}
+	if recorded, err := ReadAuditHead(cfg.AuditHeadPath); recorded != head || err != nil {
+		t.Errorf("recorded head = %s, %v; want %s", recorded, err, head)
+	}
+
+	// A log that no longer ends at the recorded head is refused
+	lines := bytes.SplitAfter(log, []byte("\n"))
+	if err := os.WriteFile(path, lines[0], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New("audit", cfg, nil); err == nil {
+		t.Errorf("expected error continuing a truncated log")
+	}
+}
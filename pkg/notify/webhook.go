@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// WebhookEvent is the JSON body posted to a webhook for each notification.
+type WebhookEvent struct {
+	Event     string `json:"event"` // "sync_success", "sync_failure" or "credential_error"
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Provider  string `json:"provider,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// webhookQueueSize is how many notifications may wait to be sent before new ones
+// are dropped, so a slow webhook never holds up the sync workers.
+const webhookQueueSize = 100
+
+// Webhook posts sync outcomes as JSON to a URL. Notifications are sent in the
+// background in the order they were made; delivery failures are logged and not
+// retried.
+type Webhook struct {
+	URL     string
+	Client  *http.Client
+	Cluster string // name of the cluster included in each event, if set
+
+	once  gosync.Once
+	queue chan WebhookEvent
+}
+
+func newWebhookFromConfig(cfg *config.Sync, _ record.EventRecorder) (Notifier, error) {
+	if cfg.NotifyWebhookURL == "" {
+		return nil, errors.New("KSS_NOTIFY_WEBHOOK_URL is not set")
+	}
+	return &Webhook{URL: cfg.NotifyWebhookURL, Client: &http.Client{Timeout: 10 * time.Second}, Cluster: cfg.ClusterName}, nil
+}
+
+func (w *Webhook) OnSyncSuccess(_ context.Context, secret *v1.Secret) {
+	w.post(WebhookEvent{Event: "sync_success", Namespace: secret.Namespace, Name: secret.Name})
+}
+
+func (w *Webhook) OnSyncFailure(_ context.Context, secret *v1.Secret, err error) {
+	w.post(WebhookEvent{Event: "sync_failure", Namespace: secret.Namespace, Name: secret.Name, Error: err.Error()})
+}
+
+func (w *Webhook) OnCredentialError(_ context.Context, secret *v1.Secret, providerName string, err error) {
+	w.post(WebhookEvent{Event: "credential_error", Namespace: secret.Namespace, Name: secret.Name, Provider: providerName, Error: err.Error()})
+}
+
+// post queues event to be sent, starting the sender on first use. The event is
+// dropped if the queue is full.
+func (w *Webhook) post(event WebhookEvent) {
+	w.once.Do(func() {
+		w.queue = make(chan WebhookEvent, webhookQueueSize)
+		go w.run()
+	})
+	event.Cluster = w.Cluster
+	select {
+	case w.queue <- event:
+	default:
+		klog.ErrorS(errors.New("queue full"), "Dropped webhook notification", "event", event.Event, "namespace", event.Namespace, "name", event.Name)
+	}
+}
+
+// run sends queued events one at a time for the life of the process.
+func (w *Webhook) run() {
+	for event := range w.queue {
+		if err := w.send(context.Background(), event); err != nil {
+			klog.ErrorS(err, "Failed to send webhook notification", "event", event.Event, "namespace", event.Namespace, "name", event.Name)
+			continue
+		}
+		logging.V(logging.Webhook, 4).InfoS("Sent webhook notification", "event", event.Event, "namespace", event.Namespace, "name", event.Name)
+	}
+}
+
+func (w *Webhook) send(ctx context.Context, event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
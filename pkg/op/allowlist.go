@@ -0,0 +1,31 @@
+package op
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+// parseAllowedVaults parses the comma separated vault titles or IDs refs may target.
+func parseAllowedVaults(spec string) []string {
+	var vaults []string
+	for _, vault := range strings.Split(spec, ",") {
+		if vault = strings.TrimSpace(vault); vault != "" {
+			vaults = append(vaults, vault)
+		}
+	}
+	return vaults
+}
+
+// vaultAllowed reports whether the vault with the given ID and title may be read or
+// written. An empty allowlist allows every vault the credentials can access.
+func vaultAllowed(allowed []string, id, title string) bool {
+	return len(allowed) == 0 || slices.Contains(allowed, id) || slices.Contains(allowed, title)
+}
+
+// errVaultNotAllowed is returned for refs to vaults missing from the allowlist.
+func errVaultNotAllowed(vault string) error {
+	return fmt.Errorf("%w: vault %q is not in KSS_OP_ALLOWED_VAULTS", provider.ErrUnauthorized, vault)
+}
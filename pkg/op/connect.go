@@ -0,0 +1,183 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func init() {
+	provider.Register(provider.Info{
+		Name:           "op-connect",
+		Aliases:        []string{"1password-connect"},
+		RequiredConfig: []string{"KSS_OP_CONNECT_HOST", "KSS_OP_CONNECT_TOKEN"},
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			return ConnectProvider{
+				Host:          cfg.OPConnectHost,
+				Token:         cfg.OPConnectToken,
+				AllowedVaults: parseAllowedVaults(cfg.OPAllowedVaults),
+				HTTP:          &http.Client{Timeout: 30 * time.Second},
+			}, nil
+		},
+	})
+}
+
+// ConnectProvider resolves the same op://vault/item/[section/]field refs as the op
+// provider through a self-hosted 1Password Connect server, for accounts that cannot
+// use service accounts. Vaults, items, sections, and fields are matched by title or
+// ID; a field matching more than one is ambiguous unless qualified by its section.
+// If AllowedVaults is set, refs to other vaults are refused before their items are read.
+type ConnectProvider struct {
+	Host          string   // URL of the Connect server, e.g. "http://onepassword-connect:8080"
+	Token         string   // Connect access token
+	AllowedVaults []string // vault titles or IDs refs may target (empty allows all)
+	HTTP          *http.Client
+}
+
+// connectItem is an item as returned by the Connect API.
+type connectItem struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Sections []struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+	} `json:"sections"`
+	Fields []struct {
+		ID      string `json:"id"`
+		Label   string `json:"label"`
+		Value   string `json:"value"`
+		Section *struct {
+			ID string `json:"id"`
+		} `json:"section"`
+	} `json:"fields"`
+}
+
+func (p ConnectProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	ref, err := parseRef(req.Ref)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	if strings.Contains(ref.field, "?") {
+		return provider.Response{}, fmt.Errorf("op-connect does not support ref attributes: %q", req.Ref)
+	}
+	value, err := p.resolve(ctx, ref)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	return provider.Response{Value: []byte(value)}, nil
+}
+
+// resolve finds the vault, item, and field a ref names and returns the field's value.
+func (p ConnectProvider) resolve(ctx context.Context, ref secretRef) (string, error) {
+	var vaults []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := p.get(ctx, "/v1/vaults", &vaults); err != nil {
+		return "", err
+	}
+	vaultID := ""
+	for _, v := range vaults {
+		if v.ID == ref.vault || v.Name == ref.vault {
+			if !vaultAllowed(p.AllowedVaults, v.ID, v.Name) {
+				return "", errVaultNotAllowed(ref.vault)
+			}
+			vaultID = v.ID
+			break
+		}
+	}
+	if vaultID == "" {
+		if !vaultAllowed(p.AllowedVaults, ref.vault, ref.vault) {
+			// Do not reveal whether vaults outside the allowlist exist
+			return "", errVaultNotAllowed(ref.vault)
+		}
+		return "", fmt.Errorf("%w: no vault named %q", provider.ErrNotFound, ref.vault)
+	}
+
+	var items []struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+	if err := p.get(ctx, "/v1/vaults/"+url.PathEscape(vaultID)+"/items", &items); err != nil {
+		return "", err
+	}
+	var itemIDs []string
+	for _, item := range items {
+		if item.ID == ref.item || item.Title == ref.item {
+			itemIDs = append(itemIDs, item.ID)
+		}
+	}
+	switch {
+	case len(itemIDs) == 0:
+		return "", fmt.Errorf("%w: no item named %q in vault %q", provider.ErrNotFound, ref.item, ref.vault)
+	case len(itemIDs) > 1:
+		return "", fmt.Errorf("more than one item named %q in vault %q", ref.item, ref.vault)
+	}
+	var item connectItem
+	if err := p.get(ctx, "/v1/vaults/"+url.PathEscape(vaultID)+"/items/"+url.PathEscape(itemIDs[0]), &item); err != nil {
+		return "", err
+	}
+
+	sectionIDs := map[string]bool{}
+	for _, s := range item.Sections {
+		if ref.section != "" && (s.ID == ref.section || s.Label == ref.section) {
+			sectionIDs[s.ID] = true
+		}
+	}
+	var values []string
+	for _, f := range item.Fields {
+		if f.ID != ref.field && f.Label != ref.field {
+			continue
+		}
+		if ref.section != "" && (f.Section == nil || !sectionIDs[f.Section.ID]) {
+			continue
+		}
+		values = append(values, f.Value)
+	}
+	switch {
+	case len(values) == 0:
+		return "", fmt.Errorf("%w: no field named %q in item %q", provider.ErrNotFound, ref.field, ref.item)
+	case len(values) > 1:
+		return "", fmt.Errorf("more than one field named %q in item %q; qualify it with its section", ref.field, ref.item)
+	}
+	return values[0], nil
+}
+
+// HealthCheck lists the vaults available to the Connect token, which fails if the
+// Connect server is unreachable or the token is no longer valid.
+func (p ConnectProvider) HealthCheck(ctx context.Context) error {
+	var vaults []json.RawMessage
+	return p.get(ctx, "/v1/vaults", &vaults)
+}
+
+// get reads an API path and decodes the JSON response into out.
+func (p ConnectProvider) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.Host, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", provider.ErrTransient, err)
+	}
+	defer resp.Body.Close()
+
+	if err := provider.CheckResponse(resp); err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", provider.ErrNotFound, path)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("reading %s: unexpected status %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
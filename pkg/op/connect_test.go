@@ -0,0 +1,107 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func TestConnectResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer connect-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/vaults":
+			w.Write([]byte(`[{"id":"vault1","name":"Prod"}]`))
+		case "/v1/vaults/vault1/items":
+			w.Write([]byte(`[{"id":"item1","title":"db"},{"id":"item2","title":"dup"},{"id":"item3","title":"dup"}]`))
+		case "/v1/vaults/vault1/items/item1":
+			w.Write([]byte(`{"id":"item1","title":"db",
+				"sections":[{"id":"s1","label":"primary"},{"id":"s2","label":"replica"}],
+				"fields":[
+					{"id":"username","label":"username","value":"app"},
+					{"id":"f1","label":"password","value":"hunter2","section":{"id":"s1"}},
+					{"id":"f2","label":"password","value":"hunter3","section":{"id":"s2"}}
+				]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	p := ConnectProvider{Host: server.URL + "/", Token: "connect-token", HTTP: server.Client()}
+	ctx := context.Background()
+
+	for ref, want := range map[string]string{
+		"op://Prod/db/username":         "app",
+		"op://vault1/item1/username":    "app",
+		"op://Prod/db/primary/password": "hunter2",
+		"op://Prod/db/s2/password":      "hunter3",
+	} {
+		resp, err := p.Resolve(ctx, provider.Request{Ref: ref})
+		if err != nil || string(resp.Value) != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", ref, resp.Value, err, want)
+		}
+	}
+
+	for ref, want := range map[string]error{
+		"op://Dev/db/username":          provider.ErrNotFound,
+		"op://Prod/missing/username":    provider.ErrNotFound,
+		"op://Prod/db/email":            provider.ErrNotFound,
+		"op://Prod/db/replica/username": provider.ErrNotFound,
+	} {
+		if _, err := p.Resolve(ctx, provider.Request{Ref: ref}); !errors.Is(err, want) {
+			t.Errorf("Resolve(%q) = %v, want %v", ref, err, want)
+		}
+	}
+	for _, ref := range []string{"op://Prod/db/password", "op://Prod/dup/username", "op://Prod/db/username?attribute=otp", "Prod/db/username", "op://Prod/db"} {
+		if _, err := p.Resolve(ctx, provider.Request{Ref: ref}); err == nil || errors.Is(err, provider.ErrNotFound) {
+			t.Errorf("Resolve(%q) = %v, want an ambiguous or invalid ref error", ref, err)
+		}
+	}
+
+	if err := p.HealthCheck(ctx); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+	p.Token = "revoked"
+	if err := p.HealthCheck(ctx); !errors.Is(err, provider.ErrUnauthorized) {
+		t.Errorf("HealthCheck with revoked token = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestConnectAllowedVaults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/vaults":
+			w.Write([]byte(`[{"id":"vault1","name":"Prod"},{"id":"vault2","name":"Platform"}]`))
+		case "/v1/vaults/vault1/items", "/v1/vaults/vault2/items":
+			w.Write([]byte(`[{"id":"item1","title":"db"}]`))
+		case "/v1/vaults/vault1/items/item1", "/v1/vaults/vault2/items/item1":
+			w.Write([]byte(`{"id":"item1","title":"db","fields":[{"id":"password","label":"password","value":"hunter2"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	p := ConnectProvider{Host: server.URL, AllowedVaults: parseAllowedVaults(" Prod, vault3 ,"), HTTP: server.Client()}
+	ctx := context.Background()
+
+	for _, ref := range []string{"op://Prod/db/password", "op://vault1/db/password"} {
+		if _, err := p.Resolve(ctx, provider.Request{Ref: ref}); err != nil {
+			t.Errorf("Resolve(%q) = %v", ref, err)
+		}
+	}
+	for _, ref := range []string{"op://Platform/db/password", "op://vault2/db/password", "op://Missing/db/password"} {
+		if _, err := p.Resolve(ctx, provider.Request{Ref: ref}); !errors.Is(err, provider.ErrUnauthorized) {
+			t.Errorf("Resolve(%q) = %v, want ErrUnauthorized", ref, err)
+		}
+	}
+	if _, err := p.Resolve(ctx, provider.Request{Ref: "op://vault3/db/password"}); !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("Resolve of a missing allowed vault = %v, want ErrNotFound", err)
+	}
+}
@@ -2,24 +2,205 @@ package op
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/1password/onepassword-sdk-go"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
 	"k8s.io/klog/v2"
 )
 
+// notFoundMessages are the 1Password SDK error messages indicating that a secret
+// reference does not resolve to an existing vault, item, section, or field.
+var notFoundMessages = []string{
+	"no vault matched the secret reference query",
+	"no item matched the secret reference query",
+	"no section matched the secret reference",
+	"the specified field cannot be found within the item",
+	"resource not found",
+}
+
+// unauthorizedMessages are the 1Password SDK error messages indicating that the
+// service account token is invalid or lacks access to the requested vault.
+var unauthorizedMessages = []string{
+	"invalid service account token",
+	"service account does not have access",
+	"service account is deleted",
+}
+
+func init() {
+	provider.Register(provider.Info{
+		Name:           "op",
+		Aliases:        []string{"1password"},
+		RequiredConfig: []string{"OP_SERVICE_ACCOUNT_TOKEN"},
+		Capabilities:   provider.Capabilities{Push: true},
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			client, err := InitClient()
+			if err != nil {
+				return nil, err
+			}
+			return SecretProvider{Client: client, AllowedVaults: parseAllowedVaults(cfg.OPAllowedVaults)}, nil
+		},
+	})
+}
+
+// SecretProvider resolves 1Password secret references with a service account. If
+// AllowedVaults is set, refs to other vaults are refused before they are resolved,
+// however many vaults the service account can read.
 type SecretProvider struct {
-	Client *onepassword.Client
+	Client        *onepassword.Client
+	AllowedVaults []string // vault titles or IDs refs may target (empty allows all)
+}
+
+func (p SecretProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	if err := p.checkVault(ctx, req.Ref); err != nil {
+		return provider.Response{}, err
+	}
+	value, err := p.Client.Secrets().Resolve(ctx, req.Ref)
+	if err != nil {
+		klog.ErrorS(err, "Failed to resolve 1Password secret URI", "secretID", req.Ref)
+		return provider.Response{}, mapError(err)
+	}
+
+	return provider.Response{Value: []byte(value)}, nil
+}
+
+// HealthCheck lists the vaults available to the service account, which fails if
+// 1Password is unreachable or the token is no longer valid.
+func (p SecretProvider) HealthCheck(ctx context.Context) error {
+	if _, err := p.Client.Vaults().List(ctx); err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+// checkVault returns an error unless the vault a ref names is allowed. Vaults named
+// by neither an allowed title nor an allowed ID are looked up, so the allowlist can
+// mix both.
+func (p SecretProvider) checkVault(ctx context.Context, ref string) error {
+	if len(p.AllowedVaults) == 0 {
+		return nil
+	}
+	parsed, err := parseRef(ref)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(p.AllowedVaults, parsed.vault) {
+		return nil
+	}
+	vaults, err := p.Client.Vaults().List(ctx)
+	if err != nil {
+		return mapError(err)
+	}
+	for _, v := range vaults {
+		if (v.ID == parsed.vault || v.Title == parsed.vault) && vaultAllowed(p.AllowedVaults, v.ID, v.Title) {
+			return nil
+		}
+	}
+	return errVaultNotAllowed(parsed.vault)
 }
 
-func (p SecretProvider) GetSecretValue(ctx context.Context, secretID string) (string, error) {
-	value, err := p.Client.Secrets().Resolve(ctx, secretID)
+// pushSection is the item section fields created by Push are added to.
+var pushSection = onepassword.ItemSection{ID: "k8ssecretsync", Title: "k8s-secret-sync"}
+
+// Push writes value to the field named by a ref of the form op://vault/item/field,
+// matching vaults, items and fields by title or ID. A missing item is created as a
+// secure note, and a missing field is added as a concealed field.
+func (p SecretProvider) Push(ctx context.Context, req provider.Request, value []byte) error {
+	parts := strings.Split(strings.TrimPrefix(req.Ref, "op://"), "/")
+	if !strings.HasPrefix(req.Ref, "op://") || len(parts) != 3 || slices.Contains(parts, "") {
+		return fmt.Errorf("pushing to %q: expected a ref of the form op://vault/item/field", req.Ref)
+	}
+	vaultName, itemName, fieldName := parts[0], parts[1], parts[2]
+
+	vaults, err := p.Client.Vaults().List(ctx)
+	if err != nil {
+		return mapError(err)
+	}
+	i := slices.IndexFunc(vaults, func(v onepassword.VaultOverview) bool { return v.ID == vaultName || v.Title == vaultName })
+	if i < 0 {
+		return fmt.Errorf("%w: no vault named %q", provider.ErrNotFound, vaultName)
+	}
+	if !vaultAllowed(p.AllowedVaults, vaults[i].ID, vaults[i].Title) {
+		return errVaultNotAllowed(vaultName)
+	}
+	vaultID := vaults[i].ID
+
+	items, err := p.Client.Items().List(ctx, vaultID)
 	if err != nil {
-		klog.ErrorS(err, "Failed to resolve 1Password secret URI", "secretID", secretID)
-		return "", err
+		return mapError(err)
+	}
+	i = slices.IndexFunc(items, func(item onepassword.ItemOverview) bool { return item.ID == itemName || item.Title == itemName })
+	if i < 0 {
+		_, err := p.Client.Items().Create(ctx, onepassword.ItemCreateParams{
+			Category: onepassword.ItemCategorySecureNote,
+			VaultID:  vaultID,
+			Title:    itemName,
+			Sections: []onepassword.ItemSection{pushSection},
+			Fields:   []onepassword.ItemField{pushField(fieldName, value)},
+		})
+		if err != nil {
+			return mapError(err)
+		}
+		return nil
 	}
 
-	return value, nil
+	item, err := p.Client.Items().Get(ctx, vaultID, items[i].ID)
+	if err != nil {
+		return mapError(err)
+	}
+	if i := slices.IndexFunc(item.Fields, func(f onepassword.ItemField) bool { return f.ID == fieldName || f.Title == fieldName }); i >= 0 {
+		item.Fields[i].Value = string(value)
+	} else {
+		if !slices.ContainsFunc(item.Sections, func(s onepassword.ItemSection) bool { return s.ID == pushSection.ID }) {
+			item.Sections = append(item.Sections, pushSection)
+		}
+		item.Fields = append(item.Fields, pushField(fieldName, value))
+	}
+	if _, err := p.Client.Items().Put(ctx, item); err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+// pushField returns a new concealed field in the push section.
+func pushField(name string, value []byte) onepassword.ItemField {
+	return onepassword.ItemField{
+		ID:        name,
+		Title:     name,
+		SectionID: &pushSection.ID,
+		FieldType: onepassword.ItemFieldTypeConcealed,
+		Value:     string(value),
+	}
+}
+
+// mapError wraps SDK errors in the shared provider errors where they can be identified.
+func mapError(err error) error {
+	var rateLimited *onepassword.RateLimitExceededError
+	if errors.As(err, &rateLimited) {
+		// The SDK does not surface a Retry-After hint, so leave the delay to the controller's backoff.
+		return &provider.RateLimitedError{Err: err}
+	}
+	for _, msg := range notFoundMessages {
+		if strings.Contains(err.Error(), msg) {
+			return fmt.Errorf("%w: %v", provider.ErrNotFound, err)
+		}
+	}
+	for _, msg := range unauthorizedMessages {
+		if strings.Contains(err.Error(), msg) {
+			return fmt.Errorf("%w: %v", provider.ErrUnauthorized, err)
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", provider.ErrTransient, err)
+	}
+	return err
 }
 
 func InitClient() (*onepassword.Client, error) {
@@ -31,7 +212,7 @@ func InitClient() (*onepassword.Client, error) {
 		onepassword.WithIntegrationInfo("My k8s secret sync operator", "v0"),
 	)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return client, nil
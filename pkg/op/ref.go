@@ -0,0 +1,29 @@
+package op
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// secretRef is a parsed 1Password secret reference. Vaults, items, sections, and
+// fields are named by title or ID.
+type secretRef struct {
+	vault   string
+	item    string
+	section string // empty if the field is not qualified by a section
+	field   string
+}
+
+// parseRef parses a secret reference of the form op://vault/item/[section/]field.
+// Query parameters such as ?attribute=otp are left on the field.
+func parseRef(ref string) (secretRef, error) {
+	parts := strings.Split(strings.TrimPrefix(ref, "op://"), "/")
+	if !strings.HasPrefix(ref, "op://") || len(parts) < 3 || len(parts) > 4 || slices.Contains(parts, "") {
+		return secretRef{}, fmt.Errorf("invalid 1Password ref %q, expected op://vault/item/[section/]field", ref)
+	}
+	if len(parts) == 3 {
+		return secretRef{vault: parts[0], item: parts[1], field: parts[2]}, nil
+	}
+	return secretRef{vault: parts[0], item: parts[1], section: parts[2], field: parts[3]}, nil
+}
@@ -0,0 +1,88 @@
+// Package provider holds types shared between the sync engine and secret providers.
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Errors returned (possibly wrapped) by providers, so the controller can tell
+// failures that retrying will fix from ones that need attention. Along with
+// *RateLimitedError these make up the failures providers should distinguish;
+// anything else is treated as transient.
+var (
+	// ErrNotFound means the referenced secret does not exist upstream. The secret's
+	// not-found policy decides what happens next.
+	ErrNotFound = errors.New("secret not found in provider")
+
+	// ErrUnauthorized means the provider rejected the operator's credentials or denied
+	// access to the secret. Retrying will not help until someone intervenes, so the
+	// secret is parked until its next refresh and a warning is raised.
+	ErrUnauthorized = errors.New("unauthorized by provider")
+
+	// ErrTransient means the provider failed in a way that is expected to clear up,
+	// such as a timeout or server error, and the sync is retried with backoff.
+	ErrTransient = errors.New("transient provider error")
+)
+
+// RateLimitedError is returned by a provider when the upstream API asked the caller
+// to back off. RetryAfter is zero when upstream did not say for how long.
+type RateLimitedError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitedError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited by provider (retry after %s): %v", e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("rate limited by provider: %v", e.Err)
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return e.Err
+}
+
+// CheckResponse returns a *RateLimitedError for HTTP 429 and 503 responses, honoring
+// any Retry-After header, ErrUnauthorized for 401 and 403, ErrTransient for other
+// server errors, and nil otherwise. Providers built on HTTP APIs should call it before
+// handling other status codes.
+func CheckResponse(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		retryAfter, _ := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return &RateLimitedError{
+			RetryAfter: retryAfter,
+			Err:        fmt.Errorf("unexpected status %s", resp.Status),
+		}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: unexpected status %s", ErrUnauthorized, resp.Status)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: unexpected status %s", ErrTransient, resp.Status)
+	}
+	return nil
+}
+
+// ParseRetryAfter parses a Retry-After header value, which is either a number of
+// seconds or an HTTP date, into a delay relative to now.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := at.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"30", 30 * time.Second, true},
+		{"-1", 0, false},
+		{"Wed, 01 Jan 2025 12:01:00 GMT", time.Minute, true},
+		{"Wed, 01 Jan 2025 11:59:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, c := range cases {
+		got, ok := ParseRetryAfter(c.value, now)
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("ParseRetryAfter(%q) = %v, %v; want %v, %v", c.value, got, ok, c.want, c.wantOK)
+		}
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	if err := CheckResponse(resp); err != nil {
+		t.Fatalf("expected nil for 200, got %v", err)
+	}
+
+	resp = &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Status:     "429 Too Many Requests",
+		Header:     http.Header{"Retry-After": []string{"5"}},
+	}
+	var rateLimited *RateLimitedError
+	if err := CheckResponse(resp); !errors.As(err, &rateLimited) {
+		t.Fatalf("expected RateLimitedError, got %v", err)
+	}
+	if rateLimited.RetryAfter != 5*time.Second {
+		t.Errorf("RetryAfter = %v, want 5s", rateLimited.RetryAfter)
+	}
+}
+
+func TestCheckResponseClassifiesErrors(t *testing.T) {
+	cases := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrUnauthorized},
+		{http.StatusInternalServerError, ErrTransient},
+		{http.StatusBadGateway, ErrTransient},
+		{http.StatusNotFound, nil},
+	}
+	for _, c := range cases {
+		err := CheckResponse(&http.Response{StatusCode: c.status, Status: http.StatusText(c.status), Header: http.Header{}})
+		if c.want == nil {
+			if err != nil {
+				t.Errorf("CheckResponse(%d) = %v, want nil", c.status, err)
+			}
+			continue
+		}
+		if !errors.Is(err, c.want) {
+			t.Errorf("CheckResponse(%d) = %v, want %v", c.status, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	gosync "sync"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+)
+
+// SecretProvider fetches secret values from an upstream secret store.
+type SecretProvider interface {
+	// Resolve fetches a value. Responses with an expiry are never cached, and are
+	// renewed ahead of their expiry instead of every poll interval; a reported
+	// version is recorded in the provenance annotations.
+	Resolve(ctx context.Context, req Request) (Response, error)
+
+	// HealthCheck returns an error if the provider cannot currently serve requests.
+	HealthCheck(ctx context.Context) error
+}
+
+// Pusher is implemented by providers with the Push capability, which can write a
+// value to the place a ref names, creating it if it does not exist.
+type Pusher interface {
+	Push(ctx context.Context, req Request, value []byte) error
+}
+
+// Factory creates a provider from the operator configuration. It is called for
+// each request, so it should be cheap or cache what it creates.
+type Factory func(ctx context.Context, cfg *config.Sync) (SecretProvider, error)
+
+// Capabilities describe what a provider supports beyond resolving a ref to a value.
+type Capabilities struct {
+	Binary     bool // values may be arbitrary bytes rather than UTF-8 text
+	Versioning bool // requests may pin an upstream version with Request.Version
+	Expiry     bool // values expire and are renewed ahead of their expiry
+	Push       bool // values can be written back to the provider
+}
+
+// Missing returns the capabilities in required that c lacks, by name.
+func (c Capabilities) Missing(required Capabilities) []string {
+	var missing []string
+	if required.Binary && !c.Binary {
+		missing = append(missing, "binary values")
+	}
+	if required.Versioning && !c.Versioning {
+		missing = append(missing, "pinned versions")
+	}
+	if required.Expiry && !c.Expiry {
+		missing = append(missing, "expiring values")
+	}
+	if required.Push && !c.Push {
+		missing = append(missing, "pushing values")
+	}
+	return missing
+}
+
+// Info describes a registered provider.
+type Info struct {
+	Name           string
+	Aliases        []string // other names accepted for the provider
+	RequiredConfig []string // environment variables that must be set to use it
+	Capabilities   Capabilities
+	New            Factory
+
+	// NamespaceScoped providers decide what a request may read from its namespace, so
+	// their values are never shared between secrets in different namespaces.
+	NamespaceScoped bool
+}
+
+var (
+	mu        gosync.Mutex
+	factories = map[string]Info{}
+	aliases   = map[string]string{}
+)
+
+// Register makes a provider available by name, or any of its aliases, for
+// KSS_PROVIDERS and the provider-name annotation. Provider packages register
+// themselves from an init function. It panics if a name is already registered.
+func Register(info Info) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range append([]string{info.Name}, info.Aliases...) {
+		if _, exists := aliases[name]; exists {
+			panic(fmt.Sprintf("provider %q already registered", name))
+		}
+	}
+	factories[info.Name] = info
+	for _, name := range append([]string{info.Name}, info.Aliases...) {
+		aliases[name] = info.Name
+	}
+}
+
+// Lookup returns the provider registered under name or one of its aliases.
+func Lookup(name string) (Info, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	info, ok := factories[aliases[name]]
+	return info, ok
+}
+
+// Registered returns all registered providers, sorted by name.
+func Registered() []Info {
+	mu.Lock()
+	defer mu.Unlock()
+	infos := make([]Info, 0, len(factories))
+	for _, name := range slices.Sorted(maps.Keys(factories)) {
+		infos = append(infos, factories[name])
+	}
+	return infos
+}
+
+// Enabled returns constructors for the providers named in names (comma separated),
+// keyed by name and each of its aliases. It fails if a provider is unknown or its
+// required configuration is not set.
+func Enabled(ctx context.Context, names string, cfg *config.Sync) (map[string]func() (SecretProvider, error), error) {
+	providers := make(map[string]func() (SecretProvider, error))
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		info, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q, expected one of %s", name, strings.Join(knownNames(), ", "))
+		}
+		var missing []string
+		for _, envVar := range info.RequiredConfig {
+			if os.Getenv(envVar) == "" {
+				missing = append(missing, envVar)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("provider %q requires %s to be set", info.Name, strings.Join(missing, ", "))
+		}
+		newProvider := func() (SecretProvider, error) { return info.New(ctx, cfg) }
+		for _, alias := range append([]string{info.Name}, info.Aliases...) {
+			providers[alias] = newProvider
+		}
+	}
+	return providers, nil
+}
+
+// knownNames returns the registered names and aliases, sorted.
+func knownNames() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return slices.Sorted(maps.Keys(aliases))
+}
@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+)
+
+type stubProvider struct{}
+
+func (stubProvider) Resolve(context.Context, Request) (Response, error) {
+	return Response{Value: []byte("value")}, nil
+}
+
+func (stubProvider) HealthCheck(context.Context) error { return nil }
+
+func TestEnabled(t *testing.T) {
+	Register(Info{
+		Name:         "test-stub",
+		Aliases:      []string{"test-alias"},
+		Capabilities: Capabilities{Binary: true},
+		New: func(context.Context, *config.Sync) (SecretProvider, error) {
+			return stubProvider{}, nil
+		},
+	})
+
+	info, ok := Lookup("test-alias")
+	if !ok || info.Name != "test-stub" || !info.Capabilities.Binary {
+		t.Errorf("Lookup(test-alias) = %+v, %v; want test-stub", info, ok)
+	}
+
+	providers, err := Enabled(context.Background(), " test-alias, ", &config.Sync{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(providers) != 2 || providers["test-stub"] == nil || providers["test-alias"] == nil {
+		t.Fatalf("providers = %v, want test-stub and its alias", providers)
+	}
+	p, err := providers["test-stub"]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := p.Resolve(context.Background(), Request{}); string(resp.Value) != "value" {
+		t.Errorf("Resolve = %q, want value", resp.Value)
+	}
+
+	_, err = Enabled(context.Background(), "test-stub,missing", &config.Sync{})
+	if err == nil || !strings.Contains(err.Error(), `"missing"`) || !strings.Contains(err.Error(), "test-stub") {
+		t.Errorf("Enabled with unknown provider = %v, want error naming it and the known providers", err)
+	}
+}
+
+func TestEnabledRequiresConfig(t *testing.T) {
+	Register(Info{
+		Name:           "test-configured",
+		RequiredConfig: []string{"KSS_TEST_PROVIDER_TOKEN"},
+		New:            func(context.Context, *config.Sync) (SecretProvider, error) { return stubProvider{}, nil },
+	})
+
+	t.Setenv("KSS_TEST_PROVIDER_TOKEN", "")
+	if _, err := Enabled(context.Background(), "test-configured", &config.Sync{}); err == nil || !strings.Contains(err.Error(), "KSS_TEST_PROVIDER_TOKEN") {
+		t.Errorf("Enabled without required config = %v, want error naming it", err)
+	}
+	t.Setenv("KSS_TEST_PROVIDER_TOKEN", "token")
+	if _, err := Enabled(context.Background(), "test-configured", &config.Sync{}); err != nil {
+		t.Errorf("Enabled with required config: %v", err)
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	Register(Info{Name: "test-duplicate"})
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name already used as an alias did not panic")
+		}
+	}()
+	Register(Info{Name: "test-other", Aliases: []string{"test-duplicate"}})
+}
@@ -0,0 +1,20 @@
+package provider
+
+import "time"
+
+// Request identifies a value to fetch from a provider. Metadata holds
+// provider-specific parameters set per secret (e.g. an AWS external ID); providers
+// ignore keys they do not recognize.
+type Request struct {
+	Ref       string
+	Version   string // upstream version to fetch, for providers that keep versions; empty fetches the latest
+	Metadata  map[string]string
+	Namespace string // namespace of the secret the value is for, for providers that authorize by it
+}
+
+// Response is a value fetched from a provider.
+type Response struct {
+	Value   []byte
+	Version string    // upstream version or etag of the value, if the provider reports one
+	Expiry  time.Time // when the value stops being valid, for short-lived values such as minted credentials
+}
@@ -0,0 +1,189 @@
+// Package awssm implements a secret provider that reads secrets from AWS Secrets
+// Manager by name or ARN.
+package awssm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	gosync "sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/smithy-go"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/awsrole"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+// unauthorizedCodes are the Secrets Manager error codes returned when the operator's
+// identity may not read a secret, cannot decrypt it with its KMS key, or has invalid
+// credentials.
+var unauthorizedCodes = map[string]bool{
+	"AccessDenied":                true, // from STS, assuming the secret's role
+	"AccessDeniedException":       true,
+	"DecryptionFailure":           true,
+	"ExpiredTokenException":       true,
+	"InvalidClientTokenId":        true,
+	"SignatureDoesNotMatch":       true,
+	"UnrecognizedClientException": true,
+}
+
+func init() {
+	provider.Register(provider.Info{
+		Name:            "aws-sm",
+		Aliases:         []string{"aws-secrets-manager"},
+		Capabilities:    provider.Capabilities{Binary: true, Versioning: true},
+		NamespaceScoped: true,
+		New: func(ctx context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			client, err := shared.client(ctx, cfg.AWSRegion)
+			if err != nil {
+				return nil, err
+			}
+			roles, err := awsrole.Load(ctx, cfg)
+			if err != nil {
+				return nil, err
+			}
+			return SecretProvider{Client: client, Roles: roles}, nil
+		},
+	})
+}
+
+// shared caches clients between the providers created for each request, so the AWS
+// configuration is only loaded once.
+var shared = &clientCache{}
+
+// clientCache holds the client for each region read from by default.
+type clientCache struct {
+	mu      gosync.Mutex
+	clients map[string]*secretsmanager.Client
+}
+
+// client returns the cached client for region, creating it on first use.
+func (c *clientCache) client(ctx context.Context, region string) (*secretsmanager.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, ok := c.clients[region]; ok {
+		return client, nil
+	}
+	client, err := NewClient(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	if c.clients == nil {
+		c.clients = make(map[string]*secretsmanager.Client)
+	}
+	c.clients[region] = client
+	return client, nil
+}
+
+// Client is the part of the Secrets Manager API used by the provider.
+type Client interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error)
+}
+
+// SecretProvider resolves refs that are secret names or ARNs to the secret's string or
+// binary value; key mappings can split JSON secrets into keys. ARNs are read from the
+// region they name. A pinned version is a version ID, or a staging label such as
+// AWSPREVIOUS (custom labels are written "stage:<label>"). The response version is the
+// version ID read.
+//
+// Secrets are read as a role mapped to the secret's namespace, if any, chosen by the
+// "roleArn" metadata, to reach secrets in other AWS accounts by ARN.
+type SecretProvider struct {
+	Client Client
+	Roles  *awsrole.Roles // roles assumed for secrets or namespaces; nil reads as the operator
+}
+
+func (p SecretProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	if req.Ref == "" {
+		return provider.Response{}, errors.New("invalid aws-sm ref, expected a secret name or ARN")
+	}
+	input := &secretsmanager.GetSecretValueInput{SecretId: aws.String(req.Ref)}
+	switch {
+	case strings.HasPrefix(req.Version, "stage:"):
+		input.VersionStage = aws.String(strings.TrimPrefix(req.Version, "stage:"))
+	case strings.HasPrefix(req.Version, "AWS"):
+		input.VersionStage = aws.String(req.Version)
+	case req.Version != "":
+		input.VersionId = aws.String(req.Version)
+	}
+	var optFns []func(*secretsmanager.Options)
+	if parsed, err := arn.Parse(req.Ref); err == nil && parsed.Region != "" {
+		optFns = append(optFns, func(o *secretsmanager.Options) { o.Region = parsed.Region })
+	}
+	if p.Roles != nil {
+		roleARN, err := p.Roles.Role(req)
+		if err != nil {
+			return provider.Response{}, err
+		}
+		if roleARN != "" {
+			creds := p.Roles.Credentials(roleARN)
+			optFns = append(optFns, func(o *secretsmanager.Options) { o.Credentials = creds })
+		}
+	}
+
+	out, err := p.Client.GetSecretValue(ctx, input, optFns...)
+	if err != nil {
+		return provider.Response{}, mapError(err)
+	}
+	value := out.SecretBinary
+	if out.SecretString != nil {
+		value = []byte(*out.SecretString)
+	}
+	return provider.Response{Value: value, Version: aws.ToString(out.VersionId)}, nil
+}
+
+// HealthCheck lists a secret, which fails if Secrets Manager is unreachable or the
+// operator's credentials are invalid. Being denied the listing still shows both, so
+// least-privilege roles without ListSecrets are healthy.
+func (p SecretProvider) HealthCheck(ctx context.Context) error {
+	_, err := p.Client.ListSecrets(ctx, &secretsmanager.ListSecretsInput{MaxResults: aws.Int32(1)})
+	var apiErr smithy.APIError
+	if err == nil || (errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException") {
+		return nil
+	}
+	return mapError(err)
+}
+
+// mapError wraps SDK errors in the shared provider errors where they can be identified.
+func mapError(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.ErrorCode() == "ResourceNotFoundException":
+			return fmt.Errorf("%w: %v", provider.ErrNotFound, err)
+		case apiErr.ErrorCode() == "ThrottlingException":
+			return &provider.RateLimitedError{Err: err}
+		case unauthorizedCodes[apiErr.ErrorCode()]:
+			return fmt.Errorf("%w: %v", provider.ErrUnauthorized, err)
+		case apiErr.ErrorFault() == smithy.FaultServer:
+			return fmt.Errorf("%w: %v", provider.ErrTransient, err)
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", provider.ErrTransient, err)
+	}
+	return err
+}
+
+// NewClient returns a Secrets Manager client using the standard AWS credential chain
+// (environment, shared config, web identity, or instance metadata), in region if set
+// and otherwise in the region from the environment or shared config.
+func NewClient(ctx context.Context, region string) (*secretsmanager.Client, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	return secretsmanager.NewFromConfig(cfg), nil
+}
@@ -0,0 +1,141 @@
+package awssm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/smithy-go"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/awsrole"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+type fakeClient struct {
+	input  *secretsmanager.GetSecretValueInput
+	region string
+	creds  aws.CredentialsProvider
+	output *secretsmanager.GetSecretValueOutput
+	err    error
+}
+
+func (c *fakeClient) GetSecretValue(_ context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	c.input = params
+	opts := secretsmanager.Options{}
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+	c.region = opts.Region
+	c.creds = opts.Credentials
+	return c.output, c.err
+}
+
+func (c *fakeClient) ListSecrets(context.Context, *secretsmanager.ListSecretsInput, ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error) {
+	return &secretsmanager.ListSecretsOutput{}, c.err
+}
+
+func TestResolve(t *testing.T) {
+	client := &fakeClient{output: &secretsmanager.GetSecretValueOutput{SecretString: aws.String(`{"password":"hunter2"}`), VersionId: aws.String("v-1")}}
+	p := SecretProvider{Client: client}
+	ctx := context.Background()
+
+	resp, err := p.Resolve(ctx, provider.Request{Ref: "prod/db"})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if string(resp.Value) != `{"password":"hunter2"}` || resp.Version != "v-1" {
+		t.Errorf("response = %q, %q", resp.Value, resp.Version)
+	}
+	if aws.ToString(client.input.SecretId) != "prod/db" || client.region != "" {
+		t.Errorf("SecretId, region = %q, %q", aws.ToString(client.input.SecretId), client.region)
+	}
+
+	// ARNs are read from their own region
+	ref := "arn:aws:secretsmanager:eu-west-1:123456789012:secret:prod/db-AbCdEf"
+	if _, err := p.Resolve(ctx, provider.Request{Ref: ref}); err != nil || client.region != "eu-west-1" {
+		t.Errorf("region for ARN = %q, %v; want eu-west-1", client.region, err)
+	}
+
+	for version, want := range map[string][2]string{
+		"AWSPREVIOUS":   {"", "AWSPREVIOUS"},
+		"stage:blue":    {"", "blue"},
+		"6e5a1f3b-uuid": {"6e5a1f3b-uuid", ""},
+	} {
+		if _, err := p.Resolve(ctx, provider.Request{Ref: "prod/db", Version: version}); err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+		if got := [2]string{aws.ToString(client.input.VersionId), aws.ToString(client.input.VersionStage)}; got != want {
+			t.Errorf("version %q = id %q, stage %q; want %q", version, got[0], got[1], want)
+		}
+	}
+
+	client.output = &secretsmanager.GetSecretValueOutput{SecretBinary: []byte{0xff, 0x00}}
+	if resp, err := p.Resolve(ctx, provider.Request{Ref: "prod/cert"}); err != nil || string(resp.Value) != "\xff\x00" {
+		t.Errorf("binary secret = %q, %v", resp.Value, err)
+	}
+}
+
+func TestResolveRole(t *testing.T) {
+	client := &fakeClient{output: &secretsmanager.GetSecretValueOutput{SecretString: aws.String("hunter2")}}
+	roles, err := awsrole.New(nil, "team-a=arn:aws:iam::111111111111:role/team-a", "kss")
+	if err != nil {
+		t.Fatalf("awsrole.New: %v", err)
+	}
+	p := SecretProvider{Client: client, Roles: roles}
+	ctx := context.Background()
+
+	if _, err := p.Resolve(ctx, provider.Request{Ref: "prod/db", Namespace: "default"}); err != nil || client.creds != nil {
+		t.Errorf("Resolve without a role used credentials %v, %v", client.creds, err)
+	}
+	if _, err := p.Resolve(ctx, provider.Request{Ref: "prod/db", Namespace: "team-a"}); err != nil || client.creds == nil {
+		t.Errorf("Resolve in team-a used credentials %v, %v; want the team-a role", client.creds, err)
+	}
+	req := provider.Request{Ref: "prod/db", Namespace: "team-a", Metadata: map[string]string{"roleArn": "arn:aws:iam::222222222222:role/reader"}}
+	if _, err := p.Resolve(ctx, req); err == nil {
+		t.Errorf("Resolve in team-a with another role succeeded, want an error")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for code, want := range map[string]error{
+		"ResourceNotFoundException": provider.ErrNotFound,
+		"AccessDeniedException":     provider.ErrUnauthorized,
+		"DecryptionFailure":         provider.ErrUnauthorized,
+	} {
+		client := &fakeClient{err: &smithy.GenericAPIError{Code: code}}
+		_, err := SecretProvider{Client: client}.Resolve(context.Background(), provider.Request{Ref: "prod/db"})
+		if !errors.Is(err, want) {
+			t.Errorf("%s: got %v, want %v", code, err, want)
+		}
+	}
+
+	client := &fakeClient{err: &smithy.GenericAPIError{Code: "ThrottlingException"}}
+	_, err := SecretProvider{Client: client}.Resolve(context.Background(), provider.Request{Ref: "prod/db"})
+	var rateLimited *provider.RateLimitedError
+	if !errors.As(err, &rateLimited) {
+		t.Errorf("throttling: got %v, want RateLimitedError", err)
+	}
+
+	// Being denied the listing still shows the service and credentials work
+	if err := (SecretProvider{Client: &fakeClient{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}}}).HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck with ListSecrets denied = %v, want nil", err)
+	}
+	if err := (SecretProvider{Client: &fakeClient{err: &smithy.GenericAPIError{Code: "UnrecognizedClientException"}}}).HealthCheck(context.Background()); err == nil {
+		t.Errorf("expected HealthCheck to fail with invalid credentials")
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	cache := &clientCache{}
+	first, err := cache.client(context.Background(), "us-east-1")
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	if second, _ := cache.client(context.Background(), "us-east-1"); second != first {
+		t.Errorf("expected the client to be reused between requests")
+	}
+	if other, _ := cache.client(context.Background(), "eu-west-1"); other == first {
+		t.Errorf("expected a separate client for another region")
+	}
+}
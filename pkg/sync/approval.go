@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+)
+
+// approvalRecheckInterval is how often a secret holding a value change re-checks
+// whether the change has been approved.
+const approvalRecheckInterval = 30 * time.Second
+
+// requiresApproval reports whether value changes for a secret must be approved
+// before they are written.
+func (c *controller) requiresApproval(secret *v1.Secret) bool {
+	required, _ := strconv.ParseBool(secret.Annotations[c.cfg.Annotations.RequireApproval])
+	return required
+}
+
+// awaitingApproval reports whether a change to data hashing to hash is still waiting
+// for approval, with a status message telling the approver how to approve it. The
+// pending-approval metric is kept in step with the result.
+//
+// A change is approved once, by setting the approve annotation to its hash. Who set it
+// cannot be verified from the annotation, so anyone allowed to update the Secret can
+// approve; restrict that with RBAC or an admission policy.
+func (c *controller) awaitingApproval(secret *v1.Secret, hash string) (bool, string) {
+	if strings.TrimSpace(secret.Annotations[c.cfg.Annotations.Approve]) == hash {
+		metrics.SetPendingApproval(secret.Namespace, secret.Name, false)
+		return false, ""
+	}
+	metrics.SetPendingApproval(secret.Namespace, secret.Name, true)
+	return true, fmt.Sprintf("Value change awaiting approval; set %s to %q to apply it", c.cfg.Annotations.Approve, hash)
+}
+
+// forgetApproval clears the pending-approval metric for a secret that no longer holds
+// a change for approval, because it was deleted, opted out, or the change was dropped.
+func forgetApproval(namespace, name string) {
+	metrics.SetPendingApproval(namespace, name, false)
+}
@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"slices"
+	"strconv"
+	"time"
+)
+
+// BackstageEntity is a Backstage catalog Resource describing the synced secrets of one
+// namespace, so developer portals can show sync health next to the owning component.
+type BackstageEntity struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   BackstageMetadata `json:"metadata"`
+	Spec       BackstageSpec     `json:"spec"`
+}
+
+// BackstageMetadata is the metadata block of a BackstageEntity.
+type BackstageMetadata struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// BackstageSpec is the spec block of a BackstageEntity.
+type BackstageSpec struct {
+	Type    string         `json:"type"`
+	Owner   string         `json:"owner"`
+	Health  string         `json:"health"` // "healthy" when no secret in the namespace is failing
+	Secrets int            `json:"secrets"`
+	Status  map[string]int `json:"status"` // number of secrets per sync status
+	Failing []string       `json:"failing,omitempty"`
+}
+
+// Backstage groups the report per namespace into Backstage catalog entities, sorted by
+// namespace. Owners are left as "unknown" for the portal to fill in from its own catalog.
+func (r *Report) Backstage() []BackstageEntity {
+	byNamespace := map[string]*BackstageEntity{}
+	var namespaces []string
+	for _, s := range r.Secrets {
+		entity, ok := byNamespace[s.Namespace]
+		if !ok {
+			entity = &BackstageEntity{
+				APIVersion: "backstage.io/v1alpha1",
+				Kind:       "Resource",
+				Metadata: BackstageMetadata{
+					Name:        "secret-sync-" + s.Namespace,
+					Description: "Secrets synced by k8s-secret-sync in namespace " + s.Namespace,
+					Annotations: map[string]string{
+						"backstage.io/kubernetes-namespace":          s.Namespace,
+						"k8s-secret-sync.weinbender.io/generated-at": r.GeneratedAt.Format(time.RFC3339),
+					},
+				},
+				Spec: BackstageSpec{Type: "kubernetes-secrets", Owner: "unknown", Health: "healthy", Status: map[string]int{}},
+			}
+			byNamespace[s.Namespace] = entity
+			namespaces = append(namespaces, s.Namespace)
+		}
+
+		status := s.Status
+		if status == "" {
+			status = "Unknown"
+		}
+		entity.Spec.Secrets++
+		entity.Spec.Status[status]++
+		if s.Status == StatusFailed || s.Status == StatusNotFound {
+			entity.Spec.Health = "degraded"
+			entity.Spec.Failing = append(entity.Spec.Failing, s.Name)
+		}
+	}
+
+	slices.Sort(namespaces)
+	entities := make([]BackstageEntity, 0, len(namespaces))
+	for _, ns := range namespaces {
+		entity := byNamespace[ns]
+		entity.Metadata.Annotations["k8s-secret-sync.weinbender.io/failures"] = strconv.Itoa(len(entity.Spec.Failing))
+		entities = append(entities, *entity)
+	}
+	return entities
+}
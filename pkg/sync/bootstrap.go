@@ -0,0 +1,180 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/informers"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// bootstrapSecret is one Secret a Namespace's secrets annotation asks for.
+type bootstrapSecret struct {
+	Name        string            `json:"name"`
+	Type        v1.SecretType     `json:"type,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// parseBootstrapSecrets parses a secrets annotation. Each Secret must be annotated
+// with a provider, so that the controller fills it in once created.
+func parseBootstrapSecrets(value string, annotations config.Annotations) ([]bootstrapSecret, error) {
+	var secrets []bootstrapSecret
+	if err := yaml.UnmarshalStrict([]byte(value), &secrets); err != nil {
+		return nil, fmt.Errorf("parsing secrets annotation: %w", err)
+	}
+	for _, s := range secrets {
+		if errs := validation.IsDNS1123Subdomain(s.Name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid secret name %q: %s", s.Name, strings.Join(errs, "; "))
+		}
+		if s.Annotations[annotations.ProviderName] == "" {
+			return nil, fmt.Errorf("secret %s has no %s annotation", s.Name, annotations.ProviderName)
+		}
+	}
+	return secrets, nil
+}
+
+// placeholderData returns the data a new Secret of the given type needs to pass
+// validation before its first sync.
+func placeholderData(secretType v1.SecretType) map[string][]byte {
+	data := make(map[string][]byte)
+	for _, keys := range requiredKeys[secretType] {
+		data[keys[0]] = []byte{}
+	}
+	if secretType == v1.SecretTypeDockerConfigJson {
+		data[v1.DockerConfigJsonKey] = []byte("{}")
+	}
+	return data
+}
+
+// bootstrapper creates the Secrets listed in the secrets annotation on Namespaces, so
+// they need not be created as placeholders first. The Secrets are annotated for sync,
+// so the controller fills them in like any other, and owned by the Namespace.
+type bootstrapper struct {
+	cfg *config.Sync
+}
+
+// run watches Namespaces until ctx is cancelled.
+func (b *bootstrapper) run(ctx context.Context) {
+	factory := informers.NewSharedInformerFactory(b.cfg.Clientset, 10*time.Minute)
+	handle := func(obj any) {
+		namespace := obj.(*v1.Namespace)
+		if err := b.syncNamespace(ctx, namespace); err != nil {
+			klog.ErrorS(err, "Failed to create secrets for namespace", "namespace", namespace.Name)
+		}
+	}
+	informer := factory.Core().V1().Namespaces().Informer()
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    handle,
+		UpdateFunc: func(_, obj any) { handle(obj) },
+	}); err != nil {
+		klog.ErrorS(err, "Failed to watch namespaces for secrets to create")
+		return
+	}
+	factory.Start(ctx.Done())
+	<-ctx.Done()
+	factory.Shutdown()
+}
+
+// syncNamespace ensures the Secrets listed on a Namespace exist. Failing Secrets do
+// not stop the others from being created.
+func (b *bootstrapper) syncNamespace(ctx context.Context, namespace *v1.Namespace) error {
+	value := namespace.Annotations[b.cfg.Annotations.Secrets]
+	if value == "" || namespace.Status.Phase == v1.NamespaceTerminating {
+		return nil
+	}
+	if isProtectedNamespace(b.cfg, namespace.Name) {
+		klog.InfoS("Not creating secrets in protected namespace", "namespace", namespace.Name)
+		return nil
+	}
+	secrets, err := parseBootstrapSecrets(value, b.cfg.Annotations)
+	if err != nil {
+		return err
+	}
+	owner := metav1.OwnerReference{APIVersion: "v1", Kind: "Namespace", Name: namespace.Name, UID: namespace.UID}
+	var errs []error
+	for _, s := range secrets {
+		if err := b.ensureSecret(ctx, namespace.Name, owner, s); err != nil {
+			errs = append(errs, fmt.Errorf("secret %s: %w", s.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ensureSecret creates a listed Secret, or updates its labels and annotations if the
+// listing changed. It refuses to take over a Secret the Namespace does not own.
+func (b *bootstrapper) ensureSecret(ctx context.Context, namespace string, owner metav1.OwnerReference, s bootstrapSecret) error {
+	secretType := s.Type
+	if secretType == "" {
+		secretType = v1.SecretTypeOpaque
+	}
+	secrets := b.cfg.Clientset.CoreV1().Secrets(namespace)
+	existing, err := secrets.Get(ctx, s.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            s.Name,
+				Namespace:       namespace,
+				Labels:          s.Labels,
+				Annotations:     s.Annotations,
+				OwnerReferences: []metav1.OwnerReference{owner},
+			},
+			Type: secretType,
+			Data: placeholderData(secretType),
+		}, metav1.CreateOptions{})
+		if err == nil {
+			klog.InfoS("Created secret listed on namespace", "namespace", namespace, "name", s.Name, "type", secretType)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	owned := false
+	for _, ref := range existing.OwnerReferences {
+		if ref.UID == owner.UID {
+			owned = true
+		}
+	}
+	if !owned {
+		return fmt.Errorf("secret already exists and is not owned by namespace %s", namespace)
+	}
+	if existing.Type != secretType {
+		return fmt.Errorf("secret has type %s, not %s; types cannot be changed, so delete it to recreate it", existing.Type, secretType)
+	}
+	labels := maps.Clone(existing.Labels)
+	annotations := maps.Clone(existing.Annotations)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	maps.Copy(labels, s.Labels)
+	maps.Copy(annotations, s.Annotations)
+	if maps.Equal(labels, existing.Labels) && maps.Equal(annotations, existing.Annotations) {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"labels": s.Labels, "annotations": s.Annotations},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = secrets.Patch(ctx, s.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const bootstrapAnnotation = `
+- name: db
+  type: kubernetes.io/basic-auth
+  labels: {app: api}
+  annotations:
+    k8s-secret-sync.weinbender.io/provider-name: op
+    k8s-secret-sync.weinbender.io/provider-ref: op://vault/db
+    k8s-secret-sync.weinbender.io/transform: basic-auth
+- name: taken
+  annotations:
+    k8s-secret-sync.weinbender.io/provider-name: op
+    k8s-secret-sync.weinbender.io/provider-ref: op://vault/taken
+`
+
+func TestBootstrapSecrets(t *testing.T) {
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-a",
+		UID:         "uid-1",
+		Annotations: map[string]string{"k8s-secret-sync.weinbender.io/secrets": bootstrapAnnotation},
+	}}
+	taken := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "taken"}}
+	cs := fake.NewSimpleClientset(namespace, taken)
+	b := &bootstrapper{cfg: config.New(cs)}
+	ctx := context.Background()
+
+	if err := b.syncNamespace(ctx, namespace); err == nil {
+		t.Errorf("expected error for a secret the namespace does not own")
+	}
+	secret, err := cs.CoreV1().Secrets("team-a").Get(ctx, "db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting created secret: %v", err)
+	}
+	if secret.Type != v1.SecretTypeBasicAuth || secret.Labels["app"] != "api" {
+		t.Errorf("secret type, labels = %s, %v; want basic-auth with app label", secret.Type, secret.Labels)
+	}
+	if _, ok := secret.Data["username"]; !ok {
+		t.Errorf("expected placeholder username key for a basic-auth secret, got %v", secret.Data)
+	}
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].UID != "uid-1" {
+		t.Errorf("owner references = %v, want the namespace", secret.OwnerReferences)
+	}
+	if got, _ := cs.CoreV1().Secrets("team-a").Get(ctx, "taken", metav1.GetOptions{}); len(got.Annotations) != 0 {
+		t.Errorf("expected existing secret to be left alone, got annotations %v", got.Annotations)
+	}
+}
+
+func TestParseBootstrapSecrets(t *testing.T) {
+	annotations := config.New(nil).Annotations
+	for _, value := range []string{
+		"- name: Invalid_Name\n  annotations: {k8s-secret-sync.weinbender.io/provider-name: op}",
+		"- name: db",
+		"- name: db\n  color: red\n  annotations: {k8s-secret-sync.weinbender.io/provider-name: op}",
+	} {
+		if _, err := parseBootstrapSecrets(value, annotations); err == nil {
+			t.Errorf("parseBootstrapSecrets(%q): expected error", value)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Annotations written by the operator to track break-glass checkouts: when the current
+// checkout began, the reason of the last checkout to expire, and hashes of every
+// reason a checkout has been made for, comma separated.
+const (
+	checkedOutAnnotation  = "k8s-secret-sync.weinbender.io/break-glass-checked-out"
+	expiredAnnotation     = "k8s-secret-sync.weinbender.io/break-glass-expired"
+	usedReasonsAnnotation = "k8s-secret-sync.weinbender.io/break-glass-used-reasons"
+)
+
+// isBreakGlass reports whether a secret holds a break-glass credential.
+func (c *controller) isBreakGlass(secret *v1.Secret) bool {
+	breakGlass, _ := strconv.ParseBool(secret.Annotations[c.cfg.Annotations.BreakGlass])
+	return breakGlass
+}
+
+// breakGlassTTL returns how long a checkout of a secret lasts: KSS_BREAK_GLASS_TTL, or
+// the shorter duration set on the secret.
+func (c *controller) breakGlassTTL(secret *v1.Secret) (time.Duration, error) {
+	ttl := time.Duration(c.cfg.BreakGlassTTL) * time.Second
+	value := secret.Annotations[c.cfg.Annotations.BreakGlassTTL]
+	if value == "" {
+		return ttl, nil
+	}
+	override, err := time.ParseDuration(value)
+	if err != nil || override <= 0 {
+		return 0, fmt.Errorf("invalid break-glass TTL %q", value)
+	}
+	return min(ttl, override), nil
+}
+
+// checkOut decides whether a break-glass secret may be synced at now. Secrets without
+// a reason, or with one a checkout was already made for, are held as Pending, and
+// checkouts past their TTL are expired; in both cases it returns false. Otherwise it
+// returns the annotations recording a new checkout, if this is one, and queues the
+// secret to be expired when the checkout ends. Checkouts said to begin in the future
+// can only have been edited, and are expired.
+func (c *controller) checkOut(ctx context.Context, secret *v1.Secret, now time.Time) (bool, map[string]string, error) {
+	cfg := c.cfg
+	reason := secret.Annotations[cfg.Annotations.BreakGlassReason]
+	checkedOut, err := time.Parse(time.RFC3339, secret.Annotations[checkedOutAnnotation])
+	active := err == nil
+	if reason == "" || (!active && reasonUsed(secret, reason)) {
+		message := fmt.Sprintf("Break-glass secret is not checked out; set %s to the reason it is needed", cfg.Annotations.BreakGlassReason)
+		if reason != "" {
+			message = c.checkoutExpiredMessage()
+		}
+		if secret.Annotations[statusMessageAnnotation] != message {
+			if err := setStatus(ctx, cfg.Clientset, secret, StatusPending, message); err != nil {
+				klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+			}
+		}
+		return false, nil, nil
+	}
+
+	ttl, err := c.breakGlassTTL(secret)
+	if err != nil {
+		return false, nil, err
+	}
+	key := secret.Namespace + "/" + secret.Name
+	if !active {
+		klog.InfoS("Checking out break-glass secret", "namespace", secret.Namespace, "name", secret.Name, "reason", reason, "ttl", ttl)
+		c.recorder.Eventf(secret, v1.EventTypeWarning, "BreakGlassCheckout", "Break-glass secret checked out for %s: %s", ttl, reason)
+		c.queue.AddAfter(key, ttl)
+		used := secret.Annotations[usedReasonsAnnotation]
+		if used != "" {
+			used += ","
+		}
+		return true, map[string]string{
+			checkedOutAnnotation:  now.UTC().Format(time.RFC3339),
+			expiredAnnotation:     "",
+			usedReasonsAnnotation: used + reasonHash(reason),
+		}, nil
+	}
+	if checkedOut.After(now) {
+		klog.InfoS("Expiring break-glass checkout that begins in the future", "namespace", secret.Namespace, "name", secret.Name, "checkedOut", checkedOut)
+		return false, nil, c.expireCheckout(ctx, secret, reason)
+	}
+	if expiry := checkedOut.Add(ttl); now.Before(expiry) {
+		c.queue.AddAfter(key, expiry.Sub(now))
+		return true, nil, nil
+	}
+	return false, nil, c.expireCheckout(ctx, secret, reason)
+}
+
+// expireCheckout removes the managed keys of a break-glass secret whose checkout has
+// ended, remembering the reason so it must be given a new one to be checked out again.
+func (c *controller) expireCheckout(ctx context.Context, secret *v1.Secret, reason string) error {
+	data := make(map[string]any)
+	for _, key := range managedKeys(secret) {
+		data[key] = nil
+	}
+	annotations := map[string]string{
+		checkedOutAnnotation:    "",
+		expiredAnnotation:       reason,
+		managedKeysAnnotation:   "",
+		dataHashAnnotation:      "",
+		valueHashAnnotation:     "",
+		statusAnnotation:        StatusPending,
+		statusMessageAnnotation: c.checkoutExpiredMessage(),
+	}
+	strategy, err := c.patchStrategy(secret)
+	if err != nil {
+		klog.ErrorS(err, "Invalid patch strategy, using default", "namespace", secret.Namespace, "name", secret.Name)
+	}
+	if err := writeSecret(ctx, c.cfg.Clientset, secret, strategy, nil, annotations, data); err != nil {
+		return fmt.Errorf("expiring break-glass checkout: %w", err)
+	}
+	klog.InfoS("Break-glass checkout expired", "namespace", secret.Namespace, "name", secret.Name, "reason", reason)
+	c.recorder.Event(secret, v1.EventTypeNormal, "BreakGlassExpired", "Break-glass checkout expired and its value was removed")
+	return nil
+}
+
+// reasonUsed reports whether a checkout of a break-glass secret was already made for
+// reason, so it must be given a new one to be checked out again.
+func reasonUsed(secret *v1.Secret, reason string) bool {
+	return reason == secret.Annotations[expiredAnnotation] ||
+		slices.Contains(strings.Split(secret.Annotations[usedReasonsAnnotation], ","), reasonHash(reason))
+}
+
+// reasonHash returns the hash a checkout reason is recorded by, which keeps the
+// record of used reasons short however long they are.
+func reasonHash(reason string) string {
+	sum := sha256.Sum256([]byte(reason))
+	return hex.EncodeToString(sum[:8])
+}
+
+// checkoutExpiredMessage is the status message of a break-glass secret whose checkout
+// has expired.
+func (c *controller) checkoutExpiredMessage() string {
+	return fmt.Sprintf("Break-glass checkout expired; set a reason not used before in %s to check it out again", c.cfg.Annotations.BreakGlassReason)
+}
@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBreakGlassCheckout(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	c, cs := newTestController(t, p, annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/break-glass": "true",
+	}))
+	reconcile := func() {
+		t.Helper()
+		if err := c.reconcile(context.Background(), "default/example"); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+		if err := c.store.Update(getSecret(t, cs)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without a reason the value is not synced
+	reconcile()
+	if secret := getSecret(t, cs); secret.Data["value"] != nil || secret.Annotations[statusAnnotation] != StatusPending {
+		t.Fatalf("secret without a reason = %v, %q; want no value and Pending", secret.Data, secret.Annotations[statusAnnotation])
+	}
+
+	// With one it is checked out
+	secret := getSecret(t, cs)
+	secret.Annotations["k8s-secret-sync.weinbender.io/break-glass-reason"] = "INC-123 database recovery"
+	if _, err := cs.CoreV1().Secrets("default").Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.store.Update(secret); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	secret = getSecret(t, cs)
+	if string(secret.Data["value"]) != "s3cr3t" || secret.Annotations[checkedOutAnnotation] == "" {
+		t.Fatalf("checked out secret = %v, %v; want value and checkout time", secret.Data, secret.Annotations)
+	}
+	if !hasEvent(c, "BreakGlassCheckout") {
+		t.Errorf("expected BreakGlassCheckout event")
+	}
+
+	// Once the TTL passes the value is removed
+	secret.Annotations[checkedOutAnnotation] = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	if _, err := cs.CoreV1().Secrets("default").Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.store.Update(secret); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	secret = getSecret(t, cs)
+	if _, ok := secret.Data["value"]; ok || string(secret.Data["existing"]) != "keep" {
+		t.Errorf("expired secret data = %v, want only unmanaged keys", secret.Data)
+	}
+	if secret.Annotations[expiredAnnotation] != "INC-123 database recovery" {
+		t.Errorf("expired annotation = %q, want the reason", secret.Annotations[expiredAnnotation])
+	}
+
+	// And is not synced again for the same reason
+	reconcile()
+	if secret := getSecret(t, cs); secret.Data["value"] != nil || secret.Annotations[statusMessageAnnotation] != c.checkoutExpiredMessage() {
+		t.Errorf("secret after expiry = %v, %q; want no value and the expired message", secret.Data, secret.Annotations[statusMessageAnnotation])
+	}
+
+	update := func(annotations map[string]string) {
+		t.Helper()
+		secret := getSecret(t, cs)
+		for k, v := range annotations {
+			secret.Annotations[k] = v
+		}
+		if _, err := cs.CoreV1().Secrets("default").Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.store.Update(secret); err != nil {
+			t.Fatal(err)
+		}
+		reconcile()
+	}
+
+	// A checkout said to begin in the future is expired rather than lasting forever
+	update(map[string]string{"k8s-secret-sync.weinbender.io/break-glass-reason": "INC-124 replica rebuild"})
+	if secret := getSecret(t, cs); string(secret.Data["value"]) != "s3cr3t" {
+		t.Fatalf("secret checked out for a new reason = %v, want value", secret.Data)
+	}
+	update(map[string]string{checkedOutAnnotation: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)})
+	if secret := getSecret(t, cs); secret.Data["value"] != nil {
+		t.Errorf("secret checked out in the future = %v, want the value removed", secret.Data)
+	}
+
+	// Neither reason can be used again, even after the other
+	update(map[string]string{"k8s-secret-sync.weinbender.io/break-glass-reason": "INC-123 database recovery"})
+	if secret := getSecret(t, cs); secret.Data["value"] != nil {
+		t.Errorf("secret checked out again for an earlier reason = %v, want no value", secret.Data)
+	}
+}
+
+func TestBreakGlassTTL(t *testing.T) {
+	c, _ := newTestController(t, &fakeProvider{})
+	c.cfg.BreakGlassTTL = 3600
+
+	cases := map[string]time.Duration{"": time.Hour, "15m": 15 * time.Minute, "4h": time.Hour}
+	for value, want := range cases {
+		secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/break-glass-ttl": value})
+		if got, err := c.breakGlassTTL(secret); err != nil || got != want {
+			t.Errorf("breakGlassTTL(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := c.breakGlassTTL(annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/break-glass-ttl": "-1m"})); err == nil {
+		t.Errorf("expected error for negative TTL")
+	}
+}
@@ -0,0 +1,185 @@
+package sync
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// valueHashAnnotation records a keyed SHA-256 of the raw provider value, so secrets
+// that render the same value differently can still be compared during canary rollouts.
+const valueHashAnnotation = "k8s-secret-sync.weinbender.io/value-sha256"
+
+// canaryHeldSinceAnnotation records when a secret's value change was first held for
+// canaries, so holds that outlast KSS_CANARY_MAX_HOLD can be failed.
+const canaryHeldSinceAnnotation = "k8s-secret-sync.weinbender.io/canary-held-since"
+
+// canaryRecheckInterval is how often a held secret re-checks its canaries while
+// they have not yet received the new value or their workloads are unhealthy.
+const canaryRecheckInterval = 30 * time.Second
+
+// valueHash returns the hex HMAC-SHA256 of a provider value under the operator's
+// hash key, so the recorded hash cannot be used to confirm guesses of the value.
+func valueHash(hashKey []byte, value string) string {
+	h := hmac.New(sha256.New, hashKey)
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// legacyValueHash is the unkeyed SHA-256 recorded as the value hash before hashes
+// were keyed, which canaries last synced by earlier versions still carry.
+func legacyValueHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// isCanary reports whether a secret is marked as a canary for its ref.
+func (c *controller) isCanary(secret *v1.Secret) bool {
+	canary, _ := strconv.ParseBool(secret.Annotations[c.cfg.Annotations.Canary])
+	return canary
+}
+
+// canaryHold returns how long a non-canary secret must wait before applying a new
+// value, and why. Changes are held until every canary in the secret's namespace making
+// the same provider request has received the new value, soaked for the configured
+// period, and (if configured) its workloads are healthy, so marking a secret as a
+// canary never holds back other namespaces. Requests without canaries are never held.
+func (c *controller) canaryHold(ctx context.Context, secret *v1.Secret, providerName string, req provider.Request, value string) (time.Duration, string, error) {
+	objs, err := c.store.ByIndex(refIndex, refIndexKey(providerName, req.Ref))
+	if err != nil {
+		return 0, "", err
+	}
+	key := requestKey(providerName, req)
+
+	newValueHash, legacyHash := valueHash(c.hashKey, value), legacyValueHash(value)
+	soak := time.Duration(c.cfg.CanarySoak) * time.Second
+	var hold time.Duration
+	var reason string
+	for _, obj := range objs {
+		canary, ok := obj.(*v1.Secret)
+		if !ok || canary.Namespace != secret.Namespace || !c.isCanary(canary) {
+			continue
+		}
+		canaryReq, err := c.providerRequest(canary, providerName, canary.Annotations[c.cfg.Annotations.ProviderRef])
+		if err != nil || requestKey(providerName, canaryReq) != key {
+			continue
+		}
+		id := canary.Namespace + "/" + canary.Name
+
+		if recorded := canary.Annotations[valueHashAnnotation]; (recorded != newValueHash && recorded != legacyHash) || canary.Annotations[statusAnnotation] != StatusSynced {
+			return canaryRecheckInterval, fmt.Sprintf("Waiting for canary %s to receive the new value", id), nil
+		}
+
+		changedAt, err := time.Parse(time.RFC3339, canary.Annotations["last-synced"])
+		if err != nil {
+			return canaryRecheckInterval, fmt.Sprintf("Waiting for canary %s to record its sync time", id), nil
+		}
+		if remaining := time.Until(changedAt.Add(soak)); remaining > hold {
+			hold = remaining
+			reason = fmt.Sprintf("Soaking canary %s until %s", id, changedAt.Add(soak).UTC().Format(time.RFC3339))
+		}
+
+		if workloads := canary.Annotations[c.cfg.Annotations.CanaryWorkloads]; workloads != "" {
+			healthy, err := c.workloadsHealthy(ctx, canary.Namespace, workloads)
+			if err != nil {
+				return 0, "", err
+			}
+			if !healthy && hold < canaryRecheckInterval {
+				hold = canaryRecheckInterval
+				reason = fmt.Sprintf("Waiting for canary %s workloads to become healthy", id)
+			}
+		}
+	}
+	return hold, reason, nil
+}
+
+// holdForCanaries defers a secret's value change while canaries roll it out, recording
+// when the hold began. Holds longer than KSS_CANARY_MAX_HOLD, such as for canaries
+// that failed or are never synced, end with the secret marked Failed rather than
+// being rechecked forever; a later sync re-checks the canaries.
+func (c *controller) holdForCanaries(ctx context.Context, secret *v1.Secret, hold time.Duration, reason string) error {
+	now := time.Now()
+	heldSince, err := time.Parse(time.RFC3339, secret.Annotations[canaryHeldSinceAnnotation])
+	if err != nil {
+		heldSince = now
+	}
+	if maxHold := time.Duration(c.cfg.CanaryMaxHold) * time.Second; maxHold > 0 && now.Sub(heldSince) >= maxHold {
+		message := fmt.Sprintf("Canary rollout did not complete within %s: %s", maxHold, reason)
+		if secret.Annotations[statusAnnotation] != StatusFailed {
+			klog.InfoS("Giving up holding value change for canary rollout", "namespace", secret.Namespace, "name", secret.Name, "reason", reason)
+			c.recorder.Event(secret, v1.EventTypeWarning, "CanaryHoldExpired", message)
+			if err := setStatus(ctx, c.cfg.Clientset, secret, StatusFailed, message); err != nil {
+				klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+			}
+		}
+		return nil
+	}
+
+	klog.InfoS("Holding value change for canary rollout", "namespace", secret.Namespace, "name", secret.Name, "reason", reason)
+	if secret.Annotations[statusMessageAnnotation] != reason || secret.Annotations[canaryHeldSinceAnnotation] == "" {
+		err := patchAnnotations(ctx, c.cfg.Clientset, secret, map[string]string{
+			statusAnnotation:          StatusPending,
+			statusMessageAnnotation:   reason,
+			canaryHeldSinceAnnotation: heldSince.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+		}
+	}
+	c.queue.AddAfter(secret.Namespace+"/"+secret.Name, hold)
+	return nil
+}
+
+// workloadsHealthy reports whether every workload in a comma-separated list of
+// "deployment/<name>" or "statefulset/<name>" references has rolled out and is ready.
+func (c *controller) workloadsHealthy(ctx context.Context, namespace, workloads string) (bool, error) {
+	apps := c.cfg.Clientset.AppsV1()
+	for _, ref := range strings.Split(workloads, ",") {
+		kind, name, ok := strings.Cut(strings.TrimSpace(ref), "/")
+		if !ok || name == "" {
+			return false, fmt.Errorf("invalid canary workload %q (expected kind/name)", ref)
+		}
+
+		var generation, observed int64
+		var replicas, updated, ready int32
+		switch strings.ToLower(kind) {
+		case "deployment", "deployments":
+			d, err := apps.Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			generation, observed = d.Generation, d.Status.ObservedGeneration
+			replicas, updated, ready = 1, d.Status.UpdatedReplicas, d.Status.ReadyReplicas
+			if d.Spec.Replicas != nil {
+				replicas = *d.Spec.Replicas
+			}
+		case "statefulset", "statefulsets":
+			s, err := apps.StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			generation, observed = s.Generation, s.Status.ObservedGeneration
+			replicas, updated, ready = 1, s.Status.UpdatedReplicas, s.Status.ReadyReplicas
+			if s.Spec.Replicas != nil {
+				replicas = *s.Spec.Replicas
+			}
+		default:
+			return false, fmt.Errorf("unsupported canary workload kind %q", kind)
+		}
+
+		if observed < generation || updated < replicas || ready < replicas {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,101 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// checkpointKey is the checkpoint ConfigMap key holding when each secret was last
+// checked against its provider, as Unix seconds by namespace/name key.
+const checkpointKey = "checked"
+
+// checkpoint saves refresh progress to a ConfigMap on shutdown and restores it on
+// startup. Unchanged refreshes don't write to their secrets, so without it a
+// restart in the middle of a large refresh pass would check every secret again;
+// with it, only the secrets still pending when the operator stopped are refreshed.
+type checkpoint struct {
+	cs        kubernetes.Interface
+	namespace string
+	name      string
+}
+
+func newCheckpoint(cs kubernetes.Interface, ref string) (*checkpoint, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("invalid checkpoint ConfigMap %q, expected namespace/name", ref)
+	}
+	return &checkpoint{cs: cs, namespace: namespace, name: name}, nil
+}
+
+// save writes the secrets checked within the last refresh interval, creating the
+// ConfigMap if needed. Older checks are already due again, so are left out.
+func (cp *checkpoint) save(ctx context.Context, c *controller) error {
+	cutoff := time.Now().Add(-c.refreshInterval())
+	checked := make(map[string]int64)
+	c.mu.Lock()
+	for key, at := range c.checked {
+		if at.After(cutoff) {
+			checked[key] = at.Unix()
+		}
+	}
+	c.mu.Unlock()
+
+	encoded, err := json.Marshal(checked)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{checkpointKey: string(encoded)}
+
+	configMaps := cp.cs.CoreV1().ConfigMaps(cp.namespace)
+	existing, err := configMaps.Get(ctx, cp.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: cp.namespace, Name: cp.name},
+			Data:       data,
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	existing.Data = data
+	_, err = configMaps.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// restore loads the checks saved by a previous run into the controller, so their
+// secrets wait for their next refresh. A missing ConfigMap is not an error.
+func (cp *checkpoint) restore(ctx context.Context, c *controller) error {
+	configMap, err := cp.cs.CoreV1().ConfigMaps(cp.namespace).Get(ctx, cp.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var checked map[string]int64
+	if err := json.Unmarshal([]byte(configMap.Data[checkpointKey]), &checked); err != nil {
+		return fmt.Errorf("invalid checkpoint: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, at := range checked {
+		if t := time.Unix(at, 0); t.After(c.checked[key]) {
+			c.checked[key] = t
+		}
+	}
+	klog.InfoS("Restored refresh checkpoint", "namespace", cp.namespace, "name", cp.name, "secrets", len(checked))
+	return nil
+}
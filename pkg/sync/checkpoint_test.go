@@ -0,0 +1,51 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckpointResumesRefresh(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+	ctx := context.Background()
+
+	cp, err := newCheckpoint(cs, "kss-system/checkpoint")
+	if err != nil {
+		t.Fatalf("newCheckpoint: %v", err)
+	}
+	if err := cp.restore(ctx, c); err != nil {
+		t.Fatalf("restoring missing checkpoint: %v", err)
+	}
+
+	// The secret was checked this pass, but last wrote long ago
+	synced := syncAndExpire(t, c, cs)
+	c.checked["default/example"] = time.Now()
+	c.checked["default/stale"] = time.Now().Add(-2 * c.refreshInterval())
+	if err := cp.save(ctx, c); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := cp.save(ctx, c); err != nil {
+		t.Fatalf("saving over an existing checkpoint: %v", err)
+	}
+
+	// After a restart the secret isn't refreshed again until its next refresh is due
+	c.checked = map[string]time.Time{}
+	if wait := c.untilRefresh(synced); wait > 0 {
+		t.Fatalf("expected secret to be due without a checkpoint, got wait %v", wait)
+	}
+	if err := cp.restore(ctx, c); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if wait := c.untilRefresh(synced); wait <= 0 {
+		t.Errorf("expected restored check to defer the refresh, got wait %v", wait)
+	}
+	if _, ok := c.checked["default/stale"]; ok {
+		t.Errorf("expected checks older than the refresh interval to be dropped")
+	}
+
+	if _, err := newCheckpoint(cs, "checkpoint"); err == nil {
+		t.Errorf("expected error for ConfigMap without namespace")
+	}
+}
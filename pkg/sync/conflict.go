@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// conflictPolicy controls what happens when both the provider value and the managed
+// data in the cluster have changed since the last sync.
+type conflictPolicy string
+
+const (
+	// conflictProviderWins overwrites the cluster's changes with the provider value (default).
+	conflictProviderWins conflictPolicy = "provider-wins"
+	// conflictClusterWins keeps the cluster's changes, ignoring the new provider value.
+	conflictClusterWins conflictPolicy = "cluster-wins"
+	// conflictFail leaves the secret untouched, reporting the conflict as a failure
+	// and raising a Warning event for someone to resolve it.
+	conflictFail conflictPolicy = "fail-and-alert"
+)
+
+// parseConflictPolicy parses the value of the on-conflict annotation. An empty
+// value selects the default policy.
+func parseConflictPolicy(value string) (conflictPolicy, error) {
+	switch policy := conflictPolicy(value); policy {
+	case "":
+		return conflictProviderWins, nil
+	case conflictProviderWins, conflictClusterWins, conflictFail:
+		return policy, nil
+	default:
+		return conflictProviderWins, fmt.Errorf("unknown conflict policy %q (expected provider-wins, cluster-wins, or fail-and-alert)", value)
+	}
+}
+
+// clusterModified reports whether a secret's managed data no longer matches the
+// hash recorded when it was last written, i.e. it was edited in the cluster.
+func clusterModified(hashKey []byte, secret *v1.Secret) bool {
+	recorded, ok := secret.Annotations[dataHashAnnotation]
+	if encrypted := secret.Annotations[encryptedHashAnnotation]; encrypted != "" {
+		recorded = encrypted
+	}
+	keys := managedKeys(secret)
+	if !ok || len(keys) == 0 {
+		return false
+	}
+	return dataHash(hashKey, secret.Data, keys) != recorded
+}
@@ -0,0 +1,274 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/cache"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/logging"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/notify"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// controller processes secrets from a rate-limited work queue, so failed syncs are
+// retried with backoff rather than dropped.
+type controller struct {
+	cfg       *config.Sync
+	providers map[string]func() (provider.SecretProvider, error)
+	cache     cache.Store
+	shared    cache.Store // values just resolved, shared by secrets with the same ref; nil disables
+	store     toolscache.Indexer
+	limiter   workqueue.TypedRateLimiter[string]
+	queue     workqueue.TypedRateLimitingInterface[string]
+	recorder  record.EventRecorder
+	notifier  notify.Notifier
+	health    *healthChecker
+	throttle  *apiThrottle
+	syncFunc  func(ctx context.Context, secret *v1.Secret) error // syncs a secret; syncSecret unless running as a sidecar
+	hashKey   []byte                                             // key the hashes of synced data are keyed with
+	labels    map[string]string                                  // labels set on every secret written
+	verifier  *verifier                                          // checks managed data for out-of-band changes; nil if disabled
+
+	mu       gosync.Mutex
+	checked  map[string]time.Time     // when each secret was last checked against its provider
+	detected map[string]detection     // pending value changes held for an apply-after delay
+	observed map[string][2]string     // provider and outcome of each secret seen in observe-only mode
+	synced   map[string]time.Time     // when each secret was last found up to date with its provider
+	skipped  map[string]time.Time     // when each secret was last logged as skipped, by reason
+	warned   map[string]bool          // secrets in a protected namespace, warned about when they became protected
+	inFlight map[string]chan struct{} // slots for requests in flight to each provider with a limit
+	initial  []prioritized            // secrets in the informer's initial list, held until it has synced
+	started  bool                     // whether the initial list has been queued
+}
+
+func newController(cfg *config.Sync, providers map[string]func() (provider.SecretProvider, error), valueCache cache.Store, store toolscache.Indexer, recorder record.EventRecorder, notifier notify.Notifier) *controller {
+	limiter := workqueue.DefaultTypedControllerRateLimiter[string]()
+	c := &controller{
+		cfg:       cfg,
+		providers: providers,
+		cache:     valueCache,
+		store:     store,
+		limiter:   limiter,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			limiter,
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "secrets"},
+		),
+		recorder: recorder,
+		notifier: notifier,
+		health:   newHealthChecker(providers),
+		throttle: newAPIThrottle(cfg.MaxRefreshSlowdown),
+		checked:  make(map[string]time.Time),
+		detected: make(map[string]detection),
+		observed: make(map[string][2]string),
+		synced:   make(map[string]time.Time),
+		skipped:  make(map[string]time.Time),
+		warned:   make(map[string]bool),
+		inFlight: make(map[string]chan struct{}),
+	}
+	c.syncFunc = c.syncSecret
+	return c
+}
+
+// refIndex indexes secrets by provider and ref, so secrets sharing an upstream
+// value can be found without scanning the whole cache.
+const refIndex = "providerRef"
+
+// refIndexKey returns the refIndex value (and value cache key) for a provider ref.
+func refIndexKey(providerName, secretID string) string {
+	return providerName + "\x00" + secretID
+}
+
+// refIndexFunc indexes secrets by the provider and ref annotations in annotations,
+// with ref templates expanded for the cluster.
+func refIndexFunc(annotations config.Annotations, clusterName string) toolscache.IndexFunc {
+	return func(obj any) ([]string, error) {
+		secret, ok := obj.(*v1.Secret)
+		if !ok {
+			return nil, nil
+		}
+		providerName, secretID := secret.Annotations[annotations.ProviderName], secret.Annotations[annotations.ProviderRef]
+		if providerName == "" || secretID == "" {
+			return nil, nil
+		}
+		if expanded, err := expandRef(secretID, secret, clusterName); err == nil {
+			secretID = expanded
+		}
+		return []string{refIndexKey(providerName, secretID)}, nil
+	}
+}
+
+// enqueue adds a secret's namespace/name key to the work queue.
+func (c *controller) enqueue(obj any) {
+	key, err := toolscache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.ErrorS(err, "Failed to get key for object, skipping")
+		return
+	}
+	c.queue.Add(key)
+}
+
+// secretDeleted forgets what is kept about a deleted secret.
+func (c *controller) secretDeleted(obj any) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		return
+	}
+	c.forget(secret.Namespace, secret.Name)
+}
+
+// forget drops the state kept in memory and the metric series for a deleted secret,
+// so they don't accumulate as secrets come and go.
+func (c *controller) forget(namespace, name string) {
+	key := namespace + "/" + name
+	c.mu.Lock()
+	delete(c.checked, key)
+	delete(c.detected, key)
+	delete(c.observed, key)
+	delete(c.synced, key)
+	delete(c.warned, key)
+	for skipped := range c.skipped {
+		if strings.HasPrefix(skipped, key+"\x00") {
+			delete(c.skipped, skipped)
+		}
+	}
+	c.mu.Unlock()
+
+	if c.verifier != nil {
+		c.verifier.forget(namespace, name)
+	}
+	metrics.ForgetSecret(namespace, name)
+}
+
+// run starts workers and blocks until ctx is cancelled.
+func (c *controller) run(ctx context.Context, workers int) {
+	go func() {
+		<-ctx.Done()
+		c.queue.ShutDown()
+	}()
+
+	for range max(workers, 1) {
+		go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	}
+	<-ctx.Done()
+}
+
+func (c *controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+// processNextItem syncs the next queued secret, returning false when the queue shuts down.
+func (c *controller) processNextItem(ctx context.Context) bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	c.handleErr(key, c.reconcile(ctx, key))
+	return true
+}
+
+// reconcile looks up the secret for key and syncs it.
+func (c *controller) reconcile(ctx context.Context, key string) error {
+	obj, exists, err := c.store.GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		klog.ErrorS(nil, "Failed to cast object to Secret, skipping", "key", key)
+		return nil
+	}
+	if secret.DeletionTimestamp != nil {
+		logging.V(logging.Sync, 2).InfoS("Ignoring secret being deleted", "namespace", secret.Namespace, "name", secret.Name)
+		return nil
+	}
+	if err := c.syncFunc(ctx, secret); err != nil {
+		if c.tornDown(ctx, secret, err) {
+			klog.InfoS("Dropping failed sync of secret being deleted", "namespace", secret.Namespace, "name", secret.Name, "err", err)
+			return nil
+		}
+		c.notifier.OnSyncFailure(ctx, secret, err)
+		return err
+	}
+	return nil
+}
+
+// handleErr requeues failed keys. Explicit backoff hints from the provider or the
+// Kubernetes API (Retry-After) are honored; otherwise the queue's exponential
+// backoff applies, within the limits of the secret's provider policy. Throttling
+// by the API server also slows refreshes of every secret.
+func (c *controller) handleErr(key string, err error) {
+	if err == nil {
+		c.queue.Forget(key)
+		return
+	}
+	c.throttle.observe(err)
+
+	if delay := retryAfter(err); delay > 0 {
+		klog.InfoS("Backing off as requested by upstream", "key", key, "retryAfter", delay)
+		c.queue.AddAfter(key, delay)
+		return
+	}
+
+	policy := c.providerPolicy(key)
+	retries := c.queue.NumRequeues(key)
+	if policy.MaxRetries > 0 && retries >= policy.MaxRetries {
+		klog.ErrorS(err, "Failed to sync secret, retrying at next refresh", "key", key, "retries", retries)
+		c.queue.Forget(key)
+		if interval := c.refreshInterval(); interval > 0 {
+			c.queue.AddAfter(key, interval)
+		}
+		return
+	}
+
+	delay := c.limiter.When(key)
+	if backoffMax := time.Duration(policy.BackoffMax) * time.Second; backoffMax > 0 && delay > backoffMax {
+		delay = backoffMax
+	}
+	klog.ErrorS(err, "Failed to sync secret, requeuing", "key", key, "retries", retries, "delay", delay)
+	c.queue.AddAfter(key, delay)
+}
+
+// providerPolicy returns the timeout and retry policy for the provider of the secret
+// with the given key, or the default policy if it cannot be found.
+func (c *controller) providerPolicy(key string) config.ProviderPolicy {
+	var providerName string
+	if obj, exists, err := c.store.GetByKey(key); err == nil && exists {
+		if secret, ok := obj.(*v1.Secret); ok {
+			providerName = secret.Annotations[c.cfg.Annotations.ProviderName]
+		}
+	}
+	return c.cfg.ProviderPolicy(providerName)
+}
+
+// retryAfter returns the delay requested by upstream for err, or zero if none was given.
+func retryAfter(err error) time.Duration {
+	var rateLimited *provider.RateLimitedError
+	if errors.As(err, &rateLimited) {
+		return rateLimited.RetryAfter
+	}
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
@@ -0,0 +1,1586 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/cache"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/notify"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// The fake provider is registered so capability checks apply to it. It keeps
+// versions but returns only text.
+func init() {
+	provider.Register(provider.Info{Name: "fake", Capabilities: provider.Capabilities{Versioning: true}})
+}
+
+// fakeProvider resolves refs from a map, returning provider.ErrNotFound for unknown refs.
+type fakeProvider struct {
+	values    map[string]string
+	err       error
+	healthErr error
+	calls     int
+	last      provider.Request // most recent request
+	ttl       time.Duration    // lifetime of returned values; zero values do not expire
+}
+
+func (p *fakeProvider) Resolve(_ context.Context, req provider.Request) (provider.Response, error) {
+	p.calls++
+	p.last = req
+	if p.err != nil {
+		return provider.Response{}, p.err
+	}
+	value, ok := p.values[req.Ref]
+	if !ok {
+		return provider.Response{}, provider.ErrNotFound
+	}
+	resp := provider.Response{Value: []byte(value)}
+	if p.ttl > 0 {
+		resp.Expiry = time.Now().Add(p.ttl)
+	}
+	return resp, nil
+}
+
+func (p *fakeProvider) HealthCheck(context.Context) error {
+	return p.healthErr
+}
+
+// newTestController returns a controller backed by a fake clientset containing secrets,
+// with the "fake" provider name mapped to p.
+func newTestController(t *testing.T, p *fakeProvider, secrets ...*v1.Secret) (*controller, *fake.Clientset) {
+	t.Helper()
+
+	objects := make([]runtime.Object, len(secrets))
+	cfg := config.New(fake.NewSimpleClientset())
+	cfg.Refresh = true
+	store := toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, toolscache.Indexers{
+		refIndex:       refIndexFunc(cfg.Annotations, cfg.ClusterName),
+		dependsOnIndex: dependsOnIndexFunc(cfg.Annotations),
+	})
+	for i, secret := range secrets {
+		objects[i] = secret
+		if err := store.Add(secret); err != nil {
+			t.Fatalf("adding secret to store: %v", err)
+		}
+	}
+	cs := fake.NewSimpleClientset(objects...)
+	cfg.Clientset = cs
+
+	providers := map[string]func() (provider.SecretProvider, error){
+		"fake": func() (provider.SecretProvider, error) { return p, nil },
+	}
+	recorder := record.NewFakeRecorder(100)
+	c := newController(cfg, providers, nil, store, recorder, notify.Events{Recorder: recorder})
+	c.hashKey = testHashKey
+	var err error
+	if c.labels, err = parseSecretLabels(cfg.SecretLabels); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.queue.ShutDown)
+	return c, cs
+}
+
+// hasEvent drains the events recorded by c, reporting whether any had the given reason.
+func hasEvent(c *controller, reason string) bool {
+	events := c.recorder.(*record.FakeRecorder).Events
+	found := false
+	for len(events) > 0 {
+		if strings.Contains(<-events, " "+reason+" ") {
+			found = true
+		}
+	}
+	return found
+}
+
+func annotatedSecret(annotations map[string]string) *v1.Secret {
+	merged := map[string]string{
+		"k8s-secret-sync.weinbender.io/provider-name": "fake",
+		"k8s-secret-sync.weinbender.io/provider-ref":  "fake://ref",
+	}
+	for k, v := range annotations {
+		merged[k] = v
+	}
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: merged,
+		},
+		Data: map[string][]byte{"existing": []byte("keep")},
+	}
+}
+
+func getSecret(t *testing.T, cs *fake.Clientset) *v1.Secret {
+	t.Helper()
+	secret, err := cs.CoreV1().Secrets("default").Get(context.Background(), "example", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting secret: %v", err)
+	}
+	return secret
+}
+
+func TestReconcileSyncsValue(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	secret := getSecret(t, cs)
+	if got := string(secret.Data["value"]); got != "s3cr3t" {
+		t.Errorf("value = %q, want s3cr3t", got)
+	}
+	if got := string(secret.Data["existing"]); got != "keep" {
+		t.Errorf("existing = %q, want unmanaged keys preserved", got)
+	}
+	if secret.Annotations["last-synced"] == "" {
+		t.Errorf("expected last-synced annotation")
+	}
+	if got := secret.Annotations[statusAnnotation]; got != StatusSynced {
+		t.Errorf("status = %q, want %q", got, StatusSynced)
+	}
+	if got := secret.Annotations[provenanceProvider]; got != "fake" {
+		t.Errorf("provenance provider = %q, want fake", got)
+	}
+	if got := secret.Annotations[managedKeysAnnotation]; got != "value" {
+		t.Errorf("managed keys = %q, want value", got)
+	}
+}
+
+func TestReconcileSkipsRecentlySyncedAndMissing(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	lastSynced := time.Now().UTC().Format(time.RFC3339)
+	c, _ := newTestController(t, p, annotatedSecret(map[string]string{"last-synced": lastSynced}))
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if err := c.reconcile(context.Background(), "default/missing"); err != nil {
+		t.Fatalf("reconcile missing: %v", err)
+	}
+	if p.calls != 0 {
+		t.Errorf("expected no provider calls, got %d", p.calls)
+	}
+}
+
+func TestReconcileNotFoundPolicies(t *testing.T) {
+	cases := []struct {
+		policy     string
+		wantErr    bool
+		wantValue  []byte
+		wantExists bool
+	}{
+		{"", true, nil, false},
+		{"empty", false, []byte{}, true},
+		{"delete", false, nil, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.policy, func(t *testing.T) {
+			secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/on-not-found": tc.policy})
+			secret.Data["value"] = []byte("old")
+			c, cs := newTestController(t, &fakeProvider{}, secret)
+
+			err := c.reconcile(context.Background(), "default/example")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("reconcile error = %v, want error %v", err, tc.wantErr)
+			}
+
+			got := getSecret(t, cs)
+			if status := got.Annotations[statusAnnotation]; status != StatusNotFound {
+				t.Errorf("status = %q, want %q", status, StatusNotFound)
+			}
+			if tc.wantErr {
+				if string(got.Data["value"]) != "old" {
+					t.Errorf("expected value to be left untouched, got %q", got.Data["value"])
+				}
+				return
+			}
+			value, exists := got.Data["value"]
+			if exists != tc.wantExists || string(value) != string(tc.wantValue) {
+				t.Errorf("value = %q (exists %v), want %q (exists %v)", value, exists, tc.wantValue, tc.wantExists)
+			}
+		})
+	}
+}
+
+func TestReconcileProviderError(t *testing.T) {
+	p := &fakeProvider{err: errors.New("boom")}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+
+	if err := c.reconcile(context.Background(), "default/example"); err == nil {
+		t.Fatalf("expected error from reconcile")
+	}
+	if got := getSecret(t, cs).Annotations[statusAnnotation]; got != StatusFailed {
+		t.Errorf("status = %q, want %q", got, StatusFailed)
+	}
+}
+
+func TestReconcileParksUnauthorized(t *testing.T) {
+	p := &fakeProvider{err: fmt.Errorf("%w: token revoked", provider.ErrUnauthorized)}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("expected unauthorized errors not to be retried, got %v", err)
+	}
+	if got := getSecret(t, cs).Annotations[statusAnnotation]; got != StatusFailed {
+		t.Errorf("status = %q, want %q", got, StatusFailed)
+	}
+	if !hasEvent(c, "ProviderUnauthorized") {
+		t.Errorf("expected a warning event")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if got := retryAfter(errors.New("boom")); got != 0 {
+		t.Errorf("retryAfter(plain error) = %v, want 0", got)
+	}
+	err := &provider.RateLimitedError{RetryAfter: 30 * time.Second, Err: errors.New("429")}
+	if got := retryAfter(err); got != 30*time.Second {
+		t.Errorf("retryAfter(rate limited) = %v, want 30s", got)
+	}
+	throttled := apierrors.NewTooManyRequests("slow down", 7)
+	if got := retryAfter(throttled); got != 7*time.Second {
+		t.Errorf("retryAfter(429 from API server) = %v, want 7s", got)
+	}
+}
+
+func TestHandleErrHonorsRetryAfter(t *testing.T) {
+	c, _ := newTestController(t, &fakeProvider{})
+
+	c.handleErr("default/example", &provider.RateLimitedError{RetryAfter: time.Hour, Err: errors.New("429")})
+	if c.queue.Len() != 0 {
+		t.Errorf("expected key to be delayed, not immediately queued")
+	}
+	if c.queue.NumRequeues("default/example") != 0 {
+		t.Errorf("expected Retry-After to bypass the rate limiter")
+	}
+
+	c.handleErr("default/example", errors.New("boom"))
+	if c.queue.NumRequeues("default/example") != 1 {
+		t.Errorf("expected plain errors to use the rate limiter")
+	}
+}
+
+func TestHandleErrProviderPolicy(t *testing.T) {
+	t.Setenv("KSS_PROVIDER_FAKE_MAX_RETRIES", "2")
+	t.Setenv("KSS_PROVIDER_FAKE_BACKOFF_MAX", "1")
+	c, _ := newTestController(t, &fakeProvider{}, annotatedSecret(nil))
+
+	for range 2 {
+		c.handleErr("default/example", errors.New("boom"))
+	}
+	if got := c.queue.NumRequeues("default/example"); got != 2 {
+		t.Fatalf("NumRequeues = %d, want 2", got)
+	}
+
+	// Once retries are exhausted the key waits for its next refresh instead
+	c.handleErr("default/example", errors.New("boom"))
+	if got := c.queue.NumRequeues("default/example"); got != 0 {
+		t.Errorf("expected retries to be reset after giving up, got %d", got)
+	}
+}
+
+func TestHandleErrSlowsRefreshWhenThrottled(t *testing.T) {
+	c, _ := newTestController(t, &fakeProvider{})
+	now := time.Now()
+	c.throttle.now = func() time.Time { return now }
+	base := c.refreshInterval()
+
+	throttled := apierrors.NewTooManyRequests("too many requests", 1)
+	for range 5 {
+		c.handleErr("default/example", throttled)
+	}
+	if got, want := c.refreshInterval(), base*time.Duration(c.cfg.MaxRefreshSlowdown); got != want {
+		t.Errorf("refresh interval while throttled = %v, want %v", got, want)
+	}
+
+	// Other errors don't affect the slowdown, which recovers once throttling stops
+	c.handleErr("default/example", errors.New("boom"))
+	now = now.Add(throttleRecovery)
+	if got, want := c.refreshInterval(), base*time.Duration(c.cfg.MaxRefreshSlowdown/2); got != want {
+		t.Errorf("refresh interval after recovery = %v, want %v", got, want)
+	}
+	now = now.Add(time.Hour)
+	if got := c.refreshInterval(); got != base {
+		t.Errorf("refresh interval after full recovery = %v, want %v", got, base)
+	}
+}
+
+func TestReconcileKeyMapping(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": `{"user":"admin","pass":"hunter2"}`}}
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/key-mapping": "username=.user,password=.pass"})
+	c, cs := newTestController(t, p, secret)
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	got := getSecret(t, cs)
+	if string(got.Data["username"]) != "admin" || string(got.Data["password"]) != "hunter2" {
+		t.Errorf("data = %q, want mapped username/password", got.Data)
+	}
+	if _, ok := got.Data["value"]; ok {
+		t.Errorf("expected default key not to be written when a key mapping is set")
+	}
+	if keys := got.Annotations[managedKeysAnnotation]; keys != "password,username" {
+		t.Errorf("managed keys = %q, want password,username", keys)
+	}
+}
+
+func TestReconcileDotenvTransform(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "API_KEY=abc\nAPI_URL=https://example.com\n"}}
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/transform": "dotenv"})
+	c, cs := newTestController(t, p, secret)
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	got := getSecret(t, cs)
+	if string(got.Data["API_KEY"]) != "abc" || string(got.Data["API_URL"]) != "https://example.com" {
+		t.Errorf("data = %q, want expanded dotenv keys", got.Data)
+	}
+	if keys := got.Annotations[managedKeysAnnotation]; keys != "API_KEY,API_URL" {
+		t.Errorf("managed keys = %q, want API_KEY,API_URL", keys)
+	}
+}
+
+func TestReconcileAdditionalRefs(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "A=1\n", "fake://other": "B=2"}}
+	secret := annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/transform":       "dotenv",
+		"k8s-secret-sync.weinbender.io/additional-refs": "fake://other",
+	})
+	c, cs := newTestController(t, p, secret)
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := getSecret(t, cs); string(got.Data["A"]) != "1" || string(got.Data["B"]) != "2" {
+		t.Errorf("data = %q, want keys from both refs", got.Data)
+	}
+}
+
+func TestReconcileBundle(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "-----BEGIN CERTIFICATE-----\nleaf\n-----END CERTIFICATE-----", "fake://ca": "-----BEGIN CERTIFICATE-----\nca\n-----END CERTIFICATE-----\n"}}
+	secret := annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/secret-key":   "chain.pem",
+		"k8s-secret-sync.weinbender.io/bundle":       "concat",
+		"k8s-secret-sync.weinbender.io/bundle-files": "leaf.pem,ca.pem=fake://ca",
+	})
+	c, cs := newTestController(t, p, secret)
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	want := "-----BEGIN CERTIFICATE-----\nleaf\n-----END CERTIFICATE-----\n-----BEGIN CERTIFICATE-----\nca\n-----END CERTIFICATE-----\n"
+	if got := getSecret(t, cs); string(got.Data["chain.pem"]) != want {
+		t.Errorf("chain.pem = %q, want %q", got.Data["chain.pem"], want)
+	}
+}
+
+func TestReconcileExpandsRenderedRefs(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{
+		"fake://ref":           "A=1\n",
+		"fake://default/other": "B=2",
+		"fake://ref-ca":        "ca",
+		"fake://default/ca":    "-----BEGIN CERTIFICATE-----\nca\n-----END CERTIFICATE-----\n",
+	}}
+	transformed := annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/transform":       "dotenv",
+		"k8s-secret-sync.weinbender.io/additional-refs": "fake://{{ .Namespace }}/other",
+	})
+	bundled := annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/provider-ref": "fake://ref-ca",
+		"k8s-secret-sync.weinbender.io/secret-key":   "ca.pem",
+		"k8s-secret-sync.weinbender.io/bundle":       "concat",
+		"k8s-secret-sync.weinbender.io/bundle-files": "ca.pem=fake://{{ .Namespace }}/ca",
+	})
+	bundled.Name = "bundled"
+	c, cs := newTestController(t, p, transformed, bundled)
+	ctx := context.Background()
+
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := getSecret(t, cs); string(got.Data["B"]) != "2" {
+		t.Errorf("data = %q, want the expanded additional ref resolved", got.Data)
+	}
+	if err := c.reconcile(ctx, "default/bundled"); err != nil {
+		t.Fatalf("reconcile bundled: %v", err)
+	}
+	got, err := cs.CoreV1().Secrets("default").Get(ctx, "bundled", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting bundled: %v", err)
+	}
+	if want := p.values["fake://default/ca"]; string(got.Data["ca.pem"]) != want {
+		t.Errorf("ca.pem = %q, want the expanded bundle ref resolved", got.Data["ca.pem"])
+	}
+}
+
+func TestReconcileChecksSecretType(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	secret := annotatedSecret(nil)
+	secret.Type = v1.SecretTypeSSHAuth
+	c, cs := newTestController(t, p, secret)
+
+	if err := c.reconcile(context.Background(), "default/example"); err == nil {
+		t.Fatalf("expected error for a value without the ssh-privatekey key")
+	}
+	if got := getSecret(t, cs); got.Annotations[statusAnnotation] != StatusFailed {
+		t.Errorf("status = %q, want Failed", got.Annotations[statusAnnotation])
+	}
+}
+
+func TestReconcileSyncsOnceWithoutRefresh(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+	c.cfg.Refresh = false
+	ctx := context.Background()
+
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("initial reconcile: %v", err)
+	}
+	stale := getSecret(t, cs)
+	stale.Annotations["last-synced"] = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	if err := c.store.Update(stale); err != nil {
+		t.Fatalf("updating store: %v", err)
+	}
+
+	// A synced secret is left alone, however long ago it was synced
+	p.values["fake://ref"] = "v2"
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if p.calls != 1 || string(getSecret(t, cs).Data["value"]) != "v1" {
+		t.Errorf("expected synced secret not to be refreshed, got %d calls", p.calls)
+	}
+}
+
+func TestReconcileRefresh(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+	c.cfg.ChecksumAnnotation = "checksum/secret"
+	ctx := context.Background()
+
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("initial reconcile: %v", err)
+	}
+	first := getSecret(t, cs)
+	if first.Annotations["checksum/secret"] == "" {
+		t.Fatalf("expected checksum annotation to be written")
+	}
+	if first.Annotations["reloader.stakater.com/match"] != "true" {
+		t.Errorf("expected Reloader match annotation to be written")
+	}
+
+	// Make the secret due for refresh and feed the synced object back into the store
+	stale := first.DeepCopy()
+	stale.Annotations["last-synced"] = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	c.checked = map[string]time.Time{}
+	if err := c.store.Update(stale); err != nil {
+		t.Fatalf("updating store: %v", err)
+	}
+
+	// An unchanged value doesn't write to the secret
+	actions := len(cs.Actions())
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("unchanged refresh: %v", err)
+	}
+	if p.calls != 2 {
+		t.Errorf("expected refresh to resolve the value again, got %d calls", p.calls)
+	}
+	if got := len(cs.Actions()); got != actions {
+		t.Errorf("expected no writes for an unchanged value, got %d new actions", got-actions)
+	}
+
+	// A changed value is written along with a new checksum
+	p.values["fake://ref"] = "v2"
+	c.checked = map[string]time.Time{}
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("changed refresh: %v", err)
+	}
+	second := getSecret(t, cs)
+	if string(second.Data["value"]) != "v2" {
+		t.Errorf("value = %q, want v2", second.Data["value"])
+	}
+	if second.Annotations["checksum/secret"] == first.Annotations["checksum/secret"] {
+		t.Errorf("expected checksum annotation to change with the value")
+	}
+}
+
+// syncAndExpire runs an initial sync, then makes the secret due for refresh.
+func syncAndExpire(t *testing.T, c *controller, cs *fake.Clientset) *v1.Secret {
+	t.Helper()
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("initial reconcile: %v", err)
+	}
+	synced := getSecret(t, cs).DeepCopy()
+	synced.Annotations["last-synced"] = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	c.checked = map[string]time.Time{}
+	if err := c.store.Update(synced); err != nil {
+		t.Fatalf("updating store: %v", err)
+	}
+	return synced
+}
+
+func TestReconcileDefersChangesOutsideMaintenanceWindow(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	// A one-minute window on January 1st is effectively always closed
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/maintenance-window": "0 0 1 1 * 1m"})
+	c, cs := newTestController(t, p, secret)
+	syncAndExpire(t, c, cs)
+
+	p.values["fake://ref"] = "v2"
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	got := getSecret(t, cs)
+	if string(got.Data["value"]) != "v1" {
+		t.Errorf("value = %q, want change deferred", got.Data["value"])
+	}
+	if status := got.Annotations[statusAnnotation]; status != StatusPending {
+		t.Errorf("status = %q, want %q", status, StatusPending)
+	}
+}
+
+func TestReconcileConflictPolicies(t *testing.T) {
+	tests := []struct {
+		policy     string
+		wantValue  string
+		wantStatus string
+		wantEvent  bool
+	}{
+		{policy: "", wantValue: "v2", wantStatus: StatusSynced},
+		{policy: "provider-wins", wantValue: "v2", wantStatus: StatusSynced},
+		{policy: "cluster-wins", wantValue: "edited", wantStatus: StatusSynced},
+		{policy: "fail-and-alert", wantValue: "edited", wantStatus: StatusFailed, wantEvent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+			secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/on-conflict": tt.policy})
+			c, cs := newTestController(t, p, secret)
+			ctx := context.Background()
+
+			// Edit the value in the cluster, and upstream
+			synced := syncAndExpire(t, c, cs)
+			synced.Data["value"] = []byte("edited")
+			if _, err := cs.CoreV1().Secrets("default").Update(ctx, synced, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("updating secret: %v", err)
+			}
+			if err := c.store.Update(synced); err != nil {
+				t.Fatalf("updating store: %v", err)
+			}
+			p.values["fake://ref"] = "v2"
+
+			if err := c.reconcile(ctx, "default/example"); err != nil {
+				t.Fatalf("reconcile: %v", err)
+			}
+			got := getSecret(t, cs)
+			if string(got.Data["value"]) != tt.wantValue {
+				t.Errorf("value = %q, want %q", got.Data["value"], tt.wantValue)
+			}
+			if status := got.Annotations[statusAnnotation]; status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", status, tt.wantStatus)
+			}
+			if event := hasEvent(c, "SyncConflict"); event != tt.wantEvent {
+				t.Errorf("SyncConflict event = %v, want %v", event, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestReconcileCanaryRollout(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	regular := annotatedSecret(nil)
+	canary := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/canary": "true"})
+	canary.Name = "canary"
+	c, cs := newTestController(t, p, regular, canary)
+	ctx := context.Background()
+
+	// Initial sync of both secrets, then make them due for refresh
+	expire := func(name string) {
+		if err := c.reconcile(ctx, "default/"+name); err != nil {
+			t.Fatalf("reconcile %s: %v", name, err)
+		}
+		synced, err := cs.CoreV1().Secrets("default").Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("getting %s: %v", name, err)
+		}
+		synced.Annotations["last-synced"] = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		c.checked = map[string]time.Time{}
+		if err := c.store.Update(synced); err != nil {
+			t.Fatalf("updating store: %v", err)
+		}
+	}
+	expire("example")
+	expire("canary")
+
+	// The regular secret waits until the canary has the new value
+	p.values["fake://ref"] = "v2"
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := getSecret(t, cs); string(got.Data["value"]) != "v1" || got.Annotations[statusAnnotation] != StatusPending {
+		t.Fatalf("expected regular secret to be held, got value %q status %q", got.Data["value"], got.Annotations[statusAnnotation])
+	}
+
+	// The canary is updated immediately, then soaks
+	expire("canary")
+	if got, _ := cs.CoreV1().Secrets("default").Get(ctx, "canary", metav1.GetOptions{}); string(got.Data["value"]) != "v2" {
+		t.Fatalf("expected canary to receive the new value, got %q", got.Data["value"])
+	}
+	canarySynced, _ := cs.CoreV1().Secrets("default").Get(ctx, "canary", metav1.GetOptions{})
+	if err := c.store.Update(canarySynced); err != nil {
+		t.Fatalf("updating store: %v", err)
+	}
+
+	c.checked = map[string]time.Time{}
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := getSecret(t, cs); string(got.Data["value"]) != "v1" {
+		t.Fatalf("expected regular secret to be held during soak, got %q", got.Data["value"])
+	}
+
+	// Once the soak period has passed the change rolls out
+	c.cfg.CanarySoak = 0
+	c.checked = map[string]time.Time{}
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := getSecret(t, cs); string(got.Data["value"]) != "v2" {
+		t.Errorf("expected regular secret to be updated after soak, got %q", got.Data["value"])
+	}
+}
+
+func TestReconcileCanaryLegacyValueHash(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	regular := annotatedSecret(nil)
+	c, cs := newTestController(t, p, regular)
+	ctx := context.Background()
+	syncAndExpire(t, c, cs)
+
+	// A canary that received the new value before value hashes were keyed
+	canary := annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/canary": "true",
+		valueHashAnnotation:                    legacyValueHash("v2"),
+		statusAnnotation:                       StatusSynced,
+		"last-synced":                          time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+	})
+	canary.Name = "canary"
+	if err := c.store.Add(canary); err != nil {
+		t.Fatalf("adding canary: %v", err)
+	}
+
+	p.values["fake://ref"] = "v2"
+	c.cfg.CanarySoak = 0
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := getSecret(t, cs); string(got.Data["value"]) != "v2" {
+		t.Errorf("expected a canary with a legacy value hash to count as rolled out, got %q", got.Data["value"])
+	}
+	if got := getSecret(t, cs).Annotations[valueHashAnnotation]; got != valueHash(testHashKey, "v2") {
+		t.Errorf("value hash = %q, want the keyed hash", got)
+	}
+}
+
+func TestReconcileCanaryOtherNamespace(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+	ctx := context.Background()
+	syncAndExpire(t, c, cs)
+
+	// A canary for the same ref in another namespace that never receives the value
+	canary := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/canary": "true"})
+	canary.Namespace, canary.Name = "other", "canary"
+	if err := c.store.Add(canary); err != nil {
+		t.Fatalf("adding canary: %v", err)
+	}
+
+	p.values["fake://ref"] = "v2"
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := getSecret(t, cs); string(got.Data["value"]) != "v2" {
+		t.Errorf("expected a canary in another namespace not to hold the change, got %q", got.Data["value"])
+	}
+}
+
+func TestReconcileCanaryMaxHold(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+	ctx := context.Background()
+	synced := syncAndExpire(t, c, cs)
+
+	// A canary that failed and never receives the new value
+	canary := annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/canary": "true",
+		statusAnnotation:                       StatusFailed,
+	})
+	canary.Name = "canary"
+	if err := c.store.Add(canary); err != nil {
+		t.Fatalf("adding canary: %v", err)
+	}
+
+	p.values["fake://ref"] = "v2"
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	held := getSecret(t, cs)
+	if held.Annotations[statusAnnotation] != StatusPending || held.Annotations[canaryHeldSinceAnnotation] == "" {
+		t.Fatalf("status = %q, held since %q; want Pending with the hold recorded", held.Annotations[statusAnnotation], held.Annotations[canaryHeldSinceAnnotation])
+	}
+
+	// Once the hold outlasts the maximum the secret is failed instead
+	held.Annotations[canaryHeldSinceAnnotation] = time.Now().Add(-25 * time.Hour).UTC().Format(time.RFC3339)
+	held.Annotations["last-synced"] = synced.Annotations["last-synced"]
+	if _, err := cs.CoreV1().Secrets("default").Update(ctx, held, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("updating secret: %v", err)
+	}
+	c.checked = map[string]time.Time{}
+	if err := c.store.Update(held); err != nil {
+		t.Fatalf("updating store: %v", err)
+	}
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	failed := getSecret(t, cs)
+	if string(failed.Data["value"]) != "v1" || failed.Annotations[statusAnnotation] != StatusFailed {
+		t.Errorf("value = %q, status = %q; want v1 kept and Failed", failed.Data["value"], failed.Annotations[statusAnnotation])
+	}
+	if !hasEvent(c, "CanaryHoldExpired") {
+		t.Error("expected a CanaryHoldExpired event")
+	}
+}
+
+func TestReconcileTemplateFromConfigMap(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": `{"user":"admin","pass":"hunter2"}`}}
+	secret := annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/secret-key":         "config.yaml",
+		"k8s-secret-sync.weinbender.io/template-configmap": "app-templates#config.yaml",
+	})
+	c, cs := newTestController(t, p, secret)
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app-templates"},
+		Data:       map[string]string{"config.yaml": "db:\n  user: {{ .Fields.user }}\n  password: {{ .Fields.pass }}\n"},
+	}
+	if _, err := cs.CoreV1().ConfigMaps("default").Create(context.Background(), configMap, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating ConfigMap: %v", err)
+	}
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	want := "db:\n  user: admin\n  password: hunter2\n"
+	if got := string(getSecret(t, cs).Data["config.yaml"]); got != want {
+		t.Errorf("config.yaml = %q, want %q", got, want)
+	}
+}
+
+func TestReconcileTemplateConfigMapMissing(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "x"}}
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/template-configmap": "missing#tmpl"})
+	c, cs := newTestController(t, p, secret)
+
+	if err := c.reconcile(context.Background(), "default/example"); err == nil {
+		t.Fatalf("expected error for missing template ConfigMap")
+	}
+	if got := getSecret(t, cs).Annotations[statusAnnotation]; got != StatusFailed {
+		t.Errorf("status = %q, want %q", got, StatusFailed)
+	}
+}
+
+func TestReconcileTemplateResolvesAdditionalRefs(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{
+		"fake://ref":       "dev@example.com",
+		"fake://npm-token": "npm_abc",
+	}}
+	secret := annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/secret-key": ".npmrc",
+		"k8s-secret-sync.weinbender.io/template":   "//registry.npmjs.org/:_authToken={{ ref \"fake://npm-token\" }}\nemail={{ .Value }}\n",
+	})
+	c, cs := newTestController(t, p, secret)
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	want := "//registry.npmjs.org/:_authToken=npm_abc\nemail=dev@example.com\n"
+	if got := string(getSecret(t, cs).Data[".npmrc"]); got != want {
+		t.Errorf(".npmrc = %q, want %q", got, want)
+	}
+}
+
+func TestReconcileTemplateEncoding(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "pässwörd \xff"}}
+	secret := annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/template":          "{{ .Value }}",
+		"k8s-secret-sync.weinbender.io/template-encoding": "string",
+	})
+	c, cs := newTestController(t, p, secret)
+
+	if err := c.reconcile(context.Background(), "default/example"); err == nil {
+		t.Fatalf("expected error for invalid UTF-8 template output")
+	}
+	if got := getSecret(t, cs).Annotations[statusAnnotation]; got != StatusFailed {
+		t.Errorf("status = %q, want %q", got, StatusFailed)
+	}
+
+	p.values["fake://ref"] = "pässwörd ✓"
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := string(getSecret(t, cs).Data["value"]); got != "pässwörd ✓" {
+		t.Errorf("value = %q, want multi-byte characters preserved", got)
+	}
+}
+
+func TestReconcileRefConfigFromConfigMap(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://db": `{"user":"admin","pass":"hunter2"}`}}
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/provider-ref": "configmap:sync-config#db.yaml"})
+	c, cs := newTestController(t, p, secret)
+	ctx := context.Background()
+
+	if err := c.reconcile(ctx, "default/example"); err == nil {
+		t.Fatalf("expected error for missing ref configuration ConfigMap")
+	}
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sync-config"},
+		Data: map[string]string{"db.yaml": `provider-ref: fake://db
+secret-key: config.yaml
+template: |
+  user: {{ .Fields.user }}
+  password: {{ .Fields.pass }}
+`},
+	}
+	if _, err := cs.CoreV1().ConfigMaps("default").Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating ConfigMap: %v", err)
+	}
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	got := getSecret(t, cs)
+	if want := "user: admin\npassword: hunter2\n"; string(got.Data["config.yaml"]) != want {
+		t.Errorf("config.yaml = %q, want %q", got.Data["config.yaml"], want)
+	}
+	if _, ok := got.Annotations["k8s-secret-sync.weinbender.io/template"]; ok {
+		t.Errorf("expected ref configuration not to be written back to the secret")
+	}
+	if ref := got.Annotations["k8s-secret-sync.weinbender.io/provider-ref"]; ref != "configmap:sync-config#db.yaml" {
+		t.Errorf("provider-ref = %q, want it left as the ConfigMap reference", ref)
+	}
+
+	// Controls such as approval cannot be set from the ConfigMap
+	for _, setting := range []string{"approve: abc", "require-approval: false", "k8s-secret-sync.weinbender.io/break-glass-reason: x", "provider-name: other"} {
+		configMap.Data["db.yaml"] = "provider-ref: fake://db\n" + setting + "\n"
+		if _, err := cs.CoreV1().ConfigMaps("default").Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("updating ConfigMap: %v", err)
+		}
+		if _, err := c.expandRefConfig(ctx, secret); err == nil {
+			t.Errorf("expected ref configuration setting %q to be refused", setting)
+		}
+	}
+}
+
+func TestReconcileSizeGuardrails(t *testing.T) {
+	t.Run("over size limit", func(t *testing.T) {
+		p := &fakeProvider{values: map[string]string{"fake://ref": strings.Repeat("x", maxSecretSize)}}
+		c, cs := newTestController(t, p, annotatedSecret(nil))
+
+		if err := c.reconcile(context.Background(), "default/example"); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+		got := getSecret(t, cs)
+		if got.Annotations[statusAnnotation] != StatusFailed {
+			t.Errorf("status = %q, want %q", got.Annotations[statusAnnotation], StatusFailed)
+		}
+		if _, ok := got.Data["value"]; ok {
+			t.Errorf("expected oversized value not to be written")
+		}
+	})
+
+	t.Run("near size limit", func(t *testing.T) {
+		p := &fakeProvider{values: map[string]string{"fake://ref": strings.Repeat("x", maxSecretSize-100)}}
+		c, cs := newTestController(t, p, annotatedSecret(nil))
+
+		if err := c.reconcile(context.Background(), "default/example"); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+		if got := getSecret(t, cs).Annotations[statusAnnotation]; got != StatusSynced {
+			t.Errorf("status = %q, want %q", got, StatusSynced)
+		}
+		if !hasEvent(c, "SecretSizeNearLimit") {
+			t.Errorf("expected a size warning event")
+		}
+	})
+
+	t.Run("over key limit", func(t *testing.T) {
+		p := &fakeProvider{values: map[string]string{"fake://ref": `{"a":"1","b":"2"}`}}
+		c, cs := newTestController(t, p, annotatedSecret(map[string]string{
+			"k8s-secret-sync.weinbender.io/key-mapping": "a=.a,b=.b",
+		}))
+		c.cfg.MaxSecretKeys = 2 // plus the existing key makes three
+
+		if err := c.reconcile(context.Background(), "default/example"); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+		if got := getSecret(t, cs).Annotations[statusAnnotation]; got != StatusFailed {
+			t.Errorf("status = %q, want %q", got, StatusFailed)
+		}
+	})
+}
+
+func TestReconcileRequiresApproval(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/require-approval": "true"})
+	c, cs := newTestController(t, p, secret)
+	ctx := context.Background()
+
+	// The initial sync doesn't need approval
+	synced := syncAndExpire(t, c, cs)
+	if string(synced.Data["value"]) != "v1" {
+		t.Fatalf("value = %q, want v1", synced.Data["value"])
+	}
+
+	// A changed value is held until approved
+	p.values["fake://ref"] = "v2"
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	held := getSecret(t, cs)
+	if string(held.Data["value"]) != "v1" {
+		t.Errorf("value = %q, want change held", held.Data["value"])
+	}
+	if status := held.Annotations[statusAnnotation]; status != StatusPending {
+		t.Errorf("status = %q, want %q", status, StatusPending)
+	}
+
+	if got := testutil.ToFloat64(metrics.PendingApproval.WithLabelValues("default", "example")); got != 1 {
+		t.Errorf("pending approval = %v, want 1", got)
+	}
+
+	// Approving a different change is not enough
+	hash := dataHash(c.hashKey, map[string][]byte{"value": []byte("v2")}, []string{"value"})
+	approve := func(approval string) {
+		t.Helper()
+		approved := synced.DeepCopy()
+		approved.Annotations["k8s-secret-sync.weinbender.io/approve"] = approval
+		if err := c.store.Update(approved); err != nil {
+			t.Fatalf("updating store: %v", err)
+		}
+		if err := c.reconcile(ctx, "default/example"); err != nil {
+			t.Fatalf("approved refresh: %v", err)
+		}
+	}
+	approve("stale")
+	if got := getSecret(t, cs); string(got.Data["value"]) != "v1" {
+		t.Errorf("value = %q, want change held with a stale approval", got.Data["value"])
+	}
+
+	// Approving the pending hash applies the change
+	approve(hash)
+	if got := getSecret(t, cs); string(got.Data["value"]) != "v2" {
+		t.Errorf("value = %q, want v2 after approval", got.Data["value"])
+	}
+	if got := testutil.ToFloat64(metrics.PendingApproval.WithLabelValues("default", "example")); got != 0 {
+		t.Errorf("pending approval = %v, want 0 once approved", got)
+	}
+}
+
+func TestSecretDeletedForgetsSecret(t *testing.T) {
+	c, _ := newTestController(t, &fakeProvider{}, annotatedSecret(nil))
+	c.verifier = newVerifier(c.store, record.NewFakeRecorder(1), c.hashKey)
+	secret := annotatedSecret(nil)
+	now := time.Now()
+	c.checked["default/example"] = now
+	c.detected["default/example"] = detection{}
+	c.observed["default/example"] = [2]string{"fake", "synced"}
+	c.synced["default/example"] = now
+	c.skipped["default/example\x00"+skipCertManager] = now
+	c.skipped["default/other\x00"+skipCertManager] = now
+	c.verifier.reported["default/example"] = "hash"
+	metrics.SetPendingApproval(secret.Namespace, secret.Name, true)
+	metrics.SetDegraded(secret.Namespace, secret.Name, false)
+
+	c.secretDeleted(toolscache.DeletedFinalStateUnknown{Key: "default/example", Obj: secret})
+	if len(c.checked)+len(c.detected)+len(c.observed)+len(c.synced)+len(c.verifier.reported) != 0 {
+		t.Errorf("expected state for the deleted secret to be dropped")
+	}
+	if _, ok := c.skipped["default/other\x00"+skipCertManager]; !ok || len(c.skipped) != 1 {
+		t.Errorf("skipped = %v, want only the other secret left", c.skipped)
+	}
+	for name, gauge := range map[string]*prometheus.GaugeVec{"pending approval": metrics.PendingApproval, "degraded": metrics.SecretDegraded} {
+		if gauge.DeleteLabelValues("default", "example") {
+			t.Errorf("expected the %s series of the deleted secret to be removed", name)
+		}
+	}
+}
+
+func TestReconcileApplyAfter(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/apply-after": "1h"})
+	c, cs := newTestController(t, p, secret)
+	synced := syncAndExpire(t, c, cs)
+	ctx := context.Background()
+
+	// A newly detected value is held for the delay
+	p.values["fake://ref"] = "v2"
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	held := getSecret(t, cs)
+	if string(held.Data["value"]) != "v1" {
+		t.Errorf("value = %q, want change delayed", held.Data["value"])
+	}
+	if status := held.Annotations[statusAnnotation]; status != StatusPending {
+		t.Errorf("status = %q, want %q", status, StatusPending)
+	}
+
+	// Once the delay has passed since detection the value is written
+	key := synced.Namespace + "/" + synced.Name
+	d := c.detected[key]
+	d.at = d.at.Add(-time.Hour)
+	c.detected[key] = d
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("refresh after delay: %v", err)
+	}
+	if got := getSecret(t, cs); string(got.Data["value"]) != "v2" {
+		t.Errorf("value = %q, want v2 after delay", got.Data["value"])
+	}
+	if _, ok := c.detected[key]; ok {
+		t.Errorf("expected detection to be forgotten once written")
+	}
+}
+
+func TestReconcileKeepPrevious(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/keep-previous": "true"})
+	c, cs := newTestController(t, p, secret)
+	syncAndExpire(t, c, cs)
+
+	if _, ok := getSecret(t, cs).Data["value_previous"]; ok {
+		t.Errorf("expected no previous value on the initial sync")
+	}
+
+	p.values["fake://ref"] = "v2"
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	got := getSecret(t, cs)
+	if string(got.Data["value"]) != "v2" {
+		t.Errorf("value = %q, want v2", got.Data["value"])
+	}
+	if string(got.Data["value_previous"]) != "v1" {
+		t.Errorf("value_previous = %q, want v1", got.Data["value_previous"])
+	}
+}
+
+func TestReconcileEncrypt(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("generating identity: %v", err)
+	}
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/encrypt": identity.Recipient().String()})
+	c, cs := newTestController(t, p, secret)
+	c.cfg.ChecksumAnnotation = "checksum/secret"
+	synced := syncAndExpire(t, c, cs)
+
+	r, err := age.Decrypt(bytes.NewReader(synced.Data["value"]), identity)
+	if err != nil {
+		t.Fatalf("decrypting value: %v", err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading decrypted value: %v", err)
+	}
+	if string(plaintext) != "s3cr3t" {
+		t.Errorf("decrypted value = %q, want s3cr3t", plaintext)
+	}
+
+	// Hashes of the plaintext are keyed, so they can't be used to guess the value offline
+	plain := map[string][]byte{"value": []byte("s3cr3t")}
+	for _, annotation := range []string{dataHashAnnotation, valueHashAnnotation, "checksum/secret"} {
+		if got := synced.Annotations[annotation]; got == "" || got == legacyDataHash(plain, []string{"value"}) || got == legacyValueHash("s3cr3t") {
+			t.Errorf("%s = %q, want a keyed hash", annotation, got)
+		}
+	}
+
+	// Encrypted data still verifies, and an unchanged refresh doesn't re-encrypt it
+	if !newVerifier(c.store, record.NewFakeRecorder(1), c.hashKey).verify(synced) {
+		t.Errorf("expected encrypted data to verify")
+	}
+	actions := len(cs.Actions())
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if got := len(cs.Actions()); got != actions {
+		t.Errorf("expected no writes for an unchanged value, got %d new actions", got-actions)
+	}
+}
+
+func TestReconcilePausesRefreshForUnhealthyProvider(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+	syncAndExpire(t, c, cs)
+
+	p.healthErr = errors.New("unreachable")
+	c.health.check(context.Background(), time.Second)
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if p.calls != 1 {
+		t.Errorf("expected refresh to be paused, got %d provider calls", p.calls)
+	}
+
+	p.healthErr = nil
+	c.health.check(context.Background(), time.Second)
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if p.calls != 2 {
+		t.Errorf("expected refresh to resume once healthy, got %d provider calls", p.calls)
+	}
+}
+
+func TestReconcileRecordsHistory(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+	ctx := context.Background()
+	synced := syncAndExpire(t, c, cs)
+
+	// A failure is recorded once, however often it is retried
+	p.err = errors.New("provider unavailable")
+	for range 2 {
+		if err := c.reconcile(ctx, "default/example"); err == nil {
+			t.Fatalf("expected provider error")
+		}
+		failed := getSecret(t, cs)
+		failed.Annotations["last-synced"] = synced.Annotations["last-synced"]
+		if err := c.store.Update(failed); err != nil {
+			t.Fatalf("updating store: %v", err)
+		}
+	}
+
+	p.err = nil
+	p.values["fake://ref"] = "v2"
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	secret := getSecret(t, cs)
+	history, err := parseHistory(secret)
+	if err != nil {
+		t.Fatalf("parsing history: %v", err)
+	}
+	var statuses []string
+	for _, entry := range history {
+		statuses = append(statuses, entry.Status)
+	}
+	if got := strings.Join(statuses, ","); got != "Synced,Failed,Synced" {
+		t.Fatalf("history statuses = %s, want Synced,Failed,Synced", got)
+	}
+	if history[0].Hash != secret.Annotations[dataHashAnnotation] || history[0].Hash == history[2].Hash {
+		t.Errorf("expected newest entry to record the new value's hash, got %+v", history)
+	}
+	if history[1].Message != "provider unavailable" {
+		t.Errorf("failure message = %q", history[1].Message)
+	}
+
+	// History is bounded to the configured limit
+	c.cfg.HistoryLimit = 2
+	value, ok := c.appendHistory(secret, historyEntry(time.Now(), "", StatusFailed, "again"))
+	secret.Annotations[historyAnnotation] = value
+	if history, _ := parseHistory(secret); !ok || len(history) != 2 || history[0].Message != "again" {
+		t.Errorf("expected history bounded to 2 entries, got %+v", history)
+	}
+}
+
+func TestReconcileSkipsProtectedNamespaces(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	secret := annotatedSecret(nil)
+	secret.Namespace = "kube-system"
+	c, cs := newTestController(t, p, secret)
+	ctx := context.Background()
+
+	if err := c.reconcile(ctx, "kube-system/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	got, err := cs.CoreV1().Secrets("kube-system").Get(ctx, "example", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting secret: %v", err)
+	}
+	if _, ok := got.Data["value"]; ok || p.calls != 0 {
+		t.Errorf("expected secret in protected namespace to be left alone")
+	}
+	if !hasEvent(c, "ProtectedNamespace") {
+		t.Errorf("expected ProtectedNamespace event")
+	}
+
+	// Resyncs are counted as skips without warning again
+	before := testutil.ToFloat64(metrics.SecretsSkipped.WithLabelValues(skipProtected))
+	if err := c.reconcile(ctx, "kube-system/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if hasEvent(c, "ProtectedNamespace") {
+		t.Errorf("expected no further ProtectedNamespace event on resync")
+	}
+	if got := testutil.ToFloat64(metrics.SecretsSkipped.WithLabelValues(skipProtected)) - before; got != 1 {
+		t.Errorf("skipped = %v, want 1", got)
+	}
+
+	c.cfg.AllowedNamespaces = "kube-system"
+	if err := c.reconcile(ctx, "kube-system/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	got, _ = cs.CoreV1().Secrets("kube-system").Get(ctx, "example", metav1.GetOptions{})
+	if string(got.Data["value"]) != "s3cr3t" {
+		t.Errorf("expected explicitly allowed namespace to be synced, got %q", got.Data["value"])
+	}
+}
+
+func TestReconcileSkipsCertManagerSecrets(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	secret := annotatedSecret(map[string]string{"cert-manager.io/certificate-name": "example"})
+	c, cs := newTestController(t, p, secret)
+	ctx := context.Background()
+
+	// Every resync leaves the secret alone without writing status or events
+	for range 2 {
+		if err := c.reconcile(ctx, "default/example"); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+	}
+	if p.calls != 0 || len(cs.Actions()) != 0 {
+		t.Errorf("expected secret managed by cert-manager to be left alone, got %d calls and %d actions", p.calls, len(cs.Actions()))
+	}
+	if hasEvent(c, "CertManagerManaged") {
+		t.Errorf("expected no CertManagerManaged event")
+	}
+}
+
+func TestReconcileRenewsExpiringValues(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "creds"}, ttl: time.Hour}
+	secret := annotatedSecret(nil)
+	c, cs := newTestController(t, p, secret)
+	c.cfg.Refresh = false
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	synced := getSecret(t, cs)
+	expiry, ok := expiresAt(synced)
+	if !ok {
+		t.Fatalf("expected expires-at annotation, got %v", synced.Annotations)
+	}
+	if until := time.Until(expiry); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("expires in %v, want about an hour", until)
+	}
+
+	// Renewal is due after four fifths of the lifetime, even with refresh disabled
+	if wait := c.untilRefresh(synced); wait < 47*time.Minute || wait > 48*time.Minute {
+		t.Errorf("untilRefresh = %v, want about 48m", wait)
+	}
+	synced.Annotations["last-synced"] = time.Now().Add(-50 * time.Minute).UTC().Format(time.RFC3339)
+	synced.Annotations[expiresAtAnnotation] = time.Now().Add(10 * time.Minute).UTC().Format(time.RFC3339)
+	if err := c.store.Update(synced); err != nil {
+		t.Fatalf("updating store: %v", err)
+	}
+	p.values["fake://ref"] = "renewed"
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("renewal: %v", err)
+	}
+	if got := string(getSecret(t, cs).Data["value"]); got != "renewed" {
+		t.Errorf("value = %q, want renewed", got)
+	}
+
+	// A lease renewed with the same value still records its new expiry
+	synced = getSecret(t, cs)
+	synced.Annotations["last-synced"] = time.Now().Add(-50 * time.Minute).UTC().Format(time.RFC3339)
+	synced.Annotations[expiresAtAnnotation] = time.Now().Add(10 * time.Minute).UTC().Format(time.RFC3339)
+	if err := c.store.Update(synced); err != nil {
+		t.Fatalf("updating store: %v", err)
+	}
+	if _, err := cs.CoreV1().Secrets("default").Update(context.Background(), synced, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("updating secret: %v", err)
+	}
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("renewal with the same value: %v", err)
+	}
+	if expiry, ok := expiresAt(getSecret(t, cs)); !ok || time.Until(expiry) < 59*time.Minute {
+		t.Errorf("expires at %v, want about an hour from now", expiry)
+	}
+}
+
+func TestReconcileAttachesPullSecret(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": `{"auths":{}}`}}
+	secret := annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/secret-key":      ".dockerconfigjson",
+		"k8s-secret-sync.weinbender.io/pull-secret-for": "selector:pull=true",
+	})
+	secret.Type = v1.SecretTypeDockerConfigJson
+	c, cs := newTestController(t, p, secret)
+	ctx := context.Background()
+	for _, account := range []*v1.ServiceAccount{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "builder", Labels: map[string]string{"pull": "true"}},
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "other"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unrelated"}},
+	} {
+		if _, err := cs.CoreV1().ServiceAccounts("default").Create(ctx, account, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("creating service account: %v", err)
+		}
+	}
+
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	builder, err := cs.CoreV1().ServiceAccounts("default").Get(ctx, "builder", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting service account: %v", err)
+	}
+	want := []v1.LocalObjectReference{{Name: "other"}, {Name: "example"}}
+	if !slices.Equal(builder.ImagePullSecrets, want) {
+		t.Errorf("builder imagePullSecrets = %v, want %v", builder.ImagePullSecrets, want)
+	}
+	unrelated, err := cs.CoreV1().ServiceAccounts("default").Get(ctx, "unrelated", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting service account: %v", err)
+	}
+	if len(unrelated.ImagePullSecrets) != 0 {
+		t.Errorf("unrelated imagePullSecrets = %v, want none", unrelated.ImagePullSecrets)
+	}
+}
+
+func TestReconcileImmutableRotation(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	c, cs := newTestController(t, p, annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/rotation": "immutable",
+	}))
+	ctx := context.Background()
+
+	var copies []string
+	for _, value := range []string{"v1", "v2", "v3"} {
+		p.values["fake://ref"] = value
+		syncAndExpire(t, c, cs)
+
+		current := getSecret(t, cs).Annotations[currentSecretAnnotation]
+		copied, err := cs.CoreV1().Secrets("default").Get(ctx, current, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("getting immutable copy for %s: %v", value, err)
+		}
+		if string(copied.Data["value"]) != value || copied.Immutable == nil || !*copied.Immutable {
+			t.Errorf("copy %s = %q (immutable %v), want immutable %q", current, copied.Data["value"], copied.Immutable, value)
+		}
+		if _, ok := copied.Data["existing"]; ok {
+			t.Errorf("copy %s includes unmanaged data", current)
+		}
+		copies = append(copies, current)
+	}
+
+	// The current and previous copies are kept; older ones are deleted
+	list, err := cs.CoreV1().Secrets("default").List(ctx, metav1.ListOptions{LabelSelector: rotationOfLabel + "=example"})
+	if err != nil {
+		t.Fatalf("listing copies: %v", err)
+	}
+	var names []string
+	for _, s := range list.Items {
+		names = append(names, s.Name)
+	}
+	slices.Sort(names)
+	want := []string{copies[1], copies[2]}
+	slices.Sort(want)
+	if !slices.Equal(names, want) {
+		t.Errorf("copies = %v, want %v", names, want)
+	}
+}
+
+func TestReconcileSharesValuesAcrossNamespaces(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "v1"}}
+	other := annotatedSecret(nil)
+	other.Namespace = "other"
+	c, cs := newTestController(t, p, annotatedSecret(nil), other)
+	shared, err := cache.New(time.Minute)
+	if err != nil {
+		t.Fatalf("creating shared value cache: %v", err)
+	}
+	c.shared = shared
+	ctx := context.Background()
+
+	// Syncing one secret refreshes the other from the same provider request
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if c.queue.Len() != 1 {
+		t.Fatalf("queue length = %d, want the other secret queued", c.queue.Len())
+	}
+	key, _ := c.queue.Get()
+	if key != "other/example" {
+		t.Fatalf("queued %q, want other/example", key)
+	}
+	c.queue.Done(key)
+	// The other secret was synced recently, but the shared value makes it due
+	synced := other.DeepCopy()
+	synced.Annotations["last-synced"] = time.Now().UTC().Format(time.RFC3339)
+	if err := c.store.Update(synced); err != nil {
+		t.Fatalf("updating store: %v", err)
+	}
+	if err := c.reconcile(ctx, key); err != nil {
+		t.Fatalf("reconcile other: %v", err)
+	}
+
+	if p.calls != 1 {
+		t.Errorf("provider calls = %d, want 1", p.calls)
+	}
+	written, err := cs.CoreV1().Secrets("other").Get(ctx, "example", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting secret: %v", err)
+	}
+	if got := string(written.Data["value"]); got != "v1" {
+		t.Errorf("other value = %q, want v1", got)
+	}
+	if c.queue.Len() != 0 {
+		t.Errorf("queue length = %d, want no further fan-out from a shared value", c.queue.Len())
+	}
+}
+
+func TestReconcilePassesProviderMetadata(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	c, cs := newTestController(t, p, annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/provider-metadata": "stage=AWSPREVIOUS, project=prod",
+	}))
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	want := map[string]string{"stage": "AWSPREVIOUS", "project": "prod"}
+	if !maps.Equal(p.last.Metadata, want) {
+		t.Errorf("metadata = %v, want %v", p.last.Metadata, want)
+	}
+	if got := string(getSecret(t, cs).Data["value"]); got != "s3cr3t" {
+		t.Errorf("value = %q, want s3cr3t", got)
+	}
+}
+
+func TestReconcileRejectsInvalidProviderMetadata(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	c, cs := newTestController(t, p, annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/provider-metadata": "stage",
+	}))
+
+	if err := c.reconcile(context.Background(), "default/example"); err == nil {
+		t.Fatalf("expected error for invalid metadata")
+	}
+	if p.calls != 0 {
+		t.Errorf("provider calls = %d, want 0", p.calls)
+	}
+	if got := getSecret(t, cs).Annotations[statusAnnotation]; got != StatusFailed {
+		t.Errorf("status = %q, want %q", got, StatusFailed)
+	}
+}
+
+func TestReconcilePinsProviderVersion(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	c, _ := newTestController(t, p, annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/provider-version": "3",
+	}))
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if p.last.Version != "3" {
+		t.Errorf("requested version = %q, want 3", p.last.Version)
+	}
+}
+
+func TestReconcileRejectsUnsupportedCapability(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	c, cs := newTestController(t, p, annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/binary": "true",
+	}))
+
+	err := c.reconcile(context.Background(), "default/example")
+	if err == nil || !strings.Contains(err.Error(), "binary values") {
+		t.Fatalf("reconcile = %v, want unsupported capability error", err)
+	}
+	if p.calls != 0 {
+		t.Errorf("provider calls = %d, want 0", p.calls)
+	}
+	secret := getSecret(t, cs)
+	if got := secret.Annotations[statusAnnotation]; got != StatusFailed {
+		t.Errorf("status = %q, want %q", got, StatusFailed)
+	}
+}
+
+func TestReconcileObserveOnly(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}
+	c, cs := newTestController(t, p, annotatedSecret(nil))
+	c.syncFunc = c.observe
+	cs.ClearActions()
+
+	for range 2 {
+		if err := c.reconcile(context.Background(), "default/example"); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+	}
+	if p.calls != 0 {
+		t.Errorf("provider calls = %d, want 0", p.calls)
+	}
+	if actions := cs.Actions(); len(actions) != 0 {
+		t.Errorf("API actions = %v, want none", actions)
+	}
+	if got := testutil.ToFloat64(metrics.ObservedSecrets.WithLabelValues("fake", observedSync)); got != 1 {
+		t.Errorf("observed secrets = %v, want 1", got)
+	}
+}
+
+func TestReconcileExpandsRefTemplate(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://prod/default/ref": "s3cr3t"}}
+	c, cs := newTestController(t, p, annotatedSecret(map[string]string{
+		"k8s-secret-sync.weinbender.io/provider-ref": "fake://{{ .ClusterName }}/{{ .Namespace }}/ref",
+	}))
+	c.cfg.ClusterName = "prod"
+
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if p.last.Ref != "fake://prod/default/ref" {
+		t.Errorf("requested ref = %q, want fake://prod/default/ref", p.last.Ref)
+	}
+	if got := string(getSecret(t, cs).Data["value"]); got != "s3cr3t" {
+		t.Errorf("value = %q, want s3cr3t", got)
+	}
+
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/provider-ref": "fake://{{ .Team }}/ref"})
+	if _, err := expandRef(secret.Annotations["k8s-secret-sync.weinbender.io/provider-ref"], secret, "prod"); err == nil {
+		t.Errorf("expected error for unknown template variable")
+	}
+}
@@ -0,0 +1,90 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// degradedAnnotation explains why a secret has gone without a successful sync for
+// longer than the sync deadline; it is empty once the secret syncs again.
+const degradedAnnotation = "k8s-secret-sync.weinbender.io/degraded"
+
+// markSynced records that a secret was just found up to date with its provider.
+// Unchanged refreshes don't write to the secret, so this is tracked in memory.
+func (c *controller) markSynced(secret *v1.Secret) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.synced[secret.Namespace+"/"+secret.Name] = time.Now()
+}
+
+// overdue reports whether a secret has gone without a successful sync for longer
+// than the deadline: since it was created if it never synced, or since its refresh
+// was due if it did.
+func (c *controller) overdue(secret *v1.Secret, deadline time.Duration, now time.Time) bool {
+	c.mu.Lock()
+	last := c.synced[secret.Namespace+"/"+secret.Name]
+	c.mu.Unlock()
+	if synced, err := time.Parse(time.RFC3339, secret.Annotations["last-synced"]); err == nil && synced.After(last) {
+		last = synced
+	}
+
+	due := secret.CreationTimestamp.Time
+	if !last.IsZero() {
+		interval := c.refreshInterval()
+		if interval <= 0 {
+			return false
+		}
+		due = last.Add(interval)
+	}
+	return now.Sub(due) > deadline
+}
+
+// checkDeadlines flags annotated secrets that are overdue as degraded, with an
+// annotation, a warning event, and the secret-degraded metric, and clears the flag
+// from secrets that have synced since.
+func (c *controller) checkDeadlines(ctx context.Context, deadline time.Duration) {
+	now := time.Now()
+	message := fmt.Sprintf("No successful sync within the %s deadline", deadline)
+	for _, obj := range c.store.List() {
+		secret, ok := obj.(*v1.Secret)
+		if !ok || secret.Annotations[c.cfg.Annotations.ProviderName] == "" || secret.Annotations[c.cfg.Annotations.ProviderRef] == "" {
+			continue
+		}
+
+		overdue := c.overdue(secret, deadline, now)
+		metrics.SetDegraded(secret.Namespace, secret.Name, overdue)
+		want := ""
+		if overdue {
+			want = message
+		}
+		if secret.Annotations[degradedAnnotation] == want {
+			continue
+		}
+		if overdue {
+			klog.InfoS("Secret missed its sync deadline", "namespace", secret.Namespace, "name", secret.Name, "deadline", deadline)
+			c.recorder.Event(secret, v1.EventTypeWarning, "SyncDeadlineExceeded", message)
+		}
+		if err := patchAnnotations(ctx, c.cfg.Clientset, secret, map[string]string{degradedAnnotation: want}); err != nil {
+			klog.ErrorS(err, "Failed to update degraded annotation", "namespace", secret.Namespace, "name", secret.Name)
+		}
+	}
+}
+
+// watchDeadlines checks sync deadlines every interval until ctx is cancelled.
+func (c *controller) watchDeadlines(ctx context.Context, deadline, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.checkDeadlines(ctx, deadline)
+		}
+	}
+}
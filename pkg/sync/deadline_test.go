@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckDeadlinesFlagsSecretsThatNeverSynced(t *testing.T) {
+	secret := annotatedSecret(nil)
+	secret.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	p := &fakeProvider{err: errors.New("boom")}
+	c, cs := newTestController(t, p, secret)
+
+	c.checkDeadlines(context.Background(), 15*time.Minute)
+	if got := getSecret(t, cs).Annotations[degradedAnnotation]; got == "" {
+		t.Errorf("degraded annotation not set on a secret that never synced")
+	}
+	if !hasEvent(c, "SyncDeadlineExceeded") {
+		t.Errorf("expected SyncDeadlineExceeded event")
+	}
+
+	// A successful sync clears the flag at the next check
+	p.err = nil
+	p.values = map[string]string{"fake://ref": "s3cr3t"}
+	if err := c.reconcile(context.Background(), "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if err := c.store.Update(getSecret(t, cs)); err != nil {
+		t.Fatal(err)
+	}
+	c.checkDeadlines(context.Background(), 15*time.Minute)
+	if got := getSecret(t, cs).Annotations[degradedAnnotation]; got != "" {
+		t.Errorf("degraded annotation = %q after a successful sync, want empty", got)
+	}
+}
+
+func TestOverdue(t *testing.T) {
+	now := time.Now()
+	c, _ := newTestController(t, &fakeProvider{})
+	c.cfg.PollInterval = 300
+
+	fresh := annotatedSecret(nil)
+	fresh.CreationTimestamp = metav1.NewTime(now.Add(-time.Minute))
+	if c.overdue(fresh, 15*time.Minute, now) {
+		t.Errorf("secret created a minute ago is overdue")
+	}
+
+	stale := annotatedSecret(map[string]string{"last-synced": now.Add(-time.Hour).UTC().Format(time.RFC3339)})
+	if !c.overdue(stale, 15*time.Minute, now) {
+		t.Errorf("secret last synced an hour ago with a 5m refresh is not overdue")
+	}
+
+	c.markSynced(stale)
+	if c.overdue(stale, 15*time.Minute, now) {
+		t.Errorf("secret found up to date just now is overdue")
+	}
+}
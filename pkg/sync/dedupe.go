@@ -0,0 +1,41 @@
+package sync
+
+import (
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	v1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// sharedValue returns a value recently resolved for a provider ref, so secrets
+// sharing the ref are refreshed with one provider request rather than one each.
+func (c *controller) sharedValue(providerName string, req provider.Request) (string, bool) {
+	if c.shared == nil {
+		return "", false
+	}
+	return c.shared.Get(requestKey(providerName, req))
+}
+
+// shareValue keeps a value just resolved from the provider for the dedupe window.
+func (c *controller) shareValue(providerName string, req provider.Request, value string) {
+	if c.shared != nil {
+		c.shared.Set(requestKey(providerName, req), value)
+	}
+}
+
+// fanOut queues the other secrets sharing a ref whose value was just resolved from
+// the provider. Having a shared value makes them due, so they are refreshed from it
+// now, which also aligns their refreshes into one provider request per cycle.
+func (c *controller) fanOut(secret *v1.Secret, providerName, secretID string) {
+	objs, err := c.store.ByIndex(refIndex, refIndexKey(providerName, secretID))
+	if err != nil {
+		klog.ErrorS(err, "Failed to find secrets sharing ref", "namespace", secret.Namespace, "name", secret.Name)
+		return
+	}
+	self := secret.Namespace + "/" + secret.Name
+	for _, obj := range objs {
+		if key, err := toolscache.MetaNamespaceKeyFunc(obj); err == nil && key != self {
+			c.queue.Add(key)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// detection records when a changed value was first seen upstream.
+type detection struct {
+	hash string
+	at   time.Time
+}
+
+// applyAfter returns the delay configured on a secret before newly detected values
+// are written, or zero if none is set.
+func (c *controller) applyAfter(secret *v1.Secret) (time.Duration, error) {
+	value := secret.Annotations[c.cfg.Annotations.ApplyAfter]
+	if value == "" {
+		return 0, nil
+	}
+	delay, err := time.ParseDuration(value)
+	if err != nil || delay < 0 {
+		return 0, fmt.Errorf("invalid apply-after delay %q", value)
+	}
+	return delay, nil
+}
+
+// applyAt returns when a changed value hashing to hash may be written, delay after it
+// was first detected. Detections are kept in memory, so a restart restarts the delay
+// rather than cutting it short.
+func (c *controller) applyAt(secret *v1.Secret, hash string, delay time.Duration) time.Time {
+	key := secret.Namespace + "/" + secret.Name
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.detected[key]
+	if !ok || d.hash != hash {
+		d = detection{hash: hash, at: time.Now()}
+		c.detected[key] = d
+	}
+	return d.at.Add(delay)
+}
+
+// forgetDetected drops the pending detection for a secret once its value is written.
+func (c *controller) forgetDetected(secret *v1.Secret) {
+	c.mu.Lock()
+	delete(c.detected, secret.Namespace+"/"+secret.Name)
+	c.mu.Unlock()
+}
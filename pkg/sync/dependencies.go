@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// dependsOnIndex indexes secrets by the keys of the secrets they depend on, so
+// dependents can be queued as soon as a dependency syncs.
+const dependsOnIndex = "dependsOn"
+
+// dependencyRecheckInterval is how often a secret waiting on a dependency re-checks it.
+const dependencyRecheckInterval = time.Minute
+
+// parseDependencies parses a comma-separated list of secret names, optionally
+// namespace-qualified ("ns/name"), into namespace/name keys. Unqualified names
+// refer to secrets in namespace.
+func parseDependencies(namespace, value string) []string {
+	var keys []string
+	for _, ref := range strings.Split(value, ",") {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		if !strings.Contains(ref, "/") {
+			ref = namespace + "/" + ref
+		}
+		keys = append(keys, ref)
+	}
+	return keys
+}
+
+// dependsOnIndexFunc indexes secrets by the dependencies declared in annotations.
+func dependsOnIndexFunc(annotations config.Annotations) toolscache.IndexFunc {
+	return func(obj any) ([]string, error) {
+		secret, ok := obj.(*v1.Secret)
+		if !ok {
+			return nil, nil
+		}
+		return parseDependencies(secret.Namespace, secret.Annotations[annotations.DependsOn]), nil
+	}
+}
+
+// dependencies returns the keys of the secrets a secret depends on.
+func (c *controller) dependencies(secret *v1.Secret) []string {
+	return parseDependencies(secret.Namespace, secret.Annotations[c.cfg.Annotations.DependsOn])
+}
+
+// pendingDependency returns a message describing the first dependency of secret
+// that has not synced successfully, or "" if all dependencies are satisfied.
+func (c *controller) pendingDependency(secret *v1.Secret) (string, error) {
+	for _, key := range c.dependencies(secret) {
+		obj, exists, err := c.store.GetByKey(key)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return fmt.Sprintf("Waiting for dependency %s to exist", key), nil
+		}
+		dependency, ok := obj.(*v1.Secret)
+		if !ok || dependency.Annotations[statusAnnotation] != StatusSynced {
+			return fmt.Sprintf("Waiting for dependency %s to sync", key), nil
+		}
+	}
+	return "", nil
+}
+
+// dependencyCycle returns the path of a dependency cycle reachable from secret, or
+// nil if there is none.
+func (c *controller) dependencyCycle(secret *v1.Secret) []string {
+	start := secret.Namespace + "/" + secret.Name
+	visited := make(map[string]bool)
+
+	var path []string
+	var visit func(key string, deps []string) []string
+	visit = func(key string, deps []string) []string {
+		if i := slices.Index(path, key); i >= 0 {
+			return append(slices.Clone(path[i:]), key)
+		}
+		if visited[key] {
+			return nil
+		}
+		visited[key] = true
+
+		path = append(path, key)
+		defer func() { path = path[:len(path)-1] }()
+
+		for _, dep := range deps {
+			var next []string
+			if obj, exists, err := c.store.GetByKey(dep); err == nil && exists {
+				if s, ok := obj.(*v1.Secret); ok {
+					next = c.dependencies(s)
+				}
+			}
+			if cycle := visit(dep, next); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return visit(start, c.dependencies(secret))
+}
+
+// secretUpdated queues the dependents of a secret once the store shows it synced with
+// new data, so they see its new status rather than waiting to recheck it.
+func (c *controller) secretUpdated(oldObj, newObj any) {
+	old, ok := oldObj.(*v1.Secret)
+	if !ok {
+		return
+	}
+	secret, ok := newObj.(*v1.Secret)
+	if !ok || secret.Annotations[statusAnnotation] != StatusSynced {
+		return
+	}
+	if old.Annotations[statusAnnotation] != StatusSynced || old.Annotations[dataHashAnnotation] != secret.Annotations[dataHashAnnotation] {
+		c.enqueueDependents(secret)
+	}
+}
+
+// enqueueDependents queues the secrets that depend on secret.
+func (c *controller) enqueueDependents(secret *v1.Secret) {
+	dependents, err := c.store.ByIndex(dependsOnIndex, secret.Namespace+"/"+secret.Name)
+	if err != nil {
+		return
+	}
+	for _, obj := range dependents {
+		c.enqueue(obj)
+	}
+}
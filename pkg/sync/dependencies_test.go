@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseDependencies(t *testing.T) {
+	got := parseDependencies("apps", "ca, other/root,,leaf")
+	want := []string{"apps/ca", "other/root", "apps/leaf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDependencies = %v, want %v", got, want)
+	}
+}
+
+func dependentSecret(name, dependsOn string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			Annotations: map[string]string{"k8s-secret-sync.weinbender.io/depends-on": dependsOn},
+		},
+	}
+}
+
+func TestDependencyCycle(t *testing.T) {
+	a := dependentSecret("a", "b")
+	b := dependentSecret("b", "c")
+	c := dependentSecret("c", "a")
+	d := dependentSecret("d", "b")
+	ctrl, _ := newTestController(t, &fakeProvider{}, a, b, c, d)
+
+	want := []string{"default/a", "default/b", "default/c", "default/a"}
+	if got := ctrl.dependencyCycle(a); !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyCycle(a) = %v, want %v", got, want)
+	}
+	if got := ctrl.dependencyCycle(d); got == nil {
+		t.Errorf("expected cycle reachable from d to be reported")
+	}
+
+	ok := dependentSecret("ok", "missing")
+	ctrl, _ = newTestController(t, &fakeProvider{}, ok)
+	if got := ctrl.dependencyCycle(ok); got != nil {
+		t.Errorf("dependencyCycle(ok) = %v, want nil", got)
+	}
+}
+
+func TestPendingDependency(t *testing.T) {
+	ca := dependentSecret("ca", "")
+	leaf := dependentSecret("leaf", "ca")
+	ctrl, _ := newTestController(t, &fakeProvider{}, ca, leaf)
+
+	if msg, _ := ctrl.pendingDependency(leaf); msg == "" {
+		t.Errorf("expected leaf to wait for unsynced ca")
+	}
+
+	ca.Annotations[statusAnnotation] = StatusSynced
+	if err := ctrl.store.Update(ca); err != nil {
+		t.Fatalf("updating store: %v", err)
+	}
+	if msg, _ := ctrl.pendingDependency(leaf); msg != "" {
+		t.Errorf("expected dependency to be satisfied, got %q", msg)
+	}
+}
+
+func TestSecretUpdatedQueuesDependents(t *testing.T) {
+	ca := dependentSecret("ca", "")
+	leaf := dependentSecret("leaf", "ca")
+	ctrl, _ := newTestController(t, &fakeProvider{}, ca, leaf)
+
+	// Updates that don't newly sync the dependency leave dependents alone
+	ctrl.secretUpdated(ca, ca)
+	if n := ctrl.queue.Len(); n != 0 {
+		t.Fatalf("expected nothing queued, got %d", n)
+	}
+
+	synced := ca.DeepCopy()
+	synced.Annotations[statusAnnotation] = StatusSynced
+	ctrl.secretUpdated(ca, synced)
+	if key, _ := ctrl.queue.Get(); key != "default/leaf" {
+		t.Errorf("queued %q, want default/leaf", key)
+	}
+}
@@ -0,0 +1,55 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	v1 "k8s.io/api/core/v1"
+)
+
+// encryptedHashAnnotation records a hash of the managed data as written when it is
+// encrypted. Encryption is not deterministic, so the data hash annotation keeps
+// tracking the plaintext for change detection and this is used for verification.
+const encryptedHashAnnotation = "k8s-secret-sync.weinbender.io/encrypted-sha256"
+
+// encryptionRecipients parses the age recipients a secret's values are encrypted
+// to, returning none if encryption is not enabled for it.
+func (c *controller) encryptionRecipients(secret *v1.Secret) ([]age.Recipient, error) {
+	value := secret.Annotations[c.cfg.Annotations.Encrypt]
+	if value == "" {
+		return nil, nil
+	}
+
+	var recipients []age.Recipient
+	for _, field := range strings.Split(value, ",") {
+		recipient, err := age.ParseX25519Recipient(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption recipient %q: %w", field, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// encrypt returns data with each value encrypted to recipients.
+func encrypt(data map[string][]byte, recipients []age.Recipient) (map[string][]byte, error) {
+	encrypted := make(map[string][]byte, len(data))
+	for key, value := range data {
+		var buf bytes.Buffer
+		w, err := age.Encrypt(&buf, recipients...)
+		if err != nil {
+			return nil, fmt.Errorf("encrypting %q: %w", key, err)
+		}
+		if _, err := io.Copy(w, bytes.NewReader(value)); err != nil {
+			return nil, fmt.Errorf("encrypting %q: %w", key, err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("encrypting %q: %w", key, err)
+		}
+		encrypted[key] = buf.Bytes()
+	}
+	return encrypted, nil
+}
@@ -0,0 +1,34 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// newEventRecorder returns a recorder that publishes Kubernetes Events for the
+// operator, and a function that stops the underlying broadcaster.
+func newEventRecorder(ctx context.Context, cfg *config.Sync) (record.EventRecorder, func()) {
+	broadcaster := record.NewBroadcaster(record.WithContext(ctx), record.WithCorrelatorOptions(correlatorOptions(cfg)))
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: cfg.Clientset.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "k8s-secret-sync"})
+	return recorder, broadcaster.Shutdown
+}
+
+// correlatorOptions rate limits events per secret and event type with a token
+// bucket, so a flapping provider can't flood a namespace with Warning events.
+// Repeated similar events are also aggregated into one with a count.
+func correlatorOptions(cfg *config.Sync) record.CorrelatorOptions {
+	var options record.CorrelatorOptions
+	if cfg.EventBurst > 0 {
+		options.BurstSize = cfg.EventBurst
+	}
+	if cfg.EventRefillInterval > 0 {
+		options.QPS = 1 / float32(cfg.EventRefillInterval)
+	}
+	return options
+}
@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEventRecorderRateLimitsPerSecret(t *testing.T) {
+	cs := fake.NewSimpleClientset()
+	cfg := config.New(cs)
+	cfg.EventBurst = 3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recorder, stop := newEventRecorder(ctx, cfg)
+	defer stop()
+
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", UID: "uid"}}
+	for i := range 6 {
+		recorder.Eventf(secret, v1.EventTypeWarning, "SyncFailed", "attempt %d failed", i)
+	}
+
+	count := func() int {
+		events, err := cs.CoreV1().Events("default").List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("listing events: %v", err)
+		}
+		return len(events.Items)
+	}
+	err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		return count() >= cfg.EventBurst, nil
+	})
+	if err != nil {
+		t.Fatalf("expected %d events to be published", cfg.EventBurst)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := count(); got != cfg.EventBurst {
+		t.Errorf("published %d events, want %s", got, fmt.Sprint(cfg.EventBurst))
+	}
+}
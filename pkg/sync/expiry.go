@@ -0,0 +1,23 @@
+package sync
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// expiresAtAnnotation records when a synced value that expires (such as temporary
+// cloud credentials) stops being valid.
+const expiresAtAnnotation = "k8s-secret-sync.weinbender.io/expires-at"
+
+// renewAt returns when a value issued at issued and expiring at expiry should be
+// renewed: once four fifths of its lifetime have passed.
+func renewAt(issued, expiry time.Time) time.Time {
+	return expiry.Add(-expiry.Sub(issued) / 5)
+}
+
+// expiresAt returns when a secret's synced value expires, if it does.
+func expiresAt(secret *v1.Secret) (time.Time, bool) {
+	expiry, err := time.Parse(time.RFC3339, secret.Annotations[expiresAtAnnotation])
+	return expiry, err == nil
+}
@@ -0,0 +1,133 @@
+package sync
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// hashKeyDataKey is the data key of the hash key Secret holding the key.
+const hashKeyDataKey = "key"
+
+// hashesRekeyedAnnotation marks the hash key Secret once the data hashes recorded
+// before hashes were keyed have been re-baselined, so that is only done once.
+const hashesRekeyedAnnotation = "k8s-secret-sync.weinbender.io/hashes-rekeyed"
+
+// loadHashKey returns the key the hashes of synced data are keyed with: KSS_HASH_KEY,
+// or else the key kept in KSS_HASH_KEY_SECRET, which is generated and saved on first
+// start so every replica and restart hashes alike.
+func loadHashKey(ctx context.Context, cfg *config.Sync) ([]byte, error) {
+	if cfg.HashKey != "" {
+		key, err := base64.StdEncoding.DecodeString(cfg.HashKey)
+		if err != nil {
+			return nil, fmt.Errorf("decoding KSS_HASH_KEY: %w", err)
+		}
+		return key, nil
+	}
+
+	namespace, name, err := hashKeySecret(cfg)
+	if err != nil {
+		return nil, err
+	}
+	secrets := cfg.Clientset.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		key := make([]byte, 32)
+		rand.Read(key)
+		secret, err = secrets.Create(ctx, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Data:       map[string][]byte{hashKeyDataKey: key},
+		}, metav1.CreateOptions{})
+		if err == nil {
+			klog.InfoS("Generated hash key", "namespace", namespace, "name", name)
+		} else if apierrors.IsAlreadyExists(err) {
+			// Another replica generated it first
+			secret, err = secrets.Get(ctx, name, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading hash key Secret %s/%s: %w", namespace, name, err)
+	}
+	key := secret.Data[hashKeyDataKey]
+	if len(key) == 0 {
+		return nil, fmt.Errorf("hash key Secret %s/%s has no %q key", namespace, name, hashKeyDataKey)
+	}
+	return key, nil
+}
+
+// hashKeySecret returns the namespace and name of KSS_HASH_KEY_SECRET, which defaults
+// to the operator's own namespace.
+func hashKeySecret(cfg *config.Sync) (string, string, error) {
+	namespace, name, ok := strings.Cut(cfg.HashKeySecret, "/")
+	if ok {
+		return namespace, name, nil
+	}
+	namespace, err := sidecarNamespace("")
+	if err != nil {
+		return "", "", fmt.Errorf("KSS_HASH_KEY_SECRET: %w", err)
+	}
+	return namespace, cfg.HashKeySecret, nil
+}
+
+// rekeyLegacyHashes replaces the unkeyed data hashes recorded by earlier versions on
+// the secrets in the store with keyed hashes of the same data, then marks the hash key
+// Secret so it is never done again. Only hashes that still match the data are
+// replaced; the rest are reported as modified like any other edit. Hashes are only
+// re-baselined with a hash key Secret to record this in, not with KSS_HASH_KEY.
+func (c *controller) rekeyLegacyHashes(ctx context.Context) error {
+	if c.cfg.HashKey != "" {
+		return nil
+	}
+	namespace, name, err := hashKeySecret(c.cfg)
+	if err != nil {
+		return err
+	}
+	keySecret, err := c.cfg.Clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if keySecret.Annotations[hashesRekeyedAnnotation] == "true" {
+		return nil
+	}
+
+	rekeyed := 0
+	for _, obj := range c.store.List() {
+		secret, ok := obj.(*v1.Secret)
+		if !ok {
+			continue
+		}
+		annotation, hash, ok := rekeyHashes(c.hashKey, secret)
+		if !ok {
+			continue
+		}
+		patch, err := json.Marshal(map[string]any{"metadata": map[string]any{"annotations": map[string]string{annotation: hash}}})
+		if err != nil {
+			return err
+		}
+		updated, err := c.cfg.Clientset.CoreV1().Secrets(secret.Namespace).Patch(ctx, secret.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("re-baselining data hash of secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		// Workers start before the informer sees the patch, so update the store too
+		if err := c.store.Update(updated); err != nil {
+			return err
+		}
+		rekeyed++
+	}
+
+	if err := patchAnnotations(ctx, c.cfg.Clientset, keySecret, map[string]string{hashesRekeyedAnnotation: "true"}); err != nil {
+		return err
+	}
+	klog.InfoS("Re-baselined data hashes recorded before hashes were keyed", "secrets", rekeyed)
+	return nil
+}
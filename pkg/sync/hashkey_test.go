@@ -0,0 +1,35 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadHashKey(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.New(fake.NewSimpleClientset())
+	cfg.HashKeySecret = "kss/hash-key"
+
+	// A key is generated on first start and reused after
+	key, err := loadHashKey(ctx, cfg)
+	if err != nil || len(key) != 32 {
+		t.Fatalf("loadHashKey = %x, %v; want a generated 32 byte key", key, err)
+	}
+	if again, err := loadHashKey(ctx, cfg); err != nil || !bytes.Equal(again, key) {
+		t.Errorf("loadHashKey again = %x, %v; want the saved key", again, err)
+	}
+
+	cfg.HashKey = base64.StdEncoding.EncodeToString([]byte("configured"))
+	if got, err := loadHashKey(ctx, cfg); err != nil || string(got) != "configured" {
+		t.Errorf("loadHashKey with KSS_HASH_KEY = %q, %v; want the configured key", got, err)
+	}
+	cfg.HashKey = "not base64!"
+	if _, err := loadHashKey(ctx, cfg); err == nil {
+		t.Errorf("expected error for an invalid KSS_HASH_KEY")
+	}
+}
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"k8s.io/klog/v2"
+)
+
+// healthChecker periodically checks that each provider is reachable, so refreshes
+// can be paused while a provider is down rather than failing one secret at a time.
+type healthChecker struct {
+	providers map[string]func() (provider.SecretProvider, error)
+
+	mu      gosync.Mutex
+	results map[string]error // last health check result per provider
+}
+
+func newHealthChecker(providers map[string]func() (provider.SecretProvider, error)) *healthChecker {
+	return &healthChecker{
+		providers: providers,
+		results:   make(map[string]error),
+	}
+}
+
+// run checks every provider immediately and then every interval until ctx is
+// cancelled. Results are exposed through /readyz and the provider_healthy metric.
+func (h *healthChecker) run(ctx context.Context, interval time.Duration) {
+	for name := range h.providers {
+		metrics.AddReadyzCheck("provider-"+name, func() error { return h.healthy(name) })
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.check(ctx, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check runs the health check of every provider, each bounded by timeout.
+func (h *healthChecker) check(ctx context.Context, timeout time.Duration) {
+	for name, newProvider := range h.providers {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := checkProvider(checkCtx, newProvider)
+		cancel()
+
+		if err != nil {
+			klog.ErrorS(err, "Provider health check failed", "provider", name)
+			metrics.ProviderHealthy.WithLabelValues(name).Set(0)
+		} else {
+			metrics.ProviderHealthy.WithLabelValues(name).Set(1)
+		}
+
+		h.mu.Lock()
+		h.results[name] = err
+		h.mu.Unlock()
+	}
+}
+
+// checkProvider initializes a provider and runs its health check.
+func checkProvider(ctx context.Context, newProvider func() (provider.SecretProvider, error)) error {
+	secretProvider, err := newProvider()
+	if err != nil {
+		return fmt.Errorf("initializing provider: %w", err)
+	}
+	return secretProvider.HealthCheck(ctx)
+}
+
+// healthy returns the error from the named provider's last health check. Providers
+// that have not been checked yet are assumed healthy.
+func (h *healthChecker) healthy(name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.results[name]
+}
@@ -0,0 +1,90 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// historyAnnotation holds a JSON list of a secret's most recent sync outcomes,
+// newest first.
+const historyAnnotation = "k8s-secret-sync.weinbender.io/history"
+
+// HistoryEntry records the outcome of one sync of a secret. Hash is the hash of
+// the managed data written, empty if nothing was written.
+type HistoryEntry struct {
+	Time    string `json:"time"`
+	Hash    string `json:"hash,omitempty"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+func historyEntry(now time.Time, hash, status, message string) HistoryEntry {
+	return HistoryEntry{Time: now.UTC().Format(time.RFC3339), Hash: hash, Status: status, Message: message}
+}
+
+// parseHistory returns the history recorded on a secret.
+func parseHistory(secret *v1.Secret) ([]HistoryEntry, error) {
+	value := secret.Annotations[historyAnnotation]
+	if value == "" {
+		return nil, nil
+	}
+	var history []HistoryEntry
+	if err := json.Unmarshal([]byte(value), &history); err != nil {
+		return nil, fmt.Errorf("invalid history annotation: %w", err)
+	}
+	return history, nil
+}
+
+// appendHistory returns the secret's history annotation with entry added, bounded
+// to the configured limit. It returns false if history is disabled, or if entry
+// repeats the newest outcome (e.g. the same failure being retried).
+func (c *controller) appendHistory(secret *v1.Secret, entry HistoryEntry) (string, bool) {
+	limit := c.cfg.HistoryLimit
+	if limit <= 0 {
+		return "", false
+	}
+	history, err := parseHistory(secret)
+	if err != nil {
+		klog.ErrorS(err, "Discarding invalid sync history", "namespace", secret.Namespace, "name", secret.Name)
+	}
+	if len(history) > 0 {
+		newest := history[0]
+		if newest.Hash == entry.Hash && newest.Status == entry.Status && newest.Message == entry.Message {
+			return "", false
+		}
+	}
+
+	history = append([]HistoryEntry{entry}, history...)
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	value, err := json.Marshal(history)
+	if err != nil {
+		klog.ErrorS(err, "Failed to encode sync history", "namespace", secret.Namespace, "name", secret.Name)
+		return "", false
+	}
+	return string(value), true
+}
+
+// SecretHistory returns the recorded sync history of a secret, newest first.
+func SecretHistory(ctx context.Context, cfg *config.Sync, namespace, name string) ([]HistoryEntry, error) {
+	secret, err := cfg.Clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("getting secret %s/%s: %w", namespace, name, err)
+	}
+	history, err := parseHistory(secret)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []HistoryEntry{}
+	}
+	return history, nil
+}
@@ -0,0 +1,156 @@
+package sync
+
+import (
+	"context"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/diskstore"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// secretInformer is the part of a shared informer used by Run, so the watched
+// secrets can be cached either in memory or on disk.
+type secretInformer interface {
+	AddIndexers(indexers toolscache.Indexers) error
+	AddEventHandler(handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error)
+	GetStore() toolscache.Store
+	GetIndexer() toolscache.Indexer
+	Run(stopCh <-chan struct{})
+	HasSynced() bool
+}
+
+// diskInformer watches secrets into an on-disk store, for clusters with so many
+// secrets that caching them in memory is significant. Event handlers are called
+// synchronously as changes are applied to the store.
+type diskInformer struct {
+	indexer       *diskstore.Indexer
+	listerWatcher toolscache.ListerWatcher
+	resyncPeriod  time.Duration
+
+	mu         gosync.Mutex
+	handlers   []toolscache.ResourceEventHandler
+	controller toolscache.Controller
+}
+
+func newDiskInformer(cs kubernetes.Interface, path string, resyncPeriod time.Duration) (*diskInformer, error) {
+	indexer, err := diskstore.Open(path, toolscache.DeletionHandlingMetaNamespaceKeyFunc, toolscache.Indexers{},
+		func() runtime.Object { return &v1.Secret{} })
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := cs.CoreV1().Secrets(v1.NamespaceAll)
+	return &diskInformer{
+		indexer: indexer,
+		listerWatcher: &toolscache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return secrets.List(context.Background(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return secrets.Watch(context.Background(), options)
+			},
+		},
+		resyncPeriod: resyncPeriod,
+	}, nil
+}
+
+func (i *diskInformer) AddIndexers(indexers toolscache.Indexers) error {
+	return i.indexer.AddIndexers(indexers)
+}
+
+func (i *diskInformer) AddEventHandler(handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.handlers = append(i.handlers, handler)
+	return diskInformerRegistration{i}, nil
+}
+
+func (i *diskInformer) GetStore() toolscache.Store {
+	return i.indexer
+}
+
+func (i *diskInformer) GetIndexer() toolscache.Indexer {
+	return i.indexer
+}
+
+// Run watches secrets until stopCh is closed, then closes the store.
+func (i *diskInformer) Run(stopCh <-chan struct{}) {
+	fifo := toolscache.NewDeltaFIFOWithOptions(toolscache.DeltaFIFOOptions{
+		KnownObjects:          i.indexer,
+		EmitDeltaTypeReplaced: true,
+	})
+	controller := toolscache.New(&toolscache.Config{
+		Queue:            fifo,
+		ListerWatcher:    i.listerWatcher,
+		ObjectType:       &v1.Secret{},
+		FullResyncPeriod: i.resyncPeriod,
+		Process:          i.process,
+	})
+
+	i.mu.Lock()
+	i.controller = controller
+	i.mu.Unlock()
+
+	controller.Run(stopCh)
+	if err := i.indexer.Close(); err != nil {
+		klog.ErrorS(err, "Failed to close secret store")
+	}
+}
+
+func (i *diskInformer) HasSynced() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.controller != nil && i.controller.HasSynced()
+}
+
+// process applies a batch of changes to the store and notifies the event handlers.
+func (i *diskInformer) process(obj any, isInInitialList bool) error {
+	i.mu.Lock()
+	handlers := i.handlers
+	i.mu.Unlock()
+
+	for _, d := range obj.(toolscache.Deltas) {
+		switch d.Type {
+		case toolscache.Sync, toolscache.Replaced, toolscache.Added, toolscache.Updated:
+			old, exists, err := i.indexer.Get(d.Object)
+			if err != nil {
+				return err
+			}
+			if err := i.indexer.Update(d.Object); err != nil {
+				return err
+			}
+			for _, handler := range handlers {
+				if exists {
+					handler.OnUpdate(old, d.Object)
+				} else {
+					handler.OnAdd(d.Object, isInInitialList)
+				}
+			}
+		case toolscache.Deleted:
+			if err := i.indexer.Delete(d.Object); err != nil {
+				return err
+			}
+			for _, handler := range handlers {
+				handler.OnDelete(d.Object)
+			}
+		}
+	}
+	return nil
+}
+
+// diskInformerRegistration reports handlers as synced along with the informer,
+// since they are called synchronously.
+type diskInformerRegistration struct {
+	informer *diskInformer
+}
+
+func (r diskInformerRegistration) HasSynced() bool {
+	return r.informer.HasSynced()
+}
@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func TestDiskInformer(t *testing.T) {
+	cs := fake.NewSimpleClientset(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"}})
+	informer, err := newDiskInformer(cs, filepath.Join(t.TempDir(), "secrets.db"), 0)
+	if err != nil {
+		t.Fatalf("newDiskInformer: %v", err)
+	}
+
+	added := make(chan string, 1)
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) { added <- obj.(*v1.Secret).Name },
+	}); err != nil {
+		t.Fatalf("AddEventHandler: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		informer.Run(ctx.Done())
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	if !toolscache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		t.Fatalf("informer did not sync")
+	}
+	if name := <-added; name != "example" {
+		t.Errorf("added %q, want example", name)
+	}
+	if _, exists, err := informer.GetStore().GetByKey("default/example"); err != nil || !exists {
+		t.Errorf("expected secret in store, got exists=%v err=%v", exists, err)
+	}
+}
+
+func TestNamespacedInformer(t *testing.T) {
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"secret-sync": "enabled"}}}
+	cs := fake.NewSimpleClientset(
+		namespace,
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "watched"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "ignored"}},
+	)
+	informer, err := newNamespacedInformer(cs, "secret-sync=enabled", 0)
+	if err != nil {
+		t.Fatalf("newNamespacedInformer: %v", err)
+	}
+	if err := informer.AddIndexers(toolscache.Indexers{"byNamespace": toolscache.MetaNamespaceIndexFunc}); err != nil {
+		t.Fatalf("AddIndexers: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go informer.Run(ctx.Done())
+	if !toolscache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		t.Fatalf("informer did not sync")
+	}
+
+	store := informer.GetIndexer()
+	if _, exists, _ := store.GetByKey("team-a/watched"); !exists {
+		t.Errorf("expected secret in labeled namespace to be watched")
+	}
+	if _, exists, _ := store.GetByKey("team-b/ignored"); exists {
+		t.Errorf("expected secret in unlabeled namespace to be ignored")
+	}
+	if items, err := store.ByIndex("byNamespace", "team-a"); err != nil || len(items) != 1 {
+		t.Errorf("ByIndex = %d items, %v; want 1", len(items), err)
+	}
+
+	// Removing the label stops watching the namespace
+	namespace = namespace.DeepCopy()
+	namespace.Labels = nil
+	if _, err := cs.CoreV1().Namespaces().Update(ctx, namespace, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("updating namespace: %v", err)
+	}
+	err = wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		_, exists, _ := store.GetByKey("team-a/watched")
+		return !exists, nil
+	})
+	if err != nil {
+		t.Errorf("expected namespace to stop being watched once unlabeled")
+	}
+}
@@ -0,0 +1,227 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/informers"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// injection is one value a workload's inject annotation asks for.
+type injection struct {
+	name     string // environment variable, also the data key of its Secret
+	ref      string
+	provider string
+}
+
+// parseInjections parses an inject annotation, e.g.
+// "name=DB_PASSWORD,ref=op://vault/db/password;name=API_KEY,ref=op://vault/api/key".
+func parseInjections(value string) ([]injection, error) {
+	var injections []injection
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		inj := injection{provider: "op"}
+		for _, field := range strings.Split(entry, ",") {
+			key, val, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid inject field %q (expected key=value)", field)
+			}
+			switch key {
+			case "name":
+				inj.name = val
+			case "ref":
+				inj.ref = val
+			case "provider":
+				inj.provider = val
+			default:
+				return nil, fmt.Errorf("unknown inject field %q", key)
+			}
+		}
+		if errs := validation.IsEnvVarName(inj.name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid inject name %q: %s", inj.name, strings.Join(errs, "; "))
+		}
+		if inj.ref == "" {
+			return nil, fmt.Errorf("inject entry for %s has no ref", inj.name)
+		}
+		injections = append(injections, inj)
+	}
+	return injections, nil
+}
+
+// injectedSecretName returns the name of the Secret materialized for a workload's
+// injected variable, e.g. "api-db-password" for DB_PASSWORD on workload "api".
+func injectedSecretName(workload, name string) string {
+	return workload + "-" + strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+}
+
+// injector materializes Secrets for the inject annotations on Deployments and
+// StatefulSets and references them from the workloads' containers. The Secrets are
+// annotated for sync, so the controller fills them in like any other, and owned by
+// the workload so they are garbage collected with it.
+type injector struct {
+	cfg *config.Sync
+}
+
+// run watches Deployments and StatefulSets until ctx is cancelled.
+func (i *injector) run(ctx context.Context) {
+	factory := informers.NewSharedInformerFactory(i.cfg.Clientset, 10*time.Minute)
+	handle := func(obj any) {
+		var err error
+		switch w := obj.(type) {
+		case *appsv1.Deployment:
+			err = i.syncDeployment(ctx, w)
+		case *appsv1.StatefulSet:
+			err = i.syncStatefulSet(ctx, w)
+		}
+		if err != nil {
+			klog.ErrorS(err, "Failed to inject secrets into workload", "workload", klog.KObj(obj.(metav1.Object)))
+		}
+	}
+	handlers := toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    handle,
+		UpdateFunc: func(_, obj any) { handle(obj) },
+	}
+	for _, informer := range []toolscache.SharedIndexInformer{
+		factory.Apps().V1().Deployments().Informer(),
+		factory.Apps().V1().StatefulSets().Informer(),
+	} {
+		if _, err := informer.AddEventHandler(handlers); err != nil {
+			klog.ErrorS(err, "Failed to watch workloads for injection")
+			return
+		}
+	}
+	factory.Start(ctx.Done())
+	<-ctx.Done()
+	factory.Shutdown()
+}
+
+func (i *injector) syncDeployment(ctx context.Context, d *appsv1.Deployment) error {
+	owner := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: d.Name, UID: d.UID}
+	updated := d.DeepCopy()
+	changed, err := i.inject(ctx, d.Namespace, d.Name, d.Annotations, owner, &updated.Spec.Template)
+	if err != nil || !changed {
+		return err
+	}
+	_, err = i.cfg.Clientset.AppsV1().Deployments(d.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+func (i *injector) syncStatefulSet(ctx context.Context, s *appsv1.StatefulSet) error {
+	owner := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "StatefulSet", Name: s.Name, UID: s.UID}
+	updated := s.DeepCopy()
+	changed, err := i.inject(ctx, s.Namespace, s.Name, s.Annotations, owner, &updated.Spec.Template)
+	if err != nil || !changed {
+		return err
+	}
+	_, err = i.cfg.Clientset.AppsV1().StatefulSets(s.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// inject ensures a Secret exists for each value in the workload's inject annotation,
+// and adds env entries referencing them to template. It reports whether template
+// was changed.
+func (i *injector) inject(ctx context.Context, namespace, workload string, annotations map[string]string, owner metav1.OwnerReference, template *v1.PodTemplateSpec) (bool, error) {
+	value := annotations[i.cfg.Annotations.Inject]
+	if value == "" {
+		return false, nil
+	}
+	injections, err := parseInjections(value)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, inj := range injections {
+		secretName := injectedSecretName(workload, inj.name)
+		if err := i.ensureSecret(ctx, namespace, secretName, owner, inj); err != nil {
+			return false, err
+		}
+		for c := range template.Spec.Containers {
+			if addSecretEnv(&template.Spec.Containers[c], inj.name, secretName) {
+				changed = true
+			}
+		}
+	}
+	return changed, nil
+}
+
+// ensureSecret creates the Secret for an injected value, or updates its sync
+// annotations if the inject annotation changed. It refuses to take over a Secret
+// the workload does not own.
+func (i *injector) ensureSecret(ctx context.Context, namespace, name string, owner metav1.OwnerReference, inj injection) error {
+	annotations := map[string]string{
+		i.cfg.Annotations.ProviderName: inj.provider,
+		i.cfg.Annotations.ProviderRef:  inj.ref,
+		i.cfg.Annotations.SecretKey:    inj.name,
+	}
+	secrets := i.cfg.Clientset.CoreV1().Secrets(namespace)
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       namespace,
+				Annotations:     annotations,
+				OwnerReferences: []metav1.OwnerReference{owner},
+			},
+		}, metav1.CreateOptions{})
+		if err == nil {
+			klog.InfoS("Created secret for injected value", "namespace", namespace, "name", name, "workload", owner.Name)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	owned := false
+	for _, ref := range existing.OwnerReferences {
+		if ref.UID == owner.UID {
+			owned = true
+		}
+	}
+	if !owned {
+		return fmt.Errorf("secret %s/%s already exists and is not owned by %s %s", namespace, name, owner.Kind, owner.Name)
+	}
+	stale := false
+	for key, value := range annotations {
+		if existing.Annotations[key] != value {
+			stale = true
+		}
+	}
+	if !stale {
+		return nil
+	}
+	return patchAnnotations(ctx, i.cfg.Clientset, existing, annotations)
+}
+
+// addSecretEnv adds an env entry for name referencing the same-named key of the
+// Secret, unless the container already sets the variable. It reports whether the
+// container was changed.
+func addSecretEnv(container *v1.Container, name, secretName string) bool {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return false
+		}
+	}
+	container.Env = append(container.Env, v1.EnvVar{
+		Name: name,
+		ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+			Key:                  name,
+		}},
+	})
+	return true
+}
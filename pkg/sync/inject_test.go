@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseInjections(t *testing.T) {
+	got, err := parseInjections("name=DB_PASSWORD,ref=op://vault/db/password; name=TOKEN,ref=arn:aws:iam::1:role/app,provider=aws-sts")
+	if err != nil {
+		t.Fatalf("parseInjections: %v", err)
+	}
+	want := []injection{
+		{name: "DB_PASSWORD", ref: "op://vault/db/password", provider: "op"},
+		{name: "TOKEN", ref: "arn:aws:iam::1:role/app", provider: "aws-sts"},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("parseInjections = %+v, want %+v", got, want)
+	}
+
+	for _, value := range []string{"name=DB_PASSWORD", "ref=op://x", "name=1BAD,ref=op://x", "name=X,ref=op://x,color=red"} {
+		if _, err := parseInjections(value); err == nil {
+			t.Errorf("parseInjections(%q): expected error", value)
+		}
+	}
+}
+
+func TestInjectDeployment(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "api",
+			Namespace:   "default",
+			UID:         "uid-1",
+			Annotations: map[string]string{"k8s-secret-sync.weinbender.io/inject": "name=DB_PASSWORD,ref=op://vault/db/password"},
+		},
+		Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "app"}, {Name: "proxy"}},
+		}}},
+	}
+	cs := fake.NewSimpleClientset(deployment)
+	i := &injector{cfg: config.New(cs)}
+	ctx := context.Background()
+
+	if err := i.syncDeployment(ctx, deployment); err != nil {
+		t.Fatalf("syncDeployment: %v", err)
+	}
+
+	secret, err := cs.CoreV1().Secrets("default").Get(ctx, "api-db-password", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting injected secret: %v", err)
+	}
+	if secret.Annotations["k8s-secret-sync.weinbender.io/provider-ref"] != "op://vault/db/password" ||
+		secret.Annotations["k8s-secret-sync.weinbender.io/secret-key"] != "DB_PASSWORD" {
+		t.Errorf("secret annotations = %v", secret.Annotations)
+	}
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].UID != "uid-1" {
+		t.Errorf("secret owner references = %v", secret.OwnerReferences)
+	}
+
+	updated, err := cs.AppsV1().Deployments("default").Get(ctx, "api", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting deployment: %v", err)
+	}
+	for _, container := range updated.Spec.Template.Spec.Containers {
+		if len(container.Env) != 1 || container.Env[0].ValueFrom.SecretKeyRef.Name != "api-db-password" {
+			t.Errorf("container %s env = %+v", container.Name, container.Env)
+		}
+	}
+
+	// Syncing again changes nothing
+	cs.ClearActions()
+	if err := i.syncDeployment(ctx, updated); err != nil {
+		t.Fatalf("second syncDeployment: %v", err)
+	}
+	for _, action := range cs.Actions() {
+		if action.GetVerb() != "get" {
+			t.Errorf("unexpected %s %s on second sync", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
+
+func TestInjectRefusesUnownedSecret(t *testing.T) {
+	existing := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "api-db-password", Namespace: "default"}}
+	cs := fake.NewSimpleClientset(existing)
+	i := &injector{cfg: config.New(cs)}
+	owner := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "api", UID: "uid-1"}
+
+	err := i.ensureSecret(context.Background(), "default", "api-db-password", owner, injection{name: "DB_PASSWORD", ref: "op://x", provider: "op"})
+	if err == nil {
+		t.Errorf("expected error taking over an unowned secret")
+	}
+}
@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// syncMetadata are the annotations the operator records on the secrets it syncs, as
+// opposed to the annotations users configure syncing with. Only annotations with the
+// operator's prefix are removed, so last-synced, which other tools also set, is kept.
+var syncMetadata = []string{
+	statusAnnotation,
+	statusMessageAnnotation,
+	dataHashAnnotation,
+	valueHashAnnotation,
+	managedKeysAnnotation,
+	encryptedHashAnnotation,
+	expiresAtAnnotation,
+	historyAnnotation,
+	degradedAnnotation,
+	currentSecretAnnotation,
+	checkedOutAnnotation,
+	expiredAnnotation,
+	usedReasonsAnnotation,
+	canaryHeldSinceAnnotation,
+	provenanceProvider,
+	provenanceRefHash,
+	provenanceProviderVersion,
+	provenanceOperatorVersion,
+	provenanceCluster,
+}
+
+// removeSyncMetadata removes the operator's annotations and managed-by label from a
+// secret that is no longer synced, once users remove its provider or ref annotation.
+// Only secrets labelled as managed by the operator outside protected namespaces are
+// touched, and their data is left as it is.
+func (c *controller) removeSyncMetadata(ctx context.Context, secret *v1.Secret) error {
+	if !c.cfg.CleanUpUnmanaged || c.protectedNamespace(secret.Namespace) || secret.Labels[managedByLabel] != managedByValue {
+		return nil
+	}
+	annotations := map[string]any{}
+	for _, key := range syncMetadata {
+		if _, ok := secret.Annotations[key]; ok && strings.HasPrefix(key, annotationPrefix) {
+			annotations[key] = nil
+		}
+	}
+	metadata := map[string]any{"labels": map[string]any{managedByLabel: nil}}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+
+	patch, err := json.Marshal(map[string]any{"metadata": metadata})
+	if err != nil {
+		return err
+	}
+	if _, err := c.cfg.Clientset.CoreV1().Secrets(secret.Namespace).Patch(ctx, secret.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		klog.ErrorS(err, "Failed to remove sync metadata from unmanaged secret", "namespace", secret.Namespace, "name", secret.Name)
+		return err
+	}
+	klog.InfoS("Removed sync metadata from unmanaged secret", "namespace", secret.Namespace, "name", secret.Name)
+	return nil
+}
@@ -0,0 +1,71 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSyncSecretRemovesMetadataFromUnmanagedSecrets(t *testing.T) {
+	ctx := context.Background()
+	newSecret := func(namespace, name string, labels map[string]string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+				Labels:    labels,
+				Annotations: map[string]string{
+					"last-synced":      "2030-01-01T00:00:00Z",
+					statusAnnotation:   StatusSynced,
+					provenanceProvider: "fake",
+					"team/owner":       "payments",
+				},
+			},
+			Data: map[string][]byte{"value": []byte("s3cr3t")},
+		}
+	}
+	secret := newSecret("default", "example", map[string]string{managedByLabel: managedByValue, "team": "payments"})
+	unlabelled := newSecret("default", "unlabelled", nil)
+	protected := newSecret("kube-system", "example", map[string]string{managedByLabel: managedByValue})
+	c, cs := newTestController(t, &fakeProvider{}, secret, unlabelled, protected)
+
+	// Nothing is removed unless enabled
+	if err := c.syncSecret(ctx, secret); err != nil {
+		t.Fatalf("syncSecret: %v", err)
+	}
+	if actions := cs.Actions(); len(actions) != 0 {
+		t.Errorf("actions = %v, want none while disabled", actions)
+	}
+
+	c.cfg.CleanUpUnmanaged = true
+	if err := c.syncSecret(ctx, secret); err != nil {
+		t.Fatalf("syncSecret: %v", err)
+	}
+	got, err := cs.CoreV1().Secrets("default").Get(ctx, "example", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Annotations) != 2 || got.Annotations["team/owner"] != "payments" || got.Annotations["last-synced"] == "" {
+		t.Errorf("annotations = %v, want only the unprefixed ones", got.Annotations)
+	}
+	if len(got.Labels) != 1 || got.Labels["team"] != "payments" {
+		t.Errorf("labels = %v, want only the user's", got.Labels)
+	}
+	if string(got.Data["value"]) != "s3cr3t" {
+		t.Errorf("data = %v, want it left as it was", got.Data)
+	}
+
+	// Secrets not labelled as managed, those in protected namespaces, and those
+	// already cleaned up are left alone
+	cs.ClearActions()
+	for _, secret := range []*v1.Secret{got, unlabelled, protected} {
+		if err := c.syncSecret(ctx, secret); err != nil {
+			t.Fatalf("syncSecret: %v", err)
+		}
+	}
+	if actions := cs.Actions(); len(actions) != 0 {
+		t.Errorf("actions = %v, want none", actions)
+	}
+}
@@ -0,0 +1,50 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The standard label set on every secret the operator writes, so other tooling can
+// select managed secrets.
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "k8s-secret-sync"
+)
+
+// parseSecretLabels returns the labels set on every secret the operator writes: the
+// managed-by label and the extra labels in spec, formatted as comma-separated
+// "key=value" pairs.
+func parseSecretLabels(spec string) (map[string]string, error) {
+	labels := map[string]string{managedByLabel: managedByValue}
+	if spec == "" {
+		return labels, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid secret label %q, expected key=value", pair)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid secret label key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid secret label value %q: %s", value, strings.Join(errs, "; "))
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// hasLabels reports whether a secret already has all of labels.
+func hasLabels(secret *v1.Secret, labels map[string]string) bool {
+	for key, value := range labels {
+		if current, ok := secret.Labels[key]; !ok || current != value {
+			return false
+		}
+	}
+	return true
+}
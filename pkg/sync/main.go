@@ -2,142 +2,311 @@ package sync
 
 import (
 	"context"
-	"encoding/json"
-	"maps"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/jackweinbender/k8s-secret-sync/pkg/cache"
 	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
-	"github.com/jackweinbender/k8s-secret-sync/pkg/op"
-	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/notify"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
 	"k8s.io/client-go/informers"
-	"k8s.io/client-go/tools/cache"
+	toolscache "k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
-)
 
-type SecretProvider interface {
-	GetSecretValue(ctx context.Context, secretID string) (string, error)
-}
+	// Register the built-in providers
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/agevalue"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/akeyless"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/awssts"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/conjur"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/gcpsa"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/gcpsm"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/gitrepo"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/keepass"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/kubesecret"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/op"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/providers/awssm"
+	_ "github.com/jackweinbender/k8s-secret-sync/pkg/vault"
+)
 
 func Run(ctx context.Context, cfg *config.Sync) error {
-	// Map of supported secret providers (currently only 1Password)
-	providers := map[string]func() (SecretProvider, error){
-		"op": func() (SecretProvider, error) {
-			opClient, err := NewProvider()
-			if err != nil {
-				return nil, err
-			}
-			return opClient, nil
-		},
+	// Limit the cardinality of per-secret metrics
+	if err := metrics.SetSecretLabels(cfg.MetricsSecretLabels); err != nil {
+		return err
 	}
 
-	// Set up a shared informer to watch for changes to Kubernetes secrets
-	secretInformer := informers.NewSharedInformerFactory(
-		cfg.Clientset, 10*time.Second).Core().V1().Secrets().Informer()
+	// Labels set on every secret written, validated before anything is synced
+	labels, err := parseSecretLabels(cfg.SecretLabels)
+	if err != nil {
+		return fmt.Errorf("KSS_SECRET_LABELS: %w", err)
+	}
+	if _, err := parseMaintenanceWindows(cfg.MaintenanceWindows); err != nil {
+		return fmt.Errorf("KSS_MAINTENANCE_WINDOWS: %w", err)
+	}
 
-	// Register event handlers for secret add and update events
-	secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		// Handler for new secret creation events
-		AddFunc: func(obj any) {
-			secret, ok := obj.(*v1.Secret)
-			if !ok {
-				klog.ErrorS(nil, "Failed to cast object to Secret on add event, skipping")
-				return
-			}
+	// Secret providers, narrowed to those enabled
+	providers, err := provider.Enabled(ctx, cfg.Providers, cfg)
+	if err != nil {
+		return err
+	}
+	for _, info := range provider.Registered() {
+		if _, ok := providers[info.Name]; ok {
+			klog.InfoS("Enabled provider", "provider", info.Name, "aliases", info.Aliases,
+				"binary", info.Capabilities.Binary, "versioning", info.Capabilities.Versioning, "expiry", info.Capabilities.Expiry, "push", info.Capabilities.Push)
+		}
+	}
 
-			// Check for required provider annotation
-			providerName, exists := secret.Annotations[cfg.Annotations.ProviderName]
-			klog.InfoS("Processing secret with provider", "namespace", secret.Namespace, "name", secret.Name, "provider", providerName)
-			if !exists || providerName == "" {
-				klog.InfoS("Ignoring secret as it does not have the required provider annotation", "namespace", secret.Namespace, "name", secret.Name)
-				return
-			}
+	// Optionally cache resolved values (encrypted in memory) to avoid repeated provider calls
+	valueCache, err := newValueCache(ctx, cfg, cfg.CacheTTL, "kss:value:")
+	if err != nil {
+		return err
+	}
 
-			// Check for required ref annotation
-			secretID, exists := secret.Annotations[cfg.Annotations.ProviderRef]
-			if !exists || secretID == "" {
-				klog.InfoS("Ignoring secret as it does not have the required ref annotation", "namespace", secret.Namespace, "name", secret.Name)
-				return
-			}
+	// Share values resolved for one secret with the others using the same ref
+	sharedValues, err := newValueCache(ctx, cfg, cfg.DedupeWindow, "kss:shared:")
+	if err != nil {
+		return err
+	}
 
-			// Check for last-synced annotation
-			if _, synced := secret.Annotations["last-synced"]; synced {
-				klog.InfoS("Secret has already been synced (last-synced annotation present)", "namespace", secret.Namespace, "name", secret.Name)
-				return
-			}
+	// Publish Kubernetes Events for secrets the operator manages
+	recorder, stopRecorder := newEventRecorder(ctx, cfg)
+	defer stopRecorder()
 
-			// Determine which key in the secret data to update
-			secretDataKey := cfg.DefaultSecretDataKey
-			if secretKeyAnnotationValue, exists := secret.Annotations[cfg.Annotations.SecretKey]; exists && secretKeyAnnotationValue != "" {
-				secretDataKey = secretKeyAnnotationValue
-			}
+	// Set up an informer to watch for changes to Kubernetes secrets, either in the
+	// pod's own namespace as a sidecar, in namespaces matching a label selector, or
+	// cluster-wide, caching them on disk instead of in memory if configured
+	var secretInformer secretInformer
+	if cfg.SidecarPath != "" {
+		namespace, err := sidecarNamespace(cfg.Namespace)
+		if err != nil {
+			return err
+		}
+		secretInformer = informers.NewSharedInformerFactoryWithOptions(cfg.Clientset, 10*time.Second,
+			informers.WithNamespace(namespace)).Core().V1().Secrets().Informer()
+		klog.InfoS("Running as a sidecar", "namespace", namespace, "path", cfg.SidecarPath)
+	} else if cfg.NamespaceSelector != "" {
+		namespacedInformer, err := newNamespacedInformer(cfg.Clientset, cfg.NamespaceSelector, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		secretInformer = namespacedInformer
+	} else if cfg.StorePath != "" {
+		diskInformer, err := newDiskInformer(cfg.Clientset, cfg.StorePath, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		secretInformer = diskInformer
+	} else {
+		secretInformer = informers.NewSharedInformerFactory(
+			cfg.Clientset, 10*time.Second).Core().V1().Secrets().Informer()
+	}
 
-			// Fetch the secret value from the provider (e.g., 1Password)
-			provider, err := providers[providerName]()
-			if err != nil {
-				klog.ErrorS(err, "Failed to initialize provider", "provider", providerName)
-				return
-			}
+	// Key the hashes of synced data recorded in annotations, so they cannot be used to
+	// guess values; sidecars and observe-only mode record none
+	var hashKey []byte
+	if cfg.SidecarPath == "" && !cfg.ObserveOnly {
+		if hashKey, err = loadHashKey(ctx, cfg); err != nil {
+			return err
+		}
+	}
 
-			value, err := provider.GetSecretValue(ctx, secretID)
-			if err != nil {
-				klog.ErrorS(err, "Failed to resolve secret URI", "secretID", secretID)
-				return
-			}
+	// Periodically verify that managed data has not been modified out-of-band
+	var v *verifier
+	if cfg.VerifyInterval > 0 && hashKey != nil {
+		v = newVerifier(secretInformer.GetStore(), recorder, hashKey)
+		go v.run(ctx, time.Duration(cfg.VerifyInterval)*time.Second)
+	}
 
-			// Copy annotations and add last-synced
-			annotations := make(map[string]string)
-			maps.Copy(annotations, secret.Annotations)
-			annotations["last-synced"] = time.Now().UTC().Format(time.RFC3339)
-
-			// Prepare the patch data to update the Kubernetes secret
-			patchData := v1.Secret{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: annotations,
-				},
-				Data: map[string][]byte{
-					secretDataKey: []byte(value),
-				},
-			}
-			payloadBytes, err := json.Marshal(patchData)
-			if err != nil {
-				klog.ErrorS(err, "Failed to marshal patch data")
-				return
-			}
+	// Periodically publish a fleet-wide summary of sync status
+	if cfg.SummaryConfigMap != "" && !cfg.ObserveOnly {
+		s, err := newSummarizer(cfg, secretInformer.GetStore())
+		if err != nil {
+			return err
+		}
+		go s.run(ctx, time.Duration(cfg.SummaryInterval)*time.Second)
+	}
 
-			// Patch the secret in the Kubernetes cluster
-			_, err = cfg.Clientset.CoreV1().Secrets(secret.Namespace).Patch(
-				ctx,
-				secret.Name,
-				types.StrategicMergePatchType,
-				payloadBytes,
-				metav1.PatchOptions{})
+	// Materialize secrets for inject annotations on workloads, if enabled
+	if cfg.WorkloadInjection && !cfg.ObserveOnly {
+		go (&injector{cfg: cfg}).run(ctx)
+	}
 
-			if err != nil {
-				klog.ErrorS(err, "Failed to update Kubernetes Secret", "namespace", secret.Namespace, "name", secret.Name)
-				return
-			}
-			klog.InfoS("Successfully updated Kubernetes Secret with provider value and set last-synced annotation", "namespace", secret.Namespace, "name", secret.Name)
-		},
+	// Create secrets listed on namespaces that do not exist yet, if enabled
+	if cfg.SecretBootstrap && !cfg.ObserveOnly {
+		go (&bootstrapper{cfg: cfg}).run(ctx)
+	}
+
+	// Index secrets by provider ref and dependencies so related secrets can be found quickly
+	if err := secretInformer.AddIndexers(toolscache.Indexers{
+		refIndex:       refIndexFunc(cfg.Annotations, cfg.ClusterName),
+		dependsOnIndex: dependsOnIndexFunc(cfg.Annotations),
+	}); err != nil {
+		return err
+	}
+	metrics.RegisterInformerObjects("secrets", func() int { return len(secretInformer.GetStore().ListKeys()) })
+
+	// Report sync outcomes to the configured notifiers
+	notifier, err := notify.New(cfg.Notifiers, cfg, recorder)
+	if err != nil {
+		return err
+	}
+
+	// Queue new secrets for processing by the controller's workers
+	c := newController(cfg, providers, valueCache, secretInformer.GetIndexer(), recorder, notifier)
+	defer c.queue.ShutDown()
+	c.shared = sharedValues
+	c.hashKey = hashKey
+	c.labels = labels
+	c.verifier = v
+	if cfg.SidecarPath != "" {
+		c.syncFunc = (&sidecar{c: c, dir: cfg.SidecarPath}).syncFiles
+	}
+	if cfg.ObserveOnly {
+		klog.InfoS("Running in observe-only mode; no values are resolved and nothing is written")
+		c.syncFunc = c.observe
+	}
+	registration, err := secretInformer.AddEventHandler(toolscache.ResourceEventHandlerDetailedFuncs{
+		AddFunc:    c.enqueueAdded,
+		UpdateFunc: c.secretUpdated,
+		DeleteFunc: c.secretDeleted,
 	})
+	if err != nil {
+		return err
+	}
+
+	// Resume a refresh pass interrupted by the last shutdown, and save progress on
+	// this one, if configured
+	if cfg.CheckpointConfigMap != "" && !cfg.ObserveOnly {
+		cp, err := newCheckpoint(cfg.Clientset, cfg.CheckpointConfigMap)
+		if err != nil {
+			return err
+		}
+		if err := cp.restore(ctx, c); err != nil {
+			klog.ErrorS(err, "Failed to restore refresh checkpoint, refreshing from scratch")
+		}
+		defer func() {
+			// ctx is already cancelled at shutdown, so save with a fresh deadline
+			saveCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := cp.save(saveCtx, c); err != nil {
+				klog.ErrorS(err, "Failed to save refresh checkpoint")
+			}
+		}()
+	}
 
 	// Start the informer to begin watching for secret events
-	stop := make(chan struct{})
-	defer close(stop)
-	secretInformer.Run(stop)
+	go secretInformer.Run(ctx.Done())
+	if !toolscache.WaitForCacheSync(ctx.Done(), secretInformer.HasSynced, registration.HasSynced) {
+		return errors.New("timed out waiting for secret informer to sync")
+	}
+	if hashKey != nil {
+		if err := c.rekeyLegacyHashes(ctx); err != nil {
+			klog.ErrorS(err, "Failed to re-baseline data hashes recorded before hashes were keyed")
+		}
+	}
+	c.enqueueInitial()
+	klog.InfoS("Secret informer synced, starting workers", "workers", cfg.Workers)
 
-	// Block forever to keep the operator running
-	select {}
+	// Periodically check provider health, pausing refreshes for unhealthy providers
+	if cfg.HealthCheckInterval > 0 && !cfg.ObserveOnly {
+		go c.health.run(ctx, time.Duration(cfg.HealthCheckInterval)*time.Second)
+	}
+
+	// Flag secrets that go without a successful sync for too long
+	if cfg.SyncDeadline > 0 && !cfg.ObserveOnly {
+		go c.watchDeadlines(ctx, time.Duration(cfg.SyncDeadline)*time.Second, time.Minute)
+	}
+
+	// Process secrets until shutdown
+	c.run(ctx, cfg.Workers)
+	return nil
+}
+
+// accessFingerprint returns a short hash of the settings restricting which values
+// may be read. Values persisted in Redis are kept apart by it, so tightening them
+// isn't bypassed by values cached before the change.
+func accessFingerprint(cfg *config.Sync) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		cfg.OPAllowedVaults,
+		cfg.AWSNamespaceRoles,
+		cfg.AWSSTSRoles,
+		cfg.GCPNamespaceAccounts,
+		cfg.GCPKeyAccounts,
+		cfg.VaultNamespaces,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:8])
 }
 
-func NewProvider() (SecretProvider, error) {
-	client, err := op.InitClient()
+// redisTLSConfig returns the TLS configuration for the Redis cache backend, trusting
+// the configured CA certificates or the system roots, or nil if TLS is disabled.
+func redisTLSConfig(cfg *config.Sync) (*tls.Config, error) {
+	if !cfg.CacheRedisTLS {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CacheRedisCACertFile == "" {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(cfg.CacheRedisCACertFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading redis CA certificate: %w", err)
+	}
+	tlsConfig.RootCAs = x509.NewCertPool()
+	if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.CacheRedisCACertFile)
+	}
+	return tlsConfig, nil
+}
+
+// newValueCache returns a cache of values with a lifetime of ttl seconds in the
+// configured backend, or nil if ttl is not positive. prefix keeps caches sharing a
+// Redis server apart.
+func newValueCache(ctx context.Context, cfg *config.Sync, ttl int, prefix string) (cache.Store, error) {
+	if ttl <= 0 {
+		return nil, nil
+	}
+	lifetime := time.Duration(ttl) * time.Second
+	switch cfg.CacheBackend {
+	case "", "memory":
+	case "redis":
+		key, err := base64.StdEncoding.DecodeString(cfg.CacheKey)
+		if err != nil {
+			return nil, fmt.Errorf("decoding KSS_CACHE_KEY: %w", err)
+		}
+		tlsConfig, err := redisTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return cache.NewRedis(cfg.CacheRedisAddr, cfg.CacheRedisPassword, prefix+accessFingerprint(cfg)+":", tlsConfig, key, lifetime)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q, expected memory or redis", cfg.CacheBackend)
+	}
+
+	valueCache, err := cache.New(lifetime)
 	if err != nil {
 		return nil, err
 	}
 
-	return op.SecretProvider{
-		Client: client,
-	}, nil
+	// Periodically evict expired entries so their buffers are zeroed promptly
+	go func() {
+		ticker := time.NewTicker(lifetime)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				valueCache.Prune()
+			}
+		}
+	}()
+	return valueCache, nil
 }
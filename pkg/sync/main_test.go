@@ -0,0 +1,21 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+)
+
+func TestAccessFingerprint(t *testing.T) {
+	cfg := &config.Sync{OPAllowedVaults: "Prod,Shared"}
+	before := accessFingerprint(cfg)
+	if got := accessFingerprint(&config.Sync{OPAllowedVaults: "Prod,Shared"}); got != before {
+		t.Errorf("expected the same settings to give the same fingerprint, got %q and %q", before, got)
+	}
+
+	// Tightening the allowlist moves cached values out of reach
+	cfg.OPAllowedVaults = "Prod"
+	if accessFingerprint(cfg) == before {
+		t.Errorf("expected the fingerprint to change with the allowed vaults")
+	}
+}
@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maintenanceWindow is a recurring period, starting on a cron schedule and lasting
+// for a fixed duration, during which value changes may be applied.
+type maintenanceWindow struct {
+	start    cron.Schedule
+	duration time.Duration
+}
+
+// maintenanceWindows is a set of windows; an empty set allows changes at any time.
+type maintenanceWindows []maintenanceWindow
+
+// parseMaintenanceWindows parses windows formatted as a standard five-field cron
+// expression followed by a duration, e.g. "0 2 * * 6 4h" for Saturdays 02:00-06:00.
+// Multiple windows are separated by ";". A "CRON_TZ=<zone>" prefix selects a time zone.
+func parseMaintenanceWindows(value string) (maintenanceWindows, error) {
+	var windows maintenanceWindows
+	for _, spec := range strings.Split(value, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		i := strings.LastIndexAny(spec, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid maintenance window %q (expected \"<cron> <duration>\")", spec)
+		}
+		duration, err := time.ParseDuration(spec[i+1:])
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid maintenance window duration in %q", spec)
+		}
+		start, err := cron.ParseStandard(strings.TrimSpace(spec[:i]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window schedule in %q: %w", spec, err)
+		}
+		// Schedules such as "0 0 30 2 *" parse but never fire, which would defer
+		// changes forever
+		if start.Next(time.Now()).IsZero() {
+			return nil, fmt.Errorf("invalid maintenance window schedule in %q: it never fires", spec)
+		}
+		windows = append(windows, maintenanceWindow{start: start, duration: duration})
+	}
+	return windows, nil
+}
+
+// open reports whether now falls within any of the windows. With no windows
+// configured, changes are always allowed.
+func (w maintenanceWindows) open(now time.Time) bool {
+	if len(w) == 0 {
+		return true
+	}
+	for _, window := range w {
+		// The first start after (now - duration) is the only one whose window could contain now
+		if start := window.start.Next(now.Add(-window.duration)); !start.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// next returns the start of the next window after now.
+func (w maintenanceWindows) next(now time.Time) time.Time {
+	var next time.Time
+	for _, window := range w {
+		if start := window.start.Next(now); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next
+}
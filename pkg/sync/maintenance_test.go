@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMaintenanceWindows(t *testing.T) {
+	windows, err := parseMaintenanceWindows("0 2 * * 6 4h; 30 22 * * 1-5 90m")
+	if err != nil {
+		t.Fatalf("parseMaintenanceWindows: %v", err)
+	}
+	if len(windows) != 2 {
+		t.Fatalf("got %d windows, want 2", len(windows))
+	}
+
+	if windows, err := parseMaintenanceWindows(""); err != nil || len(windows) != 0 {
+		t.Errorf("expected empty value to parse to no windows, got %v, %v", windows, err)
+	}
+
+	for _, bad := range []string{"0 2 * * 6", "0 2 * * 6 soon", "0 2 * * 6 -1h", "not a cron 1h", "0 0 30 2 * 1h"} {
+		if _, err := parseMaintenanceWindows(bad); err == nil {
+			t.Errorf("parseMaintenanceWindows(%q) expected error", bad)
+		}
+	}
+}
+
+func TestMaintenanceWindowsOpen(t *testing.T) {
+	// Saturdays 02:00-06:00 UTC
+	windows, err := parseMaintenanceWindows("CRON_TZ=UTC 0 2 * * 6 4h")
+	if err != nil {
+		t.Fatalf("parseMaintenanceWindows: %v", err)
+	}
+
+	saturday := time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		at   time.Time
+		want bool
+	}{
+		{saturday.Add(1 * time.Hour), false},
+		{saturday.Add(2 * time.Hour), true},
+		{saturday.Add(5*time.Hour + 59*time.Minute), true},
+		{saturday.Add(6 * time.Hour), false},
+		{saturday.Add(26 * time.Hour), false},
+	}
+	for _, c := range cases {
+		if got := windows.open(c.at); got != c.want {
+			t.Errorf("open(%s) = %v, want %v", c.at, got, c.want)
+		}
+	}
+
+	if next := windows.next(saturday); !next.Equal(saturday.Add(2 * time.Hour)) {
+		t.Errorf("next = %s, want %s", next, saturday.Add(2*time.Hour))
+	}
+
+	if !(maintenanceWindows{}).open(saturday) {
+		t.Errorf("expected no windows to always be open")
+	}
+}
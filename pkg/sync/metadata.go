@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	v1 "k8s.io/api/core/v1"
+)
+
+// parseProviderMetadata parses a provider-metadata annotation, formatted as
+// comma-separated "key=value" pairs.
+func parseProviderMetadata(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid provider metadata %q, expected key=value", pair)
+		}
+		metadata[key] = val
+	}
+	return metadata, nil
+}
+
+// providerRequest returns the request for ref made on behalf of a secret, with any
+// template in the ref expanded, carrying the secret's provider metadata and pinned
+// version. It fails if the secret asks for
+// capabilities the provider lacks.
+func (c *controller) providerRequest(secret *v1.Secret, providerName, ref string) (provider.Request, error) {
+	metadata, err := parseProviderMetadata(secret.Annotations[c.cfg.Annotations.ProviderMetadata])
+	if err != nil {
+		return provider.Request{}, err
+	}
+	if ref, err = expandRef(ref, secret, c.cfg.ClusterName); err != nil {
+		return provider.Request{}, err
+	}
+	req := provider.Request{Ref: ref, Version: secret.Annotations[c.cfg.Annotations.ProviderVersion], Metadata: metadata, Namespace: secret.Namespace}
+
+	// Providers not in the registry, such as test doubles, are not checked
+	if info, ok := provider.Lookup(providerName); ok {
+		required := provider.Capabilities{
+			Binary:     secret.Annotations[c.cfg.Annotations.Binary] == "true",
+			Versioning: req.Version != "",
+		}
+		if missing := info.Capabilities.Missing(required); len(missing) > 0 {
+			return provider.Request{}, fmt.Errorf("provider %q does not support %s", providerName, strings.Join(missing, " or "))
+		}
+	}
+	return req, nil
+}
+
+// requestKey returns the value cache key for a request, which differs from the ref
+// index key only when the request pins a version, carries metadata, or is for a
+// namespace scoped provider.
+func requestKey(providerName string, req provider.Request) string {
+	key := refIndexKey(providerName, req.Ref)
+	if info, ok := provider.Lookup(providerName); ok && info.NamespaceScoped {
+		key += "\x00ns=" + req.Namespace
+	}
+	if req.Version != "" {
+		key += "\x00@" + req.Version
+	}
+	for _, name := range slices.Sorted(maps.Keys(req.Metadata)) {
+		key += "\x00" + name + "=" + req.Metadata[name]
+	}
+	return key
+}
@@ -0,0 +1,166 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// MigrateOptions controls how Secrets produced by Sealed Secrets are migrated.
+type MigrateOptions struct {
+	Namespaces []string // namespaces whose sealed secrets are migrated
+	Provider   string   // provider values are pushed to; it must support pushing
+	RefPrefix  string   // prepended to "<namespace>-<name>/<field>" to form refs, e.g. "op://vault/"
+	DryRun     bool     // report the planned migrations without pushing or writing anything
+}
+
+// Migration reports the outcome of migrating one secret.
+type Migration struct {
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Ref       string   `json:"ref"`
+	Keys      []string `json:"keys"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// MigrateSealedSecrets moves the values of Secrets owned by SealedSecrets in the
+// given namespaces to a provider. Each secret's data is pushed to the provider, then
+// the secret is annotated to sync from it and released from its SealedSecret, so the
+// SealedSecret can be deleted without the Secret being garbage collected. Secrets
+// that fail are reported in their Migration and the rest are still migrated.
+func MigrateSealedSecrets(ctx context.Context, cfg *config.Sync, opts MigrateOptions) ([]Migration, error) {
+	info, ok := provider.Lookup(opts.Provider)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", opts.Provider)
+	}
+	if missing := info.Capabilities.Missing(provider.Capabilities{Push: true}); len(missing) > 0 {
+		return nil, fmt.Errorf("provider %q does not support %s", opts.Provider, strings.Join(missing, ", "))
+	}
+	var pusher provider.Pusher
+	if !opts.DryRun {
+		providers, err := provider.Enabled(ctx, opts.Provider, cfg)
+		if err != nil {
+			return nil, err
+		}
+		p, err := providers[opts.Provider]()
+		if err != nil {
+			return nil, fmt.Errorf("creating provider %q: %w", opts.Provider, err)
+		}
+		if pusher, ok = p.(provider.Pusher); !ok {
+			return nil, fmt.Errorf("provider %q does not implement pushing", opts.Provider)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, namespace := range opts.Namespaces {
+		secrets, err := cfg.Clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return migrations, fmt.Errorf("listing secrets in %s: %w", namespace, err)
+		}
+		for i := range secrets.Items {
+			secret := &secrets.Items[i]
+			if sealedSecretOwner(secret) < 0 || secret.Annotations[cfg.Annotations.ProviderName] != "" {
+				continue
+			}
+			migration, err := migrateSealedSecret(ctx, cfg, secret, opts, info.Capabilities.Binary, pusher)
+			if err != nil {
+				klog.ErrorS(err, "Failed to migrate sealed secret", "namespace", secret.Namespace, "name", secret.Name)
+				migration.Error = err.Error()
+			}
+			migrations = append(migrations, migration)
+		}
+	}
+	return migrations, nil
+}
+
+// migrateSealedSecret pushes a sealed secret's data and rewrites it as managed, unless
+// pusher is nil for a dry run.
+func migrateSealedSecret(ctx context.Context, cfg *config.Sync, secret *v1.Secret, opts MigrateOptions, binary bool, pusher provider.Pusher) (Migration, error) {
+	migration := Migration{Namespace: secret.Namespace, Name: secret.Name, Keys: slices.Sorted(maps.Keys(secret.Data))}
+	value, annotations, err := migrationPlan(secret, cfg.Annotations, opts, binary)
+	if err != nil {
+		return migration, err
+	}
+	migration.Ref = annotations[cfg.Annotations.ProviderRef]
+	if pusher == nil {
+		return migration, nil
+	}
+
+	if err := pusher.Push(ctx, provider.Request{Ref: migration.Ref}, value); err != nil {
+		return migration, fmt.Errorf("pushing to %q: %w", migration.Ref, err)
+	}
+	updated := secret.DeepCopy()
+	updated.OwnerReferences = slices.Delete(updated.OwnerReferences, sealedSecretOwner(secret), sealedSecretOwner(secret)+1)
+	if updated.Annotations == nil {
+		updated.Annotations = make(map[string]string)
+	}
+	maps.Copy(updated.Annotations, annotations)
+	if _, err := cfg.Clientset.CoreV1().Secrets(secret.Namespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return migration, fmt.Errorf("updating secret: %w", err)
+	}
+	klog.InfoS("Migrated sealed secret", "namespace", secret.Namespace, "name", secret.Name, "ref", migration.Ref)
+	return migration, nil
+}
+
+// migrationPlan returns the value to push for a sealed secret and the annotations that
+// sync it back. A single key is pushed as-is; several keys are pushed as one JSON
+// object and mapped back with a key mapping, with dots in key names replaced by
+// underscores in the object.
+func migrationPlan(secret *v1.Secret, annotations config.Annotations, opts MigrateOptions, binary bool) ([]byte, map[string]string, error) {
+	keys := slices.Sorted(maps.Keys(secret.Data))
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("secret has no data")
+	}
+	for _, key := range keys {
+		if !binary && !utf8.Valid(secret.Data[key]) {
+			return nil, nil, fmt.Errorf("key %s is binary, which provider %q does not support", key, opts.Provider)
+		}
+	}
+
+	item := opts.RefPrefix + secret.Namespace + "-" + secret.Name
+	if len(keys) == 1 {
+		return secret.Data[keys[0]], map[string]string{
+			annotations.ProviderName: opts.Provider,
+			annotations.ProviderRef:  item + "/" + keys[0],
+			annotations.SecretKey:    keys[0],
+		}, nil
+	}
+
+	fields := make(map[string]string, len(keys))
+	mapping := make([]string, len(keys))
+	for i, key := range keys {
+		field := strings.ReplaceAll(key, ".", "_")
+		if _, ok := fields[field]; ok {
+			return nil, nil, fmt.Errorf("keys collide as JSON field %q", field)
+		}
+		fields[field] = string(secret.Data[key])
+		mapping[i] = key + "=." + field
+	}
+	value, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, map[string]string{
+		annotations.ProviderName: opts.Provider,
+		annotations.ProviderRef:  item + "/data",
+		annotations.KeyMapping:   strings.Join(mapping, ","),
+	}, nil
+}
+
+// sealedSecretOwner returns the index of the SealedSecret among a secret's owners, or
+// -1 if it was not produced by Sealed Secrets.
+func sealedSecretOwner(secret *v1.Secret) int {
+	return slices.IndexFunc(secret.OwnerReferences, func(ref metav1.OwnerReference) bool {
+		return ref.Kind == "SealedSecret" && strings.HasPrefix(ref.APIVersion, "bitnami.com/")
+	})
+}
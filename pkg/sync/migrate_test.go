@@ -0,0 +1,106 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// pushProvider records the values pushed to it.
+type pushProvider struct {
+	fakeProvider
+	pushed map[string]string
+}
+
+func (p *pushProvider) Push(_ context.Context, req provider.Request, value []byte) error {
+	p.pushed[req.Ref] = string(value)
+	return nil
+}
+
+var testPusher = &pushProvider{pushed: map[string]string{}}
+
+func init() {
+	provider.Register(provider.Info{
+		Name:         "fake-push",
+		Capabilities: provider.Capabilities{Push: true},
+		New:          func(context.Context, *config.Sync) (provider.SecretProvider, error) { return testPusher, nil },
+	})
+}
+
+func sealedSecret(name string, data map[string][]byte) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team",
+			Name:      name,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "bitnami.com/v1alpha1", Kind: "SealedSecret", Name: name, UID: "uid"},
+			},
+		},
+		Data: data,
+	}
+}
+
+func TestMigrateSealedSecrets(t *testing.T) {
+	cs := fake.NewSimpleClientset(
+		sealedSecret("db", map[string][]byte{"password": []byte("hunter2")}),
+		sealedSecret("tls", map[string][]byte{"tls.crt": []byte("cert"), "tls.key": []byte("key")}),
+		sealedSecret("blob", map[string][]byte{"blob": {0xff, 0xfe}}),
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "plain"}, Data: map[string][]byte{"a": []byte("b")}},
+	)
+	cfg := config.New(cs)
+	opts := MigrateOptions{Namespaces: []string{"team"}, Provider: "fake-push", RefPrefix: "fake://vault/", DryRun: true}
+
+	migrations, err := MigrateSealedSecrets(context.Background(), cfg, opts)
+	if err != nil {
+		t.Fatalf("MigrateSealedSecrets dry run: %v", err)
+	}
+	if len(migrations) != 3 || len(testPusher.pushed) != 0 {
+		t.Fatalf("dry run = %+v, pushed %v; want three planned migrations and nothing pushed", migrations, testPusher.pushed)
+	}
+
+	opts.DryRun = false
+	migrations, err = MigrateSealedSecrets(context.Background(), cfg, opts)
+	if err != nil {
+		t.Fatalf("MigrateSealedSecrets: %v", err)
+	}
+	errs := map[string]string{}
+	for _, m := range migrations {
+		errs[m.Name] = m.Error
+	}
+	if errs["db"] != "" || errs["tls"] != "" || errs["blob"] == "" {
+		t.Errorf("migration errors = %v, want only the binary secret to fail", errs)
+	}
+
+	if got := testPusher.pushed["fake://vault/team-db/password"]; got != "hunter2" {
+		t.Errorf("pushed db value = %q, want hunter2", got)
+	}
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(testPusher.pushed["fake://vault/team-tls/data"]), &fields); err != nil || fields["tls_crt"] != "cert" {
+		t.Errorf("pushed tls value = %v, %v; want JSON object with tls_crt", fields, err)
+	}
+
+	tls, err := cs.CoreV1().Secrets("team").Get(context.Background(), "tls", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tls.OwnerReferences) != 0 {
+		t.Errorf("owner references = %v, want the SealedSecret removed", tls.OwnerReferences)
+	}
+	if got := tls.Annotations[cfg.Annotations.KeyMapping]; got != "tls.crt=.tls_crt,tls.key=.tls_key" {
+		t.Errorf("key mapping = %q", got)
+	}
+
+	// Migrated secrets are skipped the next time
+	if migrations, _ := MigrateSealedSecrets(context.Background(), cfg, opts); len(migrations) != 1 || migrations[0].Name != "blob" {
+		t.Errorf("second migration = %+v, want only the unmigrated secret", migrations)
+	}
+	if _, err := MigrateSealedSecrets(context.Background(), cfg, MigrateOptions{Provider: "fake"}); err == nil {
+		t.Errorf("expected error for a provider that cannot push")
+	}
+}
@@ -0,0 +1,326 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	gosync "sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// namespacedInformer watches secrets only in namespaces matching a label selector,
+// starting and stopping a secret informer per namespace as namespaces gain or lose
+// the label. Indexers and event handlers are applied to every namespace's informer.
+type namespacedInformer struct {
+	cs           kubernetes.Interface
+	selector     labels.Selector
+	resyncPeriod time.Duration
+	namespaces   toolscache.SharedIndexInformer
+	registration toolscache.ResourceEventHandlerRegistration
+
+	mu       gosync.Mutex
+	indexers toolscache.Indexers
+	handlers []toolscache.ResourceEventHandler
+	watched  map[string]*namespaceWatch
+	stopCh   <-chan struct{}
+}
+
+// namespaceWatch is the secret informer for a single namespace.
+type namespaceWatch struct {
+	informer toolscache.SharedIndexInformer
+	stop     chan struct{}
+}
+
+func newNamespacedInformer(cs kubernetes.Interface, selector string, resyncPeriod time.Duration) (*namespacedInformer, error) {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace selector %q: %w", selector, err)
+	}
+
+	i := &namespacedInformer{
+		cs:           cs,
+		selector:     parsed,
+		resyncPeriod: resyncPeriod,
+		indexers:     toolscache.Indexers{},
+		watched:      make(map[string]*namespaceWatch),
+	}
+	i.namespaces = informers.NewSharedInformerFactoryWithOptions(cs, resyncPeriod,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = selector
+		}),
+	).Core().V1().Namespaces().Informer()
+	i.registration, err = i.namespaces.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    i.namespaceChanged,
+		UpdateFunc: func(_, obj any) { i.namespaceChanged(obj) },
+		DeleteFunc: i.namespaceDeleted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (i *namespacedInformer) AddIndexers(indexers toolscache.Indexers) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for name, indexFunc := range indexers {
+		if _, exists := i.indexers[name]; exists {
+			return fmt.Errorf("indexer conflict: %s", name)
+		}
+		i.indexers[name] = indexFunc
+	}
+	for _, w := range i.watched {
+		if err := w.informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *namespacedInformer) AddEventHandler(handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.handlers = append(i.handlers, handler)
+	for _, w := range i.watched {
+		if _, err := w.informer.AddEventHandler(handler); err != nil {
+			return nil, err
+		}
+	}
+	return namespacedRegistration{i}, nil
+}
+
+func (i *namespacedInformer) GetStore() toolscache.Store {
+	return i.GetIndexer()
+}
+
+func (i *namespacedInformer) GetIndexer() toolscache.Indexer {
+	return namespacedIndexer{i}
+}
+
+// Run watches namespaces, and the secrets in matching ones, until stopCh is closed.
+func (i *namespacedInformer) Run(stopCh <-chan struct{}) {
+	i.mu.Lock()
+	i.stopCh = stopCh
+	i.mu.Unlock()
+
+	i.namespaces.Run(stopCh)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for name, w := range i.watched {
+		close(w.stop)
+		delete(i.watched, name)
+	}
+}
+
+func (i *namespacedInformer) HasSynced() bool {
+	if !i.registration.HasSynced() {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, w := range i.watched {
+		if !w.informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// namespaceChanged starts watching a namespace that matches the selector, and
+// stops watching one that no longer does.
+func (i *namespacedInformer) namespaceChanged(obj any) {
+	namespace, ok := obj.(*v1.Namespace)
+	if !ok {
+		return
+	}
+	if !i.selector.Matches(labels.Set(namespace.Labels)) {
+		i.namespaceDeleted(obj)
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, exists := i.watched[namespace.Name]; exists || i.stopCh == nil {
+		return
+	}
+
+	informer := informers.NewSharedInformerFactoryWithOptions(i.cs, i.resyncPeriod,
+		informers.WithNamespace(namespace.Name),
+	).Core().V1().Secrets().Informer()
+	if err := informer.AddIndexers(i.indexers); err != nil {
+		klog.ErrorS(err, "Failed to add indexers for namespace", "namespace", namespace.Name)
+		return
+	}
+	for _, handler := range i.handlers {
+		if _, err := informer.AddEventHandler(handler); err != nil {
+			klog.ErrorS(err, "Failed to add event handler for namespace", "namespace", namespace.Name)
+			return
+		}
+	}
+
+	w := &namespaceWatch{informer: informer, stop: make(chan struct{})}
+	i.watched[namespace.Name] = w
+	go informer.Run(w.stop)
+	klog.InfoS("Watching secrets in namespace", "namespace", namespace.Name)
+}
+
+// namespaceDeleted stops watching a namespace that was deleted or lost the label.
+func (i *namespacedInformer) namespaceDeleted(obj any) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	namespace, ok := obj.(*v1.Namespace)
+	if !ok {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if w, exists := i.watched[namespace.Name]; exists {
+		close(w.stop)
+		delete(i.watched, namespace.Name)
+		klog.InfoS("Stopped watching secrets in namespace", "namespace", namespace.Name)
+	}
+}
+
+// indexersByNamespace returns the indexer of every watched namespace.
+func (i *namespacedInformer) indexersByNamespace() map[string]toolscache.Indexer {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	indexers := make(map[string]toolscache.Indexer, len(i.watched))
+	for name, w := range i.watched {
+		indexers[name] = w.informer.GetIndexer()
+	}
+	return indexers
+}
+
+// namespacedRegistration reports a handler as synced along with the informer.
+type namespacedRegistration struct {
+	informer *namespacedInformer
+}
+
+func (r namespacedRegistration) HasSynced() bool {
+	return r.informer.HasSynced()
+}
+
+// errReadOnlyIndexer is returned when writing to a namespacedIndexer; its contents
+// are maintained by the per-namespace informers.
+var errReadOnlyIndexer = errors.New("namespaced secret store is read-only")
+
+// namespacedIndexer is a read-only view over the stores of every watched namespace.
+type namespacedIndexer struct {
+	informer *namespacedInformer
+}
+
+var _ toolscache.Indexer = namespacedIndexer{}
+
+func (n namespacedIndexer) Add(any) error               { return errReadOnlyIndexer }
+func (n namespacedIndexer) Update(any) error            { return errReadOnlyIndexer }
+func (n namespacedIndexer) Delete(any) error            { return errReadOnlyIndexer }
+func (n namespacedIndexer) Replace([]any, string) error { return errReadOnlyIndexer }
+func (n namespacedIndexer) Resync() error               { return nil }
+
+func (n namespacedIndexer) GetIndexers() toolscache.Indexers {
+	n.informer.mu.Lock()
+	defer n.informer.mu.Unlock()
+	return maps.Clone(n.informer.indexers)
+}
+
+func (n namespacedIndexer) AddIndexers(indexers toolscache.Indexers) error {
+	return n.informer.AddIndexers(indexers)
+}
+
+func (n namespacedIndexer) List() []any {
+	var items []any
+	for _, indexer := range n.informer.indexersByNamespace() {
+		items = append(items, indexer.List()...)
+	}
+	return items
+}
+
+func (n namespacedIndexer) ListKeys() []string {
+	var keys []string
+	for _, indexer := range n.informer.indexersByNamespace() {
+		keys = append(keys, indexer.ListKeys()...)
+	}
+	return keys
+}
+
+func (n namespacedIndexer) Get(obj any) (any, bool, error) {
+	key, err := toolscache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, false, toolscache.KeyError{Obj: obj, Err: err}
+	}
+	return n.GetByKey(key)
+}
+
+func (n namespacedIndexer) GetByKey(key string) (any, bool, error) {
+	namespace, _, err := toolscache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	indexer, ok := n.informer.indexersByNamespace()[namespace]
+	if !ok {
+		return nil, false, nil
+	}
+	return indexer.GetByKey(key)
+}
+
+func (n namespacedIndexer) Index(indexName string, obj any) ([]any, error) {
+	var items []any
+	for _, indexer := range n.informer.indexersByNamespace() {
+		found, err := indexer.Index(indexName, obj)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, found...)
+	}
+	return items, nil
+}
+
+func (n namespacedIndexer) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	var keys []string
+	for _, indexer := range n.informer.indexersByNamespace() {
+		found, err := indexer.IndexKeys(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, found...)
+	}
+	return keys, nil
+}
+
+func (n namespacedIndexer) ListIndexFuncValues(indexName string) []string {
+	seen := make(map[string]bool)
+	var values []string
+	for _, indexer := range n.informer.indexersByNamespace() {
+		for _, value := range indexer.ListIndexFuncValues(indexName) {
+			if !seen[value] {
+				seen[value] = true
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+func (n namespacedIndexer) ByIndex(indexName, indexedValue string) ([]any, error) {
+	var items []any
+	for _, indexer := range n.informer.indexersByNamespace() {
+		found, err := indexer.ByIndex(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, found...)
+	}
+	return items, nil
+}
@@ -0,0 +1,28 @@
+package sync
+
+import "fmt"
+
+// notFoundPolicy controls what happens when a provider ref does not exist upstream.
+type notFoundPolicy string
+
+const (
+	// notFoundFail leaves the secret data untouched and reports the failure (default).
+	notFoundFail notFoundPolicy = "fail"
+	// notFoundEmpty writes an empty value to the managed key.
+	notFoundEmpty notFoundPolicy = "empty"
+	// notFoundDelete removes the managed key from the secret.
+	notFoundDelete notFoundPolicy = "delete"
+)
+
+// parseNotFoundPolicy parses the value of the on-not-found annotation. An empty
+// value selects the default policy.
+func parseNotFoundPolicy(value string) (notFoundPolicy, error) {
+	switch policy := notFoundPolicy(value); policy {
+	case "":
+		return notFoundFail, nil
+	case notFoundFail, notFoundEmpty, notFoundDelete:
+		return policy, nil
+	default:
+		return notFoundFail, fmt.Errorf("unknown not-found policy %q (expected fail, empty, or delete)", value)
+	}
+}
@@ -0,0 +1,23 @@
+package sync
+
+import "testing"
+
+func TestParseNotFoundPolicy(t *testing.T) {
+	cases := []struct {
+		value   string
+		want    notFoundPolicy
+		wantErr bool
+	}{
+		{"", notFoundFail, false},
+		{"fail", notFoundFail, false},
+		{"empty", notFoundEmpty, false},
+		{"delete", notFoundDelete, false},
+		{"ignore", notFoundFail, true},
+	}
+	for _, c := range cases {
+		got, err := parseNotFoundPolicy(c.value)
+		if got != c.want || (err != nil) != c.wantErr {
+			t.Errorf("parseNotFoundPolicy(%q) = %q, %v; want %q, error %v", c.value, got, err, c.want, c.wantErr)
+		}
+	}
+}
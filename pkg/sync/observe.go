@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Outcomes recorded for secrets in observe-only mode.
+const (
+	observedSync             = "sync"
+	observedProtected        = "protected-namespace"
+	observedProviderDisabled = "provider-disabled"
+)
+
+// observe records what syncing a secret would do, without resolving its value or
+// writing anything, for evaluating the operator on an existing cluster. Each
+// annotated secret is counted once in the observed-secrets metric.
+func (c *controller) observe(_ context.Context, secret *v1.Secret) error {
+	providerName := secret.Annotations[c.cfg.Annotations.ProviderName]
+	ref := secret.Annotations[c.cfg.Annotations.ProviderRef]
+	if providerName == "" || ref == "" {
+		return nil
+	}
+
+	outcome := observedSync
+	if c.protectedNamespace(secret.Namespace) {
+		outcome = observedProtected
+	} else if _, ok := c.providers[providerName]; !ok {
+		outcome = observedProviderDisabled
+	}
+
+	key := secret.Namespace + "/" + secret.Name
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if previous, seen := c.observed[key]; seen {
+		if previous == [2]string{providerName, outcome} {
+			return nil
+		}
+		metrics.ObservedSecrets.WithLabelValues(previous[0], previous[1]).Dec()
+	}
+	c.observed[key] = [2]string{providerName, outcome}
+	metrics.ObservedSecrets.WithLabelValues(providerName, outcome).Inc()
+	klog.InfoS("Observed secret", "namespace", secret.Namespace, "name", secret.Name, "provider", providerName, "ref", ref, "outcome", outcome)
+	return nil
+}
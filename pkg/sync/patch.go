@@ -0,0 +1,166 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// fieldManager is the field manager recorded for server-side apply writes.
+const fieldManager = "k8s-secret-sync"
+
+// patchStrategy is how synced values are written to a secret. Admission webhooks and
+// API extensions can treat the patch types differently, so it is configurable.
+type patchStrategy string
+
+const (
+	// patchStrategicMerge (default) sends a strategic merge patch. Concurrent writes
+	// to the same keys are last-writer-wins.
+	patchStrategicMerge patchStrategy = "strategic-merge"
+	// patchMerge sends a JSON merge patch (RFC 7386), last-writer-wins like
+	// strategic-merge.
+	patchMerge patchStrategy = "merge"
+	// patchJSON sends a JSON patch (RFC 6902) guarded by the secret's resource version,
+	// so it fails with a conflict, and is retried, if the secret changed since it was read.
+	patchJSON patchStrategy = "json-patch"
+	// patchApply uses server-side apply without forcing, so it fails with a conflict if
+	// another field manager owns a managed key or annotation.
+	patchApply patchStrategy = "apply"
+)
+
+func parsePatchStrategy(value string) (patchStrategy, error) {
+	switch s := patchStrategy(value); s {
+	case "":
+		return patchStrategicMerge, nil
+	case patchStrategicMerge, patchMerge, patchJSON, patchApply:
+		return s, nil
+	default:
+		return patchStrategicMerge, fmt.Errorf("unknown patch strategy %q, expected strategic-merge, merge, json-patch, or apply", value)
+	}
+}
+
+// patchStrategy returns the strategy for writing a secret, from its annotation or
+// the global configuration.
+func (c *controller) patchStrategy(secret *v1.Secret) (patchStrategy, error) {
+	if value, ok := secret.Annotations[c.cfg.Annotations.PatchStrategy]; ok {
+		return parsePatchStrategy(value)
+	}
+	return parsePatchStrategy(c.cfg.PatchStrategy)
+}
+
+// writeSecret writes labels, annotations, and data to a secret with the given
+// strategy. Nil data values remove the key; labels and annotations not given are left
+// as they are.
+func writeSecret(ctx context.Context, cs kubernetes.Interface, secret *v1.Secret, strategy patchStrategy, labels, annotations map[string]string, data map[string]any) error {
+	var patchType types.PatchType
+	var payload any
+	opts := metav1.PatchOptions{}
+	switch strategy {
+	case patchMerge:
+		patchType = types.MergePatchType
+		payload = map[string]any{"metadata": patchMetadata(labels, annotations), "data": data}
+	case patchJSON:
+		patchType = types.JSONPatchType
+		payload = jsonPatch(secret, labels, annotations, data)
+	case patchApply:
+		patchType = types.ApplyPatchType
+		opts.FieldManager = fieldManager
+		payload = applyConfiguration(secret, labels, annotations, data)
+	default:
+		patchType = types.StrategicMergePatchType
+		payload = map[string]any{"metadata": patchMetadata(labels, annotations), "data": data}
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = cs.CoreV1().Secrets(secret.Namespace).Patch(ctx, secret.Name, patchType, payloadBytes, opts)
+	return err
+}
+
+// patchMetadata returns the metadata of a merge patch. Labels are left out when there
+// are none, as a null would remove the secret's own labels.
+func patchMetadata(labels, annotations map[string]string) map[string]any {
+	metadata := map[string]any{"annotations": annotations}
+	if len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	return metadata
+}
+
+// jsonPatchOp is a single RFC 6902 operation.
+type jsonPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// jsonPatch returns the operations writing labels, annotations, and data, preceded by
+// a test of the resource version the secret was read at.
+func jsonPatch(secret *v1.Secret, labels, annotations map[string]string, data map[string]any) []jsonPatchOp {
+	var ops []jsonPatchOp
+	if secret.ResourceVersion != "" {
+		ops = append(ops, jsonPatchOp{Op: "test", Path: "/metadata/resourceVersion", Value: secret.ResourceVersion})
+	}
+	if secret.Labels == nil && len(labels) > 0 {
+		ops = append(ops, jsonPatchOp{Op: "add", Path: "/metadata/labels", Value: map[string]string{}})
+	}
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		ops = append(ops, jsonPatchOp{Op: "add", Path: "/metadata/labels/" + escapeJSONPointer(key), Value: labels[key]})
+	}
+	if secret.Annotations == nil && len(annotations) > 0 {
+		ops = append(ops, jsonPatchOp{Op: "add", Path: "/metadata/annotations", Value: map[string]string{}})
+	}
+	for _, key := range slices.Sorted(maps.Keys(annotations)) {
+		ops = append(ops, jsonPatchOp{Op: "add", Path: "/metadata/annotations/" + escapeJSONPointer(key), Value: annotations[key]})
+	}
+	if secret.Data == nil {
+		ops = append(ops, jsonPatchOp{Op: "add", Path: "/data", Value: map[string]string{}})
+	}
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		path := "/data/" + escapeJSONPointer(key)
+		if data[key] == nil {
+			if _, ok := secret.Data[key]; ok {
+				ops = append(ops, jsonPatchOp{Op: "remove", Path: path})
+			}
+			continue
+		}
+		ops = append(ops, jsonPatchOp{Op: "add", Path: path, Value: data[key]})
+	}
+	return ops
+}
+
+// escapeJSONPointer escapes a key for use as a JSON pointer reference token.
+func escapeJSONPointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// applyConfiguration returns the server-side apply configuration for the fields the
+// operator manages. Keys being removed are left out, which removes them if the
+// operator's field manager owned them.
+func applyConfiguration(secret *v1.Secret, labels, annotations map[string]string, data map[string]any) map[string]any {
+	applied := make(map[string]any, len(data))
+	for key, value := range data {
+		if value != nil {
+			applied[key] = value
+		}
+	}
+	metadata := patchMetadata(labels, annotations)
+	metadata["name"] = secret.Name
+	metadata["namespace"] = secret.Namespace
+	return map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   metadata,
+		"data":       applied,
+	}
+}
@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParsePatchStrategy(t *testing.T) {
+	for value, want := range map[string]patchStrategy{
+		"":           patchStrategicMerge,
+		"merge":      patchMerge,
+		"json-patch": patchJSON,
+		"apply":      patchApply,
+	} {
+		if got, err := parsePatchStrategy(value); err != nil || got != want {
+			t.Errorf("parsePatchStrategy(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := parsePatchStrategy("replace"); err == nil {
+		t.Errorf("expected error for unknown strategy")
+	}
+}
+
+func TestWriteSecretStrategies(t *testing.T) {
+	for _, strategy := range []patchStrategy{patchStrategicMerge, patchMerge, patchJSON, patchApply} {
+		t.Run(string(strategy), func(t *testing.T) {
+			ctx := context.Background()
+			cs := fake.NewClientset()
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", Annotations: map[string]string{"team": "payments"}},
+				Data:       map[string][]byte{"existing": []byte("keep")},
+			}
+			if strategy != patchJSON {
+				secret.Labels = map[string]string{"tier": "backend"}
+			}
+			secret, err := cs.CoreV1().Secrets("default").Create(ctx, secret, metav1.CreateOptions{})
+			if err != nil {
+				t.Fatalf("creating secret: %v", err)
+			}
+
+			annotations := map[string]string{"last-synced": "2030-01-01T00:00:00Z", "a/b~c": "escaped"}
+			labels := map[string]string{managedByLabel: managedByValue}
+			if err := writeSecret(ctx, cs, secret, strategy, labels, annotations, map[string]any{"value": []byte("s3cr3t")}); err != nil {
+				t.Fatalf("writeSecret: %v", err)
+			}
+			written, err := cs.CoreV1().Secrets("default").Get(ctx, "example", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("getting secret: %v", err)
+			}
+			if string(written.Data["value"]) != "s3cr3t" || string(written.Data["existing"]) != "keep" {
+				t.Errorf("data = %v", written.Data)
+			}
+			if written.Annotations["a/b~c"] != "escaped" || written.Annotations["team"] != "payments" {
+				t.Errorf("annotations = %v", written.Annotations)
+			}
+			if !hasLabels(written, labels) || (secret.Labels != nil && written.Labels["tier"] != "backend") {
+				t.Errorf("labels = %v", written.Labels)
+			}
+
+			// Removing the key again
+			if err := writeSecret(ctx, cs, written, strategy, nil, annotations, map[string]any{"value": nil}); err != nil {
+				t.Fatalf("writeSecret removing key: %v", err)
+			}
+			removed, err := cs.CoreV1().Secrets("default").Get(ctx, "example", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("getting secret: %v", err)
+			}
+			if _, ok := removed.Data["value"]; ok || string(removed.Data["existing"]) != "keep" {
+				t.Errorf("data after removal = %v", removed.Data)
+			}
+		})
+	}
+}
+
+func TestWriteSecretConflicts(t *testing.T) {
+	for _, tt := range []struct {
+		strategy patchStrategy
+		conflict bool
+	}{
+		{patchStrategicMerge, false},
+		{patchMerge, false},
+		{patchJSON, true},
+		{patchApply, true},
+	} {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			ctx := context.Background()
+			cs := fake.NewClientset()
+			secret, err := cs.CoreV1().Secrets("default").Create(ctx, &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", ResourceVersion: "1"},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				t.Fatalf("creating secret: %v", err)
+			}
+
+			// Someone else applies the managed key after the operator read the secret
+			// (the fake clientset does not maintain resource versions, so bump it here)
+			other := []byte(`{"apiVersion":"v1","kind":"Secret","metadata":{"name":"example","namespace":"default","resourceVersion":"2"},"data":{"value":"b3RoZXI="}}`)
+			if _, err := cs.CoreV1().Secrets("default").Patch(ctx, "example", types.ApplyPatchType, other,
+				metav1.PatchOptions{FieldManager: "kubectl"}); err != nil {
+				t.Fatalf("applying concurrent change: %v", err)
+			}
+
+			err = writeSecret(ctx, cs, secret, tt.strategy, nil, map[string]string{"last-synced": "now"}, map[string]any{"value": []byte("s3cr3t")})
+			if tt.conflict {
+				if err == nil {
+					t.Errorf("expected the write to fail on the concurrent change")
+				}
+				if tt.strategy == patchApply && !apierrors.IsConflict(err) {
+					t.Errorf("apply error = %v, want a field manager conflict", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("writeSecret: %v", err)
+			}
+			written, err := cs.CoreV1().Secrets("default").Get(ctx, "example", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("getting secret: %v", err)
+			}
+			if string(written.Data["value"]) != "s3cr3t" {
+				t.Errorf("value = %q, want the operator's write to win", written.Data["value"])
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package sync
+
+import (
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// previousKeySuffix is appended to a managed key to name the sibling key holding
+// its previous value.
+const previousKeySuffix = "_previous"
+
+// keepPrevious reports whether a secret keeps outgoing values when they change.
+func (c *controller) keepPrevious(secret *v1.Secret) bool {
+	keep, _ := strconv.ParseBool(secret.Annotations[c.cfg.Annotations.KeepPrevious])
+	return keep
+}
+
+// previousData returns the patch moving the current value of each previously
+// managed key that is still produced by data into its "<key>_previous" sibling.
+// Previous keys are not managed themselves, so they are neither verified nor removed.
+func previousData(secret *v1.Secret, data map[string][]byte) map[string]any {
+	previous := make(map[string]any)
+	for _, key := range managedKeys(secret) {
+		current, ok := secret.Data[key]
+		if _, produced := data[key]; !ok || !produced {
+			continue
+		}
+		previous[key+previousKeySuffix] = current
+	}
+	return previous
+}
@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// prioritized is a queue key with the priority of its secret.
+type prioritized struct {
+	key      string
+	priority int
+}
+
+// enqueueAdded queues a secret added to the informer. Secrets in the informer's
+// initial list are held until it has synced and then queued by priority, so critical
+// secrets are synced first on startup rather than in whatever order they were listed.
+func (c *controller) enqueueAdded(obj any, isInInitialList bool) {
+	key, err := toolscache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.ErrorS(err, "Failed to get key for object, skipping")
+		return
+	}
+	c.mu.Lock()
+	if isInInitialList && !c.started {
+		c.initial = append(c.initial, prioritized{key: key, priority: c.priority(obj)})
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	c.queue.Add(key)
+}
+
+// enqueueInitial queues the secrets held from the informer's initial list, highest
+// priority first, and queues secrets added from then on as they arrive.
+func (c *controller) enqueueInitial() {
+	c.mu.Lock()
+	initial := c.initial
+	c.initial, c.started = nil, true
+	c.mu.Unlock()
+
+	slices.SortStableFunc(initial, func(a, b prioritized) int {
+		return cmp.Compare(b.priority, a.priority)
+	})
+	for _, item := range initial {
+		c.queue.Add(item.key)
+	}
+}
+
+// priority returns the priority annotated on a secret, or 0 if it has none.
+func (c *controller) priority(obj any) int {
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		return 0
+	}
+	value, ok := secret.Annotations[c.cfg.Annotations.Priority]
+	if !ok {
+		return 0
+	}
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		klog.ErrorS(err, "Invalid priority, using 0", "namespace", secret.Namespace, "name", secret.Name)
+		return 0
+	}
+	return priority
+}
@@ -0,0 +1,42 @@
+package sync
+
+import (
+	"slices"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEnqueueInitialByPriority(t *testing.T) {
+	c, _ := newTestController(t, &fakeProvider{})
+	secret := func(name, priority string) *v1.Secret {
+		s := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+		if priority != "" {
+			s.Annotations = map[string]string{c.cfg.Annotations.Priority: priority}
+		}
+		return s
+	}
+
+	c.enqueueAdded(secret("app", ""), true)
+	c.enqueueAdded(secret("registry", "100"), true)
+	c.enqueueAdded(secret("batch", "-5"), true)
+	c.enqueueAdded(secret("invalid", "high"), true)
+	c.enqueueAdded(secret("db", "10"), true)
+	if c.queue.Len() != 0 {
+		t.Fatalf("queued %d secrets before the informer synced", c.queue.Len())
+	}
+
+	c.enqueueInitial()
+	c.enqueueAdded(secret("late", "1000"), true)
+	var got []string
+	for c.queue.Len() > 0 {
+		key, _ := c.queue.Get()
+		got = append(got, key)
+		c.queue.Done(key)
+	}
+	want := []string{"default/registry", "default/db", "default/app", "default/invalid", "default/batch", "default/late"}
+	if !slices.Equal(got, want) {
+		t.Errorf("queued %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"path"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+)
+
+// protectedNamespace reports whether the operator must not write to secrets in a
+// namespace: one matching a protected pattern (e.g. "kube-*") that has not been
+// explicitly allowed. This is a safety rail for cluster-wide installs, where an
+// annotation in a system namespace could otherwise overwrite critical secrets.
+func (c *controller) protectedNamespace(namespace string) bool {
+	return isProtectedNamespace(c.cfg, namespace)
+}
+
+// setProtected records whether a secret is in a protected namespace, reporting
+// whether that changed since it was last synced.
+func (c *controller) setProtected(secret *v1.Secret, protected bool) bool {
+	key := secret.Namespace + "/" + secret.Name
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.warned[key] == protected {
+		return false
+	}
+	if protected {
+		c.warned[key] = true
+	} else {
+		delete(c.warned, key)
+	}
+	return true
+}
+
+// isProtectedNamespace is protectedNamespace for code running outside the controller.
+func isProtectedNamespace(cfg *config.Sync, namespace string) bool {
+	if matchesNamespace(cfg.AllowedNamespaces, namespace) {
+		return false
+	}
+	return matchesNamespace(cfg.ProtectedNamespaces, namespace)
+}
+
+// matchesNamespace reports whether namespace matches any of the comma separated
+// glob patterns.
+func matchesNamespace(patterns, namespace string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if matched, err := path.Match(pattern, namespace); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/version"
+)
+
+// Annotations written by the operator to record where a synced value came from.
+const (
+	provenanceProvider        = "k8s-secret-sync.weinbender.io/provenance-provider"
+	provenanceRefHash         = "k8s-secret-sync.weinbender.io/provenance-ref-sha256"
+	provenanceProviderVersion = "k8s-secret-sync.weinbender.io/provenance-provider-version"
+	provenanceOperatorVersion = "k8s-secret-sync.weinbender.io/provenance-operator-version"
+	provenanceCluster         = "k8s-secret-sync.weinbender.io/provenance-cluster"
+)
+
+// provenance returns the annotations recording which provider, ref, and operator
+// version produced a synced value, and in which cluster if it is named. The ref is
+// stored as a SHA-256 hash so auditors can match it against a known ref without the
+// annotation exposing vault paths.
+func provenance(providerName, secretID, providerVersion, clusterName string) map[string]string {
+	sum := sha256.Sum256([]byte(secretID))
+	annotations := map[string]string{
+		provenanceProvider:        providerName,
+		provenanceRefHash:         hex.EncodeToString(sum[:]),
+		provenanceOperatorVersion: version.Get(),
+	}
+	if providerVersion != "" {
+		annotations[provenanceProviderVersion] = providerVersion
+	}
+	if clusterName != "" {
+		annotations[provenanceCluster] = clusterName
+	}
+	return annotations
+}
@@ -0,0 +1,34 @@
+package sync
+
+import "testing"
+
+func TestProvenance(t *testing.T) {
+	got := provenance("op", "op://vault/item/field", "", "")
+
+	if got[provenanceProvider] != "op" {
+		t.Errorf("provider = %q, want op", got[provenanceProvider])
+	}
+	// sha256("op://vault/item/field")
+	want := "5eedd2d17c02ba9d198fb01033fc02ee56612536b199501fdf04c2af906f550f"
+	if h := got[provenanceRefHash]; h != want {
+		t.Errorf("ref hash = %q, want %q", h, want)
+	}
+	if got[provenanceOperatorVersion] == "" {
+		t.Errorf("expected operator version to be recorded")
+	}
+	if _, ok := got[provenanceProviderVersion]; ok {
+		t.Errorf("expected no provider version when provider does not report one")
+	}
+
+	if _, ok := got[provenanceCluster]; ok {
+		t.Errorf("expected no cluster when the cluster is not named")
+	}
+
+	got = provenance("op", "op://vault/item/field", "42", "prod-eu")
+	if got[provenanceProviderVersion] != "42" {
+		t.Errorf("provider version = %q, want 42", got[provenanceProviderVersion])
+	}
+	if got[provenanceCluster] != "prod-eu" {
+		t.Errorf("cluster = %q, want prod-eu", got[provenanceCluster])
+	}
+}
@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// selectorPrefix marks a pull-secret-for annotation holding a label selector for
+// ServiceAccounts rather than a list of names.
+const selectorPrefix = "selector:"
+
+// pullSecretTargets returns the ServiceAccounts in the secret's namespace named by its
+// pull-secret-for annotation, either a comma-separated list of names or
+// "selector:<label selector>".
+func (c *controller) pullSecretTargets(ctx context.Context, secret *v1.Secret) ([]v1.ServiceAccount, error) {
+	value := secret.Annotations[c.cfg.Annotations.PullSecretFor]
+	accounts := c.cfg.Clientset.CoreV1().ServiceAccounts(secret.Namespace)
+	if selector, ok := strings.CutPrefix(value, selectorPrefix); ok {
+		list, err := accounts.List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, fmt.Errorf("listing service accounts: %w", err)
+		}
+		return list.Items, nil
+	}
+
+	var targets []v1.ServiceAccount
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		account, err := accounts.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting service account %s: %w", name, err)
+		}
+		targets = append(targets, *account)
+	}
+	return targets, nil
+}
+
+// attachPullSecret adds a synced dockerconfigjson secret to the imagePullSecrets of
+// the ServiceAccounts named by its pull-secret-for annotation, so pods using them
+// can pull with the synced credentials. ServiceAccounts that already list it are
+// left alone.
+func (c *controller) attachPullSecret(ctx context.Context, secret *v1.Secret) error {
+	if secret.Type != v1.SecretTypeDockerConfigJson || secret.Annotations[c.cfg.Annotations.PullSecretFor] == "" {
+		return nil
+	}
+	targets, err := c.pullSecretTargets(ctx, secret)
+	if err != nil {
+		return err
+	}
+
+	ref := v1.LocalObjectReference{Name: secret.Name}
+	for _, account := range targets {
+		if slices.Contains(account.ImagePullSecrets, ref) {
+			continue
+		}
+		// imagePullSecrets is replaced wholesale by patches, so update the account,
+		// relying on its resource version to avoid losing concurrent changes
+		account.ImagePullSecrets = append(account.ImagePullSecrets, ref)
+		if _, err := c.cfg.Clientset.CoreV1().ServiceAccounts(account.Namespace).Update(ctx, &account, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("attaching pull secret to service account %s: %w", account.Name, err)
+		}
+		klog.InfoS("Attached pull secret to service account", "namespace", secret.Namespace, "name", secret.Name, "serviceAccount", account.Name)
+	}
+	return nil
+}
+
+// attachPullSecretOrWarn attaches a synced pull secret, reporting failures without
+// failing the sync; they are retried on the secret's next refresh.
+func (c *controller) attachPullSecretOrWarn(ctx context.Context, secret *v1.Secret) {
+	if err := c.attachPullSecret(ctx, secret); err != nil {
+		klog.ErrorS(err, "Failed to attach pull secret", "namespace", secret.Namespace, "name", secret.Name)
+		c.recorder.Eventf(secret, v1.EventTypeWarning, "AttachPullSecretFailed", "Failed to attach pull secret: %v", err)
+	}
+}
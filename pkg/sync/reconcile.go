@@ -0,0 +1,518 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/kubesecret"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/logging"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// syncSecret fetches the value for an annotated secret from its provider and patches
+// it into the secret. Returned errors are retried by the controller.
+func (c *controller) syncSecret(ctx context.Context, secret *v1.Secret) error {
+	cfg := c.cfg
+
+	// Check for required provider annotation
+	providerName, exists := secret.Annotations[cfg.Annotations.ProviderName]
+	if !exists || providerName == "" {
+		c.logSkip(secret, skipUnannotated, "Ignoring secret as it does not have the required provider annotation")
+		forgetApproval(secret.Namespace, secret.Name)
+		return c.removeSyncMetadata(ctx, secret)
+	}
+	klog.InfoS("Processing secret with provider", "namespace", secret.Namespace, "name", secret.Name, "provider", providerName)
+
+	// Never write to secrets in protected namespaces unless explicitly allowed. The
+	// warning is only raised when a secret becomes protected; resyncs are just counted.
+	protected := c.protectedNamespace(secret.Namespace)
+	if c.setProtected(secret, protected) && protected {
+		c.recorder.Eventf(secret, v1.EventTypeWarning, "ProtectedNamespace",
+			"Secret is in a protected namespace and will not be synced; allow the namespace with KSS_ALLOWED_NAMESPACES")
+	}
+	if protected {
+		c.logSkip(secret, skipProtected, "Ignoring secret in protected namespace")
+		return nil
+	}
+
+	// Leave certificates to cert-manager rather than fighting over the same object.
+	// Nothing is written, so resyncs add no status updates or events to it.
+	if kubesecret.CertManagerManaged(secret) {
+		c.logSkip(secret, skipCertManager, "Ignoring secret managed by cert-manager; sync a separate Secret with the cert-manager provider instead")
+		return nil
+	}
+
+	// Apply sync configuration kept in a ConfigMap, if the ref points at one
+	expanded, err := c.expandRefConfig(ctx, secret)
+	if err != nil {
+		klog.ErrorS(err, "Failed to load ref configuration", "namespace", secret.Namespace, "name", secret.Name)
+		if err := setStatus(ctx, cfg.Clientset, secret, StatusFailed, err.Error()); err != nil {
+			klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+		}
+		return err
+	}
+	secret = expanded
+
+	// Check for required ref annotation
+	secretID, exists := secret.Annotations[cfg.Annotations.ProviderRef]
+	if !exists || secretID == "" {
+		c.logSkip(secret, skipNoRef, "Ignoring secret as it does not have the required ref annotation")
+		forgetApproval(secret.Namespace, secret.Name)
+		return c.removeSyncMetadata(ctx, secret)
+	}
+	req, err := c.providerRequest(secret, providerName, secretID)
+	if err != nil {
+		klog.ErrorS(err, "Invalid provider request", "namespace", secret.Namespace, "name", secret.Name)
+		if err := setStatus(ctx, cfg.Clientset, secret, StatusFailed, err.Error()); err != nil {
+			klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+		}
+		return err
+	}
+	secretID = req.Ref
+
+	// Only sync break-glass credentials while they are checked out with a reason
+	var checkout map[string]string
+	if c.isBreakGlass(secret) {
+		ok, annotations, err := c.checkOut(ctx, secret, time.Now())
+		if err != nil {
+			klog.ErrorS(err, "Failed to check out break-glass secret", "namespace", secret.Namespace, "name", secret.Name)
+			if err := setStatus(ctx, cfg.Clientset, secret, StatusFailed, err.Error()); err != nil {
+				klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+			}
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		checkout = annotations
+	}
+
+	// Check for last-synced annotation; synced secrets are refreshed every poll interval
+	_, synced := secret.Annotations["last-synced"]
+	_, expires := expiresAt(secret)
+	if synced {
+		if c.refreshInterval() <= 0 && !expires && checkout == nil {
+			klog.InfoS("Secret has already been synced (last-synced annotation present)", "namespace", secret.Namespace, "name", secret.Name)
+			return nil
+		}
+		// A value just resolved for another secret with the same ref makes this one
+		// due, so it shares the provider request, as does a new break-glass checkout
+		if _, shared := c.sharedValue(providerName, req); !shared && checkout == nil {
+			if wait := c.untilRefresh(secret); wait > 0 {
+				c.queue.AddAfter(secret.Namespace+"/"+secret.Name, wait)
+				return nil
+			}
+		}
+		if err := c.health.healthy(providerName); err != nil {
+			klog.InfoS("Pausing refresh while provider is unhealthy", "namespace", secret.Namespace, "name", secret.Name, "provider", providerName, "err", err)
+			c.queue.AddAfter(secret.Namespace+"/"+secret.Name, time.Duration(cfg.HealthCheckInterval)*time.Second)
+			return nil
+		}
+	}
+
+	// Wait for the secrets this one depends on to sync first
+	if cycle := c.dependencyCycle(secret); cycle != nil {
+		message := fmt.Sprintf("Dependency cycle: %s", strings.Join(cycle, " -> "))
+		c.logSkip(secret, skipDependencyCycle, "Ignoring secret with dependency cycle", "cycle", cycle)
+		if err := setStatus(ctx, cfg.Clientset, secret, StatusFailed, message); err != nil {
+			klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+		}
+		return nil
+	}
+	pending, err := c.pendingDependency(secret)
+	if err != nil {
+		return err
+	}
+	if pending != "" {
+		// The dependency queues this secret once it syncs; recheck periodically in case it never does
+		klog.InfoS("Waiting for dependency", "namespace", secret.Namespace, "name", secret.Name, "reason", pending)
+		if secret.Annotations[statusMessageAnnotation] != pending {
+			if err := setStatus(ctx, cfg.Clientset, secret, StatusPending, pending); err != nil {
+				klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+			}
+		}
+		c.queue.AddAfter(secret.Namespace+"/"+secret.Name, dependencyRecheckInterval)
+		return nil
+	}
+
+	// Determine which key in the secret data to update
+	secretDataKey := cfg.DefaultSecretDataKey
+	if secretKeyAnnotationValue, exists := secret.Annotations[cfg.Annotations.SecretKey]; exists && secretKeyAnnotationValue != "" {
+		secretDataKey = secretKeyAnnotationValue
+	}
+
+	if _, ok := c.providers[providerName]; !ok {
+		// Retrying won't help until the annotation is fixed, so record the failure and move on
+		c.logSkip(secret, skipUnknownProvider, "Ignoring secret with unknown provider", "provider", providerName)
+		if err := setStatus(ctx, cfg.Clientset, secret, StatusFailed, fmt.Sprintf("Unknown provider %q", providerName)); err != nil {
+			klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+		}
+		return nil
+	}
+
+	// Fetch the secret value from the provider (or the value cache), and refresh the
+	// other secrets sharing the ref from the same request
+	_, shared := c.sharedValue(providerName, req)
+	resp, err := c.resolve(ctx, providerName, req)
+	value, providerVersion, expiry := string(resp.Value), resp.Version, resp.Expiry
+	if err == nil && !shared {
+		if _, ok := c.sharedValue(providerName, req); ok {
+			c.fanOut(secret, providerName, secretID)
+		}
+	}
+	notFound := errors.Is(err, provider.ErrNotFound)
+	if err != nil && !notFound {
+		klog.ErrorS(err, "Failed to resolve secret URI", "secretID", secretID)
+		status := map[string]string{
+			statusAnnotation:        StatusFailed,
+			statusMessageAnnotation: err.Error(),
+		}
+		if history, ok := c.appendHistory(secret, historyEntry(time.Now(), "", StatusFailed, err.Error())); ok {
+			status[historyAnnotation] = history
+		}
+		if err := patchAnnotations(ctx, cfg.Clientset, secret, status); err != nil {
+			klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+		}
+
+		// Retrying won't fix rejected credentials, so raise the alarm and park the
+		// secret until its next refresh instead
+		if errors.Is(err, provider.ErrUnauthorized) {
+			c.recorder.Eventf(secret, v1.EventTypeWarning, "ProviderUnauthorized", "Provider %q rejected the request: %v", providerName, err)
+			c.notifier.OnCredentialError(ctx, secret, providerName, err)
+			metrics.ProviderUnauthorized.WithLabelValues(providerName).Inc()
+			c.scheduleRefresh(secret)
+			return nil
+		}
+		return err
+	}
+
+	// Add provenance and last-synced; annotations not set here are left as they are
+	// by the write
+	annotations := make(map[string]string)
+	maps.Copy(annotations, provenance(providerName, secretID, providerVersion, cfg.ClusterName))
+	annotations["last-synced"] = time.Now().UTC().Format(time.RFC3339)
+	maps.Copy(annotations, checkout)
+	annotations[statusAnnotation] = StatusSynced
+	annotations[statusMessageAnnotation] = ""
+	if !expiry.IsZero() {
+		annotations[expiresAtAnnotation] = expiry.UTC().Format(time.RFC3339)
+	} else if expires {
+		annotations[expiresAtAnnotation] = ""
+	}
+
+	// Apply the configured policy if the secret does not exist upstream
+	var data map[string][]byte
+	var keys []string
+	patchDataValues := make(map[string]any)
+	if notFound {
+		policy, err := parseNotFoundPolicy(secret.Annotations[cfg.Annotations.OnNotFound])
+		if err != nil {
+			klog.ErrorS(err, "Invalid not-found policy, using default", "namespace", secret.Namespace, "name", secret.Name)
+		}
+		klog.InfoS("Secret not found in provider", "namespace", secret.Namespace, "name", secret.Name, "secretID", secretID, "policy", policy)
+
+		data = make(map[string][]byte)
+		keys = c.targetKeys(secret, secretDataKey)
+		annotations[statusAnnotation] = StatusNotFound
+		switch policy {
+		case notFoundEmpty:
+			for _, key := range keys {
+				data[key] = []byte{}
+				patchDataValues[key] = []byte{}
+			}
+			annotations[statusMessageAnnotation] = "Secret not found in provider; wrote empty value"
+		case notFoundDelete:
+			for _, key := range keys {
+				patchDataValues[key] = nil
+			}
+			annotations[statusMessageAnnotation] = "Secret not found in provider; deleted managed key"
+		default:
+			if err := setStatus(ctx, cfg.Clientset, secret, StatusNotFound, "Secret not found in provider"); err != nil {
+				klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+			}
+			return fmt.Errorf("resolving %q: %w", secretID, provider.ErrNotFound)
+		}
+	} else {
+		// Convert the value into secret data (e.g. mapping JSON fields to keys)
+		data, err = c.render(ctx, secret, secretDataKey, value, func(ref string) (string, error) {
+			resp, err := c.resolve(ctx, providerName, provider.Request{Ref: ref, Metadata: req.Metadata, Namespace: req.Namespace})
+			return string(resp.Value), err
+		})
+		if err == nil {
+			err = checkSecretType(secret, data)
+		}
+		if err != nil {
+			klog.ErrorS(err, "Failed to render secret data", "namespace", secret.Namespace, "name", secret.Name)
+			if err := setStatus(ctx, cfg.Clientset, secret, StatusFailed, err.Error()); err != nil {
+				klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+			}
+			return err
+		}
+		for key, v := range data {
+			keys = append(keys, key)
+			patchDataValues[key] = v
+		}
+	}
+
+	// Record the managed keys and a hash of their contents for tamper verification
+	slices.Sort(keys)
+	hash := dataHash(c.hashKey, data, keys)
+	annotations[managedKeysAnnotation] = strings.Join(keys, ",")
+	annotations[dataHashAnnotation] = hash
+	annotations[valueHashAnnotation] = valueHash(c.hashKey, value)
+
+	// Look up who values are encrypted to, if anyone
+	recipients, err := c.encryptionRecipients(secret)
+	if err != nil {
+		klog.ErrorS(err, "Invalid encryption recipients", "namespace", secret.Namespace, "name", secret.Name)
+		if err := setStatus(ctx, cfg.Clientset, secret, StatusFailed, err.Error()); err != nil {
+			klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+		}
+		return err
+	}
+
+	// Nothing to write if a refresh found the same value and outcome as last time,
+	// and neither encryption nor the labels have changed since
+	encrypted := secret.Annotations[encryptedHashAnnotation] != ""
+	if synced && !changed(secret, annotations) && hasLabels(secret, c.labels) && encrypted == (len(recipients) > 0) && !c.rotationPending(secret) && checkout == nil {
+		logging.V(logging.Sync, 2).InfoS("Secret is up to date with provider", "namespace", secret.Namespace, "name", secret.Name)
+		c.attachPullSecretOrWarn(ctx, secret)
+		c.markSynced(secret)
+		c.scheduleRefresh(secret)
+		return nil
+	}
+
+	// Apply the configured policy if the value changed both upstream and in the cluster
+	if synced && hash != secret.Annotations[dataHashAnnotation] && clusterModified(c.hashKey, secret) {
+		policy, err := parseConflictPolicy(secret.Annotations[cfg.Annotations.OnConflict])
+		if err != nil {
+			klog.ErrorS(err, "Invalid conflict policy, using default", "namespace", secret.Namespace, "name", secret.Name)
+		}
+		klog.InfoS("Value changed in both the provider and the cluster since last sync", "namespace", secret.Namespace, "name", secret.Name, "policy", policy)
+
+		var status, message string
+		switch policy {
+		case conflictClusterWins:
+			status, message = StatusSynced, "Kept value modified in the cluster over a new provider value"
+		case conflictFail:
+			status, message = StatusFailed, "Conflict: value changed in both the provider and the cluster since last sync"
+			c.recorder.Event(secret, v1.EventTypeWarning, "SyncConflict", message)
+		}
+		if status != "" {
+			if secret.Annotations[statusMessageAnnotation] != message {
+				if err := setStatus(ctx, cfg.Clientset, secret, status, message); err != nil {
+					klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+				}
+			}
+			c.scheduleRefresh(secret)
+			return nil
+		}
+	}
+
+	// Hold value changes found on refresh until they are approved, if required
+	if synced && hash != secret.Annotations[dataHashAnnotation] && c.requiresApproval(secret) {
+		if pending, message := c.awaitingApproval(secret, hash); pending {
+			klog.InfoS("Holding value change for approval", "namespace", secret.Namespace, "name", secret.Name, "hash", hash)
+			if secret.Annotations[statusMessageAnnotation] != message {
+				if err := setStatus(ctx, cfg.Clientset, secret, StatusPending, message); err != nil {
+					klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+				}
+			}
+			c.queue.AddAfter(secret.Namespace+"/"+secret.Name, approvalRecheckInterval)
+			return nil
+		}
+	}
+	forgetApproval(secret.Namespace, secret.Name)
+
+	// Give dependent systems a grace period before a newly detected value is written
+	if synced && hash != secret.Annotations[dataHashAnnotation] {
+		delay, err := c.applyAfter(secret)
+		if err != nil {
+			klog.ErrorS(err, "Invalid apply-after delay", "namespace", secret.Namespace, "name", secret.Name)
+			if err := setStatus(ctx, cfg.Clientset, secret, StatusFailed, err.Error()); err != nil {
+				klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+			}
+			return err
+		}
+		if applyAt, now := c.applyAt(secret, hash, delay), time.Now(); now.Before(applyAt) {
+			message := fmt.Sprintf("New value will be applied after %s", applyAt.UTC().Format(time.RFC3339))
+			klog.InfoS("Delaying new value", "namespace", secret.Namespace, "name", secret.Name, "applyAt", applyAt)
+			if secret.Annotations[statusMessageAnnotation] != message {
+				if err := setStatus(ctx, cfg.Clientset, secret, StatusPending, message); err != nil {
+					klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+				}
+			}
+			c.queue.AddAfter(secret.Namespace+"/"+secret.Name, applyAt.Sub(now))
+			return nil
+		}
+	}
+
+	// Defer value changes found on refresh until a maintenance window is open;
+	// new secrets are always bootstrapped immediately
+	if synced && hash != secret.Annotations[dataHashAnnotation] {
+		windows, err := c.maintenanceWindows(secret)
+		if err != nil {
+			klog.ErrorS(err, "Invalid maintenance window", "namespace", secret.Namespace, "name", secret.Name)
+			if err := setStatus(ctx, cfg.Clientset, secret, StatusFailed, err.Error()); err != nil {
+				klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+			}
+			return err
+		}
+		if now := time.Now(); !windows.open(now) {
+			next := windows.next(now)
+			message := fmt.Sprintf("Value change deferred until maintenance window at %s", next.UTC().Format(time.RFC3339))
+			klog.InfoS("Deferring value change until maintenance window", "namespace", secret.Namespace, "name", secret.Name, "next", next)
+			if secret.Annotations[statusMessageAnnotation] != message {
+				if err := setStatus(ctx, cfg.Clientset, secret, StatusPending, message); err != nil {
+					klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+				}
+			}
+			c.queue.AddAfter(secret.Namespace+"/"+secret.Name, next.Sub(now))
+			return nil
+		}
+	}
+
+	// Hold value changes for regular secrets until canaries sharing the ref have soaked
+	if synced && hash != secret.Annotations[dataHashAnnotation] && !c.isCanary(secret) {
+		hold, reason, err := c.canaryHold(ctx, secret, providerName, req, value)
+		if err != nil {
+			klog.ErrorS(err, "Failed to check canary secrets", "namespace", secret.Namespace, "name", secret.Name)
+			return err
+		}
+		if hold > 0 {
+			return c.holdForCanaries(ctx, secret, hold, reason)
+		}
+	}
+	if _, ok := secret.Annotations[canaryHeldSinceAnnotation]; ok {
+		annotations[canaryHeldSinceAnnotation] = ""
+	}
+
+	// Remove keys managed by a previous sync that are no longer produced
+	for _, key := range managedKeys(secret) {
+		if _, ok := patchDataValues[key]; !ok {
+			patchDataValues[key] = nil
+		}
+	}
+
+	// Encrypt values before they are written, if configured; the ciphertext is hashed
+	// separately so verification still works
+	if len(recipients) > 0 {
+		ciphertext, err := encrypt(data, recipients)
+		if err != nil {
+			klog.ErrorS(err, "Failed to encrypt secret data", "namespace", secret.Namespace, "name", secret.Name)
+			return err
+		}
+		for key, v := range ciphertext {
+			patchDataValues[key] = v
+		}
+		annotations[encryptedHashAnnotation] = dataHash(c.hashKey, ciphertext, keys)
+	} else if _, ok := secret.Annotations[encryptedHashAnnotation]; ok {
+		annotations[encryptedHashAnnotation] = ""
+	}
+
+	// Keep outgoing values alongside the new ones for graceful credential rollover
+	if synced && hash != secret.Annotations[dataHashAnnotation] && c.keepPrevious(secret) {
+		maps.Copy(patchDataValues, previousData(secret, data))
+	}
+
+	// Refuse writes the API server would reject for size, and warn as the limit nears
+	nearLimit, err := c.checkSize(secret, patchDataValues)
+	if err != nil {
+		klog.ErrorS(err, "Secret data exceeds limits", "namespace", secret.Namespace, "name", secret.Name)
+		if secret.Annotations[statusMessageAnnotation] != err.Error() {
+			if err := setStatus(ctx, cfg.Clientset, secret, StatusFailed, err.Error()); err != nil {
+				klog.ErrorS(err, "Failed to update sync status", "namespace", secret.Namespace, "name", secret.Name)
+			}
+		}
+		c.scheduleRefresh(secret)
+		return nil
+	}
+	if nearLimit {
+		klog.InfoS("Secret data is approaching the size limit", "namespace", secret.Namespace, "name", secret.Name)
+		c.recorder.Eventf(secret, v1.EventTypeWarning, "SecretSizeNearLimit",
+			"Secret data is within %d%% of the %d byte Secret size limit", 100-cfg.SizeWarningPercent, maxSecretSize)
+	}
+
+	// Record the outcome so on-call engineers can see when the value last changed
+	if history, ok := c.appendHistory(secret, historyEntry(time.Now(), hash, annotations[statusAnnotation], annotations[statusMessageAnnotation])); ok {
+		annotations[historyAnnotation] = history
+	}
+
+	// Let downstream tools (e.g. Argo Rollouts, Flux) react to the new value
+	if cfg.ChecksumAnnotation != "" {
+		annotations[cfg.ChecksumAnnotation] = hash
+	}
+
+	// Let Stakater Reloader roll workloads that use this secret
+	reloader, err := parseReloaderAnnotations(cfg.ReloaderAnnotations)
+	if err != nil {
+		klog.ErrorS(err, "Invalid Reloader annotations", "namespace", secret.Namespace, "name", secret.Name)
+		return err
+	}
+	maps.Copy(annotations, reloader)
+
+	// Copy the new value into an immutable secret and point at it, if configured
+	if c.immutableRotation(secret) {
+		name, err := c.createImmutableCopy(ctx, secret, hash, c.labels, patchDataValues)
+		if err != nil {
+			klog.ErrorS(err, "Failed to create immutable copy of secret", "namespace", secret.Namespace, "name", secret.Name)
+			return err
+		}
+		annotations[currentSecretAnnotation] = name
+	}
+
+	// Write the secret in the Kubernetes cluster with the configured patch strategy
+	strategy, err := c.patchStrategy(secret)
+	if err != nil {
+		klog.ErrorS(err, "Invalid patch strategy, using default", "namespace", secret.Namespace, "name", secret.Name)
+	}
+	err = writeSecret(ctx, cfg.Clientset, secret, strategy, c.labels, annotations, patchDataValues)
+	if err != nil {
+		klog.ErrorS(err, "Failed to update Kubernetes Secret", "namespace", secret.Namespace, "name", secret.Name)
+		return err
+	}
+	klog.InfoS("Successfully updated Kubernetes Secret with provider value and set last-synced annotation", "namespace", secret.Namespace, "name", secret.Name)
+	c.notifier.OnSyncSuccess(ctx, secret)
+	c.attachPullSecretOrWarn(ctx, secret)
+	if current, ok := annotations[currentSecretAnnotation]; ok {
+		if err := c.pruneImmutableCopies(ctx, secret, current, secret.Annotations[currentSecretAnnotation]); err != nil {
+			klog.ErrorS(err, "Failed to prune immutable copies of secret", "namespace", secret.Namespace, "name", secret.Name)
+		}
+	}
+	c.forgetDetected(secret)
+	c.markSynced(secret)
+	c.scheduleRefresh(secret)
+	if !expiry.IsZero() {
+		c.queue.AddAfter(secret.Namespace+"/"+secret.Name, time.Until(renewAt(time.Now(), expiry)))
+	}
+	return nil
+}
+
+// changed reports whether syncing would change a secret's managed data, status or
+// expiry, given the annotations computed for the new sync. A renewed value keeps its
+// data but not its expiry, which is recorded so the next renewal is scheduled from it.
+func changed(secret *v1.Secret, annotations map[string]string) bool {
+	for _, key := range []string{dataHashAnnotation, valueHashAnnotation, managedKeysAnnotation, statusAnnotation, statusMessageAnnotation, expiresAtAnnotation} {
+		if secret.Annotations[key] != annotations[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceWindows returns the windows during which a secret's value may change,
+// from its annotation or the global configuration.
+func (c *controller) maintenanceWindows(secret *v1.Secret) (maintenanceWindows, error) {
+	if value, ok := secret.Annotations[c.cfg.Annotations.MaintenanceWindow]; ok {
+		return parseMaintenanceWindows(value)
+	}
+	return parseMaintenanceWindows(c.cfg.MaintenanceWindows)
+}
@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// refConfigPrefix marks a ref annotation that points at sync configuration in a
+// ConfigMap key ("configmap:name#key") rather than at a provider value.
+const refConfigPrefix = "configmap:"
+
+// annotationPrefix is prepended to unqualified keys in ref configuration.
+const annotationPrefix = "k8s-secret-sync.weinbender.io/"
+
+// expandRefConfig returns the secret with the sync configuration referenced by its
+// ref annotation applied, if it references any. The configuration is a YAML map of
+// annotation keys to values in a ConfigMap in the secret's namespace, working around
+// the annotation size limit and keeping complex mappings and templates reviewable.
+// Unqualified keys such as "provider-ref" or "key-mapping" get the operator's
+// annotation prefix, and the configuration must set the actual provider ref. Only the
+// annotations naming refs and shaping the secret data may be set, so writing the
+// ConfigMap cannot switch providers or bypass approval and break-glass controls.
+// Values from the ConfigMap take precedence over the secret's own annotations, and
+// are never written back to it.
+func (c *controller) expandRefConfig(ctx context.Context, secret *v1.Secret) (*v1.Secret, error) {
+	refKey := c.cfg.Annotations.ProviderRef
+	ref, ok := strings.CutPrefix(secret.Annotations[refKey], refConfigPrefix)
+	if !ok {
+		return secret, nil
+	}
+
+	name, key, ok := strings.Cut(ref, "#")
+	if !ok || name == "" || key == "" {
+		return nil, fmt.Errorf("invalid ref configuration reference %q (expected %sname#key)", refConfigPrefix+ref, refConfigPrefix)
+	}
+	configMap, err := c.cfg.Clientset.CoreV1().ConfigMaps(secret.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("getting ref configuration ConfigMap %q: %w", name, err)
+	}
+	text, ok := configMap.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("ref configuration ConfigMap %q has no key %q", name, key)
+	}
+
+	var settings map[string]any
+	if err := yaml.Unmarshal([]byte(text), &settings); err != nil {
+		return nil, fmt.Errorf("parsing ref configuration %q: %w", refConfigPrefix+ref, err)
+	}
+
+	allowed := c.refConfigAnnotations()
+	expanded := secret.DeepCopy()
+	for k, v := range settings {
+		switch v.(type) {
+		case string, bool, float64:
+		default:
+			return nil, fmt.Errorf("ref configuration %q: value of %q must be a string, number, or boolean", refConfigPrefix+ref, k)
+		}
+		if !strings.Contains(k, "/") {
+			k = annotationPrefix + k
+		}
+		if !slices.Contains(allowed, k) {
+			return nil, fmt.Errorf("ref configuration %q may not set %q, only ref and data format annotations", refConfigPrefix+ref, k)
+		}
+		expanded.Annotations[k] = fmt.Sprint(v)
+	}
+	if actual := expanded.Annotations[refKey]; actual == "" || strings.HasPrefix(actual, refConfigPrefix) {
+		return nil, fmt.Errorf("ref configuration %q must set the provider ref", refConfigPrefix+ref)
+	}
+	return expanded, nil
+}
+
+// refConfigAnnotations returns the annotations ref configuration may set: those
+// naming the refs to resolve and how their values become secret data.
+func (c *controller) refConfigAnnotations() []string {
+	a := c.cfg.Annotations
+	return []string{
+		a.ProviderRef, a.ProviderVersion, a.SecretKey, a.Binary,
+		a.KeyMapping, a.Transform, a.AdditionalRefs, a.KeystorePassword,
+		a.Bundle, a.BundleFiles,
+		a.Template, a.TemplateConfigMap, a.TemplateEncoding,
+	}
+}
@@ -0,0 +1,54 @@
+package sync
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// refreshInterval returns how often synced secrets are re-resolved, or zero if
+// refreshing is disabled. The interval is stretched while the API server is
+// throttling the operator.
+func (c *controller) refreshInterval() time.Duration {
+	if !c.cfg.Refresh {
+		return 0
+	}
+	return time.Duration(c.cfg.PollInterval) * time.Second * time.Duration(c.throttle.slowdown())
+}
+
+// untilRefresh returns how long until a synced secret is due to be re-resolved.
+// Unchanged refreshes don't write to the secret, so the last check is tracked in
+// memory and the last-synced annotation is used after a restart. Values that
+// expire are instead due when they need renewing.
+func (c *controller) untilRefresh(secret *v1.Secret) time.Duration {
+	key := secret.Namespace + "/" + secret.Name
+	if expiry, ok := expiresAt(secret); ok {
+		if synced, err := time.Parse(time.RFC3339, secret.Annotations["last-synced"]); err == nil {
+			return time.Until(renewAt(synced, expiry))
+		}
+		return 0
+	}
+
+	c.mu.Lock()
+	last := c.checked[key]
+	c.mu.Unlock()
+
+	if synced, err := time.Parse(time.RFC3339, secret.Annotations["last-synced"]); err == nil && synced.After(last) {
+		last = synced
+	}
+	return time.Until(last.Add(c.refreshInterval()))
+}
+
+// scheduleRefresh records that a secret was just checked against its provider and
+// queues its next refresh.
+func (c *controller) scheduleRefresh(secret *v1.Secret) {
+	key := secret.Namespace + "/" + secret.Name
+
+	c.mu.Lock()
+	c.checked[key] = time.Now()
+	c.mu.Unlock()
+
+	if interval := c.refreshInterval(); interval > 0 {
+		c.queue.AddAfter(key, interval)
+	}
+}
@@ -0,0 +1,36 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// refVars are the variables available to ref templates.
+type refVars struct {
+	Namespace   string
+	Name        string
+	ClusterName string
+}
+
+// expandRef renders a ref containing template actions, e.g.
+// "op://{{ .ClusterName }}-{{ .Namespace }}/db/password", so one manifest can be
+// shared by namespaces and clusters that each resolve their own value. Refs without
+// actions are returned as they are.
+func expandRef(ref string, secret *v1.Secret, clusterName string) (string, error) {
+	if !strings.Contains(ref, "{{") {
+		return ref, nil
+	}
+	tmpl, err := template.New("ref").Option("missingkey=error").Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("parsing ref template %q: %w", ref, err)
+	}
+	var expanded strings.Builder
+	vars := refVars{Namespace: secret.Namespace, Name: secret.Name, ClusterName: clusterName}
+	if err := tmpl.Execute(&expanded, vars); err != nil {
+		return "", fmt.Errorf("expanding ref template %q: %w", ref, err)
+	}
+	return expanded.String(), nil
+}
@@ -0,0 +1,43 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// signalProcesses sends the named signal (e.g. "SIGHUP") to every process whose
+// command name is name, returning how many were signalled. Processes in other
+// containers of the pod are only visible with shareProcessNamespace.
+func signalProcesses(name, signal string) (int, error) {
+	sig := unix.SignalNum(strings.ToUpper(signal))
+	if sig == 0 {
+		return 0, fmt.Errorf("unknown signal %q", signal)
+	}
+
+	comms, err := filepath.Glob("/proc/[0-9]*/comm")
+	if err != nil {
+		return 0, err
+	}
+	signaled := 0
+	for _, comm := range comms {
+		contents, err := os.ReadFile(comm)
+		if err != nil || strings.TrimSpace(string(contents)) != name {
+			continue
+		}
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(comm)))
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		if err := syscall.Kill(pid, sig); err != nil {
+			return signaled, fmt.Errorf("signalling process %d: %w", pid, err)
+		}
+		signaled++
+	}
+	return signaled, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package sync
+
+import "errors"
+
+// signalProcesses is only supported on Linux, where processes are found in /proc.
+func signalProcesses(name, signal string) (int, error) {
+	return 0, errors.New("signalling processes is only supported on Linux")
+}
@@ -0,0 +1,25 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseReloaderAnnotations parses the annotations set on synced secrets for Stakater
+// Reloader, formatted as comma-separated "key=value" pairs. The default,
+// "reloader.stakater.com/match=true", lets workloads annotated with
+// "reloader.stakater.com/search" restart when the secret changes.
+func parseReloaderAnnotations(value string) (map[string]string, error) {
+	annotations := make(map[string]string)
+	if value == "" {
+		return annotations, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid Reloader annotation %q, expected key=value", pair)
+		}
+		annotations[key] = val
+	}
+	return annotations, nil
+}
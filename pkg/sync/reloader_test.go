@@ -0,0 +1,24 @@
+package sync
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseReloaderAnnotations(t *testing.T) {
+	got, err := parseReloaderAnnotations("reloader.stakater.com/match=true, example.com/team=payments")
+	if err != nil {
+		t.Fatalf("parseReloaderAnnotations: %v", err)
+	}
+	want := map[string]string{"reloader.stakater.com/match": "true", "example.com/team": "payments"}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseReloaderAnnotations = %v, want %v", got, want)
+	}
+
+	if got, err := parseReloaderAnnotations(""); err != nil || len(got) != 0 {
+		t.Errorf("expected no annotations when disabled, got %v, %v", got, err)
+	}
+	if _, err := parseReloaderAnnotations("reloader.stakater.com/match"); err == nil {
+		t.Errorf("expected error for annotation without a value")
+	}
+}
@@ -0,0 +1,203 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/transform"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// render converts a resolved provider value into the secret data it should be
+// written as, according to the secret's annotations. Templates may resolve
+// additional refs from the same provider with resolve, expanded like the secret's
+// own ref.
+func (c *controller) render(ctx context.Context, secret *v1.Secret, secretDataKey, value string, resolve transform.Resolver) (map[string][]byte, error) {
+	if resolve != nil {
+		resolveExpanded := resolve
+		resolve = func(ref string) (string, error) {
+			expanded, err := expandRef(ref, secret, c.cfg.ClusterName)
+			if err != nil {
+				return "", err
+			}
+			return resolveExpanded(expanded)
+		}
+	}
+	spec := secret.Annotations[c.cfg.Annotations.KeyMapping]
+	name := secret.Annotations[c.cfg.Annotations.Transform]
+	bundle := secret.Annotations[c.cfg.Annotations.Bundle]
+	text, hasTemplate, err := c.template(ctx, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	set := 0
+	for _, ok := range []bool{spec != "", name != "", hasTemplate, bundle != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, errors.New("only one of the key mapping, transform, template, and bundle annotations may be used")
+	}
+	if name != "" {
+		if value, err = c.transformInput(secret, value, resolve); err != nil {
+			return nil, err
+		}
+	} else if secret.Annotations[c.cfg.Annotations.AdditionalRefs] != "" {
+		return nil, errors.New("additional refs can only be used with a transform")
+	}
+	if bundle == "" && secret.Annotations[c.cfg.Annotations.BundleFiles] != "" {
+		return nil, errors.New("bundle files can only be used with the bundle annotation")
+	}
+
+	switch {
+	case spec != "":
+		keyMap, err := transform.ParseKeyMap(spec)
+		if err != nil {
+			return nil, err
+		}
+		return keyMap.Apply(value)
+	case transform.IsKeystore(name):
+		ref := secret.Annotations[c.cfg.Annotations.KeystorePassword]
+		if ref == "" {
+			return nil, fmt.Errorf("transform %q needs the keystore password ref annotation", name)
+		}
+		if resolve == nil {
+			return nil, errors.New("resolving the keystore password ref is not supported here")
+		}
+		password, err := resolve(ref)
+		if err != nil {
+			return nil, fmt.Errorf("resolving keystore password: %w", err)
+		}
+		return transform.Keystore(name, value, password)
+	case name == "htpasswd":
+		// Keep the existing entry while it matches, as every hash has a new salt
+		return transform.HtpasswdFrom(value, secret.Data[transform.HtpasswdKey])
+	case name != "":
+		return transform.Apply(name, value)
+	case bundle != "":
+		files, err := c.bundleFiles(secret, value, resolve)
+		if err != nil {
+			return nil, err
+		}
+		packed, err := transform.Bundle(bundle, files)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{secretDataKey: packed}, nil
+	case hasTemplate:
+		rendered, err := transform.Template(text, value, resolve)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := transform.Decode(rendered, secret.Annotations[c.cfg.Annotations.TemplateEncoding])
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{secretDataKey: decoded}, nil
+	}
+
+	return map[string][]byte{
+		secretDataKey: []byte(value),
+	}, nil
+}
+
+// transformInput returns the value a transformation is applied to: the provider value
+// followed by the values of the secret's additional refs, one per line.
+func (c *controller) transformInput(secret *v1.Secret, value string, resolve transform.Resolver) (string, error) {
+	spec := secret.Annotations[c.cfg.Annotations.AdditionalRefs]
+	if spec == "" {
+		return value, nil
+	}
+	if resolve == nil {
+		return "", errors.New("resolving additional refs is not supported here")
+	}
+	parts := []string{strings.TrimRight(value, "\n")}
+	for _, ref := range strings.Split(spec, ",") {
+		if ref = strings.TrimSpace(ref); ref == "" {
+			continue
+		}
+		resolved, err := resolve(ref)
+		if err != nil {
+			return "", fmt.Errorf("resolving additional ref %q: %w", ref, err)
+		}
+		parts = append(parts, strings.TrimRight(resolved, "\n"))
+	}
+	return strings.Join(parts, "\n") + "\n", nil
+}
+
+// bundleFiles returns the files of a secret's bundle, with the provider value or the
+// value of their ref as their content.
+func (c *controller) bundleFiles(secret *v1.Secret, value string, resolve transform.Resolver) ([]transform.BundleFile, error) {
+	files, err := transform.ParseBundleFiles(secret.Annotations[c.cfg.Annotations.BundleFiles])
+	if err != nil {
+		return nil, err
+	}
+	for i, file := range files {
+		if file.Ref == "" {
+			files[i].Content = []byte(value)
+			continue
+		}
+		if resolve == nil {
+			return nil, errors.New("resolving bundle refs is not supported here")
+		}
+		resolved, err := resolve(file.Ref)
+		if err != nil {
+			return nil, fmt.Errorf("resolving bundle file %q: %w", file.Name, err)
+		}
+		files[i].Content = []byte(resolved)
+	}
+	return files, nil
+}
+
+// template returns the value template for a secret, either inline in an annotation
+// or from a key of a ConfigMap in the secret's namespace ("name#key"), which avoids
+// the size and readability limits of annotations.
+func (c *controller) template(ctx context.Context, secret *v1.Secret) (string, bool, error) {
+	inline, hasInline := secret.Annotations[c.cfg.Annotations.Template]
+	ref, hasRef := secret.Annotations[c.cfg.Annotations.TemplateConfigMap]
+	switch {
+	case hasInline && hasRef:
+		return "", false, errors.New("template and template ConfigMap annotations cannot be used together")
+	case hasInline:
+		return inline, true, nil
+	case !hasRef:
+		return "", false, nil
+	}
+
+	name, key, ok := strings.Cut(ref, "#")
+	if !ok || name == "" || key == "" {
+		return "", false, fmt.Errorf("invalid template ConfigMap reference %q (expected name#key)", ref)
+	}
+	configMap, err := c.cfg.Clientset.CoreV1().ConfigMaps(secret.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", false, fmt.Errorf("getting template ConfigMap %q: %w", name, err)
+	}
+	text, ok := configMap.Data[key]
+	if !ok {
+		return "", false, fmt.Errorf("template ConfigMap %q has no key %q", name, key)
+	}
+	return text, true, nil
+}
+
+// targetKeys returns the data keys a secret's value is written to, for outcomes
+// where there is no value to render (such as a missing upstream secret). Keys
+// recorded by a previous sync take precedence.
+func (c *controller) targetKeys(secret *v1.Secret, secretDataKey string) []string {
+	if keys := managedKeys(secret); len(keys) > 0 {
+		return keys
+	}
+	if spec := secret.Annotations[c.cfg.Annotations.KeyMapping]; spec != "" {
+		if keyMap, err := transform.ParseKeyMap(spec); err == nil {
+			return keyMap.Keys()
+		}
+	}
+	if name := secret.Annotations[c.cfg.Annotations.Transform]; name != "" {
+		return transform.Keys(name)
+	}
+	return []string{secretDataKey}
+}
@@ -0,0 +1,110 @@
+package sync
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Report summarizes the annotated secrets in a cluster, for audits and migration planning.
+type Report struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Secrets     []SecretReport `json:"secrets"`
+	Providers   map[string]int `json:"providers"` // number of secrets per provider
+	Failures    int            `json:"failures"`  // number of secrets whose last sync failed
+}
+
+// SecretReport describes a single annotated secret.
+type SecretReport struct {
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Provider   string `json:"provider"`
+	Ref        string `json:"ref"`
+	Status     string `json:"status,omitempty"`
+	Message    string `json:"message,omitempty"`
+	LastSynced string `json:"lastSynced,omitempty"`
+	AgeSeconds int64  `json:"ageSeconds,omitempty"` // seconds since last synced
+}
+
+// BuildReport lists the secrets in the cluster and reports on those annotated for sync.
+func BuildReport(ctx context.Context, cfg *config.Sync, now time.Time) (*Report, error) {
+	secrets, err := cfg.Clientset.CoreV1().Secrets(v1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing secrets: %w", err)
+	}
+
+	report := &Report{GeneratedAt: now.UTC(), Secrets: []SecretReport{}, Providers: map[string]int{}}
+	for _, secret := range secrets.Items {
+		providerName := secret.Annotations[cfg.Annotations.ProviderName]
+		if providerName == "" {
+			continue
+		}
+		entry := SecretReport{
+			Namespace:  secret.Namespace,
+			Name:       secret.Name,
+			Provider:   providerName,
+			Ref:        secret.Annotations[cfg.Annotations.ProviderRef],
+			Status:     secret.Annotations[statusAnnotation],
+			Message:    secret.Annotations[statusMessageAnnotation],
+			LastSynced: secret.Annotations["last-synced"],
+		}
+		if synced, err := time.Parse(time.RFC3339, entry.LastSynced); err == nil {
+			entry.AgeSeconds = int64(now.Sub(synced).Seconds())
+		}
+
+		report.Secrets = append(report.Secrets, entry)
+		report.Providers[providerName]++
+		if entry.Status == StatusFailed || entry.Status == StatusNotFound {
+			report.Failures++
+		}
+	}
+
+	slices.SortFunc(report.Secrets, func(a, b SecretReport) int {
+		return strings.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name)
+	})
+	return report, nil
+}
+
+// Write writes the report in the given format, "json", "csv" or "backstage". The CSV
+// form has one row per secret; the backstage form is a JSON list of catalog entities,
+// one per namespace.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "backstage":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r.Backstage())
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"namespace", "name", "provider", "ref", "status", "message", "last_synced", "age_seconds"}); err != nil {
+			return err
+		}
+		for _, s := range r.Secrets {
+			age := ""
+			if s.LastSynced != "" {
+				age = strconv.FormatInt(s.AgeSeconds, 10)
+			}
+			if err := cw.Write([]string{s.Namespace, s.Name, s.Provider, s.Ref, s.Status, s.Message, s.LastSynced, age}); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown report format %q, expected json, csv or backstage", format)
+	}
+}
@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBuildReport(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cs := fake.NewSimpleClientset(
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "db", Annotations: map[string]string{
+			"k8s-secret-sync.weinbender.io/provider-name": "op",
+			"k8s-secret-sync.weinbender.io/provider-ref":  "op://vault/db/password",
+			statusAnnotation: StatusSynced,
+			"last-synced":    now.Add(-time.Hour).Format(time.RFC3339),
+		}}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "api", Annotations: map[string]string{
+			"k8s-secret-sync.weinbender.io/provider-name": "op",
+			"k8s-secret-sync.weinbender.io/provider-ref":  "op://vault/api/token",
+			statusAnnotation:        StatusFailed,
+			statusMessageAnnotation: "boom",
+		}}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "b", Name: "unmanaged"}},
+	)
+
+	report, err := BuildReport(context.Background(), config.New(cs), now)
+	if err != nil {
+		t.Fatalf("BuildReport: %v", err)
+	}
+	if len(report.Secrets) != 2 || report.Secrets[0].Name != "api" || report.Secrets[1].Name != "db" {
+		t.Fatalf("Secrets = %+v, want api and db", report.Secrets)
+	}
+	if report.Secrets[1].AgeSeconds != 3600 {
+		t.Errorf("AgeSeconds = %d, want 3600", report.Secrets[1].AgeSeconds)
+	}
+	if report.Providers["op"] != 2 || report.Failures != 1 {
+		t.Errorf("Providers = %v, Failures = %d; want op=2, 1 failure", report.Providers, report.Failures)
+	}
+
+	var buf bytes.Buffer
+	if err := report.Write(&buf, "json"); err != nil {
+		t.Fatalf("Write json: %v", err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded.Secrets) != 2 {
+		t.Errorf("expected JSON report to round-trip, got %v", err)
+	}
+
+	buf.Reset()
+	if err := report.Write(&buf, "csv"); err != nil {
+		t.Fatalf("Write csv: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[2] != "a,db,op,op://vault/db/password,Synced,,2024-06-01T11:00:00Z,3600" {
+		t.Errorf("CSV = %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := report.Write(&buf, "backstage"); err != nil {
+		t.Fatalf("Write backstage: %v", err)
+	}
+	var entities []BackstageEntity
+	if err := json.Unmarshal(buf.Bytes(), &entities); err != nil {
+		t.Fatalf("decoding backstage entities: %v", err)
+	}
+	if len(entities) != 1 || entities[0].Metadata.Name != "secret-sync-a" || entities[0].Kind != "Resource" {
+		t.Fatalf("entities = %+v, want one Resource for namespace a", entities)
+	}
+	if spec := entities[0].Spec; spec.Health != "degraded" || spec.Secrets != 2 || spec.Status[StatusSynced] != 1 || !slices.Equal(spec.Failing, []string{"api"}) {
+		t.Errorf("spec = %+v, want degraded with api failing", spec)
+	}
+
+	if err := report.Write(&buf, "yaml"); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
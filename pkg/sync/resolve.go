@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/logging"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+// resolve fetches the value for req from the named provider, using the value
+// cache when enabled. The response only carries the value when it was served from
+// the cache or shared with another secret; otherwise it is the provider's own.
+func (c *controller) resolve(ctx context.Context, providerName string, req provider.Request) (provider.Response, error) {
+	newProvider, ok := c.providers[providerName]
+	if !ok {
+		return provider.Response{}, fmt.Errorf("unknown provider %q", providerName)
+	}
+
+	// Use a cached value if one is available
+	cacheKey := requestKey(providerName, req)
+	if c.cache != nil {
+		if value, cached := c.cache.Get(cacheKey); cached {
+			logging.V(logging.Cache, 4).InfoS("Using cached value", "provider", providerName, "ref", req.Ref)
+			return provider.Response{Value: []byte(value)}, nil
+		}
+	}
+
+	// Use a value just resolved for another secret sharing the ref
+	if value, shared := c.sharedValue(providerName, req); shared {
+		metrics.ProviderRequestsDeduplicated.WithLabelValues(providerName).Inc()
+		logging.V(logging.Provider(providerName), 4).InfoS("Using value resolved for another secret", "provider", providerName, "ref", req.Ref)
+		return provider.Response{Value: []byte(value)}, nil
+	}
+
+	// Fetch the secret value from the provider (e.g., 1Password)
+	secretProvider, err := newProvider()
+	if err != nil {
+		return provider.Response{}, fmt.Errorf("initializing provider %q: %w", providerName, err)
+	}
+	release, err := c.acquireProvider(ctx, providerName)
+	if err != nil {
+		return provider.Response{}, err
+	}
+	defer release()
+	if timeout := time.Duration(c.cfg.ProviderPolicy(providerName).Timeout) * time.Second; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	logging.V(logging.Provider(providerName), 4).InfoS("Resolving value from provider", "provider", providerName, "ref", req.Ref, "version", req.Version)
+	resp, err := secretProvider.Resolve(ctx, req)
+	if err != nil {
+		logging.V(logging.Provider(providerName), 4).InfoS("Provider failed to resolve value", "provider", providerName, "ref", req.Ref, "err", err)
+		return provider.Response{}, err
+	}
+	logging.V(logging.Provider(providerName), 5).InfoS("Resolved value from provider", "provider", providerName, "ref", req.Ref, "version", resp.Version, "expiry", resp.Expiry)
+
+	// Short-lived values are never cached, since a cached copy could outlive them
+	if resp.Expiry.IsZero() {
+		if c.cache != nil {
+			c.cache.Set(cacheKey, string(resp.Value))
+		}
+		c.shareValue(providerName, req, string(resp.Value))
+	}
+	return resp, nil
+}
+
+// acquireProvider waits for a slot to send a request to the named provider, if the
+// provider's policy limits its requests in flight, returning a function that frees
+// the slot. Aliases share their provider's slots. Waiting is not counted against the
+// provider's timeout.
+func (c *controller) acquireProvider(ctx context.Context, providerName string) (func(), error) {
+	name := providerName
+	if info, ok := provider.Lookup(providerName); ok {
+		name = info.Name
+	}
+	limit := c.cfg.ProviderPolicy(name).MaxInFlight
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	c.mu.Lock()
+	slots, ok := c.inFlight[name]
+	if !ok {
+		slots = make(chan struct{}, limit)
+		c.inFlight[name] = slots
+	}
+	c.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: waiting for a request slot for provider %q: %v", provider.ErrTransient, name, ctx.Err())
+	}
+}
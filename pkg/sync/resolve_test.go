@@ -0,0 +1,32 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func TestAcquireProviderLimitsInFlight(t *testing.T) {
+	t.Setenv("KSS_PROVIDER_FAKE_MAX_IN_FLIGHT", "1")
+	c, _ := newTestController(t, &fakeProvider{})
+
+	release, err := c.acquireProvider(context.Background(), "fake")
+	if err != nil {
+		t.Fatalf("acquireProvider: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.acquireProvider(ctx, "fake"); !errors.Is(err, provider.ErrTransient) {
+		t.Errorf("acquireProvider over the limit = %v, want ErrTransient", err)
+	}
+
+	release()
+	release, err = c.acquireProvider(context.Background(), "fake")
+	if err != nil {
+		t.Fatalf("acquireProvider after release: %v", err)
+	}
+	release()
+}
@@ -0,0 +1,107 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// currentSecretAnnotation names the immutable copy holding a secret's current
+	// value, for secrets using immutable rotation.
+	currentSecretAnnotation = "k8s-secret-sync.weinbender.io/current-secret"
+
+	// rotationOfLabel is set on immutable copies to the name of the secret they
+	// were created for.
+	rotationOfLabel = "k8s-secret-sync.weinbender.io/rotation-of"
+
+	// rotationImmutable is the rotation annotation value enabling immutable rotation.
+	rotationImmutable = "immutable"
+)
+
+// immutableRotation reports whether each new value of a secret is also written to
+// a new immutable Secret, for workloads that mount immutable secrets.
+func (c *controller) immutableRotation(secret *v1.Secret) bool {
+	return secret.Annotations[c.cfg.Annotations.Rotation] == rotationImmutable
+}
+
+// immutableSecretName returns the name of the immutable copy of a secret's data
+// with the given hash.
+func immutableSecretName(name, hash string) string {
+	return name + "-" + hash[:10]
+}
+
+// rotationPending reports whether a secret using immutable rotation has no
+// immutable copy of its current data yet, e.g. because rotation was just enabled.
+func (c *controller) rotationPending(secret *v1.Secret) bool {
+	hash := secret.Annotations[dataHashAnnotation]
+	return c.immutableRotation(secret) && len(hash) >= 10 &&
+		secret.Annotations[currentSecretAnnotation] != immutableSecretName(secret.Name, hash)
+}
+
+// createImmutableCopy creates an immutable Secret holding the managed data being
+// written to secret, owned by it, and returns its name. A copy already created
+// for the same data is reused.
+func (c *controller) createImmutableCopy(ctx context.Context, secret *v1.Secret, hash string, labels map[string]string, patchData map[string]any) (string, error) {
+	name := immutableSecretName(secret.Name, hash)
+	data := make(map[string][]byte, len(patchData))
+	for key, value := range patchData {
+		if b, ok := value.([]byte); ok {
+			data[key] = b
+		}
+	}
+	immutable := true
+	copyLabels := maps.Clone(labels)
+	if copyLabels == nil {
+		copyLabels = make(map[string]string)
+	}
+	copyLabels[rotationOfLabel] = secret.Name
+	copied := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: secret.Namespace,
+			Labels:    copyLabels,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1", Kind: "Secret", Name: secret.Name, UID: secret.UID,
+			}},
+		},
+		Type:      secret.Type,
+		Data:      data,
+		Immutable: &immutable,
+	}
+	_, err := c.cfg.Clientset.CoreV1().Secrets(secret.Namespace).Create(ctx, copied, metav1.CreateOptions{})
+	switch {
+	case err == nil:
+		klog.InfoS("Created immutable copy of secret", "namespace", secret.Namespace, "name", secret.Name, "copy", name)
+	case !apierrors.IsAlreadyExists(err):
+		return "", fmt.Errorf("creating immutable secret %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// pruneImmutableCopies deletes a secret's immutable copies other than keep, which
+// are the current copy and the one it replaced, still mounted by workloads that
+// have not rolled yet.
+func (c *controller) pruneImmutableCopies(ctx context.Context, secret *v1.Secret, keep ...string) error {
+	secrets := c.cfg.Clientset.CoreV1().Secrets(secret.Namespace)
+	copies, err := secrets.List(ctx, metav1.ListOptions{LabelSelector: rotationOfLabel + "=" + secret.Name})
+	if err != nil {
+		return fmt.Errorf("listing immutable copies: %w", err)
+	}
+	for _, copied := range copies.Items {
+		if slices.Contains(keep, copied.Name) {
+			continue
+		}
+		if err := secrets.Delete(ctx, copied.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting immutable copy %s: %w", copied.Name, err)
+		}
+		klog.InfoS("Deleted superseded immutable copy of secret", "namespace", secret.Namespace, "name", secret.Name, "copy", copied.Name)
+	}
+	return nil
+}
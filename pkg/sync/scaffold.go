@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/transform"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// ScaffoldSpec lists placeholder Secrets to generate manifests for. Namespace,
+// Provider, and RefPrefix apply to every secret that does not set its own.
+type ScaffoldSpec struct {
+	Namespace string           `json:"namespace,omitempty"`
+	Provider  string           `json:"provider,omitempty"`
+	RefPrefix string           `json:"refPrefix,omitempty"` // prepended to each ref, e.g. "op://vault/"
+	Secrets   []ScaffoldSecret `json:"secrets"`
+}
+
+// ScaffoldSecret describes one placeholder Secret. At most one of Key, Keys, and
+// Transform is set; with none, the value is written to the default data key.
+type ScaffoldSecret struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Type        v1.SecretType     `json:"type,omitempty"`
+	Provider    string            `json:"provider,omitempty"`
+	Ref         string            `json:"ref"`
+	Key         string            `json:"key,omitempty"`         // data key the value is written to
+	Keys        map[string]string `json:"keys,omitempty"`        // data keys mapped to JSON paths in the value, e.g. {"password": ".pass"}
+	Transform   string            `json:"transform,omitempty"`   // transformation applied to the value
+	Labels      map[string]string `json:"labels,omitempty"`      // labels added to the Secret
+	Annotations map[string]string `json:"annotations,omitempty"` // further annotations, e.g. an on-not-found policy
+}
+
+// Scaffold converts a YAML scaffold spec into a multi-document stream of placeholder
+// Secret manifests annotated for sync. Providers, key mappings, and transformations
+// are checked up front, and every invalid secret is reported rather than only the first.
+func Scaffold(spec []byte, annotations config.Annotations, defaults ScaffoldSpec) ([]byte, error) {
+	parsed := defaults
+	if err := yaml.UnmarshalStrict(spec, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing scaffold spec: %w", err)
+	}
+	if len(parsed.Secrets) == 0 {
+		return nil, errors.New("scaffold spec lists no secrets")
+	}
+
+	var errs []error
+	var out strings.Builder
+	for i, s := range parsed.Secrets {
+		manifest, err := scaffoldSecret(s, parsed, annotations)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("secret %d (%s): %w", i+1, s.Name, err))
+			continue
+		}
+		doc, err := yaml.Marshal(manifest)
+		if err != nil {
+			return nil, err
+		}
+		if out.Len() > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(doc)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return []byte(out.String()), nil
+}
+
+// scaffoldSecret returns the manifest for one secret of a spec.
+func scaffoldSecret(s ScaffoldSecret, spec ScaffoldSpec, annotations config.Annotations) (map[string]any, error) {
+	if errs := validation.IsDNS1123Subdomain(s.Name); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid name: %s", strings.Join(errs, "; "))
+	}
+	providerName := cmp.Or(s.Provider, spec.Provider)
+	if _, ok := provider.Lookup(providerName); !ok {
+		return nil, fmt.Errorf("unknown provider %q", providerName)
+	}
+	if s.Ref == "" {
+		return nil, errors.New("no ref")
+	}
+
+	secretAnnotations := maps.Clone(s.Annotations)
+	if secretAnnotations == nil {
+		secretAnnotations = make(map[string]string)
+	}
+	secretAnnotations[annotations.ProviderName] = providerName
+	secretAnnotations[annotations.ProviderRef] = spec.RefPrefix + s.Ref
+
+	set := 0
+	for _, ok := range []bool{s.Key != "", len(s.Keys) > 0, s.Transform != ""} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return nil, errors.New("only one of key, keys, and transform may be set")
+	case s.Key != "":
+		secretAnnotations[annotations.SecretKey] = s.Key
+	case len(s.Keys) > 0:
+		var pairs []string
+		for _, key := range slices.Sorted(maps.Keys(s.Keys)) {
+			pairs = append(pairs, key+"="+s.Keys[key])
+		}
+		mapping := strings.Join(pairs, ",")
+		if _, err := transform.ParseKeyMap(mapping); err != nil {
+			return nil, err
+		}
+		secretAnnotations[annotations.KeyMapping] = mapping
+	case s.Transform != "":
+		if !transform.Known(s.Transform) {
+			return nil, fmt.Errorf("unknown transform %q", s.Transform)
+		}
+		secretAnnotations[annotations.Transform] = s.Transform
+	}
+
+	metadata := map[string]any{"name": s.Name, "annotations": secretAnnotations}
+	if namespace := cmp.Or(s.Namespace, spec.Namespace); namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	if len(s.Labels) > 0 {
+		metadata["labels"] = s.Labels
+	}
+	manifest := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   metadata,
+	}
+	if s.Type != "" {
+		manifest["type"] = s.Type
+		// Typed secrets are only accepted with the keys their type requires
+		placeholders := make(map[string]string)
+		for key, value := range placeholderData(s.Type) {
+			placeholders[key] = string(value)
+		}
+		if len(placeholders) > 0 {
+			manifest["stringData"] = placeholders
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"sigs.k8s.io/yaml"
+)
+
+func TestScaffold(t *testing.T) {
+	spec := `
+namespace: team-a
+refPrefix: op://vault/
+secrets:
+  - name: api-key
+    ref: api/credential
+    key: API_KEY
+  - name: db
+    ref: db/data
+    keys: {username: .user, password: .pass}
+  - name: registry
+    type: kubernetes.io/dockerconfigjson
+    ref: registry/config
+`
+	out, err := Scaffold([]byte(spec), config.New(nil).Annotations, ScaffoldSpec{Provider: "fake"})
+	if err != nil {
+		t.Fatalf("Scaffold: %v", err)
+	}
+	docs := strings.Split(string(out), "---\n")
+	if len(docs) != 3 {
+		t.Fatalf("expected 3 manifests, got:\n%s", out)
+	}
+
+	var db struct {
+		Metadata struct {
+			Namespace   string            `json:"namespace"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(docs[1]), &db); err != nil {
+		t.Fatalf("parsing manifest: %v", err)
+	}
+	if db.Metadata.Namespace != "team-a" ||
+		db.Metadata.Annotations["k8s-secret-sync.weinbender.io/provider-name"] != "fake" ||
+		db.Metadata.Annotations["k8s-secret-sync.weinbender.io/provider-ref"] != "op://vault/db/data" ||
+		db.Metadata.Annotations["k8s-secret-sync.weinbender.io/key-mapping"] != "password=.pass,username=.user" {
+		t.Errorf("db manifest = %+v", db.Metadata)
+	}
+	if !strings.Contains(docs[2], `.dockerconfigjson: '{}'`) {
+		t.Errorf("expected a placeholder for the typed secret, got:\n%s", docs[2])
+	}
+}
+
+func TestScaffoldErrors(t *testing.T) {
+	spec := `
+secrets:
+  - name: unknown-provider
+    provider: nope
+    ref: x
+  - name: no-ref
+  - name: both
+    ref: x
+    key: a
+    transform: dotenv
+  - name: bad-transform
+    ref: x
+    transform: nope
+  - name: fine
+    ref: x
+`
+	_, err := Scaffold([]byte(spec), config.New(nil).Annotations, ScaffoldSpec{Provider: "fake"})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	for _, name := range []string{"unknown-provider", "no-ref", "both", "bad-transform"} {
+		if !strings.Contains(err.Error(), "("+name+")") {
+			t.Errorf("expected %s to be reported, got %v", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "(fine)") {
+		t.Errorf("valid secret reported as invalid: %v", err)
+	}
+}
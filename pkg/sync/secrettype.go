@@ -0,0 +1,36 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// requiredKeys lists, for the built-in secret types, data keys of which at least one
+// must be present. The API server rejects writes that leave them out, so they are
+// checked up front for a clearer status message.
+var requiredKeys = map[v1.SecretType][][]string{
+	v1.SecretTypeSSHAuth:          {{v1.SSHAuthPrivateKey}},
+	v1.SecretTypeBasicAuth:        {{v1.BasicAuthUsernameKey, v1.BasicAuthPasswordKey}},
+	v1.SecretTypeTLS:              {{v1.TLSCertKey}, {v1.TLSPrivateKeyKey}},
+	v1.SecretTypeDockerConfigJson: {{v1.DockerConfigJsonKey}},
+}
+
+// checkSecretType checks that a secret written with data still has the keys its type
+// requires. Secret types cannot be changed, so a mismatch means the rendered value does
+// not suit the secret.
+func checkSecretType(secret *v1.Secret, data map[string][]byte) error {
+	for _, keys := range requiredKeys[secret.Type] {
+		found := false
+		for _, key := range keys {
+			_, inData := data[key]
+			_, inSecret := secret.Data[key]
+			found = found || inData || inSecret
+		}
+		if !found {
+			return fmt.Errorf("secrets of type %s need the %s key", secret.Type, strings.Join(keys, " or "))
+		}
+	}
+	return nil
+}
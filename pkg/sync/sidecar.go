@@ -0,0 +1,185 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// serviceAccountNamespaceFile holds the namespace of the pod the operator runs in.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Files and directories the sidecar writes are only readable by the user it runs
+// as, since the volume may be shared with containers that shouldn't see them.
+const (
+	sidecarFileMode = 0o600
+	sidecarDirMode  = 0o700
+)
+
+// reloadCommandTimeout bounds how long the sidecar reload command may run.
+const reloadCommandTimeout = 30 * time.Second
+
+// sidecar writes the values of annotated secrets to files instead of into the
+// secrets themselves, for teams who want file-based delivery to a pod (e.g. via
+// a shared emptyDir) without the CSI driver. Each secret's data keys are written
+// to "<dir>/<secret name>/<key>", and only the pod's own namespace is watched.
+// Whole config files can be rendered with the template annotations, and the app
+// told to reload them when they change.
+type sidecar struct {
+	c   *controller
+	dir string
+}
+
+// sidecarNamespace returns the namespace watched in sidecar mode: the configured
+// one, or else the namespace of the pod the operator is running in.
+func sidecarNamespace(configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	namespace, err := os.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		return "", fmt.Errorf("determining pod namespace (set KSS_NAMESPACE outside a pod): %w", err)
+	}
+	return strings.TrimSpace(string(namespace)), nil
+}
+
+// syncFiles resolves the value of an annotated secret and writes its data to files,
+// removing files for keys no longer produced. Files are only rewritten when their
+// contents change, and each is replaced atomically.
+func (s *sidecar) syncFiles(ctx context.Context, secret *v1.Secret) error {
+	cfg := s.c.cfg
+	secret, err := s.c.expandRefConfig(ctx, secret)
+	if err != nil {
+		return err
+	}
+	providerName := secret.Annotations[cfg.Annotations.ProviderName]
+	secretID := secret.Annotations[cfg.Annotations.ProviderRef]
+	if providerName == "" || secretID == "" {
+		return nil
+	}
+	if wait := s.c.untilRefresh(secret); wait > 0 {
+		s.c.queue.AddAfter(secret.Namespace+"/"+secret.Name, wait)
+		return nil
+	}
+
+	secretDataKey := cfg.DefaultSecretDataKey
+	if key := secret.Annotations[cfg.Annotations.SecretKey]; key != "" {
+		secretDataKey = key
+	}
+	req, err := s.c.providerRequest(secret, providerName, secretID)
+	if err != nil {
+		return err
+	}
+	secretID = req.Ref
+	resp, err := s.c.resolve(ctx, providerName, req)
+	if err != nil {
+		return fmt.Errorf("resolving %q: %w", secretID, err)
+	}
+	data, err := s.c.render(ctx, secret, secretDataKey, string(resp.Value), func(ref string) (string, error) {
+		resp, err := s.c.resolve(ctx, providerName, provider.Request{Ref: ref, Metadata: req.Metadata, Namespace: req.Namespace})
+		return string(resp.Value), err
+	})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(s.dir, secret.Name)
+	if err := os.MkdirAll(dir, sidecarDirMode); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, sidecarDirMode); err != nil {
+		return err
+	}
+	changed := false
+	for key, contents := range data {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return fmt.Errorf("invalid file name %q: %s", key, strings.Join(errs, "; "))
+		}
+		written, err := writeFileIfChanged(filepath.Join(dir, key), contents)
+		if err != nil {
+			return err
+		}
+		changed = changed || written
+	}
+
+	// Remove files written for keys that are no longer produced
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, ok := data[entry.Name()]; !ok && !entry.IsDir() {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+			changed = true
+		}
+	}
+
+	if changed {
+		klog.InfoS("Wrote secret values to files", "namespace", secret.Namespace, "name", secret.Name, "dir", dir)
+		s.reload(ctx)
+	}
+	s.c.scheduleRefresh(secret)
+	return nil
+}
+
+// reload tells the app that its files changed, by running the configured command
+// and signalling the configured process, so apps that only read configuration at
+// startup pick up new values. Failures are logged rather than retried, since the
+// files have already been written.
+func (s *sidecar) reload(ctx context.Context) {
+	cfg := s.c.cfg
+	if cfg.SidecarReloadCommand != "" {
+		ctx, cancel := context.WithTimeout(ctx, reloadCommandTimeout)
+		defer cancel()
+		output, err := exec.CommandContext(ctx, "sh", "-c", cfg.SidecarReloadCommand).CombinedOutput()
+		if err != nil {
+			klog.ErrorS(err, "Reload command failed", "command", cfg.SidecarReloadCommand, "output", string(output))
+		}
+	}
+	if cfg.SidecarReloadProcess != "" {
+		signaled, err := signalProcesses(cfg.SidecarReloadProcess, cfg.SidecarReloadSignal)
+		if err != nil {
+			klog.ErrorS(err, "Failed to signal process", "process", cfg.SidecarReloadProcess, "signal", cfg.SidecarReloadSignal)
+		} else if signaled == 0 {
+			klog.InfoS("No process to signal found; is shareProcessNamespace enabled for the pod?", "process", cfg.SidecarReloadProcess)
+		}
+	}
+}
+
+// writeFileIfChanged replaces the file at path with contents, unless it already
+// holds them. The new file is written alongside and renamed into place, so readers
+// never see a partial value.
+func writeFileIfChanged(path string, contents []byte) (bool, error) {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, contents) {
+		return false, nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Chmod(sidecarFileMode); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp.Name(), path)
+}
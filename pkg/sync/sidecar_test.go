@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSidecarWritesFiles(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"fake://ref": `{"user":"admin","pass":"hunter2"}`}}
+	secret := annotatedSecret(map[string]string{"k8s-secret-sync.weinbender.io/key-mapping": "username=.user,password=.pass"})
+	c, cs := newTestController(t, p, secret)
+	dir := t.TempDir()
+	reloads := filepath.Join(t.TempDir(), "reloads")
+	c.cfg.SidecarReloadCommand = "echo reload >> " + reloads
+	c.syncFunc = (&sidecar{c: c, dir: dir}).syncFiles
+	ctx := context.Background()
+	reloaded := func() int {
+		contents, _ := os.ReadFile(reloads)
+		return strings.Count(string(contents), "reload")
+	}
+
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if got := reloaded(); got != 1 {
+		t.Errorf("reload command ran %d times, want 1", got)
+	}
+	for key, want := range map[string]string{"username": "admin", "password": "hunter2"} {
+		got, err := os.ReadFile(filepath.Join(dir, "example", key))
+		if err != nil {
+			t.Fatalf("reading %s: %v", key, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+		if info, err := os.Stat(filepath.Join(dir, "example", key)); err != nil {
+			t.Fatalf("stat %s: %v", key, err)
+		} else if info.Mode().Perm() != sidecarFileMode {
+			t.Errorf("%s mode = %v, want %v", key, info.Mode().Perm(), os.FileMode(sidecarFileMode))
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, "example")); err != nil {
+		t.Fatalf("stat directory: %v", err)
+	} else if info.Mode().Perm() != sidecarDirMode {
+		t.Errorf("directory mode = %v, want %v", info.Mode().Perm(), os.FileMode(sidecarDirMode))
+	}
+	if _, ok := getSecret(t, cs).Data["username"]; ok {
+		t.Errorf("expected the secret itself to be left untouched")
+	}
+
+	// Unchanged values don't trigger a reload
+	c.checked = map[string]time.Time{}
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if got := reloaded(); got != 1 {
+		t.Errorf("reload command ran %d times after unchanged refresh, want 1", got)
+	}
+
+	// Keys no longer produced are removed on refresh
+	secret.Annotations["k8s-secret-sync.weinbender.io/key-mapping"] = "password=.pass"
+	if err := c.store.Update(secret); err != nil {
+		t.Fatalf("updating store: %v", err)
+	}
+	c.checked = map[string]time.Time{}
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "example"))
+	if err != nil {
+		t.Fatalf("reading dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "password" {
+		t.Errorf("expected only the password file to remain, got %v", entries)
+	}
+}
@@ -0,0 +1,45 @@
+package sync
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// maxSecretSize is the limit the API server enforces on the total size of a
+// Secret's data values.
+const maxSecretSize = 1 << 20
+
+// patchedData returns the size of the secret's data values and its number of keys
+// once patch (data key to value, or nil to remove it) has been applied.
+func patchedData(secret *v1.Secret, patch map[string]any) (size, keys int) {
+	for key, value := range secret.Data {
+		if _, ok := patch[key]; !ok {
+			size += len(value)
+			keys++
+		}
+	}
+	for _, value := range patch {
+		if value, ok := value.([]byte); ok {
+			size += len(value)
+			keys++
+		}
+	}
+	return size, keys
+}
+
+// checkSize returns an error if applying patch would take the secret over the
+// Kubernetes size limit or the configured key limit, so the failure is reported
+// clearly rather than as an opaque API error. It also reports whether the result
+// is close enough to the size limit to warrant a warning.
+func (c *controller) checkSize(secret *v1.Secret, patch map[string]any) (nearLimit bool, err error) {
+	size, keys := patchedData(secret, patch)
+	if size > maxSecretSize {
+		return false, fmt.Errorf("secret data would be %d bytes, over the %d byte limit", size, maxSecretSize)
+	}
+	if limit := c.cfg.MaxSecretKeys; limit > 0 && keys > limit {
+		return false, fmt.Errorf("secret would have %d data keys, over the limit of %d", keys, limit)
+	}
+	percent := c.cfg.SizeWarningPercent
+	return percent > 0 && size*100 >= maxSecretSize*percent, nil
+}
@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/logging"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Reasons a secret is skipped, as counted in kss_secrets_skipped_total.
+const (
+	skipUnannotated     = "unannotated"
+	skipProtected       = "protected_namespace"
+	skipCertManager     = "cert_manager"
+	skipNoRef           = "no_ref"
+	skipDependencyCycle = "dependency_cycle"
+	skipUnknownProvider = "unknown_provider"
+)
+
+// skipVerbosity is the sync verbosity skips that are not sampled are logged at.
+const skipVerbosity = 4
+
+// logSkip counts a secret skipped for reason and logs msg according to the skip
+// logging mode: "all" logs every skip, "debug" logs them only at sync verbosity 4, and
+// the default samples them, logging each secret's reason once per skip log interval
+// and the rest at verbosity 4. Secrets without the provider annotation are most of a
+// cluster, so they are only logged at verbosity 4 unless every skip is logged.
+func (c *controller) logSkip(secret *v1.Secret, reason, msg string, keysAndValues ...any) {
+	metrics.SecretsSkipped.WithLabelValues(reason).Inc()
+	keysAndValues = append([]any{"namespace", secret.Namespace, "name", secret.Name}, keysAndValues...)
+
+	if c.cfg.SkipLogging == "all" || (c.cfg.SkipLogging != "debug" && reason != skipUnannotated && c.sampleSkip(secret, reason)) {
+		klog.InfoS(msg, keysAndValues...)
+		return
+	}
+	logging.V(logging.Sync, skipVerbosity).InfoS(msg, keysAndValues...)
+}
+
+// sampleSkip reports whether a secret skipped for reason has not been logged within
+// the skip log interval, recording that it is being logged now.
+func (c *controller) sampleSkip(secret *v1.Secret, reason string) bool {
+	key := secret.Namespace + "/" + secret.Name + "\x00" + reason
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.skipped[key]; ok && now.Sub(last) < time.Duration(c.cfg.SkipLogInterval)*time.Second {
+		return false
+	}
+	c.skipped[key] = now
+	return true
+}
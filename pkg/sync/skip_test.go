@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLogSkipCountsAndSamples(t *testing.T) {
+	unannotated := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "plain"}}
+	c, _ := newTestController(t, &fakeProvider{}, unannotated)
+
+	before := testutil.ToFloat64(metrics.SecretsSkipped.WithLabelValues(skipUnannotated))
+	for range 3 {
+		if err := c.syncSecret(context.Background(), unannotated); err != nil {
+			t.Fatalf("syncSecret: %v", err)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.SecretsSkipped.WithLabelValues(skipUnannotated)) - before; got != 3 {
+		t.Errorf("skipped unannotated = %v, want 3", got)
+	}
+	if len(c.skipped) != 0 {
+		t.Errorf("unannotated secrets were sampled: %v", c.skipped)
+	}
+
+	secret := annotatedSecret(nil)
+	if !c.sampleSkip(secret, skipNoRef) {
+		t.Error("first skip was not logged")
+	}
+	if c.sampleSkip(secret, skipNoRef) {
+		t.Error("repeated skip within the interval was logged")
+	}
+	if !c.sampleSkip(secret, skipProtected) {
+		t.Error("skip for another reason was not logged")
+	}
+	c.cfg.SkipLogInterval = 0
+	if !c.sampleSkip(secret, skipNoRef) {
+		t.Error("skip was not logged with sampling disabled")
+	}
+}
@@ -0,0 +1,55 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Annotations written by the operator to report the outcome of the last sync.
+const (
+	statusAnnotation        = "k8s-secret-sync.weinbender.io/status"
+	statusMessageAnnotation = "k8s-secret-sync.weinbender.io/status-message"
+)
+
+// Values of the status annotation.
+const (
+	StatusSynced   = "Synced"
+	StatusNotFound = "NotFound"
+	StatusFailed   = "Failed"
+	StatusPending  = "Pending"
+)
+
+// setStatus patches only the status annotations of a secret, for outcomes that
+// do not otherwise write to it.
+func setStatus(ctx context.Context, cs kubernetes.Interface, secret *v1.Secret, status, message string) error {
+	return patchAnnotations(ctx, cs, secret, map[string]string{
+		statusAnnotation:        status,
+		statusMessageAnnotation: message,
+	})
+}
+
+// patchAnnotations patches only the given annotations of a secret.
+func patchAnnotations(ctx context.Context, cs kubernetes.Interface, secret *v1.Secret, annotations map[string]string) error {
+	patch := map[string]any{
+		"metadata": map[string]any{
+			"annotations": annotations,
+		},
+	}
+	payloadBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = cs.CoreV1().Secrets(secret.Namespace).Patch(
+		ctx,
+		secret.Name,
+		types.StrategicMergePatchType,
+		payloadBytes,
+		metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// summaryTotalKey is the summary ConfigMap key holding counts across all namespaces.
+const summaryTotalKey = "_total"
+
+// statusCounts counts managed secrets by the outcome of their last sync.
+type statusCounts struct {
+	Managed int `json:"managed"`
+	Healthy int `json:"healthy"`
+	Failing int `json:"failing"`
+	Pending int `json:"pending"`
+}
+
+// add counts a managed secret with the given status annotation.
+func (s *statusCounts) add(status string) {
+	s.Managed++
+	switch status {
+	case StatusSynced:
+		s.Healthy++
+	case StatusFailed, StatusNotFound:
+		s.Failing++
+	default:
+		s.Pending++
+	}
+}
+
+// summarizer periodically writes per-namespace counts of managed secrets to a
+// ConfigMap, giving platform teams fleet-wide visibility without scraping metrics.
+type summarizer struct {
+	cfg       *config.Sync
+	store     toolscache.Store
+	namespace string
+	name      string
+}
+
+func newSummarizer(cfg *config.Sync, store toolscache.Store) (*summarizer, error) {
+	namespace, name, ok := strings.Cut(cfg.SummaryConfigMap, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("invalid summary ConfigMap %q, expected namespace/name", cfg.SummaryConfigMap)
+	}
+	return &summarizer{cfg: cfg, store: store, namespace: namespace, name: name}, nil
+}
+
+// run updates the summary every interval until ctx is cancelled.
+func (s *summarizer) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.update(ctx); err != nil {
+				klog.ErrorS(err, "Failed to update sync summary", "namespace", s.namespace, "name", s.name)
+			}
+		}
+	}
+}
+
+// summarize counts the managed secrets in the store by namespace.
+func (s *summarizer) summarize() map[string]*statusCounts {
+	counts := map[string]*statusCounts{summaryTotalKey: {}}
+	for _, obj := range s.store.List() {
+		secret, ok := obj.(*v1.Secret)
+		if !ok || secret.Annotations[s.cfg.Annotations.ProviderName] == "" {
+			continue
+		}
+		if counts[secret.Namespace] == nil {
+			counts[secret.Namespace] = &statusCounts{}
+		}
+		status := secret.Annotations[statusAnnotation]
+		counts[secret.Namespace].add(status)
+		counts[summaryTotalKey].add(status)
+	}
+	return counts
+}
+
+// update writes the current summary to the ConfigMap, creating it if needed.
+func (s *summarizer) update(ctx context.Context) error {
+	data := make(map[string]string)
+	for namespace, counts := range s.summarize() {
+		encoded, err := json.Marshal(counts)
+		if err != nil {
+			return err
+		}
+		data[namespace] = string(encoded)
+	}
+
+	configMaps := s.cfg.Clientset.CoreV1().ConfigMaps(s.namespace)
+	existing, err := configMaps.Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: s.namespace, Name: s.name},
+			Data:       data,
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	existing.Data = data
+	_, err = configMaps.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func TestSummarizerUpdate(t *testing.T) {
+	cs := fake.NewSimpleClientset()
+	cfg := config.New(cs)
+	cfg.SummaryConfigMap = "kss-system/sync-summary"
+
+	store := toolscache.NewStore(toolscache.MetaNamespaceKeyFunc)
+	secret := func(namespace, name, status string) *v1.Secret {
+		annotations := map[string]string{"k8s-secret-sync.weinbender.io/provider-name": "fake"}
+		if status != "" {
+			annotations[statusAnnotation] = status
+		}
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: annotations}}
+	}
+	for _, s := range []*v1.Secret{
+		secret("a", "synced", StatusSynced),
+		secret("a", "failed", StatusFailed),
+		secret("b", "new", ""),
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "b", Name: "unmanaged"}},
+	} {
+		if err := store.Add(s); err != nil {
+			t.Fatalf("adding secret: %v", err)
+		}
+	}
+
+	s, err := newSummarizer(cfg, store)
+	if err != nil {
+		t.Fatalf("newSummarizer: %v", err)
+	}
+	// Run twice to cover both creating and updating the ConfigMap
+	for range 2 {
+		if err := s.update(context.Background()); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	}
+
+	configMap, err := cs.CoreV1().ConfigMaps("kss-system").Get(context.Background(), "sync-summary", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting summary: %v", err)
+	}
+	want := map[string]statusCounts{
+		"a":             {Managed: 2, Healthy: 1, Failing: 1},
+		"b":             {Managed: 1, Pending: 1},
+		summaryTotalKey: {Managed: 3, Healthy: 1, Failing: 1, Pending: 1},
+	}
+	for key, counts := range want {
+		var got statusCounts
+		if err := json.Unmarshal([]byte(configMap.Data[key]), &got); err != nil {
+			t.Fatalf("decoding %q: %v", key, err)
+		}
+		if got != counts {
+			t.Errorf("%s = %+v, want %+v", key, got, counts)
+		}
+	}
+}
+
+func TestNewSummarizerInvalid(t *testing.T) {
+	cfg := config.New(fake.NewSimpleClientset())
+	cfg.SummaryConfigMap = "no-namespace"
+	if _, err := newSummarizer(cfg, toolscache.NewStore(toolscache.MetaNamespaceKeyFunc)); err == nil {
+		t.Errorf("expected error for summary ConfigMap without a namespace")
+	}
+}
@@ -0,0 +1,35 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// tornDown reports whether a sync of secret failed with err because the secret or its
+// namespace is being deleted, in which case retrying or alerting on it is pointless.
+// It is only checked once a sync has failed, so healthy syncs cost no extra requests.
+func (c *controller) tornDown(ctx context.Context, secret *v1.Secret, err error) bool {
+	if apierrors.HasStatusCause(err, v1.NamespaceTerminatingCause) {
+		return true
+	}
+
+	namespace, nsErr := c.cfg.Clientset.CoreV1().Namespaces().Get(ctx, secret.Namespace, metav1.GetOptions{})
+	if nsErr == nil && (namespace.DeletionTimestamp != nil || namespace.Status.Phase == v1.NamespaceTerminating) {
+		return true
+	}
+	if nsErr != nil && !apierrors.IsNotFound(nsErr) {
+		logging.V(logging.Sync, 2).InfoS("Failed to check whether namespace is terminating", "namespace", secret.Namespace, "err", nsErr)
+	}
+
+	// A secret deleted while it was being synced, or with its namespace, fails with
+	// NotFound
+	if apierrors.IsNotFound(err) {
+		_, getErr := c.cfg.Clientset.CoreV1().Secrets(secret.Namespace).Get(ctx, secret.Name, metav1.GetOptions{})
+		return apierrors.IsNotFound(getErr)
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileDropsSecretsBeingDeleted(t *testing.T) {
+	ctx := context.Background()
+
+	// A failure in a terminating namespace is dropped rather than retried
+	c, cs := newTestController(t, &fakeProvider{err: errors.New("boom")}, annotatedSecret(nil))
+	if err := c.reconcile(ctx, "default/example"); err == nil {
+		t.Fatal("expected error from reconcile in an active namespace")
+	}
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}, Status: v1.NamespaceStatus{Phase: v1.NamespaceTerminating}}
+	if _, err := cs.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Errorf("reconcile in a terminating namespace = %v, want nil", err)
+	}
+
+	// So is a write to a secret deleted since it was queued
+	c, cs = newTestController(t, &fakeProvider{values: map[string]string{"fake://ref": "s3cr3t"}}, annotatedSecret(nil))
+	if err := cs.CoreV1().Secrets("default").Delete(ctx, "example", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.reconcile(ctx, "default/example"); err != nil {
+		t.Errorf("reconcile of a deleted secret = %v, want nil", err)
+	}
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+// throttleRecovery is how long the API server must go without throttling the
+// operator before the refresh slowdown is halved.
+const throttleRecovery = time.Minute
+
+// apiThrottle adapts how often secrets are refreshed to API server load. Each time
+// the API server throttles the operator (429 Too Many Requests, e.g. from priority
+// and fairness), refreshes are slowed by a factor of two, up to max; the slowdown
+// then halves for every throttleRecovery without further throttling.
+type apiThrottle struct {
+	max int
+	now func() time.Time
+
+	mu       gosync.Mutex
+	factor   int
+	lastSeen time.Time
+}
+
+func newAPIThrottle(max int) *apiThrottle {
+	return &apiThrottle{max: max, now: time.Now, factor: 1}
+}
+
+// observe slows refreshes if err shows the API server throttling the operator.
+func (t *apiThrottle) observe(err error) {
+	if !apierrors.IsTooManyRequests(err) || t.max <= 1 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	factor := min(t.current()*2, t.max)
+	if factor != t.factor {
+		klog.InfoS("API server is throttling requests, slowing refreshes", "slowdown", factor)
+	}
+	t.factor = factor
+	t.lastSeen = t.now()
+	metrics.RefreshSlowdown.Set(float64(factor))
+}
+
+// slowdown returns the factor refresh intervals are currently multiplied by.
+func (t *apiThrottle) slowdown() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	factor := t.current()
+	metrics.RefreshSlowdown.Set(float64(factor))
+	return factor
+}
+
+// current returns the slowdown after recovery since throttling was last seen.
+// The caller must hold t.mu.
+func (t *apiThrottle) current() int {
+	if t.factor <= 1 {
+		return 1
+	}
+	halvings := int(t.now().Sub(t.lastSeen) / throttleRecovery)
+	if halvings >= 31 {
+		return 1
+	}
+	return max(t.factor>>halvings, 1)
+}
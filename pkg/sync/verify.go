@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"slices"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// Annotations written by the operator to record which data keys it manages and
+// what their contents hashed to when last written.
+const (
+	managedKeysAnnotation = "k8s-secret-sync.weinbender.io/managed-keys"
+	dataHashAnnotation    = "k8s-secret-sync.weinbender.io/data-sha256"
+)
+
+// dataHash returns an HMAC-SHA256 over the given keys of data, keyed with the
+// operator's hash key so that recorded hashes cannot be used to guess values offline.
+// Keys are hashed in sorted order, and missing keys hash differently from empty ones.
+func dataHash(hashKey []byte, data map[string][]byte, keys []string) string {
+	return writeData(hmac.New(sha256.New, hashKey), data, keys)
+}
+
+// legacyDataHash is the unkeyed SHA-256 recorded as the data hash before hashes were
+// keyed, which secrets last synced by earlier versions carry until they are
+// re-baselined by rekeyLegacyHashes.
+func legacyDataHash(data map[string][]byte, keys []string) string {
+	return writeData(sha256.New(), data, keys)
+}
+
+// writeData writes the given keys of data to h and returns the hex sum.
+func writeData(h hash.Hash, data map[string][]byte, keys []string) string {
+	sorted := slices.Clone(keys)
+	slices.Sort(sorted)
+
+	for _, key := range sorted {
+		h.Write([]byte(key))
+		if value, ok := data[key]; ok {
+			h.Write([]byte{1})
+			h.Write(value)
+		} else {
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// rekeyHashes returns the annotation holding the hash of a secret's managed data as
+// written and the keyed hash of the same data, if the recorded hash is one recorded
+// before hashes were keyed, so upgrading is not mistaken for an edit in the cluster.
+func rekeyHashes(hashKey []byte, secret *v1.Secret) (annotation, rekeyed string, ok bool) {
+	annotation = dataHashAnnotation
+	if secret.Annotations[encryptedHashAnnotation] != "" {
+		annotation = encryptedHashAnnotation
+	}
+	keys := managedKeys(secret)
+	recorded := secret.Annotations[annotation]
+	if recorded == "" || len(keys) == 0 || recorded != legacyDataHash(secret.Data, keys) {
+		return "", "", false
+	}
+	return annotation, dataHash(hashKey, secret.Data, keys), true
+}
+
+// managedKeys returns the data keys recorded as managed by the operator.
+func managedKeys(secret *v1.Secret) []string {
+	value := secret.Annotations[managedKeysAnnotation]
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// verifier periodically checks that managed data keys still match the hash recorded
+// when they were last synced, warning when they have been modified out-of-band.
+type verifier struct {
+	store    toolscache.Store
+	recorder record.EventRecorder
+	hashKey  []byte
+
+	// reported tracks the last mismatching hash warned about per secret, so a
+	// single modification is reported once rather than on every pass.
+	mu       gosync.Mutex
+	reported map[string]string
+}
+
+func newVerifier(store toolscache.Store, recorder record.EventRecorder, hashKey []byte) *verifier {
+	return &verifier{
+		store:    store,
+		recorder: recorder,
+		hashKey:  hashKey,
+		reported: make(map[string]string),
+	}
+}
+
+// forget drops what was reported about a deleted secret.
+func (v *verifier) forget(namespace, name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.reported, namespace+"/"+name)
+}
+
+// run verifies all managed secrets every interval until ctx is cancelled.
+func (v *verifier) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, obj := range v.store.List() {
+				if secret, ok := obj.(*v1.Secret); ok {
+					v.verify(secret)
+				}
+			}
+		}
+	}
+}
+
+// verify checks a single secret, returning false if its managed data was modified.
+func (v *verifier) verify(secret *v1.Secret) bool {
+	recorded, ok := secret.Annotations[dataHashAnnotation]
+	if encrypted := secret.Annotations[encryptedHashAnnotation]; encrypted != "" {
+		recorded = encrypted
+	}
+	keys := managedKeys(secret)
+	if !ok || len(keys) == 0 {
+		return true
+	}
+
+	id := secret.Namespace + "/" + secret.Name
+	current := dataHash(v.hashKey, secret.Data, keys)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if current == recorded {
+		delete(v.reported, id)
+		return true
+	}
+	if v.reported[id] == current {
+		return false
+	}
+	v.reported[id] = current
+
+	klog.InfoS("Managed secret data was modified outside of the operator", "namespace", secret.Namespace, "name", secret.Name, "keys", keys)
+	v.recorder.Eventf(secret, v1.EventTypeWarning, "ManagedDataModified",
+		"Managed keys %s no longer match the last synced value", strings.Join(keys, ","))
+	metrics.RecordTamper(secret.Namespace, secret.Name)
+	return false
+}
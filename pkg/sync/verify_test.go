@@ -0,0 +1,134 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+var testHashKey = []byte("test-hash-key")
+
+func TestDataHash(t *testing.T) {
+	data := map[string][]byte{"a": []byte("1"), "b": []byte("2")}
+
+	if dataHash(testHashKey, data, []string{"a", "b"}) != dataHash(testHashKey, data, []string{"b", "a"}) {
+		t.Errorf("expected hash to be independent of key order")
+	}
+	if dataHash(testHashKey, data, []string{"a"}) == dataHash(testHashKey, data, []string{"a", "b"}) {
+		t.Errorf("expected hash to depend on the managed key set")
+	}
+	empty := map[string][]byte{"a": {}}
+	if dataHash(testHashKey, empty, []string{"a"}) == dataHash(testHashKey, map[string][]byte{}, []string{"a"}) {
+		t.Errorf("expected missing and empty keys to hash differently")
+	}
+	// Without the key the hash cannot be recomputed from a guessed value
+	if h := dataHash(testHashKey, data, []string{"a"}); h == dataHash([]byte("other-key"), data, []string{"a"}) || h == legacyDataHash(data, []string{"a"}) {
+		t.Errorf("expected hash to depend on the hash key")
+	}
+}
+
+func TestRekeyHashes(t *testing.T) {
+	secret := managedSecret("original")
+	secret.Annotations[dataHashAnnotation] = legacyDataHash(secret.Data, []string{"value"})
+	if annotation, hash, ok := rekeyHashes(testHashKey, secret); !ok || annotation != dataHashAnnotation || hash != dataHash(testHashKey, secret.Data, []string{"value"}) {
+		t.Errorf("rekeyHashes = %q, %q, %v; want the keyed hash of the same data", annotation, hash, ok)
+	}
+	if _, _, ok := rekeyHashes(testHashKey, managedSecret("tampered")); ok {
+		t.Errorf("expected a secret whose data no longer matches its hash to be left alone")
+	}
+
+	// Outside of re-baselining, an unkeyed hash is no better than a wrong one
+	if !clusterModified(testHashKey, secret) || newVerifier(toolscache.NewStore(toolscache.MetaNamespaceKeyFunc), record.NewFakeRecorder(1), testHashKey).verify(secret) {
+		t.Errorf("expected a secret carrying an unkeyed hash to count as modified")
+	}
+}
+
+func TestRekeyLegacyHashes(t *testing.T) {
+	legacy := managedSecret("original")
+	legacy.Annotations[dataHashAnnotation] = legacyDataHash(legacy.Data, []string{"value"})
+	c, cs := newTestController(t, &fakeProvider{}, legacy)
+	c.cfg.HashKeySecret = "kss/hash-key"
+	ctx := context.Background()
+	if _, err := loadHashKey(ctx, c.cfg); err != nil {
+		t.Fatalf("loadHashKey: %v", err)
+	}
+
+	if err := c.rekeyLegacyHashes(ctx); err != nil {
+		t.Fatalf("rekeyLegacyHashes: %v", err)
+	}
+	rekeyed := getSecret(t, cs)
+	if got, want := rekeyed.Annotations[dataHashAnnotation], dataHash(testHashKey, legacy.Data, []string{"value"}); got != want {
+		t.Errorf("data hash = %q, want the keyed hash %q", got, want)
+	}
+	if obj, _, _ := c.store.GetByKey("default/example"); clusterModified(c.hashKey, obj.(*v1.Secret)) {
+		t.Errorf("expected the store to hold the re-baselined secret")
+	}
+
+	// Once done, unkeyed hashes forged afterwards are not re-baselined
+	forged := rekeyed.DeepCopy()
+	forged.Data["value"] = []byte("forged")
+	forged.Annotations[dataHashAnnotation] = legacyDataHash(forged.Data, []string{"value"})
+	if err := c.store.Update(forged); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.rekeyLegacyHashes(ctx); err != nil {
+		t.Fatalf("rekeyLegacyHashes again: %v", err)
+	}
+	if obj, _, _ := c.store.GetByKey("default/example"); !clusterModified(c.hashKey, obj.(*v1.Secret)) {
+		t.Errorf("expected a forged unkeyed hash to be detected as a modification")
+	}
+}
+
+func managedSecret(value string) *v1.Secret {
+	synced := map[string][]byte{"value": []byte("original")}
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "example",
+			Annotations: map[string]string{
+				managedKeysAnnotation: "value",
+				dataHashAnnotation:    dataHash(testHashKey, synced, []string{"value"}),
+			},
+		},
+		Data: map[string][]byte{"value": []byte(value)},
+	}
+}
+
+func TestVerifierDetectsModification(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	v := newVerifier(toolscache.NewStore(toolscache.MetaNamespaceKeyFunc), recorder, testHashKey)
+
+	if !v.verify(managedSecret("original")) {
+		t.Fatalf("expected unmodified secret to verify")
+	}
+	if len(recorder.Events) != 0 {
+		t.Fatalf("expected no events for unmodified secret")
+	}
+
+	if v.verify(managedSecret("tampered")) {
+		t.Fatalf("expected modified secret to fail verification")
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected one warning event, got %d", len(recorder.Events))
+	}
+
+	// The same modification is only reported once
+	v.verify(managedSecret("tampered"))
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected modification to be reported once, got %d events", len(recorder.Events))
+	}
+}
+
+func TestVerifierIgnoresUnmanagedSecrets(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	v := newVerifier(toolscache.NewStore(toolscache.MetaNamespaceKeyFunc), recorder, testHashKey)
+
+	secret := &v1.Secret{Data: map[string][]byte{"value": []byte("x")}}
+	if !v.verify(secret) {
+		t.Errorf("expected unmanaged secret to be ignored")
+	}
+}
@@ -0,0 +1,54 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Data keys written by the basic-auth transformation, matching kubernetes.io/basic-auth
+// secrets.
+const (
+	UsernameKey = "username"
+	PasswordKey = "password"
+)
+
+// BasicAuth writes a username and password to the username and password keys, as
+// expected by kubernetes.io/basic-auth secrets. The value is either a JSON object with
+// "username" and "password" fields, such as a single provider item, or the username and
+// the password on two lines, such as a username ref with the password as an additional
+// ref.
+func BasicAuth(value string) (map[string][]byte, error) {
+	username, password, err := parseCredentials(value)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		UsernameKey: []byte(username),
+		PasswordKey: []byte(password),
+	}, nil
+}
+
+// parseCredentials returns the username and password in a value accepted by BasicAuth.
+func parseCredentials(value string) (string, string, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		var fields struct {
+			Username *string `json:"username"`
+			Password *string `json:"password"`
+		}
+		if err := json.Unmarshal([]byte(value), &fields); err != nil {
+			return "", "", fmt.Errorf("parsing credentials: %w", err)
+		}
+		if fields.Username == nil || fields.Password == nil {
+			return "", "", errors.New(`credentials need "username" and "password" fields`)
+		}
+		return *fields.Username, *fields.Password, nil
+	}
+
+	lines := strings.Split(strings.TrimRight(value, "\n"), "\n")
+	if len(lines) != 2 {
+		return "", "", fmt.Errorf("credentials need a username and a password on two lines, got %d lines", len(lines))
+	}
+	return strings.TrimSuffix(lines[0], "\r"), strings.TrimSuffix(lines[1], "\r"), nil
+}
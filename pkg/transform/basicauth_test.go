@@ -0,0 +1,25 @@
+package transform
+
+import "testing"
+
+func TestBasicAuth(t *testing.T) {
+	for _, value := range []string{
+		`{"username": "admin", "password": "hunter2", "url": "https://example.com"}`,
+		"admin\nhunter2\n",
+	} {
+		data, err := BasicAuth(value)
+		if err != nil {
+			t.Errorf("BasicAuth(%q): %v", value, err)
+			continue
+		}
+		if string(data[UsernameKey]) != "admin" || string(data[PasswordKey]) != "hunter2" {
+			t.Errorf("BasicAuth(%q) = %q, want admin/hunter2", value, data)
+		}
+	}
+
+	for _, value := range []string{`{"username": "admin"}`, "admin", "admin\nhunter2\nextra"} {
+		if _, err := BasicAuth(value); err == nil {
+			t.Errorf("BasicAuth(%q): expected error", value)
+		}
+	}
+}
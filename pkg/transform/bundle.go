@@ -0,0 +1,121 @@
+package transform
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// BundleFile is a file written into a bundle.
+type BundleFile struct {
+	Name    string // path of the file within the bundle
+	Ref     string // ref the content is resolved from; empty for the provider value
+	Content []byte
+}
+
+// bundleModTime is the modification time of every file in tar and zip bundles, so a
+// bundle of unchanged values is byte-for-byte the same and does not rewrite the Secret.
+// It is the earliest time zip can represent.
+var bundleModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ParseBundleFiles parses the files of a bundle, of the form
+// "client.properties,truststore.jks=op://vault/kafka/truststore": each file is named
+// by its path in the bundle, followed by the ref it is resolved from, or nothing to
+// hold the provider value.
+func ParseBundleFiles(spec string) ([]BundleFile, error) {
+	var files []BundleFile
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, ref, hasRef := strings.Cut(entry, "=")
+		name, ref = strings.TrimSpace(name), strings.TrimSpace(ref)
+		if !fs.ValidPath(name) || name == "." || (hasRef && ref == "") {
+			return nil, fmt.Errorf("invalid bundle file %q (expected path or path=ref)", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate file %q in bundle", name)
+		}
+		seen[name] = true
+		files = append(files, BundleFile{Name: name, Ref: ref})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("empty bundle")
+	}
+	return files, nil
+}
+
+// Bundle packs files into a single value in the given format: "tar", "tar.gz", or
+// "zip" archives holding each file under its name, or "concat", which joins the
+// contents in order, one after another on separate lines, for PEM bundles and the
+// like.
+func Bundle(format string, files []BundleFile) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "tar":
+		if err := writeTar(&buf, files); err != nil {
+			return nil, err
+		}
+	case "tar.gz":
+		gz := gzip.NewWriter(&buf)
+		if err := writeTar(gz, files); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+	case "zip":
+		zw := zip.NewWriter(&buf)
+		for _, file := range files {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: bundleModTime})
+			if err != nil {
+				return nil, err
+			}
+			if _, err := w.Write(file.Content); err != nil {
+				return nil, err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	case "concat":
+		for _, file := range files {
+			buf.Write(file.Content)
+			if len(file.Content) > 0 && !bytes.HasSuffix(file.Content, []byte("\n")) {
+				buf.WriteByte('\n')
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown bundle format %q (expected tar, tar.gz, zip, or concat)", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTar writes files to w as a tar archive.
+func writeTar(w io.Writer, files []BundleFile) error {
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Name,
+			Mode:     0o644,
+			Size:     int64(len(file.Content)),
+			ModTime:  bundleModTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.Content); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
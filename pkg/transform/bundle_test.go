@@ -0,0 +1,90 @@
+package transform
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+func TestParseBundleFiles(t *testing.T) {
+	files, err := ParseBundleFiles("client.properties, certs/truststore.jks=op://vault/kafka/truststore")
+	if err != nil {
+		t.Fatalf("ParseBundleFiles: %v", err)
+	}
+	if len(files) != 2 || files[0].Name != "client.properties" || files[0].Ref != "" ||
+		files[1].Name != "certs/truststore.jks" || files[1].Ref != "op://vault/kafka/truststore" {
+		t.Errorf("files = %+v", files)
+	}
+
+	for _, spec := range []string{"", "a,a=op://x", "../escape", "/abs", "a//b", ".", "a="} {
+		if _, err := ParseBundleFiles(spec); err == nil {
+			t.Errorf("ParseBundleFiles(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestBundle(t *testing.T) {
+	files := []BundleFile{
+		{Name: "client.properties", Content: []byte("security.protocol=SSL\n")},
+		{Name: "certs/truststore.jks", Content: []byte{0xfe, 0xed, 0xfe, 0xed}},
+	}
+
+	for _, format := range []string{"tar", "tar.gz"} {
+		packed, err := Bundle(format, files)
+		if err != nil {
+			t.Fatalf("Bundle(%s): %v", format, err)
+		}
+		var r io.Reader = bytes.NewReader(packed)
+		if format == "tar.gz" {
+			if r, err = gzip.NewReader(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tr := tar.NewReader(r)
+		for _, want := range files {
+			header, err := tr.Next()
+			if err != nil {
+				t.Fatalf("%s: reading %s: %v", format, want.Name, err)
+			}
+			content, _ := io.ReadAll(tr)
+			if header.Name != want.Name || !bytes.Equal(content, want.Content) {
+				t.Errorf("%s: file %q = %q, want %q = %q", format, header.Name, content, want.Name, want.Content)
+			}
+		}
+		again, _ := Bundle(format, files)
+		if !bytes.Equal(packed, again) {
+			t.Errorf("%s bundles of the same files differ", format)
+		}
+	}
+
+	packed, err := Bundle("zip", files)
+	if err != nil {
+		t.Fatalf("Bundle(zip): %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(packed), int64(len(packed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range zr.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		if f.Name != files[i].Name || !bytes.Equal(content, files[i].Content) {
+			t.Errorf("zip: file %q = %q", f.Name, content)
+		}
+	}
+	if again, _ := Bundle("zip", files); !bytes.Equal(packed, again) {
+		t.Error("zip bundles of the same files differ")
+	}
+
+	concat, err := Bundle("concat", []BundleFile{{Content: []byte("a")}, {Content: []byte("b\n")}})
+	if err != nil || string(concat) != "a\nb\n" {
+		t.Errorf("Bundle(concat) = %q, %v", concat, err)
+	}
+	if _, err := Bundle("rar", files); err == nil {
+		t.Error("Bundle(rar) succeeded, want error")
+	}
+}
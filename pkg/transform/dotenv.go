@@ -0,0 +1,93 @@
+package transform
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Dotenv parses a value in dotenv format ("KEY=value" lines) into one data key per
+// variable. Blank lines and "#" comments are ignored, an optional "export " prefix
+// is allowed, double-quoted values support \n, \t, \", and \\ escapes, and
+// single-quoted values are taken literally.
+func Dotenv(value string) (map[string][]byte, error) {
+	data := make(map[string][]byte)
+	scanner := bufio.NewScanner(strings.NewReader(value))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(value)+1)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNumber)
+		}
+		if err := validateKey(key); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		parsed, err := parseDotenvValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		data[key] = []byte(parsed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no variables found in dotenv value")
+	}
+	return data, nil
+}
+
+// parseDotenvValue unquotes a single dotenv value.
+func parseDotenvValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		var b strings.Builder
+		for i := 1; i < len(raw); i++ {
+			switch ch := raw[i]; ch {
+			case '"':
+				return b.String(), nil
+			case '\\':
+				if i+1 >= len(raw) {
+					return "", fmt.Errorf("unterminated escape sequence")
+				}
+				i++
+				switch raw[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case 'r':
+					b.WriteByte('\r')
+				default:
+					b.WriteByte(raw[i])
+				}
+			default:
+				b.WriteByte(ch)
+			}
+		}
+		return "", fmt.Errorf("unterminated double-quoted value")
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single-quoted value")
+		}
+		return raw[1 : end+1], nil
+	default:
+		// Unquoted values end at an inline comment
+		if i := strings.Index(raw, " #"); i >= 0 {
+			raw = raw[:i]
+		}
+		return strings.TrimSpace(raw), nil
+	}
+}
@@ -0,0 +1,55 @@
+package transform
+
+import "testing"
+
+func TestDotenv(t *testing.T) {
+	value := `# database settings
+DB_HOST=db.internal
+export DB_PORT=5432
+DB_PASSWORD="p@ss \"word\"\nline2"
+DB_USER='admin # not a comment'
+EMPTY=
+WITH_COMMENT=value # trailing comment
+`
+	data, err := Dotenv(value)
+	if err != nil {
+		t.Fatalf("Dotenv: %v", err)
+	}
+
+	want := map[string]string{
+		"DB_HOST":      "db.internal",
+		"DB_PORT":      "5432",
+		"DB_PASSWORD":  "p@ss \"word\"\nline2",
+		"DB_USER":      "admin # not a comment",
+		"EMPTY":        "",
+		"WITH_COMMENT": "value",
+	}
+	if len(data) != len(want) {
+		t.Errorf("got %d keys, want %d: %q", len(data), len(want), data)
+	}
+	for key, v := range want {
+		if got, ok := data[key]; !ok || string(got) != v {
+			t.Errorf("%s = %q, want %q", key, got, v)
+		}
+	}
+}
+
+func TestDotenvErrors(t *testing.T) {
+	cases := map[string]string{
+		"no equals":          "JUSTAKEY",
+		"invalid key":        "BAD KEY=value",
+		"unterminated quote": `KEY="value`,
+		"empty":              "# only a comment\n",
+	}
+	for name, value := range cases {
+		if _, err := Dotenv(value); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestApplyUnknownTransform(t *testing.T) {
+	if _, err := Apply("nope", "value"); err == nil {
+		t.Errorf("expected error for unknown transform")
+	}
+}
@@ -0,0 +1,53 @@
+package transform
+
+import (
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Encodings accepted for rendered template output.
+const (
+	// EncodingBytes writes the output as-is (the default).
+	EncodingBytes = "bytes"
+	// EncodingString requires the output to be valid UTF-8 text, like a Secret's
+	// stringData, so a binary provider value can't silently produce mangled text.
+	EncodingString = "string"
+	// EncodingBase64 decodes the output from base64, for templates producing
+	// binary data such as keystores.
+	EncodingBase64 = "base64"
+)
+
+// Decode converts rendered template output into Secret data according to encoding.
+func Decode(rendered []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", EncodingBytes:
+		return rendered, nil
+	case EncodingString:
+		if !utf8.Valid(rendered) {
+			return nil, fmt.Errorf("template output is not valid UTF-8 (first invalid byte at offset %d)", invalidUTF8Offset(rendered))
+		}
+		return rendered, nil
+	case EncodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(string(rendered))
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64 template output: %w", err)
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unknown template encoding %q (expected %s, %s, or %s)", encoding, EncodingBytes, EncodingString, EncodingBase64)
+	}
+}
+
+// invalidUTF8Offset returns the offset of the first byte of b that isn't part of a
+// valid UTF-8 sequence.
+func invalidUTF8Offset(b []byte) int {
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return i
+		}
+		i += size
+	}
+	return len(b)
+}
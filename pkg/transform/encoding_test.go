@@ -0,0 +1,47 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	// Multi-byte characters pass through a template unchanged
+	rendered, err := Template("greeting={{ .Fields.msg }}", `{"msg":"grüß dich ✓ 🔑"}`, nil)
+	if err != nil {
+		t.Fatalf("Template: %v", err)
+	}
+	for _, encoding := range []string{"", EncodingBytes, EncodingString} {
+		got, err := Decode(rendered, encoding)
+		if err != nil {
+			t.Fatalf("Decode(%q): %v", encoding, err)
+		}
+		if want := "greeting=grüß dich ✓ 🔑"; string(got) != want {
+			t.Errorf("Decode(%q) = %q, want %q", encoding, got, want)
+		}
+	}
+
+	got, err := Decode([]byte("AAEC/w=="), EncodingBase64)
+	if err != nil {
+		t.Fatalf("Decode(base64): %v", err)
+	}
+	if string(got) != "\x00\x01\x02\xff" {
+		t.Errorf("Decode(base64) = %q", got)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	_, err := Decode([]byte("ok ✓ \xff\xfe"), EncodingString)
+	if err == nil || !strings.Contains(err.Error(), "offset 7") {
+		t.Errorf("expected invalid UTF-8 error at offset 7, got %v", err)
+	}
+	if got, err := Decode([]byte("\xff"), EncodingBytes); err != nil || string(got) != "\xff" {
+		t.Errorf("expected raw bytes to pass through, got %q, %v", got, err)
+	}
+	if _, err := Decode([]byte("not base64!"), EncodingBase64); err == nil {
+		t.Errorf("expected base64 error")
+	}
+	if _, err := Decode(nil, "latin1"); err == nil {
+		t.Errorf("expected error for unknown encoding")
+	}
+}
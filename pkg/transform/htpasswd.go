@@ -0,0 +1,47 @@
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HtpasswdKey is the data key written by the htpasswd transformation, as read by the
+// ingress-nginx basic-auth annotations.
+const HtpasswdKey = "auth"
+
+// Htpasswd writes an htpasswd file with a bcrypt entry for the username and password in
+// value, given as for BasicAuth, to the auth key. Each call hashes with a new salt; see
+// HtpasswdFrom to keep an existing entry.
+func Htpasswd(value string) (map[string][]byte, error) {
+	return HtpasswdFrom(value, nil)
+}
+
+// HtpasswdFrom is like Htpasswd, but keeps previous, the file written by an earlier sync,
+// if it still matches the username and password, so refreshes do not rewrite the secret.
+func HtpasswdFrom(value string, previous []byte) (map[string][]byte, error) {
+	username, password, err := parseCredentials(value)
+	if err != nil {
+		return nil, err
+	}
+	if username == "" || strings.ContainsAny(username, ":\n") {
+		return nil, fmt.Errorf("invalid htpasswd username %q", username)
+	}
+
+	if user, hash, ok := strings.Cut(string(bytes.TrimSpace(previous)), ":"); ok && user == username {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return map[string][]byte{HtpasswdKey: previous}, nil
+		}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return nil, errors.New("htpasswd passwords are limited to 72 bytes by bcrypt")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("hashing password: %w", err)
+	}
+	return map[string][]byte{HtpasswdKey: []byte(username + ":" + string(hash) + "\n")}, nil
+}
@@ -0,0 +1,34 @@
+package transform
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHtpasswd(t *testing.T) {
+	data, err := Htpasswd("admin\nhunter2\n")
+	if err != nil {
+		t.Fatalf("Htpasswd: %v", err)
+	}
+	user, hash, _ := strings.Cut(strings.TrimSpace(string(data[HtpasswdKey])), ":")
+	if user != "admin" || bcrypt.CompareHashAndPassword([]byte(hash), []byte("hunter2")) != nil {
+		t.Errorf("auth = %q, want a bcrypt entry for admin", data[HtpasswdKey])
+	}
+
+	// An entry that still matches is kept rather than rehashed with a new salt
+	kept, err := HtpasswdFrom(`{"username": "admin", "password": "hunter2"}`, data[HtpasswdKey])
+	if err != nil || !bytes.Equal(kept[HtpasswdKey], data[HtpasswdKey]) {
+		t.Errorf("expected the previous entry to be kept, got %q, %v", kept[HtpasswdKey], err)
+	}
+	changed, err := HtpasswdFrom("admin\nnew-password", data[HtpasswdKey])
+	if err != nil || bytes.Equal(changed[HtpasswdKey], data[HtpasswdKey]) {
+		t.Errorf("expected a new entry after the password changed, got %q, %v", changed[HtpasswdKey], err)
+	}
+
+	if _, err := Htpasswd("ad:min\nhunter2"); err == nil {
+		t.Errorf("expected error for a username containing a colon")
+	}
+}
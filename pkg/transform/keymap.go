@@ -0,0 +1,113 @@
+// Package transform converts values resolved from secret providers into
+// Kubernetes Secret data.
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KeyMapEntry maps a field of a JSON provider value to a Secret data key.
+type KeyMapEntry struct {
+	Key  string // Secret data key to write
+	Path string // Field path within the JSON value, e.g. ".credentials.user"
+}
+
+// KeyMap maps fields of a JSON provider value to Secret data keys.
+type KeyMap []KeyMapEntry
+
+// ParseKeyMap parses a key mapping of the form "username=.user,password=.pass".
+// Paths are dot-separated field names; numeric segments index into arrays, and
+// "." alone selects the whole document.
+func ParseKeyMap(spec string) (KeyMap, error) {
+	var keyMap KeyMap
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, path, ok := strings.Cut(pair, "=")
+		key, path = strings.TrimSpace(key), strings.TrimSpace(path)
+		if !ok || key == "" || !strings.HasPrefix(path, ".") {
+			return nil, fmt.Errorf("invalid key mapping %q (expected key=.path)", pair)
+		}
+		if err := validateKey(key); err != nil {
+			return nil, err
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key %q in key mapping", key)
+		}
+		seen[key] = true
+		keyMap = append(keyMap, KeyMapEntry{Key: key, Path: path})
+	}
+	if len(keyMap) == 0 {
+		return nil, fmt.Errorf("empty key mapping")
+	}
+	return keyMap, nil
+}
+
+// Keys returns the Secret data keys written by the mapping.
+func (m KeyMap) Keys() []string {
+	keys := make([]string, len(m))
+	for i, entry := range m {
+		keys[i] = entry.Key
+	}
+	return keys
+}
+
+// Apply extracts each mapped field from the JSON document value. String fields are
+// written as-is; other values are written as their JSON encoding.
+func (m KeyMap) Apply(value string) (map[string][]byte, error) {
+	var doc any
+	if err := json.Unmarshal([]byte(value), &doc); err != nil {
+		return nil, fmt.Errorf("provider value is not valid JSON: %w", err)
+	}
+
+	data := make(map[string][]byte, len(m))
+	for _, entry := range m {
+		field, err := lookup(doc, entry.Path)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.Key, err)
+		}
+		if s, ok := field.(string); ok {
+			data[entry.Key] = []byte(s)
+			continue
+		}
+		encoded, err := json.Marshal(field)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.Key, err)
+		}
+		data[entry.Key] = encoded
+	}
+	return data, nil
+}
+
+// lookup resolves a dot-separated path within a decoded JSON document.
+func lookup(doc any, path string) (any, error) {
+	current := doc
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if segment == "" {
+			continue
+		}
+		switch node := current.(type) {
+		case map[string]any:
+			next, ok := node[segment]
+			if !ok {
+				return nil, fmt.Errorf("field %q not found at path %q", segment, path)
+			}
+			current = next
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("invalid array index %q at path %q", segment, path)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("cannot descend into %q at path %q", segment, path)
+		}
+	}
+	return current, nil
+}
@@ -0,0 +1,69 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyMap(t *testing.T) {
+	keyMap, err := ParseKeyMap("username=.user, password=.pass")
+	if err != nil {
+		t.Fatalf("ParseKeyMap: %v", err)
+	}
+	want := KeyMap{{Key: "username", Path: ".user"}, {Key: "password", Path: ".pass"}}
+	if !reflect.DeepEqual(keyMap, want) {
+		t.Errorf("ParseKeyMap = %+v, want %+v", keyMap, want)
+	}
+	if got := keyMap.Keys(); !reflect.DeepEqual(got, []string{"username", "password"}) {
+		t.Errorf("Keys = %v", got)
+	}
+
+	for _, bad := range []string{"", "username", "username=user", "=.user", "a=.x,a=.y", "a b=.x", "../x=.x"} {
+		if _, err := ParseKeyMap(bad); err == nil {
+			t.Errorf("ParseKeyMap(%q) expected error", bad)
+		}
+	}
+}
+
+func TestKeyMapApply(t *testing.T) {
+	keyMap, err := ParseKeyMap("username=.user,password=.creds.pass,port=.port,host=.hosts.1,all=.")
+	if err != nil {
+		t.Fatalf("ParseKeyMap: %v", err)
+	}
+
+	value := `{"user":"admin","creds":{"pass":"hunter2"},"port":5432,"hosts":["a","b"]}`
+	data, err := keyMap.Apply(value)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	want := map[string]string{
+		"username": "admin",
+		"password": "hunter2",
+		"port":     "5432",
+		"host":     "b",
+	}
+	for key, v := range want {
+		if got := string(data[key]); got != v {
+			t.Errorf("%s = %q, want %q", key, got, v)
+		}
+	}
+	if len(data["all"]) == 0 {
+		t.Errorf("expected whole document for path \".\"")
+	}
+}
+
+func TestKeyMapApplyErrors(t *testing.T) {
+	keyMap, _ := ParseKeyMap("username=.user")
+	if _, err := keyMap.Apply("not json"); err == nil {
+		t.Errorf("expected error for invalid JSON")
+	}
+	if _, err := keyMap.Apply(`{"name":"x"}`); err == nil {
+		t.Errorf("expected error for missing field")
+	}
+
+	keyMap, _ = ParseKeyMap("host=.hosts.5")
+	if _, err := keyMap.Apply(`{"hosts":["a"]}`); err == nil {
+		t.Errorf("expected error for out-of-range index")
+	}
+}
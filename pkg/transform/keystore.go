@@ -0,0 +1,157 @@
+package transform
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"golang.org/x/crypto/hkdf"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Data keys written by the keystore transformations.
+const (
+	PKCS12Key = "keystore.p12"
+	JKSKey    = "keystore.jks"
+)
+
+// keystoreAlias is the alias of the private key entry in generated keystores.
+const keystoreAlias = "tls"
+
+// keystores maps the transform names that build keystores to their implementations.
+// They need a store password, so they are applied with Keystore rather than Apply.
+var keystores = map[string]func(value, password string) (map[string][]byte, error){
+	"pkcs12": PKCS12,
+	"jks":    JKS,
+}
+
+// IsKeystore reports whether the named transformation builds a keystore, and so needs
+// a store password.
+func IsKeystore(name string) bool {
+	_, ok := keystores[name]
+	return ok
+}
+
+// Keystore runs the named keystore transformation on value, protecting the keystore
+// with password.
+func Keystore(name, value, password string) (map[string][]byte, error) {
+	fn, ok := keystores[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown keystore transform %q", name)
+	}
+	if password == "" {
+		return nil, errors.New("keystore password is empty")
+	}
+	return fn(value, password)
+}
+
+// PKCS12 assembles a PKCS#12 keystore, written to keystore.p12, from a PEM bundle of a
+// private key, its certificate chain, and CA certificates, as accepted by PEMBundle.
+// The same bundle and password always produce the same keystore, so refreshes do not
+// rewrite the secret.
+func PKCS12(value, password string) (map[string][]byte, error) {
+	b, err := parseKeystoreBundle(value)
+	if err != nil {
+		return nil, err
+	}
+	store, err := pkcs12.Modern.WithRand(b.rand(password)).Encode(b.key, b.chain[0], append(b.chain[1:], b.ca...), password)
+	if err != nil {
+		return nil, fmt.Errorf("encoding PKCS#12 keystore: %w", err)
+	}
+	return map[string][]byte{PKCS12Key: store}, nil
+}
+
+// JKS assembles a Java keystore, written to keystore.jks, from a PEM bundle of a
+// private key, its certificate chain, and CA certificates, as accepted by PEMBundle.
+// The key is stored under the alias "tls" with the store password, and CA certificates
+// as trusted entries "ca-0", "ca-1", and so on. The same bundle and password always
+// produce the same keystore, so refreshes do not rewrite the secret.
+func JKS(value, password string) (map[string][]byte, error) {
+	b, err := parseKeystoreBundle(value)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(b.key)
+	if err != nil {
+		return nil, fmt.Errorf("encoding private key: %w", err)
+	}
+
+	// Entries are dated by the certificate rather than the time of the sync
+	created := b.chain[0].NotBefore
+	ks := keystore.New(keystore.WithOrderedAliases(), keystore.WithCustomRandomNumberGenerator(b.rand(password)))
+	entry := keystore.PrivateKeyEntry{CreationTime: created, PrivateKey: key}
+	for _, cert := range b.chain {
+		entry.CertificateChain = append(entry.CertificateChain, keystore.Certificate{Type: "X509", Content: cert.Raw})
+	}
+	if err := ks.SetPrivateKeyEntry(keystoreAlias, entry, []byte(password)); err != nil {
+		return nil, fmt.Errorf("adding private key to JKS keystore: %w", err)
+	}
+	for i, cert := range b.ca {
+		trusted := keystore.TrustedCertificateEntry{CreationTime: created, Certificate: keystore.Certificate{Type: "X509", Content: cert.Raw}}
+		if err := ks.SetTrustedCertificateEntry(fmt.Sprintf("ca-%d", i), trusted); err != nil {
+			return nil, fmt.Errorf("adding CA certificate to JKS keystore: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ks.Store(&buf, []byte(password)); err != nil {
+		return nil, fmt.Errorf("encoding JKS keystore: %w", err)
+	}
+	return map[string][]byte{JKSKey: buf.Bytes()}, nil
+}
+
+// keystoreBundle is a PEM bundle parsed for building a keystore.
+type keystoreBundle struct {
+	value string
+	key   any
+	chain []*x509.Certificate // leaf first
+	ca    []*x509.Certificate
+}
+
+// parseKeystoreBundle splits and parses a PEM bundle with PEMBundle.
+func parseKeystoreBundle(value string) (*keystoreBundle, error) {
+	data, err := PEMBundle(value)
+	if err != nil {
+		return nil, err
+	}
+	b := &keystoreBundle{value: value}
+	block, _ := pem.Decode(data[TLSKeyKey])
+	if b.key, err = parsePrivateKey(block); err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	if b.chain, err = parseCertificates(data[TLSCertKey]); err != nil {
+		return nil, err
+	}
+	if b.ca, err = parseCertificates(data[CACertKey]); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// rand returns the source of the salts and IVs used to encrypt the keystore, derived
+// from the bundle and password so that the keystore is reproducible.
+func (b *keystoreBundle) rand(password string) io.Reader {
+	return hkdf.New(sha256.New, []byte(b.value), []byte(password), []byte("k8s-secret-sync keystore"))
+}
+
+// parseCertificates parses the certificates in PEM encoded data.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+}
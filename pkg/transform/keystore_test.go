@@ -0,0 +1,69 @@
+package transform
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func testBundle(t *testing.T) string {
+	t.Helper()
+	rootKey, _ := testKey(t)
+	root, rootPEM := testCert(t, "root", true, rootKey, nil, nil)
+	leafKey, leafKeyPEM := testKey(t)
+	_, leafPEM := testCert(t, "leaf", false, leafKey, root, rootKey)
+	return leafKeyPEM + leafPEM + rootPEM
+}
+
+func TestPKCS12(t *testing.T) {
+	bundle := testBundle(t)
+	data, err := Keystore("pkcs12", bundle, "changeit")
+	if err != nil {
+		t.Fatalf("Keystore: %v", err)
+	}
+	_, leaf, ca, err := pkcs12.DecodeChain(data[PKCS12Key], "changeit")
+	if err != nil {
+		t.Fatalf("decoding keystore: %v", err)
+	}
+	if leaf.Subject.CommonName != "leaf" || len(ca) != 1 || ca[0].Subject.CommonName != "root" {
+		t.Errorf("keystore holds %q with CAs %v, want leaf with root", leaf.Subject.CommonName, ca)
+	}
+
+	// Refreshes must not rewrite an unchanged keystore
+	again, err := Keystore("pkcs12", bundle, "changeit")
+	if err != nil || !bytes.Equal(again[PKCS12Key], data[PKCS12Key]) {
+		t.Errorf("expected the same keystore for the same bundle and password")
+	}
+}
+
+func TestJKS(t *testing.T) {
+	data, err := Keystore("jks", testBundle(t), "changeit")
+	if err != nil {
+		t.Fatalf("Keystore: %v", err)
+	}
+	ks := keystore.New()
+	if err := ks.Load(bytes.NewReader(data[JKSKey]), []byte("changeit")); err != nil {
+		t.Fatalf("loading keystore: %v", err)
+	}
+	entry, err := ks.GetPrivateKeyEntry("tls", []byte("changeit"))
+	if err != nil || len(entry.CertificateChain) != 1 {
+		t.Errorf("private key entry = %d certificates, %v; want the leaf", len(entry.CertificateChain), err)
+	}
+	if !ks.IsTrustedCertificateEntry("ca-0") {
+		t.Errorf("expected the root as a trusted entry, got aliases %v", ks.Aliases())
+	}
+}
+
+func TestKeystoreErrors(t *testing.T) {
+	if _, err := Keystore("jks", testBundle(t), ""); err == nil {
+		t.Errorf("expected error for an empty password")
+	}
+	if _, err := Apply("pkcs12", testBundle(t)); err == nil {
+		t.Errorf("expected Apply to require a password for keystore transforms")
+	}
+	if _, err := Keystore("pkcs12", "not a bundle", "changeit"); err == nil {
+		t.Errorf("expected error for an invalid bundle")
+	}
+}
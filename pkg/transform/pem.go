@@ -0,0 +1,123 @@
+package transform
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// Data keys written by the pem-bundle transformation, matching kubernetes.io/tls secrets.
+const (
+	TLSCertKey = "tls.crt"
+	TLSKeyKey  = "tls.key"
+	CACertKey  = "ca.crt"
+)
+
+// PEMBundle splits a combined PEM bundle into tls.crt, tls.key, and ca.crt. The
+// bundle must contain exactly one private key and a certificate whose public key
+// matches it; that certificate and any intermediates are written to tls.crt, and
+// self-signed CA certificates are written to ca.crt (omitted if there are none).
+// Every block is parsed, so a malformed part fails the whole transformation.
+func PEMBundle(value string) (map[string][]byte, error) {
+	var keyBlock *pem.Block
+	var key crypto.PrivateKey
+	var certs []*x509.Certificate
+	var certBlocks []*pem.Block
+
+	rest := []byte(value)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parsing certificate %d: %w", len(certs)+1, err)
+			}
+			certs = append(certs, cert)
+			certBlocks = append(certBlocks, block)
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if keyBlock != nil {
+				return nil, errors.New("bundle contains more than one private key")
+			}
+			parsed, err := parsePrivateKey(block)
+			if err != nil {
+				return nil, fmt.Errorf("parsing private key: %w", err)
+			}
+			keyBlock, key = block, parsed
+		default:
+			return nil, fmt.Errorf("unexpected PEM block %q in bundle", block.Type)
+		}
+	}
+
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return nil, errors.New("bundle contains data that is not PEM encoded")
+	}
+	if keyBlock == nil {
+		return nil, errors.New("bundle does not contain a private key")
+	}
+
+	// The leaf is the certificate whose public key matches the private key
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	leaf := -1
+	for i, cert := range certs {
+		if pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && pub.Equal(signer.Public()) {
+			leaf = i
+			break
+		}
+	}
+	if leaf < 0 {
+		return nil, errors.New("bundle does not contain a certificate matching the private key")
+	}
+
+	var chain, ca bytes.Buffer
+	chain.Write(pem.EncodeToMemory(certBlocks[leaf]))
+	for i, cert := range certs {
+		if i == leaf {
+			continue
+		}
+		if isSelfSignedCA(cert) {
+			ca.Write(pem.EncodeToMemory(certBlocks[i]))
+		} else {
+			chain.Write(pem.EncodeToMemory(certBlocks[i]))
+		}
+	}
+
+	data := map[string][]byte{
+		TLSCertKey: chain.Bytes(),
+		TLSKeyKey:  pem.EncodeToMemory(keyBlock),
+	}
+	if ca.Len() > 0 {
+		data[CACertKey] = ca.Bytes()
+	}
+	return data, nil
+}
+
+// parsePrivateKey parses PKCS#8, PKCS#1, and SEC 1 private keys.
+func parsePrivateKey(block *pem.Block) (crypto.PrivateKey, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
+
+// isSelfSignedCA reports whether cert is a CA certificate that signed itself.
+func isSelfSignedCA(cert *x509.Certificate) bool {
+	if !cert.IsCA || !bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+		return false
+	}
+	return cert.CheckSignatureFrom(cert) == nil
+}
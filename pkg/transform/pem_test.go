@@ -0,0 +1,110 @@
+package transform
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert issues a certificate for key, signed by parent/parentKey (self-signed if nil).
+func testCert(t *testing.T, name string, isCA bool, key *ecdsa.PrivateKey, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, string) {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+	return cert, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func testKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func TestPEMBundle(t *testing.T) {
+	rootKey, _ := testKey(t)
+	root, rootPEM := testCert(t, "root", true, rootKey, nil, nil)
+	interKey, _ := testKey(t)
+	inter, interPEM := testCert(t, "intermediate", true, interKey, root, rootKey)
+	leafKey, leafKeyPEM := testKey(t)
+	_, leafPEM := testCert(t, "leaf", false, leafKey, inter, interKey)
+
+	// Order in the bundle shouldn't matter
+	data, err := PEMBundle(rootPEM + leafKeyPEM + interPEM + leafPEM)
+	if err != nil {
+		t.Fatalf("PEMBundle: %v", err)
+	}
+
+	if got := string(data[TLSCertKey]); got != leafPEM+interPEM {
+		t.Errorf("tls.crt should contain the leaf followed by the intermediate, got:\n%s", got)
+	}
+	if got := string(data[TLSKeyKey]); got != leafKeyPEM {
+		t.Errorf("tls.key = %q, want leaf key", got)
+	}
+	if got := string(data[CACertKey]); got != rootPEM {
+		t.Errorf("ca.crt = %q, want root certificate", got)
+	}
+}
+
+func TestPEMBundleWithoutCA(t *testing.T) {
+	key, keyPEM := testKey(t)
+	_, certPEM := testCert(t, "leaf", false, key, nil, nil)
+
+	data, err := PEMBundle(certPEM + keyPEM)
+	if err != nil {
+		t.Fatalf("PEMBundle: %v", err)
+	}
+	if _, ok := data[CACertKey]; ok {
+		t.Errorf("expected no ca.crt for a bundle without CA certificates")
+	}
+}
+
+func TestPEMBundleErrors(t *testing.T) {
+	key, keyPEM := testKey(t)
+	_, certPEM := testCert(t, "leaf", false, key, nil, nil)
+	_, otherKeyPEM := testKey(t)
+
+	cases := map[string]string{
+		"no key":          certPEM,
+		"two keys":        certPEM + keyPEM + otherKeyPEM,
+		"mismatched key":  certPEM + otherKeyPEM,
+		"garbage":         certPEM + keyPEM + "not pem",
+		"bad certificate": strings.Replace(certPEM, "\n", "\nAAAA", 2) + keyPEM,
+	}
+	for name, value := range cases {
+		if _, err := PEMBundle(value); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
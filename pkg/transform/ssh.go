@@ -0,0 +1,97 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Data keys written by the SSH transformations. SSHPrivateKeyKey is the key required by
+// kubernetes.io/ssh-auth secrets.
+const (
+	KnownHostsKey     = "known_hosts"
+	AuthorizedKeysKey = "authorized_keys"
+	SSHPrivateKeyKey  = "ssh-privatekey"
+)
+
+// KnownHosts composes a known_hosts file from a value of host key lines, such as the
+// values of several refs joined by newlines. Blank lines and "#" comments are dropped,
+// duplicate lines are written once, and every line must parse as a known_hosts entry.
+func KnownHosts(value string) (map[string][]byte, error) {
+	file, err := sshLines(value, func(line string) error {
+		_, _, _, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{KnownHostsKey: file}, nil
+}
+
+// AuthorizedKeys composes an authorized_keys file from a value of public key lines, in
+// the same way as KnownHosts.
+func AuthorizedKeys(value string) (map[string][]byte, error) {
+	file, err := sshLines(value, func(line string) error {
+		_, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{AuthorizedKeysKey: file}, nil
+}
+
+// SSHAuth writes the PEM encoded private key in value to ssh-privatekey, as expected by
+// kubernetes.io/ssh-auth secrets. Any lines outside the key are host keys, composed into
+// known_hosts as by KnownHosts. Keys protected by a passphrase are accepted as-is.
+func SSHAuth(value string) (map[string][]byte, error) {
+	start := strings.Index(value, "-----BEGIN ")
+	if start < 0 {
+		return nil, errors.New("value does not contain a PEM encoded private key")
+	}
+	block, rest := pem.Decode([]byte(value[start:]))
+	if block == nil {
+		return nil, errors.New("value does not contain a PEM encoded private key")
+	}
+	key := pem.EncodeToMemory(block)
+	var missing *ssh.PassphraseMissingError
+	if _, err := ssh.ParseRawPrivateKey(key); err != nil && !errors.As(err, &missing) {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	data := map[string][]byte{SSHPrivateKeyKey: key}
+	if hosts := value[:start] + "\n" + string(rest); strings.TrimSpace(hosts) != "" {
+		known, err := KnownHosts(hosts)
+		if err != nil {
+			return nil, err
+		}
+		data[KnownHostsKey] = known[KnownHostsKey]
+	}
+	return data, nil
+}
+
+// sshLines validates each non-blank, non-comment line of value with parse, returning
+// the lines without duplicates as a file.
+func sshLines(value string, parse func(line string) error) ([]byte, error) {
+	var file bytes.Buffer
+	seen := make(map[string]bool)
+	for i, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		seen[line] = true
+		file.WriteString(line + "\n")
+	}
+	if file.Len() == 0 {
+		return nil, errors.New("value contains no keys")
+	}
+	return file.Bytes(), nil
+}
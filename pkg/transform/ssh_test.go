@@ -0,0 +1,79 @@
+package transform
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testSSHKey(t *testing.T) (string, string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+	public, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("marshaling public key: %v", err)
+	}
+	return string(pem.EncodeToMemory(block)), strings.TrimSpace(string(ssh.MarshalAuthorizedKey(public)))
+}
+
+func TestKnownHosts(t *testing.T) {
+	_, a := testSSHKey(t)
+	_, b := testSSHKey(t)
+	value := "# GitHub\ngithub.com " + a + "\n\ngitlab.com " + b + "\ngithub.com " + a + "\n"
+
+	data, err := KnownHosts(value)
+	if err != nil {
+		t.Fatalf("KnownHosts: %v", err)
+	}
+	if want := "github.com " + a + "\ngitlab.com " + b + "\n"; string(data[KnownHostsKey]) != want {
+		t.Errorf("known_hosts = %q, want %q", data[KnownHostsKey], want)
+	}
+	if _, err := KnownHosts("github.com not-a-key"); err == nil {
+		t.Errorf("expected error for an invalid host key")
+	}
+}
+
+func TestAuthorizedKeys(t *testing.T) {
+	_, a := testSSHKey(t)
+	data, err := AuthorizedKeys(a + " ci@example.com\n")
+	if err != nil || string(data[AuthorizedKeysKey]) != a+" ci@example.com\n" {
+		t.Errorf("authorized_keys = %q, %v", data[AuthorizedKeysKey], err)
+	}
+	if _, err := AuthorizedKeys("\n# only comments\n"); err == nil {
+		t.Errorf("expected error for a value without keys")
+	}
+}
+
+func TestSSHAuth(t *testing.T) {
+	key, _ := testSSHKey(t)
+	_, host := testSSHKey(t)
+
+	data, err := SSHAuth(key + "github.com " + host + "\n")
+	if err != nil {
+		t.Fatalf("SSHAuth: %v", err)
+	}
+	if string(data[SSHPrivateKeyKey]) != key {
+		t.Errorf("ssh-privatekey = %q, want the private key", data[SSHPrivateKeyKey])
+	}
+	if string(data[KnownHostsKey]) != "github.com "+host+"\n" {
+		t.Errorf("known_hosts = %q, want the host key", data[KnownHostsKey])
+	}
+
+	if data, err := SSHAuth(key); err != nil || data[KnownHostsKey] != nil {
+		t.Errorf("key alone = %v, %v; want only ssh-privatekey", data, err)
+	}
+	if _, err := SSHAuth("github.com " + host); err == nil {
+		t.Errorf("expected error without a private key")
+	}
+}
@@ -0,0 +1,68 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"text/template"
+)
+
+// TemplateData is the data available to value templates.
+type TemplateData struct {
+	// Value is the raw value resolved from the provider.
+	Value string
+	// Fields holds the value decoded as a JSON object, or nil if it isn't one.
+	Fields map[string]any
+}
+
+// templateFuncs are the helper functions available to value templates.
+var templateFuncs = template.FuncMap{
+	"b64enc": func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"b64dec": func(s string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		return string(decoded), err
+	},
+	"toJson": func(v any) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+}
+
+// Resolver resolves an additional provider ref referenced from a template.
+type Resolver func(ref string) (string, error)
+
+// Template renders a Go text/template with the provider value, for composing
+// secrets such as whole config files with embedded credentials. Templates can pull
+// in further values with {{ ref "<provider ref>" }}, resolved by resolve (which may
+// be nil to disallow it). Referencing a missing field is an error rather than
+// rendering "<no value>".
+func Template(text, value string, resolve Resolver) ([]byte, error) {
+	funcs := template.FuncMap{
+		"ref": func(ref string) (string, error) {
+			if resolve == nil {
+				return "", fmt.Errorf("resolving additional refs is not supported here")
+			}
+			return resolve(ref)
+		},
+	}
+	maps.Copy(funcs, templateFuncs)
+
+	tmpl, err := template.New("value").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	data := TemplateData{Value: value}
+	var fields map[string]any
+	if json.Unmarshal([]byte(value), &fields) == nil {
+		data.Fields = fields
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("rendering template: %w", err)
+	}
+	return out.Bytes(), nil
+}
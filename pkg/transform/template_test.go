@@ -0,0 +1,60 @@
+package transform
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	got, err := Template("password={{ .Value }}", "hunter2", nil)
+	if err != nil {
+		t.Fatalf("Template: %v", err)
+	}
+	if string(got) != "password=hunter2" {
+		t.Errorf("Template = %q", got)
+	}
+
+	got, err = Template(`url=postgres://{{ .Fields.user }}:{{ .Fields.pass }}@db/app auth={{ b64enc .Fields.user }}`, `{"user":"admin","pass":"s3cr3t"}`, nil)
+	if err != nil {
+		t.Fatalf("Template with fields: %v", err)
+	}
+	if want := "url=postgres://admin:s3cr3t@db/app auth=YWRtaW4="; string(got) != want {
+		t.Errorf("Template = %q, want %q", got, want)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	if _, err := Template("{{ .Value", "x", nil); err == nil {
+		t.Errorf("expected parse error")
+	}
+	if _, err := Template("{{ .Fields.missing }}", `{"user":"admin"}`, nil); err == nil {
+		t.Errorf("expected error for missing field")
+	}
+}
+
+func TestTemplateRef(t *testing.T) {
+	refs := map[string]string{"op://vault/npm/token": "npm_abc"}
+	resolve := func(ref string) (string, error) {
+		value, ok := refs[ref]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		return value, nil
+	}
+
+	text := "//registry.npmjs.org/:_authToken={{ ref \"op://vault/npm/token\" }}\nemail={{ .Value }}\n"
+	got, err := Template(text, "dev@example.com", resolve)
+	if err != nil {
+		t.Fatalf("Template: %v", err)
+	}
+	if want := "//registry.npmjs.org/:_authToken=npm_abc\nemail=dev@example.com\n"; string(got) != want {
+		t.Errorf("Template = %q, want %q", got, want)
+	}
+
+	if _, err := Template(`{{ ref "op://vault/missing" }}`, "", resolve); err == nil {
+		t.Errorf("expected error for unresolvable ref")
+	}
+	if _, err := Template(`{{ ref "op://vault/npm/token" }}`, "", nil); err == nil {
+		t.Errorf("expected error when refs are not supported")
+	}
+}
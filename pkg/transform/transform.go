@@ -0,0 +1,67 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Func converts a provider value into Secret data.
+type Func func(value string) (map[string][]byte, error)
+
+// transforms maps the names accepted by the transform annotation to their implementations.
+var transforms = map[string]Func{
+	"dotenv":          Dotenv,
+	"pem-bundle":      PEMBundle,
+	"known-hosts":     KnownHosts,
+	"authorized-keys": AuthorizedKeys,
+	"ssh-auth":        SSHAuth,
+	"basic-auth":      BasicAuth,
+	"htpasswd":        Htpasswd,
+}
+
+// staticKeys lists the data keys written by transformations whose output keys do
+// not depend on the value.
+var staticKeys = map[string][]string{
+	"pem-bundle":      {TLSCertKey, TLSKeyKey, CACertKey},
+	"pkcs12":          {PKCS12Key},
+	"jks":             {JKSKey},
+	"known-hosts":     {KnownHostsKey},
+	"authorized-keys": {AuthorizedKeysKey},
+	"ssh-auth":        {SSHPrivateKeyKey, KnownHostsKey},
+	"basic-auth":      {UsernameKey, PasswordKey},
+	"htpasswd":        {HtpasswdKey},
+}
+
+// Apply runs the named transformation on value.
+func Apply(name, value string) (map[string][]byte, error) {
+	if IsKeystore(name) {
+		return nil, fmt.Errorf("transform %q needs a keystore password", name)
+	}
+	fn, ok := transforms[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown transform %q", name)
+	}
+	return fn(value)
+}
+
+// Known reports whether name is a transformation, including the keystore ones.
+func Known(name string) bool {
+	_, ok := transforms[name]
+	return ok || IsKeystore(name)
+}
+
+// Keys returns the data keys written by the named transformation, or nil if they
+// depend on the value.
+func Keys(name string) []string {
+	return staticKeys[name]
+}
+
+// validateKey checks that key is usable as a Secret data key.
+func validateKey(key string) error {
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		return fmt.Errorf("invalid data key %q: %s", key, strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	gosync "sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// leases holds the dynamic credentials issued for each role between the providers
+// created for each request.
+var leases = &leaseCache{}
+
+// leaseCache holds the lease last issued for each role, by Vault namespace and path.
+type leaseCache struct {
+	mu     gosync.Mutex
+	leases map[string]lease
+}
+
+// lease is a dynamic credential issued by Vault, valid until expiry unless renewed.
+type lease struct {
+	id        string
+	data      map[string]any
+	renewable bool
+	duration  time.Duration // length of the lease as first issued
+	renewed   time.Time     // when the lease was issued or last renewed
+	expiry    time.Time
+}
+
+// leaseResponse is the response to issuing dynamic credentials or renewing a lease.
+type leaseResponse struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+}
+
+// credentials returns the dynamic credentials for the role at path, such as
+// "database/creds/app", with the time they expire. The lease last issued for the role
+// is returned again until four fifths of it have passed, when the controller renews
+// it, so retries do not issue more. It is then renewed, or replaced with new
+// credentials if it can't be renewed or Vault caps the renewal at less than half of
+// the original lease, as happens near its max TTL. Superseded leases are left to
+// expire, so workloads still using them keep working until then.
+func (p SecretProvider) credentials(ctx context.Context, namespace, path string) (map[string]any, time.Time, error) {
+	cache := p.leases
+	if cache == nil {
+		cache = &leaseCache{}
+	}
+	now := time.Now()
+	if p.now != nil {
+		now = p.now()
+	}
+	key := namespace + "\x00" + path
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	current, ok := cache.leases[key]
+	if ok && now.Before(current.expiry.Add(-current.expiry.Sub(current.renewed)/5)) {
+		return current.data, current.expiry, nil
+	}
+
+	if ok && current.renewable && now.Before(current.expiry) {
+		renewed, err := p.renew(ctx, namespace, current, now)
+		if err == nil && renewed.expiry.Sub(now) >= current.duration/2 {
+			cache.leases[key] = renewed
+			return renewed.data, renewed.expiry, nil
+		}
+		if err != nil {
+			klog.InfoS("Failed to renew Vault lease, issuing new credentials", "path", path, "err", err)
+		}
+	}
+
+	var resp leaseResponse
+	if err := p.do(ctx, http.MethodGet, namespace, path, nil, nil, &resp); err != nil {
+		return nil, time.Time{}, err
+	}
+	if resp.LeaseID == "" || resp.LeaseDuration <= 0 {
+		return nil, time.Time{}, fmt.Errorf("%s returned credentials without a lease", path)
+	}
+	duration := time.Duration(resp.LeaseDuration) * time.Second
+	issued := lease{
+		id:        resp.LeaseID,
+		data:      resp.Data,
+		renewable: resp.Renewable,
+		duration:  duration,
+		renewed:   now,
+		expiry:    now.Add(duration),
+	}
+	klog.InfoS("Issued Vault dynamic credentials", "path", path, "expiry", issued.expiry)
+	if cache.leases == nil {
+		cache.leases = make(map[string]lease)
+	}
+	cache.leases[key] = issued
+	return issued.data, issued.expiry, nil
+}
+
+// renew extends a lease by its original duration, returning it with the new expiry
+// Vault granted.
+func (p SecretProvider) renew(ctx context.Context, namespace string, l lease, now time.Time) (lease, error) {
+	body := map[string]any{"lease_id": l.id, "increment": int(l.duration.Seconds())}
+	var resp leaseResponse
+	if err := p.do(ctx, http.MethodPut, namespace, "sys/leases/renew", nil, body, &resp); err != nil {
+		return lease{}, err
+	}
+	if resp.LeaseDuration <= 0 {
+		return lease{}, errors.New("lease was not renewed")
+	}
+	l.renewable = resp.Renewable
+	l.renewed = now
+	l.expiry = now.Add(time.Duration(resp.LeaseDuration) * time.Second)
+	return l, nil
+}
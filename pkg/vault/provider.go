@@ -0,0 +1,250 @@
+// Package vault implements a secret provider that reads secrets from a HashiCorp Vault
+// KV version 2 secrets engine, or issues dynamic credentials from engines such as the
+// database and AWS engines, authenticating with a Vault token.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/config"
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func init() {
+	provider.Register(provider.Info{
+		Name:            "vault",
+		RequiredConfig:  []string{"KSS_VAULT_ADDR", "KSS_VAULT_TOKEN"},
+		Capabilities:    provider.Capabilities{Versioning: true, Expiry: true},
+		NamespaceScoped: true,
+		New: func(_ context.Context, cfg *config.Sync) (provider.SecretProvider, error) {
+			namespaces, err := parseNamespaces(cfg.VaultNamespaces)
+			if err != nil {
+				return nil, fmt.Errorf("KSS_VAULT_NAMESPACES: %w", err)
+			}
+			return SecretProvider{
+				Addr:       cfg.VaultAddr,
+				Token:      cfg.VaultToken,
+				Namespace:  cfg.VaultNamespace,
+				HTTP:       &http.Client{Timeout: 30 * time.Second},
+				namespaces: namespaces,
+				leases:     leases,
+			}, nil
+		},
+	})
+}
+
+// SecretProvider resolves refs of the form "mount/data/path#key" to a key of a KV v2
+// secret, or "mount/data/path" to all of its keys as a JSON object for key mappings and
+// templates. Values that are not strings are returned as JSON. A pinned version reads
+// that version of the secret. The response version is the secret version read.
+//
+// Refs of the form "mount/creds/role#key", or "mount/creds/role" for all keys, issue
+// dynamic credentials for a role, e.g. "database/creds/app#password". They expire with
+// their lease, which is renewed while Vault allows and the credentials re-issued after.
+// All secrets using a role in a Vault namespace share its credentials.
+//
+// On Vault Enterprise, secrets are read from the Vault namespace given by the
+// "namespace" provider metadata, e.g. "namespace=team-a/apps", or else the first mapped
+// to the secret's Kubernetes namespace, or else the default namespace. A secret may only
+// select a Vault namespace mapped to its own Kubernetes namespace, or one nested within
+// it. The token must be valid in the namespace read or one of its parents.
+type SecretProvider struct {
+	Addr      string // address of the Vault server, e.g. "https://vault.example.com:8200"
+	Token     string // Vault token requests are authenticated with
+	Namespace string // default Vault Enterprise namespace (empty for the root namespace)
+	HTTP      *http.Client
+
+	namespaces []namespaceMapping
+	leases     *leaseCache
+	now        func() time.Time
+}
+
+// namespaceMapping is a Vault namespace secrets in Kubernetes namespaces matching a
+// glob may be read from.
+type namespaceMapping struct {
+	pattern   string
+	namespace string
+}
+
+// kvResponse is the response to reading a KV v2 secret.
+type kvResponse struct {
+	Data struct {
+		Data     map[string]any `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+}
+
+func (p SecretProvider) Resolve(ctx context.Context, req provider.Request) (provider.Response, error) {
+	path, key, hasKey := strings.Cut(req.Ref, "#")
+	path = strings.Trim(path, "/")
+	mount, rest, _ := strings.Cut(path, "/")
+	dynamic := strings.HasPrefix(rest, "creds/") && rest != "creds/"
+	if mount == "" || !(dynamic || (strings.HasPrefix(rest, "data/") && rest != "data/")) || (hasKey && key == "") {
+		return provider.Response{}, fmt.Errorf("invalid vault ref %q, expected mount/data/path#key or mount/creds/role#key", req.Ref)
+	}
+	query := url.Values{}
+	if req.Version != "" {
+		if dynamic {
+			return provider.Response{}, fmt.Errorf("vault dynamic credentials %s have no versions to pin", path)
+		}
+		if _, err := strconv.Atoi(req.Version); err != nil {
+			return provider.Response{}, fmt.Errorf("invalid vault version %q, expected a version number", req.Version)
+		}
+		query.Set("version", req.Version)
+	}
+
+	namespace, err := p.namespace(req)
+	if err != nil {
+		return provider.Response{}, err
+	}
+
+	if dynamic {
+		data, expiry, err := p.credentials(ctx, namespace, path)
+		if err != nil {
+			return provider.Response{}, err
+		}
+		value, err := field(data, path, key, hasKey)
+		return provider.Response{Value: value, Expiry: expiry}, err
+	}
+
+	var resp kvResponse
+	if err := p.do(ctx, http.MethodGet, namespace, path, query, nil, &resp); err != nil {
+		return provider.Response{}, err
+	}
+	version := strconv.Itoa(resp.Data.Metadata.Version)
+	if resp.Data.Data == nil {
+		// Deleted versions are returned with their metadata but no data
+		return provider.Response{}, fmt.Errorf("%w: version %s of %s is deleted", provider.ErrNotFound, version, path)
+	}
+	value, err := field(resp.Data.Data, path, key, hasKey)
+	return provider.Response{Value: value, Version: version}, err
+}
+
+// field returns the value of key in the data read from path, or all of the data as a
+// JSON object if there is no key. Values that are not strings are returned as JSON.
+func field(data map[string]any, path, key string, hasKey bool) ([]byte, error) {
+	if !hasKey {
+		return json.Marshal(data)
+	}
+	value, ok := data[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: secret %s has no key %q", provider.ErrNotFound, path, key)
+	}
+	if s, ok := value.(string); ok {
+		return []byte(s), nil
+	}
+	return json.Marshal(value)
+}
+
+// HealthCheck looks up the operator's token, which fails if Vault is unreachable or
+// the token has expired or been revoked.
+func (p SecretProvider) HealthCheck(ctx context.Context) error {
+	return p.do(ctx, http.MethodGet, p.Namespace, "auth/token/lookup-self", nil, nil, nil)
+}
+
+// namespace returns the Vault namespace to read a request's secret from: the one it
+// selects, which must be within one mapped to its Kubernetes namespace, or else the
+// first mapped, or else the default.
+func (p SecretProvider) namespace(req provider.Request) (string, error) {
+	var mapped []string
+	for _, m := range p.namespaces {
+		if matched, _ := path.Match(m.pattern, req.Namespace); matched {
+			mapped = append(mapped, m.namespace)
+		}
+	}
+	selected, ok := req.Metadata["namespace"]
+	if !ok {
+		if len(mapped) > 0 {
+			return mapped[0], nil
+		}
+		return p.Namespace, nil
+	}
+
+	selected = strings.Trim(selected, "/")
+	for _, namespace := range mapped {
+		if selected == namespace || strings.HasPrefix(selected, namespace+"/") {
+			return selected, nil
+		}
+	}
+	return "", fmt.Errorf("%w: vault namespace %q is not mapped to namespace %s in KSS_VAULT_NAMESPACES", provider.ErrUnauthorized, selected, req.Namespace)
+}
+
+// parseNamespaces parses Vault namespaces mapped to Kubernetes namespaces as
+// "pattern=namespace" pairs, comma separated, where patterns are globs such as
+// "team-*". A Kubernetes namespace may use every Vault namespace mapped to it, and
+// those nested within them; the first is its default.
+func parseNamespaces(spec string) ([]namespaceMapping, error) {
+	var namespaces []namespaceMapping
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		pattern, namespace, ok := strings.Cut(pair, "=")
+		pattern, namespace = strings.TrimSpace(pattern), strings.Trim(strings.TrimSpace(namespace), "/")
+		if !ok || pattern == "" || namespace == "" {
+			return nil, fmt.Errorf("invalid vault namespace mapping %q, expected namespace=vault-namespace", pair)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+		}
+		namespaces = append(namespaces, namespaceMapping{pattern: pattern, namespace: namespace})
+	}
+	return namespaces, nil
+}
+
+// do calls an API path in a namespace, empty for the root namespace, with body encoded
+// as JSON if not nil, and decodes the JSON response into out, if not nil.
+func (p SecretProvider) do(ctx context.Context, method, namespace, path string, query url.Values, body, out any) error {
+	endpoint := strings.TrimSuffix(p.Addr, "/") + "/v1/" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	if namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", provider.ErrTransient, err)
+	}
+	defer resp.Body.Close()
+
+	if err := provider.CheckResponse(resp); err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", provider.ErrNotFound, path)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("reading %s: unexpected status %s", path, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,202 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackweinbender/k8s-secret-sync/pkg/provider"
+)
+
+func TestResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.RequestURI() {
+		case "/v1/kv/data/app/db":
+			w.Write([]byte(`{"data":{"data":{"password":"hunter2","port":5432},"metadata":{"version":3}}}`))
+		case "/v1/kv/data/app/db?version=2":
+			w.Write([]byte(`{"data":{"data":null,"metadata":{"version":2,"deletion_time":"2030-01-01T00:00:00Z"}}}`))
+		case "/v1/auth/token/lookup-self":
+			w.Write([]byte(`{"data":{}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	p := SecretProvider{Addr: server.URL + "/", Token: "s.token", HTTP: server.Client()}
+	ctx := context.Background()
+
+	resp, err := p.Resolve(ctx, provider.Request{Ref: "kv/data/app/db#password"})
+	if err != nil || string(resp.Value) != "hunter2" || resp.Version != "3" {
+		t.Errorf("Resolve(password) = %q at version %q, %v", resp.Value, resp.Version, err)
+	}
+	resp, err = p.Resolve(ctx, provider.Request{Ref: "kv/data/app/db#port"})
+	if err != nil || string(resp.Value) != "5432" {
+		t.Errorf("Resolve(port) = %q, %v", resp.Value, err)
+	}
+	resp, err = p.Resolve(ctx, provider.Request{Ref: "kv/data/app/db"})
+	if err != nil || string(resp.Value) != `{"password":"hunter2","port":5432}` {
+		t.Errorf("Resolve(secret) = %q, %v", resp.Value, err)
+	}
+
+	for _, tt := range []struct {
+		req  provider.Request
+		want error
+	}{
+		{provider.Request{Ref: "kv/data/app/db#user"}, provider.ErrNotFound},
+		{provider.Request{Ref: "kv/data/app/db#password", Version: "2"}, provider.ErrNotFound},
+		{provider.Request{Ref: "kv/data/app/missing#password"}, provider.ErrNotFound},
+	} {
+		if _, err := p.Resolve(ctx, tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("Resolve(%+v) = %v, want %v", tt.req, err, tt.want)
+		}
+	}
+	for _, ref := range []string{"kv/app/db#password", "kv/data/#password", "kv/data/app/db#"} {
+		if _, err := p.Resolve(ctx, provider.Request{Ref: ref}); err == nil || errors.Is(err, provider.ErrNotFound) {
+			t.Errorf("Resolve(%q) = %v, want an invalid ref error", ref, err)
+		}
+	}
+
+	if err := p.HealthCheck(ctx); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+	p.Token = "s.revoked"
+	if err := p.HealthCheck(ctx); !errors.Is(err, provider.ErrUnauthorized) {
+		t.Errorf("HealthCheck with revoked token = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestResolveNamespace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Vault-Namespace") {
+		case "team-a":
+			w.Write([]byte(`{"data":{"data":{"password":"a"},"metadata":{"version":1}}}`))
+		case "team-b/apps":
+			w.Write([]byte(`{"data":{"data":{"password":"b"},"metadata":{"version":1}}}`))
+		case "":
+			w.Write([]byte(`{"data":{"data":{"password":"root"},"metadata":{"version":1}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	namespaces, err := parseNamespaces("team-a=team-a, team-b=team-b")
+	if err != nil {
+		t.Fatalf("parseNamespaces: %v", err)
+	}
+	p := SecretProvider{Addr: server.URL, Token: "s.token", HTTP: server.Client(), namespaces: namespaces}
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		namespace string
+		metadata  map[string]string
+		want      string
+	}{
+		{"team-a", nil, "a"},
+		{"team-b", map[string]string{"namespace": "/team-b/apps/"}, "b"},
+		{"default", nil, "root"},
+	} {
+		resp, err := p.Resolve(ctx, provider.Request{Ref: "kv/data/app/db#password", Namespace: tt.namespace, Metadata: tt.metadata})
+		if err != nil || string(resp.Value) != tt.want {
+			t.Errorf("Resolve in %s with metadata %v = %q, %v, want %q", tt.namespace, tt.metadata, resp.Value, err, tt.want)
+		}
+	}
+
+	// Secrets can't select a Vault namespace outside those mapped to their own,
+	// including the root namespace
+	for _, tt := range []struct {
+		namespace string
+		metadata  map[string]string
+	}{
+		{"team-a", map[string]string{"namespace": "team-b/apps"}},
+		{"team-a", map[string]string{"namespace": "team-ab"}},
+		{"team-a", map[string]string{"namespace": ""}},
+		{"default", map[string]string{"namespace": "team-a"}},
+	} {
+		if _, err := p.Resolve(ctx, provider.Request{Ref: "kv/data/app/db#password", Namespace: tt.namespace, Metadata: tt.metadata}); !errors.Is(err, provider.ErrUnauthorized) {
+			t.Errorf("Resolve in %s with metadata %v = %v, want ErrUnauthorized", tt.namespace, tt.metadata, err)
+		}
+	}
+}
+
+func TestResolveDynamic(t *testing.T) {
+	var issued, renewals int
+	renewFor := 3600
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/database/creds/app":
+			issued++
+			fmt.Fprintf(w, `{"lease_id":"database/creds/app/%d","lease_duration":3600,"renewable":true,"data":{"username":"v-app-%d","password":"pw"}}`, issued, issued)
+		case r.Method == http.MethodPut && r.URL.Path == "/v1/sys/leases/renew":
+			var body struct {
+				LeaseID   string `json:"lease_id"`
+				Increment int    `json:"increment"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Increment != 3600 {
+				t.Errorf("renew body = %+v, %v", body, err)
+			}
+			renewals++
+			fmt.Fprintf(w, `{"lease_id":%q,"lease_duration":%d,"renewable":true}`, body.LeaseID, renewFor)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := SecretProvider{Addr: server.URL, Token: "s.token", HTTP: server.Client(), leases: &leaseCache{}, now: func() time.Time { return now }}
+	ctx := context.Background()
+	resolve := func() provider.Response {
+		t.Helper()
+		resp, err := p.Resolve(ctx, provider.Request{Ref: "database/creds/app#username"})
+		if err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+		return resp
+	}
+
+	// Credentials are issued once and returned again until they are due for renewal
+	resp := resolve()
+	if string(resp.Value) != "v-app-1" || !resp.Expiry.Equal(now.Add(time.Hour)) {
+		t.Errorf("Resolve = %q expiring %v, want v-app-1 expiring in an hour", resp.Value, resp.Expiry)
+	}
+	now = now.Add(30 * time.Minute)
+	if resp := resolve(); string(resp.Value) != "v-app-1" || issued != 1 || renewals != 0 {
+		t.Errorf("Resolve before renewal = %q, issued %d, renewed %d", resp.Value, issued, renewals)
+	}
+
+	// Once due, the lease is renewed and the same credentials returned
+	now = now.Add(20 * time.Minute)
+	if resp := resolve(); string(resp.Value) != "v-app-1" || !resp.Expiry.Equal(now.Add(time.Hour)) || renewals != 1 {
+		t.Errorf("Resolve after renewal = %q expiring %v, renewed %d", resp.Value, resp.Expiry, renewals)
+	}
+
+	// Near its max TTL, renewals are capped and new credentials issued instead
+	renewFor = 600
+	now = now.Add(50 * time.Minute)
+	if resp := resolve(); string(resp.Value) != "v-app-2" || issued != 2 {
+		t.Errorf("Resolve near max TTL = %q, issued %d; want new credentials", resp.Value, issued)
+	}
+
+	if _, err := p.Resolve(ctx, provider.Request{Ref: "database/creds/app#password", Version: "1"}); err == nil {
+		t.Errorf("expected error pinning a version of dynamic credentials")
+	}
+	if _, err := p.Resolve(ctx, provider.Request{Ref: "database/creds/app#host"}); !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("Resolve(missing key) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestParseNamespaces(t *testing.T) {
+	for _, spec := range []string{"team-a", "team-a=", "=team-a", "[=team-a"} {
+		if _, err := parseNamespaces(spec); err == nil {
+			t.Errorf("parseNamespaces(%q) succeeded, want error", spec)
+		}
+	}
+}
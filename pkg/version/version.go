@@ -0,0 +1,29 @@
+// Package version reports the build version of the operator.
+package version
+
+import "runtime/debug"
+
+// Version is the operator version. It can be set at build time with
+//
+//	-ldflags "-X github.com/jackweinbender/k8s-secret-sync/pkg/version.Version=v1.2.3"
+//
+// When unset, the VCS revision embedded by the Go toolchain is used instead.
+var Version = ""
+
+// Get returns the operator version, falling back to the VCS revision or "dev".
+func Get() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && setting.Value != "" {
+				if len(setting.Value) > 12 {
+					return setting.Value[:12]
+				}
+				return setting.Value
+			}
+		}
+	}
+	return "dev"
+}